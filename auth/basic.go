@@ -18,6 +18,7 @@ import (
 type basicAuthUser struct {
 	Password string   `mapstructure:"password"`
 	Roles    []string `mapstructure:"roles"`
+	Tenant   string   `mapstructure:"tenant"`
 }
 
 func newBasicAuthUser(m map[string]interface{}) (*basicAuthUser, error) {
@@ -53,6 +54,16 @@ func newBasicAuthUser(m map[string]interface{}) (*basicAuthUser, error) {
 		newUser.Roles = make([]string, 0)
 	}
 
+	tenantRaw, ok := m["tenant"]
+	if ok {
+		switch t := tenantRaw.(type) {
+		case string:
+			newUser.Tenant = t
+		default:
+			return nil, fmt.Errorf("invalid tenant: expected string found %T", t)
+		}
+	}
+
 	return &newUser, nil
 }
 
@@ -81,6 +92,7 @@ func (o *BasicAuthorizer) Init(v *viper.Viper, logger *zap.SugaredLogger) error
 					"user", name,
 					"password", newUser.Password,
 					"roles", newUser.Roles,
+					"tenant", newUser.Tenant,
 				)
 				o.users[name] = newUser
 			default:
@@ -144,6 +156,10 @@ func (o *BasicAuthorizer) GetGinHandler(role string) gin.HandlerFunc {
 
 		if gotRole {
 			log.Debugw("user authenticated", "user", userName, "role", role)
+
+			if userInfo.Tenant != "" {
+				c.Set(TenantIDKey, userInfo.Tenant)
+			}
 		} else {
 			c.Writer.Header().Set("WWW-Authenticate", "Basic realm=veraison")
 			ReportProblem(c, http.StatusUnauthorized,
@@ -72,6 +72,12 @@ func (o *KeycloakAuthorizer) getAuthCheck(
 
 		roleOK := ginkeycloak.RealmCheck(roles)(tc, ctx)
 
+		if claims, ok := tc.KeyCloakToken.CustomClaims.(map[string]string); ok {
+			if tenantID := claims["tenant_id"]; tenantID != "" {
+				ctx.Set(TenantIDKey, tenantID)
+			}
+		}
+
 		o.logger.Debugw("auth check", "role", roleOK)
 
 		return roleOK
@@ -5,3 +5,5 @@ package auth
 var NoRole = ""
 var ManagerRole = "manager"
 var ProvisionerRole = "provisioner"
+var AttesterRole = "attester"
+var RelyingPartyRole = "relying-party"
@@ -0,0 +1,18 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package auth
+
+import "github.com/gin-gonic/gin"
+
+// TenantIDKey is the gin.Context key under which authorizers store the ID of
+// the tenant associated with an authenticated request.
+const TenantIDKey = "tenant_id"
+
+// GetTenantID returns the ID of the tenant the authorizer has associated with
+// the request. The second return value is false if the request is not
+// associated with a tenant (e.g. when using the passthrough backend).
+func GetTenantID(c *gin.Context) (string, bool) {
+	tenantID := c.GetString(TenantIDKey)
+
+	return tenantID, tenantID != ""
+}
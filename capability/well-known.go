@@ -11,12 +11,26 @@ const (
 )
 
 type WellKnownInfo struct {
-	PublicKey    jwk.Key           `json:"ear-verification-key,omitempty"`
-	MediaTypes   []string          `json:"media-types,omitempty"`
-	Schemes      []string          `json:"attestation-schemes,omitempty"`
-	Version      string            `json:"version"`
-	ServiceState string            `json:"service-state"`
-	ApiEndpoints map[string]string `json:"api-endpoints"`
+	PublicKey               jwk.Key           `json:"ear-verification-key,omitempty"`
+	MediaTypes              []string          `json:"media-types,omitempty"`
+	ResultMediaTypes        []string          `json:"result-media-types,omitempty"`
+	ResultSigningAlgorithms []string          `json:"result-signing-algorithms,omitempty"`
+	SessionPolicy           *SessionPolicy    `json:"session-policy,omitempty"`
+	Schemes                 []string          `json:"attestation-schemes,omitempty"`
+	Version                 string            `json:"version"`
+	ServiceState            string            `json:"service-state"`
+	ApiEndpoints            map[string]string `json:"api-endpoints"`
+}
+
+// SessionPolicy describes the parameters of the challenge-response sessions
+// issued by the verification service.
+type SessionPolicy struct {
+	TTL              string `json:"ttl"`
+	MinTTL           string `json:"min-ttl"`
+	NonceSize        uint8  `json:"nonce-size"`
+	MinNonceSize     uint8  `json:"min-nonce-size"`
+	MaxNonceSize     uint8  `json:"max-nonce-size"`
+	AllowClientNonce bool   `json:"allow-client-nonce"`
 }
 
 var ssTrans = map[string]string{
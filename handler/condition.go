@@ -0,0 +1,114 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Condition describes the evidence an endorsement applies to (e.g. the
+// environment and measurements an endorsed value is conditional on). Claims
+// are identified by slash-separated paths into the evidence claims (e.g.
+// "platform/cca-platform-implementation-id"). Values are compared in their
+// JSON representation, which is how the evidence claims are available at
+// appraisal time (e.g. byte strings are base64-encoded).
+type Condition struct {
+	// Claims maps claim paths onto the values the claims must have.
+	Claims map[string]interface{} `json:"claims,omitempty"`
+	// Entries maps the paths of claims that are arrays of objects (e.g.
+	// software components) onto entries that must be present in them. An
+	// entry is present if one of the objects has (at least) all of its
+	// fields, with the same values.
+	Entries map[string][]map[string]interface{} `json:"entries,omitempty"`
+}
+
+// Matches returns true if the specified evidence claims satisfy the
+// condition.
+func (o Condition) Matches(evidence map[string]interface{}) bool {
+	for path, expected := range o.Claims {
+		actual, ok := LookupClaim(evidence, path)
+		if !ok || !jsonEqual(actual, expected) {
+			return false
+		}
+	}
+
+	for path, entries := range o.Entries {
+		actual, ok := LookupClaim(evidence, path)
+		if !ok {
+			return false
+		}
+
+		objects, ok := actual.([]interface{})
+		if !ok {
+			return false
+		}
+
+		for _, entry := range entries {
+			if !containsEntry(objects, entry) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// LookupClaim returns the value of the claim at the specified slash-separated
+// path in claims, and whether it was found.
+func LookupClaim(claims map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = claims
+
+	for _, name := range strings.Split(path, "/") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = m[name]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func containsEntry(objects []interface{}, entry map[string]interface{}) bool {
+	for _, o := range objects {
+		object, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		found := true
+		for name, expected := range entry {
+			actual, ok := object[name]
+			if !ok || !jsonEqual(actual, expected) {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	aJSON, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bJSON, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(aJSON, bJSON)
+}
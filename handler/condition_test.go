@@ -0,0 +1,107 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondition_Matches(t *testing.T) {
+	evidence := map[string]interface{}{
+		"platform": map[string]interface{}{
+			"implementation-id": "YWNtZQ==",
+			"lifecycle":         float64(12288),
+			"sw-components": []interface{}{
+				map[string]interface{}{
+					"measurement-type":  "BL",
+					"measurement-value": "AQI=",
+					"signer-id":         "AwQ=",
+				},
+				"not an object",
+			},
+		},
+	}
+
+	testCases := []struct {
+		desc     string
+		cond     Condition
+		expected bool
+	}{
+		{
+			desc:     "empty",
+			cond:     Condition{},
+			expected: true,
+		},
+		{
+			desc: "claims match",
+			cond: Condition{Claims: map[string]interface{}{
+				"platform/implementation-id": []byte("acme"),
+				"platform/lifecycle":         12288,
+			}},
+			expected: true,
+		},
+		{
+			desc: "claim mismatch",
+			cond: Condition{Claims: map[string]interface{}{
+				"platform/implementation-id": []byte("emca"),
+			}},
+			expected: false,
+		},
+		{
+			desc: "claim missing",
+			cond: Condition{Claims: map[string]interface{}{
+				"platform/instance-id": []byte("acme"),
+			}},
+			expected: false,
+		},
+		{
+			desc: "entry present",
+			cond: Condition{Entries: map[string][]map[string]interface{}{
+				"platform/sw-components": {
+					{"measurement-type": "BL", "signer-id": []byte{0x03, 0x04}},
+				},
+			}},
+			expected: true,
+		},
+		{
+			desc: "entry absent",
+			cond: Condition{Entries: map[string][]map[string]interface{}{
+				"platform/sw-components": {
+					{"measurement-type": "BL", "measurement-value": []byte{0x02, 0x01}},
+				},
+			}},
+			expected: false,
+		},
+		{
+			desc: "entries not an array",
+			cond: Condition{Entries: map[string][]map[string]interface{}{
+				"platform/lifecycle": {{"measurement-type": "BL"}},
+			}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cond.Matches(evidence))
+		})
+	}
+}
+
+func TestLookupClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"a": map[string]interface{}{"b": "c"},
+	}
+
+	v, ok := LookupClaim(claims, "a/b")
+	assert.True(t, ok)
+	assert.Equal(t, "c", v)
+
+	_, ok = LookupClaim(claims, "a/b/c")
+	assert.False(t, ok)
+
+	_, ok = LookupClaim(claims, "b")
+	assert.False(t, ok)
+}
@@ -8,6 +8,7 @@ const (
 	EndorsementType_UNSPECIFIED      string = "unspecified"
 	EndorsementType_REFERENCE_VALUE  string = "reference value"
 	EndorsementType_VERIFICATION_KEY string = "trust anchor"
+	EndorsementType_ENDORSED_VALUE   string = "endorsed value"
 )
 
 type Endorsement struct {
@@ -16,8 +17,31 @@ type Endorsement struct {
 
 	SubType    string          `json:"subType"`
 	Attributes json.RawMessage `json:"attributes"`
+	// Condition, if set, restricts the evidence the endorsement applies to.
+	// It is only used for endorsed values.
+	Condition *Condition `json:"condition,omitempty"`
 }
 type EndorsementHandlerResponse struct {
 	ReferenceValues []Endorsement
 	TrustAnchors    []Endorsement
+	EndorsedValues  []Endorsement
+}
+
+// SplitEndorsedValues separates the endorsed values from the other
+// endorsements (i.e. the reference values) in a list of JSON-encoded
+// endorsements. Endorsements that cannot be decoded are kept with the others.
+func SplitEndorsedValues(endorsements []string) (others []string, endorsedValues []string) {
+	for _, e := range endorsements {
+		var endorsement Endorsement
+
+		if err := json.Unmarshal([]byte(e), &endorsement); err == nil &&
+			endorsement.Type == EndorsementType_ENDORSED_VALUE {
+			endorsedValues = append(endorsedValues, e)
+			continue
+		}
+
+		others = append(others, e)
+	}
+
+	return others, endorsedValues
 }
@@ -0,0 +1,25 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitEndorsedValues(t *testing.T) {
+	refVal := `{"scheme":"PSA_IOT","type":"reference value","subType":"PSA_IOT.sw-component",` +
+		`"attributes":{"PSA_IOT.impl-id":"YWNtZQ=="}}`
+	endVal := `{"scheme":"PSA_IOT","type":"endorsed value","subType":"PSA_IOT.endorsed-values",` +
+		`"attributes":{"PSA_IOT.impl-id":"YWNtZQ=="}}`
+
+	others, endVals := SplitEndorsedValues([]string{refVal, endVal, "not JSON"})
+
+	assert.Equal(t, []string{refVal, "not JSON"}, others)
+	assert.Equal(t, []string{endVal}, endVals)
+
+	others, endVals = SplitEndorsedValues(nil)
+	assert.Nil(t, others)
+	assert.Nil(t, endVals)
+}
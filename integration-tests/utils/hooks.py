@@ -9,6 +9,7 @@ from util import run_command, get_access_token
 def setup_end_to_end(test, variables):
     _set_content_types(test, variables)
     _set_authorization(test, variables, 'provisioner')
+    _set_attester_authorization(test, variables)
     _set_nonce(test, variables)
     generate_endorsements(test)
     generate_evidence_from_test(test)
@@ -16,9 +17,14 @@ def setup_end_to_end(test, variables):
 
 def setup_bad_session(test, variables):
     _set_authorization(test, variables, 'provisioner')
+    _set_attester_authorization(test, variables)
     generate_endorsements(test)
 
 
+def setup_bad_nonce(test, variables):
+    _set_attester_authorization(test, variables)
+
+
 def setup_no_nonce(test, variables):
     _set_authorization(test, variables, 'provisioner')
     generate_evidence_from_test(test)
@@ -27,6 +33,7 @@ def setup_no_nonce(test, variables):
 def setup_multi_nonce(test, variables):
     _set_content_types(test, variables)
     _set_authorization(test, variables, 'provisioner')
+    _set_attester_authorization(test, variables)
     _set_nonce(test, variables)
     generate_endorsements(test)
     generate_evidence_from_test_no_nonce(test)
@@ -34,6 +41,7 @@ def setup_multi_nonce(test, variables):
 
 def setup_enacttrust_badnode(test, variables):
     _set_authorization(test, variables, 'provisioner')
+    _set_attester_authorization(test, variables)
     _set_content_types(test, variables)
     _set_nonce(test, variables)
     generate_endorsements(test)
@@ -56,6 +64,7 @@ def setup_provisioning_fail_empty_body(test, variables):
 def setup_cca_verify_challenge(test, variables):
     _set_content_types(test, variables)
     _set_authorization(test, variables, 'provisioner')
+    _set_attester_authorization(test, variables)
     _set_alt_authorization(test, variables, 'manager')
     _set_nonce(test, variables)
     generate_endorsements(test)
@@ -65,6 +74,7 @@ def setup_cca_verify_challenge(test, variables):
 def setup_freshness_check_fail(test, variables):
     _set_content_types(test, variables)
     _set_authorization(test, variables, 'provisioner')
+    _set_attester_authorization(test, variables)
     _set_nonce(test, variables)
     generate_endorsements(test)
     generate_evidence_from_test(test)
@@ -89,6 +99,11 @@ def _set_alt_authorization(test, variables, role):
     variables['alt-authorization'] = f'Bearer {token}'
 
 
+def _set_attester_authorization(test, variables):
+    token = get_access_token(test, 'attester')
+    variables['attester-authorization'] = f'Bearer {token}'
+
+
 def _set_nonce(test, variables):
     nonce_config = test.test_vars['nonce']
     variables['nonce-value'] = test.common_vars[nonce_config]['value']
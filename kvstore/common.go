@@ -6,10 +6,26 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var ErrKeyNotFound = errors.New("key not found")
 
+// KeyTenant returns the tenant of a key, which is encoded as the authority of
+// the key URI (e.g. "0" in "PSA_IOT://0/..."; see README.md). An empty string
+// is returned if the key is not a URI. Note that url.Parse cannot be used, as
+// the scheme names used in keys are not valid URI schemes.
+func KeyTenant(key string) string {
+	_, rest, found := strings.Cut(key, "://")
+	if !found {
+		return ""
+	}
+
+	tenant, _, _ := strings.Cut(rest, "/")
+
+	return tenant
+}
+
 func sanitizeKV(key, val string) error {
 	if err := sanitizeK(key); err != nil {
 		return err
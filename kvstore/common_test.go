@@ -0,0 +1,16 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package kvstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyTenant(t *testing.T) {
+	assert.Equal(t, "0", KeyTenant("PSA_IOT://0/deadbeef/"))
+	assert.Equal(t, "7", KeyTenant("TPM_ENACTTRUST://7"))
+	assert.Equal(t, "", KeyTenant("PSA_IOT:///deadbeef"))
+	assert.Equal(t, "", KeyTenant("0:TPM_ENACTTRUST:opa"))
+}
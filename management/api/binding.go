@@ -0,0 +1,172 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/veraison/services/policy"
+)
+
+const (
+	BindingMediaType  = "application/vnd.veraison.policy-binding+json"
+	BindingsMediaType = "application/vnd.veraison.policy-bindings+json"
+)
+
+// CreateBinding adds a binding, selecting a specific policy version for the
+// appraisals matching its criteria.
+func (o Handler) CreateBinding(c *gin.Context) {
+	offered := c.NegotiateFormat(BindingMediaType)
+	if offered != BindingMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				BindingMediaType),
+		)
+		return
+	}
+
+	mediaType := c.Request.Header.Get("Content-Type")
+	if mediaType != BindingMediaType {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("the only supported binding format is %s",
+				BindingMediaType),
+		)
+		return
+	}
+
+	scheme := c.Param("scheme")
+	if !o.Manager.IsSchemeSupported(scheme) {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("unrecognised scheme %q", scheme),
+		)
+		return
+	}
+
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("error reading body: %s", err))
+		return
+	}
+
+	var binding policy.Binding
+	if err := json.Unmarshal(payload, &binding); err != nil {
+		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid binding: %s", err))
+		return
+	}
+
+	if err := binding.Validate(); err != nil {
+		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid binding: %s", err))
+		return
+	}
+
+	added, err := o.Manager.AddBinding(c, tenantID, scheme, &binding)
+	if err != nil {
+		if errors.Is(err, policy.ErrNoPolicy) {
+			reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid binding: %s", err))
+		} else {
+			reportProblem(c,
+				http.StatusInternalServerError,
+				fmt.Sprintf("could not add binding: %s", err),
+			)
+		}
+		return
+	}
+
+	respBytes, err := json.Marshal(added)
+	if err != nil {
+		reportProblem(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Data(http.StatusCreated, BindingMediaType, respBytes)
+}
+
+func (o Handler) GetBindings(c *gin.Context) {
+	offered := c.NegotiateFormat(BindingsMediaType)
+	if offered != BindingsMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				BindingsMediaType),
+		)
+		return
+	}
+
+	scheme := c.Param("scheme")
+	if !o.Manager.IsSchemeSupported(scheme) {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("unrecognised scheme %q", scheme),
+		)
+		return
+	}
+
+	bindings, err := o.Manager.GetBindings(c, tenantID, scheme)
+	o.respondToGet(c, BindingsMediaType, bindings, err)
+}
+
+func (o Handler) GetBinding(c *gin.Context) {
+	offered := c.NegotiateFormat(BindingMediaType)
+	if offered != BindingMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				BindingMediaType),
+		)
+		return
+	}
+
+	scheme := c.Param("scheme")
+	if !o.Manager.IsSchemeSupported(scheme) {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("unrecognised scheme %q", scheme),
+		)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("bad binding ID %q", c.Param("id")),
+		)
+		return
+	}
+
+	binding, err := o.Manager.GetBinding(c, tenantID, scheme, id)
+	o.respondToGet(c, BindingMediaType, binding, err)
+}
+
+func (o Handler) DeleteBinding(c *gin.Context) {
+	scheme := c.Param("scheme")
+	if !o.Manager.IsSchemeSupported(scheme) {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("unrecognised scheme %q", scheme),
+		)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("bad binding ID %q", c.Param("id")),
+		)
+		return
+	}
+
+	err = o.Manager.DeleteBinding(c, tenantID, scheme, id)
+	o.respondSimple(c, err)
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/veraison/services/policy"
+)
+
+const (
+	DataMediaType         = "application/vnd.veraison.policy-data+json"
+	DataVersionsMediaType = "application/vnd.veraison.policy-data-versions+json"
+)
+
+// CreateData adds a new version of the data document made available to the
+// policies. The request body is the JSON object that becomes the data.
+func (o Handler) CreateData(c *gin.Context) {
+	offered := c.NegotiateFormat(DataMediaType)
+	if offered != DataMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				DataMediaType),
+		)
+		return
+	}
+
+	mediaType := c.Request.Header.Get("Content-Type")
+	if mediaType != gin.MIMEJSON {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("the only supported data format is %s", gin.MIMEJSON),
+		)
+		return
+	}
+
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("error reading body: %s", err))
+		return
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid data: %s", err))
+		return
+	}
+
+	if err := (policy.DataDocument{Data: data}).Validate(); err != nil {
+		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid data: %s", err))
+		return
+	}
+
+	doc, err := o.Manager.AddData(c, tenant, scheme, data)
+	if err != nil {
+		reportProblem(c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("could not add data: %s", err),
+		)
+		return
+	}
+
+	respBytes, err := json.Marshal(doc)
+	if err != nil {
+		reportProblem(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Data(http.StatusCreated, DataMediaType, respBytes)
+}
+
+// GetData returns the latest version of the data document.
+func (o Handler) GetData(c *gin.Context) {
+	offered := c.NegotiateFormat(DataMediaType)
+	if offered != DataMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				DataMediaType),
+		)
+		return
+	}
+
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	doc, err := o.Manager.GetData(c, tenant, scheme)
+	o.respondToGet(c, DataMediaType, doc, err)
+}
+
+func (o Handler) GetDataVersion(c *gin.Context) {
+	offered := c.NegotiateFormat(DataMediaType)
+	if offered != DataMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				DataMediaType),
+		)
+		return
+	}
+
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("bad UUID %q", c.Param("uuid")),
+		)
+		return
+	}
+
+	doc, err := o.Manager.GetDataVersion(c, tenant, scheme, id)
+	o.respondToGet(c, DataMediaType, doc, err)
+}
+
+func (o Handler) GetDataVersions(c *gin.Context) {
+	offered := c.NegotiateFormat(DataVersionsMediaType)
+	if offered != DataVersionsMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				DataVersionsMediaType),
+		)
+		return
+	}
+
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	docs, err := o.Manager.GetDataVersions(c, tenant, scheme)
+	o.respondToGet(c, DataVersionsMediaType, docs, err)
+}
+
+// DeleteData removes all the versions of the data document.
+func (o Handler) DeleteData(c *gin.Context) {
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	err := o.Manager.DeleteData(c, tenant, scheme)
+	o.respondSimple(c, err)
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/veraison/services/management"
+	"github.com/veraison/services/policy"
+)
+
+const (
+	PolicyEvaluationRequestMediaType = "application/vnd.veraison.policy-evaluation-request+json"
+	PolicyEvaluationMediaType        = "application/vnd.veraison.policy-evaluation+json"
+)
+
+// Evaluate evaluates a candidate policy against a supplied input, or a
+// captured appraisal, without activating it. The response contains the
+// original and evaluated appraisals, along with their differences.
+func (o Handler) Evaluate(c *gin.Context) {
+	offered := c.NegotiateFormat(PolicyEvaluationMediaType)
+	if offered != PolicyEvaluationMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				PolicyEvaluationMediaType),
+		)
+		return
+	}
+
+	mediaType := c.Request.Header.Get("Content-Type")
+	if mediaType != PolicyEvaluationRequestMediaType {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("the only supported request format is %s",
+				PolicyEvaluationRequestMediaType),
+		)
+		return
+	}
+
+	scheme := c.Param("scheme")
+	if !o.Manager.IsSchemeSupported(scheme) {
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("unrecognised scheme %q", scheme),
+		)
+		return
+	}
+
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("error reading body: %s", err))
+		return
+	}
+
+	var req management.EvaluationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid request: %s", err))
+		return
+	}
+
+	if req.Rules != "" {
+		if err := o.Manager.Validate(c, req.Rules); err != nil {
+			reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid policy: %s", err))
+			return
+		}
+	}
+
+	result, err := o.Manager.Evaluate(c, tenantID, scheme, &req)
+	if err != nil {
+		if errors.Is(err, policy.ErrNoPolicy) {
+			reportProblem(c, http.StatusNotFound, err.Error())
+		} else if errors.Is(err, management.ErrBadEvaluationRequest) {
+			reportProblem(c, http.StatusBadRequest, err.Error())
+		} else {
+			reportProblem(c,
+				http.StatusInternalServerError,
+				fmt.Sprintf("could not evaluate policy: %s", err),
+			)
+		}
+		return
+	}
+
+	o.respondToGet(c, PolicyEvaluationMediaType, result, nil)
+}
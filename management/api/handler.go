@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
@@ -24,12 +26,26 @@ import (
 
 const (
 	RulesMediaType    = "application/vnd.veraison.policy.opa"
+	CELRulesMediaType = "application/vnd.veraison.policy.cel"
 	PolicyMediaType   = "application/vnd.veraison.policy+json"
 	PoliciesMediaType = "application/vnd.veraison.policies+json"
+	// MultipartMediaType is used to upload the rules of a policy along with
+	// its signature and/or tests, as the "rules", "signature" and "tests"
+	// parts.
+	MultipartMediaType = "multipart/form-data"
+
+	PolicyTestResultsMediaType = "application/vnd.veraison.policy-test-results+json"
 )
 
 var (
 	tenantID = "0"
+
+	// rulesMediaTypes maps policy backend names onto the media types of the
+	// rules they understand.
+	rulesMediaTypes = map[string]string{
+		"opa": RulesMediaType,
+		"cel": CELRulesMediaType,
+	}
 )
 
 type Handler struct {
@@ -55,22 +71,8 @@ func (o Handler) CreatePolicy(c *gin.Context) {
 		return
 	}
 
-	mediaType := c.Request.Header.Get("Content-Type")
-	if mediaType != RulesMediaType {
-		reportProblem(c,
-			http.StatusBadRequest,
-			fmt.Sprintf("the only supported rules format is %s",
-				RulesMediaType),
-		)
-		return
-	}
-
-	scheme := c.Param("scheme")
-	if !o.Manager.IsSchemeSupported(scheme) {
-		reportProblem(c,
-			http.StatusBadRequest,
-			fmt.Sprintf("unrecognised scheme %q", scheme),
-		)
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
 		return
 	}
 
@@ -79,39 +81,125 @@ func (o Handler) CreatePolicy(c *gin.Context) {
 		name = "default"
 	}
 
-	payload, err := io.ReadAll(c.Request.Body)
+	var policyRules, policySignature, policyTests string
+
+	mediaType, _, err := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
 	if err != nil {
-		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("error reading body: %s", err))
+		mediaType = ""
+	}
+
+	rulesMediaType := rulesMediaTypes[o.Manager.Agent.GetBackendName()]
+
+	switch mediaType {
+	case rulesMediaType:
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			reportProblem(c, http.StatusBadRequest, fmt.Sprintf("error reading body: %s", err))
+			return
+		}
+
+		policyRules = string(payload)
+	case MultipartMediaType:
+		policyRules, policySignature, policyTests, err = readPolicyParts(c)
+		if err != nil {
+			reportProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		reportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("the only supported rules formats are %s and %s",
+				rulesMediaType, MultipartMediaType),
+		)
 		return
 	}
 
-	if len(payload) == 0 {
+	if len(policyRules) == 0 {
 		reportProblem(c, http.StatusBadRequest, "empty body")
 		return
 	}
 
-	policyRules := string(payload)
-
 	if err = o.Manager.Validate(c, policyRules); err != nil {
 		reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid policy: %s", err))
+		return
 	}
 
-	policy, err := o.Manager.Update(c, tenantID, scheme, name, policyRules)
+	pol, err := o.Manager.Update(c, tenant, scheme, name,
+		policyRules, policySignature, policyTests)
 	if err != nil {
-		reportProblem(c,
-			http.StatusInternalServerError,
-			fmt.Sprintf("could not update policy: %s", err),
-		)
+		if errors.Is(err, policy.ErrBadSignature) || errors.Is(err, policy.ErrUnsignedPolicy) {
+			reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid signature: %s", err))
+		} else if errors.Is(err, management.ErrBadTests) {
+			reportProblem(c, http.StatusBadRequest, fmt.Sprintf("invalid tests: %s", err))
+		} else {
+			reportProblem(c,
+				http.StatusInternalServerError,
+				fmt.Sprintf("could not update policy: %s", err),
+			)
+		}
+		return
 	}
 
-	respBytes, err := json.Marshal(&policy)
+	respBytes, err := json.Marshal(&pol)
 	if err != nil {
 		reportProblem(c, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	c.Data(http.StatusCreated, PolicyMediaType, respBytes)
 }
 
+// readPolicyParts returns the contents of the "rules", and (optional)
+// "signature" and "tests" parts of a multipart/form-data request. Each part
+// may be either a form field or a file.
+func readPolicyParts(c *gin.Context) (string, string, string, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return "", "", "", fmt.Errorf("error reading body: %w", err)
+	}
+
+	rules, err := readFormPart(form, "rules")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	signature, err := readFormPart(form, "signature")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	tests, err := readFormPart(form, "tests")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return rules, strings.TrimSpace(signature), tests, nil
+}
+
+func readFormPart(form *multipart.Form, name string) (string, error) {
+	if values := form.Value[name]; len(values) != 0 {
+		return values[0], nil
+	}
+
+	files := form.File[name]
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	f, err := files[0].Open()
+	if err != nil {
+		return "", fmt.Errorf("error reading %q part: %w", name, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("error reading %q part: %w", name, err)
+	}
+
+	return string(data), nil
+}
+
 func (o Handler) GetActivePolicy(c *gin.Context) {
 	offered := c.NegotiateFormat(PolicyMediaType)
 	if offered != PolicyMediaType {
@@ -123,16 +211,12 @@ func (o Handler) GetActivePolicy(c *gin.Context) {
 		return
 	}
 
-	scheme := c.Param("scheme")
-	if !o.Manager.IsSchemeSupported(scheme) {
-		reportProblem(c,
-			http.StatusBadRequest,
-			fmt.Sprintf("unrecognised scheme %q", scheme),
-		)
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
 		return
 	}
 
-	pol, err := o.Manager.GetActive(c, tenantID, scheme)
+	pol, err := o.Manager.GetActive(c, tenant, scheme)
 	o.respondToGet(c, PolicyMediaType, pol, err)
 }
 
@@ -147,12 +231,8 @@ func (o Handler) GetPolicy(c *gin.Context) {
 		return
 	}
 
-	scheme := c.Param("scheme")
-	if !o.Manager.IsSchemeSupported(scheme) {
-		reportProblem(c,
-			http.StatusBadRequest,
-			fmt.Sprintf("unrecognised scheme %q", scheme),
-		)
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
 		return
 	}
 
@@ -165,7 +245,7 @@ func (o Handler) GetPolicy(c *gin.Context) {
 		return
 	}
 
-	pol, err := o.Manager.GetPolicy(c, tenantID, scheme, uuid)
+	pol, err := o.Manager.GetPolicy(c, tenant, scheme, uuid)
 	o.respondToGet(c, PolicyMediaType, pol, err)
 }
 
@@ -180,29 +260,94 @@ func (o Handler) GetPolicies(c *gin.Context) {
 		return
 	}
 
-	scheme := c.Param("scheme")
-	if !o.Manager.IsSchemeSupported(scheme) {
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	policies, err := o.Manager.GetPolicies(c, tenant, scheme, c.Query("name"))
+	o.respondToGet(c, PoliciesMediaType, policies, err)
+}
+
+func (o Handler) Activate(c *gin.Context) {
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
 		reportProblem(c,
 			http.StatusBadRequest,
-			fmt.Sprintf("unrecognised scheme %q", scheme),
+			fmt.Sprintf("bad UUID %q", c.Param("uuid")),
 		)
 		return
 	}
 
-	policies, err := o.Manager.GetPolicies(c, tenantID, scheme, c.Query("name"))
-	o.respondToGet(c, PoliciesMediaType, policies, err)
+	err = o.Manager.Activate(c, tenant, scheme, uuid)
+	o.respondSimple(c, err)
 }
 
-func (o Handler) Activate(c *gin.Context) {
-	scheme := c.Param("scheme")
-	if !o.Manager.IsSchemeSupported(scheme) {
+// RunTests runs the tests of the policy version with the UUID in the path,
+// and returns their results.
+func (o Handler) RunTests(c *gin.Context) {
+	offered := c.NegotiateFormat(PolicyTestResultsMediaType)
+	if offered != PolicyTestResultsMediaType {
+		reportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s",
+				PolicyTestResultsMediaType),
+		)
+		return
+	}
+
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
 		reportProblem(c,
 			http.StatusBadRequest,
-			fmt.Sprintf("unrecognised scheme %q", scheme),
+			fmt.Sprintf("bad UUID %q", c.Param("uuid")),
 		)
 		return
 	}
 
+	results, err := o.Manager.RunTests(c, tenant, scheme, uuid)
+	if err != nil {
+		if errors.Is(err, policy.ErrNoTests) {
+			reportProblem(c, http.StatusNotFound, err.Error())
+		} else if errors.Is(err, management.ErrBadTests) {
+			reportProblem(c, http.StatusBadRequest, err.Error())
+		} else {
+			o.respondToGet(c, PolicyTestResultsMediaType, nil, err)
+		}
+		return
+	}
+
+	o.respondToGet(c, PolicyTestResultsMediaType, results, nil)
+}
+
+func (o Handler) DeactivateAll(c *gin.Context) {
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	err := o.Manager.DeactivateAll(c, tenant, scheme)
+	o.respondSimple(c, err)
+}
+
+// DeletePolicy deletes the policy version with the UUID in the path. The
+// active version cannot be deleted.
+func (o Handler) DeletePolicy(c *gin.Context) {
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
 	uuid, err := uuid.Parse(c.Param("uuid"))
 	if err != nil {
 		reportProblem(c,
@@ -212,30 +357,57 @@ func (o Handler) Activate(c *gin.Context) {
 		return
 	}
 
-	err = o.Manager.Activate(c, tenantID, scheme, uuid)
+	err = o.Manager.DeletePolicy(c, tenant, scheme, uuid)
 	o.respondSimple(c, err)
 }
 
-func (o Handler) DeactivateAll(c *gin.Context) {
+// DeletePolicies deletes all the policy versions for the scheme, including the
+// active one.
+func (o Handler) DeletePolicies(c *gin.Context) {
+	tenant, scheme, ok := o.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	err := o.Manager.DeletePolicies(c, tenant, scheme)
+	o.respondSimple(c, err)
+}
+
+// resolveScope returns the tenant and the scheme of the policy a request is
+// for. The scheme is empty for the baseline and tenant-wide policies, in which
+// case the tenant is management.BaselineTenantID for the baseline policy. If
+// the scheme is not supported, a problem is reported and false is returned.
+func (o Handler) resolveScope(c *gin.Context) (string, string, bool) {
+	switch c.GetString(layerContextKey) {
+	case BaselineLayer:
+		return management.BaselineTenantID, "", true
+	case TenantLayer:
+		return tenantID, "", true
+	}
+
 	scheme := c.Param("scheme")
 	if !o.Manager.IsSchemeSupported(scheme) {
 		reportProblem(c,
 			http.StatusBadRequest,
 			fmt.Sprintf("unrecognised scheme %q", scheme),
 		)
-		return
+		return "", "", false
 	}
 
-	err := o.Manager.DeactivateAll(c, tenantID, scheme)
-	o.respondSimple(c, err)
+	return tenantID, scheme, true
 }
 
 func (o Handler) respondSimple(c *gin.Context, err error) {
 	if err == nil {
 		c.Status(http.StatusOK)
 	} else {
-		if errors.Is(err, policy.ErrNoPolicy) {
+		if errors.Is(err, policy.ErrNoPolicy) || errors.Is(err, policy.ErrNoBinding) ||
+			errors.Is(err, policy.ErrNoData) {
 			reportProblem(c, http.StatusNotFound, err.Error())
+		} else if errors.Is(err, policy.ErrPolicyActive) ||
+			errors.Is(err, policy.ErrPolicyBound) ||
+			errors.Is(err, management.ErrTestsFailed) {
+			reportProblem(c, http.StatusConflict, err.Error())
 		} else {
 			reportProblem(c, http.StatusInternalServerError, err.Error())
 		}
@@ -277,7 +449,8 @@ func (o Handler) GetManagementWellKnownInfo(c *gin.Context) {
 
 func (o Handler) respondToGet(c *gin.Context, mt string, ret interface{}, err error) {
 	if err != nil {
-		if errors.Is(err, policy.ErrNoPolicy) || errors.Is(err, policy.ErrNoActivePolicy) {
+		if errors.Is(err, policy.ErrNoPolicy) || errors.Is(err, policy.ErrNoActivePolicy) ||
+			errors.Is(err, policy.ErrNoBinding) || errors.Is(err, policy.ErrNoData) {
 			reportProblem(c, http.StatusNotFound, err.Error())
 		} else {
 			reportProblem(c, http.StatusInternalServerError, err.Error())
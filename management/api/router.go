@@ -15,6 +15,65 @@ var publicApiMap = map[string]string{
 	"getPolicy":          "/management/v1/policy/:scheme/:uuid",
 	"deactivatePolicies": "/management/v1/policies/:scheme/deactivate",
 	"getPolicies":        "/management/v1/policies/:scheme",
+	"deletePolicy":       "/management/v1/policy/:scheme/:uuid",
+	"deletePolicies":     "/management/v1/policies/:scheme",
+	"evaluatePolicy":     "/management/v1/policy/:scheme/evaluate",
+	"testPolicy":         "/management/v1/policy/:scheme/:uuid/test",
+	"createBinding":      "/management/v1/binding/:scheme",
+	"getBinding":         "/management/v1/binding/:scheme/:id",
+	"deleteBinding":      "/management/v1/binding/:scheme/:id",
+	"getBindings":        "/management/v1/bindings/:scheme",
+	"createData":         "/management/v1/data/:scheme",
+	"getData":            "/management/v1/data/:scheme",
+	"getDataVersion":     "/management/v1/data/:scheme/:uuid",
+	"getDataVersions":    "/management/v1/data-versions/:scheme",
+	"deleteData":         "/management/v1/data/:scheme",
+
+	"createBaselinePolicy":       "/management/v1/baseline/policy",
+	"activateBaselinePolicy":     "/management/v1/baseline/policy/:uuid/activate",
+	"testBaselinePolicy":         "/management/v1/baseline/policy/:uuid/test",
+	"getActiveBaselinePolicy":    "/management/v1/baseline/policy",
+	"getBaselinePolicy":          "/management/v1/baseline/policy/:uuid",
+	"deleteBaselinePolicy":       "/management/v1/baseline/policy/:uuid",
+	"deactivateBaselinePolicies": "/management/v1/baseline/policies/deactivate",
+	"getBaselinePolicies":        "/management/v1/baseline/policies",
+	"deleteBaselinePolicies":     "/management/v1/baseline/policies",
+	"createBaselineData":         "/management/v1/baseline/data",
+	"getBaselineData":            "/management/v1/baseline/data",
+	"getBaselineDataVersion":     "/management/v1/baseline/data/:uuid",
+	"getBaselineDataVersions":    "/management/v1/baseline/data-versions",
+	"deleteBaselineData":         "/management/v1/baseline/data",
+
+	"createTenantPolicy":       "/management/v1/tenant/policy",
+	"activateTenantPolicy":     "/management/v1/tenant/policy/:uuid/activate",
+	"testTenantPolicy":         "/management/v1/tenant/policy/:uuid/test",
+	"getActiveTenantPolicy":    "/management/v1/tenant/policy",
+	"getTenantPolicy":          "/management/v1/tenant/policy/:uuid",
+	"deleteTenantPolicy":       "/management/v1/tenant/policy/:uuid",
+	"deactivateTenantPolicies": "/management/v1/tenant/policies/deactivate",
+	"getTenantPolicies":        "/management/v1/tenant/policies",
+	"deleteTenantPolicies":     "/management/v1/tenant/policies",
+	"createTenantData":         "/management/v1/tenant/data",
+	"getTenantData":            "/management/v1/tenant/data",
+	"getTenantDataVersion":     "/management/v1/tenant/data/:uuid",
+	"getTenantDataVersions":    "/management/v1/tenant/data-versions",
+	"deleteTenantData":         "/management/v1/tenant/data",
+}
+
+// Policies are applied in layers: the operator-wide baseline policy, the
+// tenant-wide policy, and then the policy for the attestation scheme. The
+// layer a route is for (if not the scheme's) is set in the gin context.
+const (
+	BaselineLayer = "baseline"
+	TenantLayer   = "tenant"
+
+	layerContextKey = "policy-layer"
+)
+
+func setLayer(layer string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(layerContextKey, layer)
+	}
 }
 
 func NewRouter(handler Handler, authorizer auth.IAuthorizer) *gin.Engine {
@@ -26,11 +85,53 @@ func NewRouter(handler Handler, authorizer auth.IAuthorizer) *gin.Engine {
 
 	router.POST(publicApiMap["createPolicy"], handler.CreatePolicy)
 	router.POST(publicApiMap["activatePolicy"], handler.Activate)
+	router.POST(publicApiMap["evaluatePolicy"], handler.Evaluate)
+	router.POST(publicApiMap["testPolicy"], handler.RunTests)
 	router.GET(publicApiMap["getActivePolicy"], handler.GetActivePolicy)
 	router.GET(publicApiMap["getPolicy"], handler.GetPolicy)
+	router.DELETE(publicApiMap["deletePolicy"], handler.DeletePolicy)
 
 	router.POST(publicApiMap["deactivatePolicies"], handler.DeactivateAll)
 	router.GET(publicApiMap["getPolicies"], handler.GetPolicies)
+	router.DELETE(publicApiMap["deletePolicies"], handler.DeletePolicies)
+
+	router.POST(publicApiMap["createBinding"], handler.CreateBinding)
+	router.GET(publicApiMap["getBinding"], handler.GetBinding)
+	router.DELETE(publicApiMap["deleteBinding"], handler.DeleteBinding)
+	router.GET(publicApiMap["getBindings"], handler.GetBindings)
+
+	router.POST(publicApiMap["createData"], handler.CreateData)
+	router.GET(publicApiMap["getData"], handler.GetData)
+	router.GET(publicApiMap["getDataVersion"], handler.GetDataVersion)
+	router.GET(publicApiMap["getDataVersions"], handler.GetDataVersions)
+	router.DELETE(publicApiMap["deleteData"], handler.DeleteData)
+
+	for _, layer := range []struct {
+		Name   string
+		Prefix string
+	}{
+		{BaselineLayer, "Baseline"},
+		{TenantLayer, "Tenant"},
+	} {
+		set := setLayer(layer.Name)
+
+		router.POST(publicApiMap["create"+layer.Prefix+"Policy"], set, handler.CreatePolicy)
+		router.POST(publicApiMap["activate"+layer.Prefix+"Policy"], set, handler.Activate)
+		router.POST(publicApiMap["test"+layer.Prefix+"Policy"], set, handler.RunTests)
+		router.GET(publicApiMap["getActive"+layer.Prefix+"Policy"], set, handler.GetActivePolicy)
+		router.GET(publicApiMap["get"+layer.Prefix+"Policy"], set, handler.GetPolicy)
+		router.DELETE(publicApiMap["delete"+layer.Prefix+"Policy"], set, handler.DeletePolicy)
+
+		router.POST(publicApiMap["deactivate"+layer.Prefix+"Policies"], set, handler.DeactivateAll)
+		router.GET(publicApiMap["get"+layer.Prefix+"Policies"], set, handler.GetPolicies)
+		router.DELETE(publicApiMap["delete"+layer.Prefix+"Policies"], set, handler.DeletePolicies)
+
+		router.POST(publicApiMap["create"+layer.Prefix+"Data"], set, handler.CreateData)
+		router.GET(publicApiMap["get"+layer.Prefix+"Data"], set, handler.GetData)
+		router.GET(publicApiMap["get"+layer.Prefix+"DataVersion"], set, handler.GetDataVersion)
+		router.GET(publicApiMap["get"+layer.Prefix+"DataVersions"], set, handler.GetDataVersions)
+		router.DELETE(publicApiMap["delete"+layer.Prefix+"Data"], set, handler.DeleteData)
+	}
 
 	router.GET("/.well-known/veraison/management", handler.GetManagementWellKnownInfo)
 
@@ -3,12 +3,16 @@
 package main
 
 import (
+	"context"
+	"time"
+
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/veraison/services/auth"
 	"github.com/veraison/services/config"
 	"github.com/veraison/services/log"
 	"github.com/veraison/services/management"
 	"github.com/veraison/services/management/api"
+	"github.com/veraison/services/policy"
 )
 
 var (
@@ -17,6 +21,29 @@ var (
 
 type cfg struct {
 	ListenAddr string `mapstructure:"listen-addr" valid:"dialstring"`
+	// RetainVersions is the maximum number of inactive versions kept for
+	// each policy (zero means no limit).
+	RetainVersions int `mapstructure:"retain-versions" config:"zerodefault"`
+	// RetainAge is the maximum age of the inactive policy versions kept
+	// (empty means no limit).
+	RetainAge string `mapstructure:"retain-age" config:"zerodefault"`
+	// RequirePassingTests prevents activating policy versions whose tests
+	// do not pass.
+	RequirePassingTests bool `mapstructure:"require-passing-tests" config:"zerodefault"`
+}
+
+func (o cfg) retention() (policy.Retention, error) {
+	retention := policy.Retention{MaxVersions: o.RetainVersions}
+
+	if o.RetainAge != "" {
+		age, err := time.ParseDuration(o.RetainAge)
+		if err != nil {
+			return retention, err
+		}
+		retention.MaxAge = age
+	}
+
+	return retention, nil
 }
 
 func main() {
@@ -53,6 +80,19 @@ func main() {
 
 	}
 
+	pm.Retention, err = cfg.retention()
+	if err != nil {
+		log.Fatalf("invalid policy retention: %v", err)
+	}
+
+	pm.RequirePassingTests = cfg.RequirePassingTests
+
+	if pruned, err := pm.PruneAll(context.Background()); err != nil {
+		log.Errorf("could not prune policy versions: %v", err)
+	} else if len(pruned) != 0 {
+		log.Infow("pruned policy versions", "count", len(pruned))
+	}
+
 	log.Infow("initializing management API service", "address", cfg.ListenAddr)
 	authorizer, err := auth.NewAuthorizer(subs["auth"], log.Named("auth"))
 	if err != nil {
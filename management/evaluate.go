@@ -0,0 +1,317 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/veraison/ear"
+	"github.com/veraison/services/config"
+	"github.com/veraison/services/policy"
+	"github.com/veraison/services/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ErrBadEvaluationRequest is returned when an evaluation request is invalid
+// (e.g. it does not specify the input of the evaluation).
+var ErrBadEvaluationRequest = errors.New("invalid evaluation request")
+
+// EvaluationRequest is a request to evaluate a candidate policy, without
+// activating it. The policy is either specified by its Rules, or by the UUID
+// of a policy version in the store. Exactly one of Input and Appraisal must be
+// specified.
+type EvaluationRequest struct {
+	Rules      string     `json:"rules,omitempty"`
+	PolicyUUID *uuid.UUID `json:"policy-uuid,omitempty"`
+	// Submod is the name of the submod being appraised. It defaults to the
+	// scheme name.
+	Submod    string             `json:"submod,omitempty"`
+	Input     *EvaluationInput   `json:"input,omitempty"`
+	Appraisal *CapturedAppraisal `json:"appraisal,omitempty"`
+	// Data is the data document made available to the policy. If not
+	// specified, the latest version of the data document for the scheme
+	// (if any) is used.
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// EvaluationInput has the shape of the input a policy is evaluated against,
+// as made available to the policy.
+type EvaluationInput struct {
+	Session map[string]interface{} `json:"session,omitempty"`
+	// Result is the submod appraisal produced by the scheme. If not
+	// specified, an appraisal with no claims is used.
+	Result       map[string]interface{} `json:"result,omitempty"`
+	Evidence     map[string]interface{} `json:"evidence"`
+	Endorsements []json.RawMessage      `json:"endorsements,omitempty"`
+	// History is the prior attestations of the device, oldest first.
+	History []map[string]interface{} `json:"history,omitempty"`
+}
+
+// CapturedAppraisal is the evidence context and the endorsements of an
+// appraisal, as captured from VTS.
+type CapturedAppraisal struct {
+	Session map[string]interface{} `json:"session,omitempty"`
+	// EvidenceContext is the JSON encoding of a proto.EvidenceContext.
+	EvidenceContext json.RawMessage `json:"evidence-context"`
+	// Result is the submod appraisal produced by the scheme. If not
+	// specified, an appraisal with no claims is used.
+	Result       map[string]interface{} `json:"result,omitempty"`
+	Endorsements []json.RawMessage      `json:"endorsements,omitempty"`
+	// History is the prior attestations of the device, oldest first.
+	History []map[string]interface{} `json:"history,omitempty"`
+}
+
+// EvaluationResult is the outcome of evaluating a policy.
+type EvaluationResult struct {
+	Original  *ear.Appraisal `json:"original"`
+	Evaluated *ear.Appraisal `json:"evaluated"`
+	// Diff lists the claims of the appraisal that have been changed by the
+	// policy.
+	Diff []Difference `json:"diff"`
+}
+
+// Difference is a claim whose value differs between two appraisals. Path
+// is the slash-separated path of the claim within the appraisal. A nil
+// value means that the claim is not set.
+type Difference struct {
+	Path      string      `json:"path"`
+	Original  interface{} `json:"original"`
+	Evaluated interface{} `json:"evaluated"`
+}
+
+// Evaluate evaluates the policy in the request against its input, without
+// activating the policy, and returns the updated appraisal along with its
+// differences from the original one.
+func (o *PolicyManager) Evaluate(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+	req *EvaluationRequest,
+) (*EvaluationResult, error) {
+	if scheme == "" {
+		return nil, fmt.Errorf("%w: only policies for attestation schemes can be evaluated",
+			ErrBadEvaluationRequest)
+	}
+
+	key, err := o.resolvePolicyKey(tenantID, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	pol, err := o.evaluationPolicy(key, req)
+	if err != nil {
+		return nil, err
+	}
+
+	submod := req.Submod
+	if submod == "" {
+		submod = scheme
+	}
+
+	var (
+		session      map[string]interface{}
+		result       map[string]interface{}
+		evidence     *proto.EvidenceContext
+		endorsements []json.RawMessage
+		history      []map[string]interface{}
+	)
+
+	switch {
+	case req.Input != nil && req.Appraisal != nil:
+		return nil, fmt.Errorf("%w: only one of input and appraisal may be specified",
+			ErrBadEvaluationRequest)
+	case req.Input != nil:
+		evidenceStruct, err := structpb.NewStruct(req.Input.Evidence)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid evidence: %v", ErrBadEvaluationRequest, err)
+		}
+
+		session, result, endorsements = req.Input.Session, req.Input.Result, req.Input.Endorsements
+		history = req.Input.History
+		evidence = &proto.EvidenceContext{TenantId: tenantID, Evidence: evidenceStruct}
+	case req.Appraisal != nil:
+		evidence = &proto.EvidenceContext{}
+		if err := protojson.Unmarshal(req.Appraisal.EvidenceContext, evidence); err != nil {
+			return nil, fmt.Errorf("%w: invalid evidence context: %v", ErrBadEvaluationRequest, err)
+		}
+
+		if evidence.Evidence == nil {
+			evidence.Evidence = &structpb.Struct{}
+		}
+
+		session, result, endorsements = req.Appraisal.Session, req.Appraisal.Result, req.Appraisal.Endorsements
+		history = req.Appraisal.History
+	default:
+		return nil, fmt.Errorf("%w: one of input and appraisal must be specified",
+			ErrBadEvaluationRequest)
+	}
+
+	original, err := newEvaluationAppraisal(scheme, submod, result)
+	if err != nil {
+		return nil, err
+	}
+
+	endorsementStrings := make([]string, len(endorsements))
+	for i, e := range endorsements {
+		endorsementStrings[i] = string(e)
+	}
+
+	if session == nil {
+		session = map[string]interface{}{}
+	}
+
+	// the agent may update the appraisal it is passed, so make sure the
+	// original is preserved
+	input, err := copyAppraisal(original)
+	if err != nil {
+		return nil, err
+	}
+
+	data := req.Data
+	if data == nil {
+		doc, err := o.Store.GetData(tenantID, scheme)
+		if err != nil {
+			if !errors.Is(err, policy.ErrNoData) {
+				return nil, err
+			}
+		} else {
+			data = doc.Data
+		}
+	}
+
+	evaluated, err := o.Agent.Evaluate(ctx, session, scheme, pol, data, history, submod,
+		input, evidence, endorsementStrings)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := DiffAppraisals(original, evaluated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EvaluationResult{Original: original, Evaluated: evaluated, Diff: diff}, nil
+}
+
+func (o *PolicyManager) evaluationPolicy(
+	key policy.PolicyKey,
+	req *EvaluationRequest,
+) (*policy.Policy, error) {
+	if req.PolicyUUID != nil {
+		if req.Rules != "" {
+			return nil, fmt.Errorf("%w: only one of rules and policy-uuid may be specified",
+				ErrBadEvaluationRequest)
+		}
+
+		return o.Store.GetPolicy(key, *req.PolicyUUID)
+	}
+
+	if req.Rules == "" {
+		return nil, fmt.Errorf("%w: one of rules and policy-uuid must be specified",
+			ErrBadEvaluationRequest)
+	}
+
+	return policy.NewPolicy(key, "dry-run", o.Agent.GetBackendName(), req.Rules)
+}
+
+// newEvaluationAppraisal returns the appraisal corresponding to the specified
+// result, or an appraisal with no claims if it is nil.
+func newEvaluationAppraisal(
+	scheme string,
+	submod string,
+	result map[string]interface{},
+) (*ear.Appraisal, error) {
+	if result == nil {
+		res := ear.NewAttestationResult(submod, config.Version, config.Developer)
+		appraisal := res.Submods[submod]
+
+		policyID := fmt.Sprintf("policy:%s", scheme)
+		appraisal.AppraisalPolicyID = &policyID
+
+		return appraisal, nil
+	}
+
+	appraisal, err := ear.ToAppraisal(result)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid result: %v", ErrBadEvaluationRequest, err)
+	}
+
+	return appraisal, nil
+}
+
+func copyAppraisal(appraisal *ear.Appraisal) (*ear.Appraisal, error) {
+	m, err := appraisalToJSONMap(appraisal)
+	if err != nil {
+		return nil, err
+	}
+
+	return ear.ToAppraisal(m)
+}
+
+func appraisalToJSONMap(appraisal *ear.Appraisal) (map[string]interface{}, error) {
+	data, err := json.Marshal(appraisal)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// DiffAppraisals returns the claims whose values differ between the original
+// and the updated appraisals, sorted by path.
+func DiffAppraisals(original, updated *ear.Appraisal) ([]Difference, error) {
+	o, err := appraisalToJSONMap(original)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode original appraisal: %w", err)
+	}
+
+	u, err := appraisalToJSONMap(updated)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode updated appraisal: %w", err)
+	}
+
+	diff := []Difference{}
+	diffValues("", o, u, &diff)
+
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+
+	return diff, nil
+}
+
+func diffValues(path string, original, updated interface{}, diff *[]Difference) {
+	om, oIsMap := original.(map[string]interface{})
+	um, uIsMap := updated.(map[string]interface{})
+
+	if (oIsMap || original == nil) && (uIsMap || updated == nil) && (oIsMap || uIsMap) {
+		keys := make(map[string]bool)
+		for k := range om {
+			keys[k] = true
+		}
+		for k := range um {
+			keys[k] = true
+		}
+
+		for k := range keys {
+			diffValues(path+"/"+k, om[k], um[k], diff)
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(original, updated) {
+		*diff = append(*diff, Difference{Path: path, Original: original, Evaluated: updated})
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/ear"
+	"github.com/veraison/services/log"
+	"github.com/veraison/services/policy"
+)
+
+const testRules = `package policy
+
+hardware = GENUINE_HW {
+	input.evidence["psa-hardware-version"] == "1.0"
+} else = UNSAFE_HW
+
+status = WARNING {
+	count(input.endorsements) > 0
+}
+`
+
+func newTestPolicyManager(t *testing.T) *PolicyManager {
+	agent, err := policy.CreateAgent(viper.New(), log.Named("agent"))
+	require.NoError(t, err)
+
+	v := viper.New()
+	v.Set("backend", "memory")
+
+	store, err := policy.NewStore(v, log.Named("store"))
+	require.NoError(t, err)
+
+	return NewPolicyManager(agent, store, []string{"PSA_IOT"})
+}
+
+func TestPolicyManager_Evaluate_input(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	req := EvaluationRequest{
+		Rules: testRules,
+		Input: &EvaluationInput{
+			Evidence: map[string]interface{}{"psa-hardware-version": "1.0"},
+		},
+	}
+
+	res, err := pm.Evaluate(context.Background(), "0", "PSA_IOT", &req)
+	require.NoError(t, err)
+
+	assert.Equal(t, ear.TrustTierNone, *res.Original.Status)
+	assert.Equal(t, ear.TrustTierNone, *res.Evaluated.Status)
+	assert.Equal(t, ear.GenuineHardwareClaim, res.Evaluated.TrustVector.Hardware)
+
+	// claims that are not set are omitted from the appraisal
+	assert.Equal(t, []Difference{
+		{Path: "/ear.trustworthiness-vector/hardware", Original: nil, Evaluated: float64(2)},
+	}, res.Diff)
+}
+
+func TestPolicyManager_Evaluate_history(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	rules := `package policy
+
+# the firmware must not be rolled back
+executables = UNSAFE_RT {
+	input.history[_].evidence.firmware > input.evidence.firmware
+} else = APPROVED_RT
+`
+
+	req := EvaluationRequest{
+		Rules: rules,
+		Input: &EvaluationInput{
+			Evidence: map[string]interface{}{"firmware": 7},
+			History: []map[string]interface{}{
+				{"time": "2023-10-01T12:00:00Z", "evidence": map[string]interface{}{"firmware": 8}},
+			},
+		},
+	}
+
+	res, err := pm.Evaluate(context.Background(), "0", "PSA_IOT", &req)
+	require.NoError(t, err)
+	assert.Equal(t, ear.UnsafeRuntimeClaim, res.Evaluated.TrustVector.Executables)
+
+	req.Input.History = nil
+
+	res, err = pm.Evaluate(context.Background(), "0", "PSA_IOT", &req)
+	require.NoError(t, err)
+	assert.Equal(t, ear.ApprovedRuntimeClaim, res.Evaluated.TrustVector.Executables)
+}
+
+func TestPolicyManager_Evaluate_appraisal(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	pol, err := pm.Update(context.Background(), "0", "PSA_IOT", "test", testRules, "", "")
+	require.NoError(t, err)
+
+	req := EvaluationRequest{
+		PolicyUUID: &pol.UUID,
+		Appraisal: &CapturedAppraisal{
+			EvidenceContext: json.RawMessage(
+				`{"tenant-id": "0", "evidence": {"psa-hardware-version": "2.0"}}`),
+			Result: map[string]interface{}{
+				"ear.status":                 "affirming",
+				"ear.trustworthiness-vector": map[string]interface{}{"hardware": 2},
+			},
+			Endorsements: []json.RawMessage{
+				json.RawMessage(`{"scheme": "PSA_IOT", "type": "reference value"}`),
+			},
+		},
+	}
+
+	res, err := pm.Evaluate(context.Background(), "0", "PSA_IOT", &req)
+	require.NoError(t, err)
+
+	assert.Equal(t, ear.TrustTierAffirming, *res.Original.Status)
+	assert.Equal(t, ear.TrustTierWarning, *res.Evaluated.Status)
+	assert.Equal(t, ear.UnsafeHardwareClaim, res.Evaluated.TrustVector.Hardware)
+	assert.Equal(t, []Difference{
+		{Path: "/ear.status", Original: "affirming", Evaluated: "warning"},
+		{Path: "/ear.trustworthiness-vector/hardware", Original: float64(2), Evaluated: float64(32)},
+	}, res.Diff)
+}
+
+func TestPolicyManager_Evaluate_bad_request(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	_, err := pm.Evaluate(context.Background(), "0", "PSA_IOT", &EvaluationRequest{Rules: testRules})
+	assert.ErrorIs(t, err, ErrBadEvaluationRequest)
+	assert.EqualError(t, err,
+		"invalid evaluation request: one of input and appraisal must be specified")
+
+	_, err = pm.Evaluate(context.Background(), "0", "PSA_IOT", &EvaluationRequest{
+		Input: &EvaluationInput{},
+	})
+	assert.ErrorIs(t, err, ErrBadEvaluationRequest)
+	assert.EqualError(t, err,
+		"invalid evaluation request: one of rules and policy-uuid must be specified")
+
+	_, err = pm.Evaluate(context.Background(), "0", "PSA_IOT", &EvaluationRequest{
+		Rules:     testRules,
+		Appraisal: &CapturedAppraisal{EvidenceContext: []byte(`{"tenant-id": 7}`)},
+	})
+	assert.ErrorIs(t, err, ErrBadEvaluationRequest)
+	assert.ErrorContains(t, err, "invalid evaluation request: invalid evidence context: ")
+
+	_, err = pm.Evaluate(context.Background(), "0", "FOO", &EvaluationRequest{})
+	assert.EqualError(t, err, `Unsupported attestation scheme: "FOO"`)
+}
+
+func TestPolicyManager_Evaluate_data(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	rules := `package policy
+
+hardware = GENUINE_HW {
+	data.hw_versions[_] == input.evidence["psa-hardware-version"]
+} else = UNSAFE_HW
+`
+	req := EvaluationRequest{
+		Rules: rules,
+		Input: &EvaluationInput{
+			Evidence: map[string]interface{}{"psa-hardware-version": "1.0"},
+		},
+	}
+
+	// the stored data document is used, unless data is specified
+	_, err := pm.AddData(context.Background(), "0", "PSA_IOT",
+		map[string]interface{}{"hw_versions": []interface{}{"1.0"}})
+	require.NoError(t, err)
+
+	res, err := pm.Evaluate(context.Background(), "0", "PSA_IOT", &req)
+	require.NoError(t, err)
+	assert.Equal(t, ear.GenuineHardwareClaim, res.Evaluated.TrustVector.Hardware)
+
+	req.Data = map[string]interface{}{"hw_versions": []interface{}{"2.0"}}
+
+	res, err = pm.Evaluate(context.Background(), "0", "PSA_IOT", &req)
+	require.NoError(t, err)
+	assert.Equal(t, ear.UnsafeHardwareClaim, res.Evaluated.TrustVector.Hardware)
+}
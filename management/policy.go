@@ -5,7 +5,9 @@ package management
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
@@ -17,10 +19,35 @@ import (
 	"github.com/veraison/services/policy"
 )
 
+// BaselineTenantID is the tenant ID used, along with an empty scheme, to
+// manage the operator-wide baseline policy. The tenant-wide policy of a tenant
+// is managed using its ID along with an empty scheme.
+const BaselineTenantID = ""
+
+// ErrTestsFailed is returned when activating a policy version whose tests do
+// not pass, while passing tests are required.
+var ErrTestsFailed = errors.New("policy tests did not pass")
+
+// ErrBadTests is returned when the tests of a policy cannot be run (e.g.
+// because they fail to compile).
+var ErrBadTests = errors.New("could not run policy tests")
+
 type PolicyManager struct {
 	Agent            policy.IAgent
 	Store            *policy.Store
 	SupportedSchemes []string
+
+	// Retention specifies which inactive policy versions are kept when the
+	// policies change. By default, all versions are kept.
+	Retention policy.Retention
+
+	// RequirePassingTests, if set, prevents activating policy versions
+	// that do not have tests, or whose tests do not pass.
+	RequirePassingTests bool
+
+	// Verifier verifies the signatures of new policy versions. If nil,
+	// signatures are not verified.
+	Verifier *policy.SignatureVerifier
 }
 
 func CreatePolicyManagerFromConfig(v *viper.Viper, name string) (*PolicyManager, error) {
@@ -59,9 +86,17 @@ func CreatePolicyManagerFromConfig(v *viper.Viper, name string) (*PolicyManager,
 	}
 	defer pluginManager.Close()
 
+	verifier, err := policy.NewSignatureVerifier(subs["po-agent"].Sub("signing"))
+	if err != nil {
+		return nil, err
+	}
+
 	supportedSchemes := pluginManager.GetRegisteredAttestationSchemes()
 
-	return NewPolicyManager(agent, store, supportedSchemes), nil
+	pm := NewPolicyManager(agent, store, supportedSchemes)
+	pm.Verifier = verifier
+
+	return pm, nil
 }
 
 func NewPolicyManager(agent policy.IAgent, store *policy.Store, schemes []string) *PolicyManager {
@@ -82,19 +117,54 @@ func (o *PolicyManager) Validate(ctx context.Context, policyRules string) error
 	return o.Agent.Validate(ctx, policyRules)
 }
 
+// Update adds a new version of the policy for the scheme, with the specified
+// rules and, optionally, their signature and tests. The signature (if any) is
+// verified, and a version whose signature cannot be verified (or that is not
+// signed, if signatures are enforced) is not added. If tests are specified,
+// they are run against the rules, and their results are stored alongside the
+// new version. Failing tests do not prevent the version from being added,
+// however tests that cannot be run (e.g. that do not compile) do.
 func (o *PolicyManager) Update(
 	ctx context.Context,
 	tenantID string,
 	scheme string,
 	name string,
 	rules string,
+	signature string,
+	tests string,
 ) (*policy.Policy, error) {
 	key, err := o.resolvePolicyKey(tenantID, scheme)
 	if err != nil {
 		return nil, err
 	}
 
-	return o.Store.Update(key, name, o.Agent.GetBackendName(), rules)
+	pol, err := policy.NewPolicy(key, name, o.Agent.GetBackendName(), rules)
+	if err != nil {
+		return nil, err
+	}
+
+	pol.Signature = signature
+
+	if err := o.Verifier.Verify(pol); err != nil {
+		return nil, err
+	}
+
+	if tests != "" {
+		pol.Tests = tests
+
+		pol.TestResults, err = o.runTests(ctx, pol)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := o.Store.AddVersion(pol); err != nil {
+		return nil, err
+	}
+
+	o.prune(key)
+
+	return pol, nil
 }
 
 func (o *PolicyManager) GetActive(
@@ -155,6 +225,11 @@ func (o *PolicyManager) GetPolicies(
 	return ret, nil
 }
 
+// Activate makes the policy version with the specified UUID the active one
+// for the tenant's scheme. VTS caches active policies, along with their
+// bindings and data, and only reads them from the store again once they expire
+// (see active-policy-ttl in the policy agent's configuration), so that is how
+// long the change may take to be applied. There is no other notification.
 func (o *PolicyManager) Activate(
 	ctx context.Context,
 	tenantID string,
@@ -166,9 +241,26 @@ func (o *PolicyManager) Activate(
 		return err
 	}
 
-	return o.Store.Activate(key, policyID)
+	pol, err := o.Store.GetPolicy(key, policyID)
+	if err != nil {
+		return err
+	}
+
+	if err := o.checkTests(ctx, pol); err != nil {
+		return err
+	}
+
+	if err := o.Store.Activate(key, policyID); err != nil {
+		return err
+	}
+
+	o.prune(key)
+
+	return nil
 }
 
+// DeactivateAll deactivates all versions of the policy for the tenant's
+// scheme. As with Activate, VTS applies the change once its cache expires.
 func (o *PolicyManager) DeactivateAll(
 	ctx context.Context,
 	tenantID string,
@@ -179,13 +271,308 @@ func (o *PolicyManager) DeactivateAll(
 		return err
 	}
 
-	return o.Store.DeactivateAll(key)
+	if err := o.Store.DeactivateAll(key); err != nil {
+		return err
+	}
+
+	o.prune(key)
+
+	return nil
 }
 
+// RunTests runs the tests of the policy version with the specified UUID, and
+// returns their results. policy.ErrNoTests is returned if the version has no
+// tests.
+func (o *PolicyManager) RunTests(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+	policyID uuid.UUID,
+) ([]policy.TestCaseResult, error) {
+	key, err := o.resolvePolicyKey(tenantID, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	pol, err := o.Store.GetPolicy(key, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pol.Tests == "" {
+		return nil, fmt.Errorf("%w: UUID %q", policy.ErrNoTests, policyID.String())
+	}
+
+	return o.runTests(ctx, pol)
+}
+
+// DeletePolicy deletes the policy version with the specified UUID. The active
+// version cannot be deleted.
+func (o *PolicyManager) DeletePolicy(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+	policyID uuid.UUID,
+) error {
+	key, err := o.resolvePolicyKey(tenantID, scheme)
+	if err != nil {
+		return err
+	}
+
+	return o.Store.DelPolicy(key, policyID)
+}
+
+// DeletePolicies deletes all the policy versions, including the active one,
+// and all the bindings, for the scheme.
+func (o *PolicyManager) DeletePolicies(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+) error {
+	key, err := o.resolvePolicyKey(tenantID, scheme)
+	if err != nil {
+		return err
+	}
+
+	if _, err := o.Store.Get(key); err != nil {
+		return err
+	}
+
+	// the bindings would be left dangling otherwise
+	if err := o.Store.DelBindings(tenantID, scheme); err != nil {
+		return err
+	}
+
+	return o.Store.Del(key)
+}
+
+// AddBinding adds a binding, selecting the policy version with the UUID
+// specified by the binding for the appraisals matching its criteria. The
+// binding is assigned a new ID and creation time.
+func (o *PolicyManager) AddBinding(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+	binding *policy.Binding,
+) (*policy.Binding, error) {
+	if scheme == "" {
+		return nil, errors.New("bindings are only supported for attestation schemes")
+	}
+
+	key, err := o.resolvePolicyKey(tenantID, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := o.Store.GetPolicy(key, binding.PolicyUUID); err != nil {
+		return nil, err
+	}
+
+	newBinding, err := policy.NewBinding(binding.PolicyUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	newBinding.Priority = binding.Priority
+	newBinding.MediaType = binding.MediaType
+	newBinding.Submod = binding.Submod
+	newBinding.Attributes = binding.Attributes
+
+	if err := o.Store.AddBinding(tenantID, scheme, newBinding); err != nil {
+		return nil, err
+	}
+
+	return newBinding, nil
+}
+
+// GetBindings returns the bindings for the scheme, in the order in which they
+// are considered.
+func (o *PolicyManager) GetBindings(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+) ([]*policy.Binding, error) {
+	if _, err := o.resolvePolicyKey(tenantID, scheme); err != nil {
+		return nil, err
+	}
+
+	return o.Store.GetBindings(tenantID, scheme)
+}
+
+func (o *PolicyManager) GetBinding(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+	bindingID uuid.UUID,
+) (*policy.Binding, error) {
+	if _, err := o.resolvePolicyKey(tenantID, scheme); err != nil {
+		return nil, err
+	}
+
+	return o.Store.GetBinding(tenantID, scheme, bindingID)
+}
+
+func (o *PolicyManager) DeleteBinding(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+	bindingID uuid.UUID,
+) error {
+	if _, err := o.resolvePolicyKey(tenantID, scheme); err != nil {
+		return err
+	}
+
+	return o.Store.DelBinding(tenantID, scheme, bindingID)
+}
+
+// AddData adds a new version of the data document made available to the
+// policies of the tenant's scheme (or, if the scheme is empty, to the
+// tenant-wide or baseline policy).
+func (o *PolicyManager) AddData(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+	data map[string]interface{},
+) (*policy.DataDocument, error) {
+	if _, err := o.resolvePolicyKey(tenantID, scheme); err != nil {
+		return nil, err
+	}
+
+	return o.Store.AddData(tenantID, scheme, data)
+}
+
+// GetData returns the latest version of the data document.
+func (o *PolicyManager) GetData(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+) (*policy.DataDocument, error) {
+	if _, err := o.resolvePolicyKey(tenantID, scheme); err != nil {
+		return nil, err
+	}
+
+	return o.Store.GetData(tenantID, scheme)
+}
+
+func (o *PolicyManager) GetDataVersion(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+	dataID uuid.UUID,
+) (*policy.DataDocument, error) {
+	if _, err := o.resolvePolicyKey(tenantID, scheme); err != nil {
+		return nil, err
+	}
+
+	return o.Store.GetDataVersion(tenantID, scheme, dataID)
+}
+
+// GetDataVersions returns all the versions of the data document, oldest
+// first.
+func (o *PolicyManager) GetDataVersions(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+) ([]*policy.DataDocument, error) {
+	if _, err := o.resolvePolicyKey(tenantID, scheme); err != nil {
+		return nil, err
+	}
+
+	return o.Store.GetDataVersions(tenantID, scheme)
+}
+
+// DeleteData removes all the versions of the data document.
+func (o *PolicyManager) DeleteData(
+	ctx context.Context,
+	tenantID string,
+	scheme string,
+) error {
+	if _, err := o.resolvePolicyKey(tenantID, scheme); err != nil {
+		return err
+	}
+
+	return o.Store.DelData(tenantID, scheme)
+}
+
+// PruneAll prunes the inactive versions of all the policies in the store
+// according to the retention, and returns the pruned versions.
+func (o *PolicyManager) PruneAll(ctx context.Context) ([]*policy.Policy, error) {
+	return o.Store.PruneAll(o.Retention, time.Now())
+}
+
+// prune prunes the inactive versions of the policy with the specified key.
+// Failing to do so is not fatal: the versions will be pruned the next time the
+// policy changes.
+func (o *PolicyManager) prune(key policy.PolicyKey) {
+	pruned, err := o.Store.Prune(key, o.Retention, time.Now())
+	if err != nil {
+		log.Warnw("could not prune policy versions", "key", key.String(), "error", err)
+		return
+	}
+
+	if len(pruned) != 0 {
+		log.Infow("pruned policy versions", "key", key.String(), "count", len(pruned))
+	}
+}
+
+func (o *PolicyManager) runTests(
+	ctx context.Context,
+	pol *policy.Policy,
+) ([]policy.TestCaseResult, error) {
+	results, err := o.Agent.Test(ctx, pol.Rules, pol.Tests)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadTests, err)
+	}
+
+	return results, nil
+}
+
+// checkTests runs the tests of the policy version about to be activated. The
+// tests are re-run, rather than relying on the results stored with the
+// version, as the policy engine may have changed since the version was added.
+// Failures only prevent activation if RequirePassingTests is set.
+func (o *PolicyManager) checkTests(ctx context.Context, pol *policy.Policy) error {
+	if pol.Tests == "" {
+		if o.RequirePassingTests {
+			return fmt.Errorf("%w: UUID %q has no tests", ErrTestsFailed, pol.UUID.String())
+		}
+
+		return nil
+	}
+
+	results, err := o.runTests(ctx, pol)
+	if err == nil && policy.TestsPassed(results) {
+		return nil
+	}
+
+	if !o.RequirePassingTests {
+		log.Warnw("activating policy whose tests did not pass",
+			"key", pol.StoreKey.String(), "uuid", pol.UUID.String(), "error", err)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTestsFailed, err)
+	}
+
+	return fmt.Errorf("%w: UUID %q", ErrTestsFailed, pol.UUID.String())
+}
+
+// resolvePolicyKey returns the key of the policy for the tenant's scheme. If
+// the scheme is empty, the key of the tenant-wide policy is returned, or, if
+// the tenant is also BaselineTenantID, the key of the baseline policy.
 func (o *PolicyManager) resolvePolicyKey(
 	tenantID string,
 	scheme string,
 ) (policy.PolicyKey, error) {
+	if scheme == "" {
+		if tenantID == BaselineTenantID {
+			return policy.BaselinePolicyKey(o.Agent.GetBackendName()), nil
+		}
+
+		return policy.TenantPolicyKey(tenantID, o.Agent.GetBackendName()), nil
+	}
+
 	schemeFound := false
 	for _, supportedScheme := range o.SupportedSchemes {
 		if supportedScheme == scheme {
@@ -0,0 +1,286 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package management
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/services/policy"
+)
+
+const testTests = `package policy_test
+
+import data.policy
+
+test_genuine_hw {
+	policy.hardware == policy.GENUINE_HW with input as {
+		"evidence": {"psa-hardware-version": "1.0"}
+	}
+}
+`
+
+const failingTests = `package policy_test
+
+import data.policy
+
+test_genuine_hw {
+	policy.hardware == policy.GENUINE_HW with input as {
+		"evidence": {"psa-hardware-version": "2.0"}
+	}
+}
+`
+
+func TestPolicyManager_Update_tests(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	ctx := context.Background()
+
+	pol, err := pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", testTests)
+	require.NoError(t, err)
+	assert.Equal(t, testTests, pol.Tests)
+	assert.Equal(t, []policy.TestCaseResult{
+		{Package: "data.policy_test", Name: "test_genuine_hw", Status: policy.TestPass},
+	}, pol.TestResults)
+
+	stored, err := pm.GetPolicy(ctx, "0", "PSA_IOT", pol.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, pol.TestResults, stored.TestResults)
+
+	results, err := pm.RunTests(ctx, "0", "PSA_IOT", pol.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, pol.TestResults, results)
+
+	// failing tests do not prevent the policy from being added...
+	pol, err = pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", failingTests)
+	require.NoError(t, err)
+	require.Len(t, pol.TestResults, 1)
+	assert.Equal(t, policy.TestFail, pol.TestResults[0].Status)
+
+	// ...but tests that cannot be run do
+	_, err = pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", "package policy_test\n\ntest_x { x }\n")
+	assert.ErrorIs(t, err, ErrBadTests)
+
+	pol, err = pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", "")
+	require.NoError(t, err)
+	assert.Nil(t, pol.TestResults)
+
+	_, err = pm.RunTests(ctx, "0", "PSA_IOT", pol.UUID)
+	assert.ErrorIs(t, err, policy.ErrNoTests)
+}
+
+func TestPolicyManager_Update_signature(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	ctx := context.Background()
+
+	author, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pub, err := jwk.FromRaw(&author.PublicKey)
+	require.NoError(t, err)
+
+	keys := jwk.NewSet()
+	require.NoError(t, keys.AddKey(pub))
+
+	pm.Verifier = &policy.SignatureVerifier{Keys: keys, Enforce: true}
+
+	signature, err := jws.Sign(nil, jws.WithKey(jwa.ES256, author),
+		jws.WithDetachedPayload([]byte(testRules)))
+	require.NoError(t, err)
+
+	pol, err := pm.Update(ctx, "0", "PSA_IOT", "test", testRules, string(signature), "")
+	require.NoError(t, err)
+	assert.Equal(t, string(signature), pol.Signature)
+
+	_, err = pm.Update(ctx, "0", "PSA_IOT", "test", testRules+"\n", string(signature), "")
+	assert.ErrorIs(t, err, policy.ErrBadSignature)
+
+	_, err = pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", "")
+	assert.ErrorIs(t, err, policy.ErrUnsignedPolicy)
+
+	// only the signed version has been added
+	versions, err := pm.GetPolicies(ctx, "0", "PSA_IOT", "")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+func TestPolicyManager_Activate_tests(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	ctx := context.Background()
+
+	passing, err := pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", testTests)
+	require.NoError(t, err)
+
+	failing, err := pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", failingTests)
+	require.NoError(t, err)
+
+	untested, err := pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", "")
+	require.NoError(t, err)
+
+	// by default, the test results do not affect activation
+	require.NoError(t, pm.Activate(ctx, "0", "PSA_IOT", failing.UUID))
+	require.NoError(t, pm.Activate(ctx, "0", "PSA_IOT", untested.UUID))
+
+	pm.RequirePassingTests = true
+
+	err = pm.Activate(ctx, "0", "PSA_IOT", failing.UUID)
+	assert.ErrorIs(t, err, ErrTestsFailed)
+
+	err = pm.Activate(ctx, "0", "PSA_IOT", untested.UUID)
+	assert.ErrorIs(t, err, ErrTestsFailed)
+
+	require.NoError(t, pm.Activate(ctx, "0", "PSA_IOT", passing.UUID))
+
+	active, err := pm.GetActive(ctx, "0", "PSA_IOT")
+	require.NoError(t, err)
+	assert.Equal(t, passing.UUID, active.UUID)
+}
+
+func TestPolicyManager_bindings(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	ctx := context.Background()
+
+	pol, err := pm.Update(ctx, "0", "PSA_IOT", "test", testRules, "", "")
+	require.NoError(t, err)
+
+	_, err = pm.AddBinding(ctx, "0", "PSA_IOT", &policy.Binding{PolicyUUID: uuid.New()})
+	assert.ErrorIs(t, err, policy.ErrNoPolicy)
+
+	_, err = pm.AddBinding(ctx, "0", "CCA_SSD_PLATFORM", &policy.Binding{PolicyUUID: pol.UUID})
+	assert.ErrorContains(t, err, "Unsupported attestation scheme")
+
+	binding, err := pm.AddBinding(ctx, "0", "PSA_IOT", &policy.Binding{
+		Priority:   1,
+		Submod:     "PSA_IOT",
+		PolicyUUID: pol.UUID,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, binding.ID)
+
+	ret, err := pm.GetBinding(ctx, "0", "PSA_IOT", binding.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, ret.Priority)
+
+	err = pm.DeletePolicy(ctx, "0", "PSA_IOT", pol.UUID)
+	assert.ErrorIs(t, err, policy.ErrPolicyBound)
+
+	// deleting all the policies also deletes the bindings
+	require.NoError(t, pm.DeletePolicies(ctx, "0", "PSA_IOT"))
+
+	bindings, err := pm.GetBindings(ctx, "0", "PSA_IOT")
+	require.NoError(t, err)
+	assert.Empty(t, bindings)
+
+	err = pm.DeleteBinding(ctx, "0", "PSA_IOT", binding.ID)
+	assert.ErrorIs(t, err, policy.ErrNoBinding)
+}
+
+func TestPolicyManager_layers(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	ctx := context.Background()
+
+	baseline, err := pm.Update(ctx, BaselineTenantID, "", "baseline", testRules, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, policy.BaselinePolicyKey("opa"), baseline.StoreKey)
+
+	tenant, err := pm.Update(ctx, "0", "", "tenant", testRules, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, policy.TenantPolicyKey("0", "opa"), tenant.StoreKey)
+
+	require.NoError(t, pm.Activate(ctx, BaselineTenantID, "", baseline.UUID))
+	require.NoError(t, pm.Activate(ctx, "0", "", tenant.UUID))
+
+	active, err := pm.GetActive(ctx, BaselineTenantID, "")
+	require.NoError(t, err)
+	assert.Equal(t, baseline.UUID, active.UUID)
+
+	active, err = pm.GetActive(ctx, "0", "")
+	require.NoError(t, err)
+	assert.Equal(t, tenant.UUID, active.UUID)
+
+	// the layers are managed separately from the scheme's policy
+	_, err = pm.GetActive(ctx, "0", "PSA_IOT")
+	assert.ErrorIs(t, err, policy.ErrNoPolicy)
+
+	_, err = pm.AddBinding(ctx, "0", "", &policy.Binding{PolicyUUID: tenant.UUID})
+	assert.EqualError(t, err, "bindings are only supported for attestation schemes")
+}
+
+func TestPolicyManager_data(t *testing.T) {
+	pm := newTestPolicyManager(t)
+	defer pm.Store.Close()
+
+	ctx := context.Background()
+
+	_, err := pm.AddData(ctx, "0", "CCA_SSD_PLATFORM", map[string]interface{}{"a": 1.0})
+	assert.ErrorContains(t, err, "Unsupported attestation scheme")
+
+	_, err = pm.GetData(ctx, "0", "PSA_IOT")
+	assert.ErrorIs(t, err, policy.ErrNoData)
+
+	scheme, err := pm.AddData(ctx, "0", "PSA_IOT", map[string]interface{}{"a": 1.0})
+	require.NoError(t, err)
+
+	// each layer has its own data document
+	tenant, err := pm.AddData(ctx, "0", "", map[string]interface{}{"a": 2.0})
+	require.NoError(t, err)
+
+	baseline, err := pm.AddData(ctx, BaselineTenantID, "", map[string]interface{}{"a": 3.0})
+	require.NoError(t, err)
+
+	for _, expected := range []struct {
+		Tenant string
+		Scheme string
+		Doc    *policy.DataDocument
+	}{
+		{"0", "PSA_IOT", scheme},
+		{"0", "", tenant},
+		{BaselineTenantID, "", baseline},
+	} {
+		ret, err := pm.GetData(ctx, expected.Tenant, expected.Scheme)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Doc.UUID, ret.UUID)
+		assert.Equal(t, expected.Doc.Data, ret.Data)
+	}
+
+	updated, err := pm.AddData(ctx, "0", "PSA_IOT", map[string]interface{}{"a": 4.0})
+	require.NoError(t, err)
+
+	versions, err := pm.GetDataVersions(ctx, "0", "PSA_IOT")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, scheme.UUID, versions[0].UUID)
+	assert.Equal(t, updated.UUID, versions[1].UUID)
+
+	ret, err := pm.GetDataVersion(ctx, "0", "PSA_IOT", scheme.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, ret.Data["a"])
+
+	require.NoError(t, pm.DeleteData(ctx, "0", "PSA_IOT"))
+
+	_, err = pm.GetData(ctx, "0", "PSA_IOT")
+	assert.ErrorIs(t, err, policy.ErrNoData)
+
+	// other layers are not affected
+	_, err = pm.GetData(ctx, "0", "")
+	require.NoError(t, err)
+}
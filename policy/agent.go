@@ -5,7 +5,10 @@ package policy
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"github.com/veraison/ear"
 	"github.com/veraison/services/config"
@@ -17,8 +20,21 @@ var ErrBadResult = "could not create updated AttestationResult: %w from JSON %s"
 var ErrNoStatus = "backend returned outcome with no status field: %v"
 var ErrNoTV = "backend returned no trust-vector field, or its not a map[string]interface{}: %v"
 
+// ExplanationClaim is the policy claim under which backends report the
+// explanation of a decision, if they have been configured to explain them.
+const ExplanationClaim = "explanation"
+
 type cfg struct {
 	Backend string
+	// ActivePolicyTTL is not used by the agent, but by the VTS policy
+	// manager, which shares its configuration.
+	ActivePolicyTTL string `mapstructure:"active-policy-ttl" config:"zerodefault"`
+	// Signing is not used by the agent either, but by the policy managers
+	// of VTS and the management service, which verify policy signatures
+	// (see NewSignatureVerifier).
+	Signing map[string]interface{} `mapstructure:"signing" config:"zerodefault"`
+	// BackendConfigs are the entries named after backends, configuring them.
+	BackendConfigs map[string]interface{} `mapstructure:",remain"`
 }
 
 func (o cfg) Validate() error {
@@ -26,6 +42,18 @@ func (o cfg) Validate() error {
 		return fmt.Errorf("backend %q is not supported", o.Backend)
 	}
 
+	var unexpected []string
+	for k := range o.BackendConfigs {
+		if _, ok := backends[k]; !ok {
+			unexpected = append(unexpected, k)
+		}
+	}
+
+	if len(unexpected) > 0 {
+		sort.Strings(unexpected)
+		return fmt.Errorf("unexpected directives: %s", strings.Join(unexpected, ", "))
+	}
+
 	return nil
 }
 
@@ -40,7 +68,12 @@ func CreateAgent(v *viper.Viper, logger *zap.SugaredLogger) (IAgent, error) {
 		return nil, err
 	}
 
-	return &Agent{Backend: backends[cfg.Backend], logger: logger}, nil
+	backend := backends[cfg.Backend]()
+	if err := backend.Init(v.Sub(cfg.Backend)); err != nil {
+		return nil, fmt.Errorf("%s backend: %w", cfg.Backend, err)
+	}
+
+	return &Agent{Backend: backend, logger: logger}, nil
 }
 
 type Agent struct {
@@ -66,11 +99,15 @@ func (o *Agent) GetBackendName() string {
 // Evaluate the provided policy w.r.t. to the specified evidence and
 // endorsements, and return an updated AttestationResult. The policy may
 // overwrite the result status or any of the values in the result trust vector.
+// The data (which may be nil) is made available to the policy alongside the
+// evidence and endorsements (see DataDocument).
 func (o *Agent) Evaluate(
 	ctx context.Context,
 	sessionContext map[string]interface{},
 	scheme string,
 	policy *Policy,
+	data map[string]interface{},
+	history []map[string]interface{},
 	submod string,
 	appraisal *ear.Appraisal,
 	evidence *proto.EvidenceContext,
@@ -80,11 +117,21 @@ func (o *Agent) Evaluate(
 	resultMap := appraisal.AsMap()
 	appraisalUpdated := false
 
+	// candidate policies that have not been added to the store have no UUID,
+	// and so cannot be cached by the backend
+	var policyID string
+	if policy.UUID != uuid.Nil {
+		policyID = policy.UUID.String()
+	}
+
 	updatedByPolicy, err := o.Backend.Evaluate(
 		ctx,
 		sessionContext,
 		scheme,
+		policyID,
 		policy.Rules,
+		data,
+		history,
 		resultMap,
 		evidence.Evidence.AsMap(),
 		endorsements,
@@ -120,7 +167,18 @@ func (o *Agent) Evaluate(
 	updatedAddedClaims, ok := updatedByPolicy["ear.veraison.policy-claims"].(*map[string]interface{})
 	if ok {
 		appraisalUpdated = true
-		resultMap["ear.veraison.policy-claims"] = *updatedAddedClaims
+		claims := *updatedAddedClaims
+
+		if explanation, ok := claims[ExplanationClaim].(map[string]interface{}); ok {
+			explanation["policy-key"] = policy.StoreKey.String()
+			explanation["policy-uuid"] = policy.UUID.String()
+
+			o.logger.Infow("policy decision", "submod", submod, "explanation", explanation)
+
+			claims[ExplanationClaim] = appendExplanation(appraisal, explanation)
+		}
+
+		resultMap["ear.veraison.policy-claims"] = claims
 	}
 
 	if appraisalUpdated {
@@ -137,6 +195,25 @@ func (o *Agent) Evaluate(
 	}
 }
 
+// appendExplanation adds the explanation of a policy's decision to those of
+// the policies previously applied to the appraisal (e.g. in the baseline and
+// tenant layers), as policy claims set by a policy replace the previous ones.
+func appendExplanation(
+	appraisal *ear.Appraisal,
+	explanation map[string]interface{},
+) []interface{} {
+	var explanations []interface{}
+
+	if appraisal.VeraisonPolicyClaims != nil {
+		previous, ok := (*appraisal.VeraisonPolicyClaims)[ExplanationClaim].([]interface{})
+		if ok {
+			explanations = append(explanations, previous...)
+		}
+	}
+
+	return append(explanations, explanation)
+}
+
 // Validate performs basic validation of the provided policy rules, returning
 // an error if it fails. the nature of the validation performed is
 // backend-specific, however it would typically amount to a syntax check.
@@ -146,6 +223,34 @@ func (o *Agent) Validate(ctx context.Context, policyRules string) error {
 	return o.Backend.Validate(ctx, policyRules)
 }
 
+// Test runs the tests defined by the provided test module against the
+// provided policy rules, and returns the result of each test. An error is
+// returned if the tests could not be run (e.g. if they fail to compile);
+// failing tests are reported in the results.
+func (o *Agent) Test(ctx context.Context, policyRules, tests string) ([]TestCaseResult, error) {
+	rawResults, err := o.Backend.Test(ctx, policyRules, tests)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]TestCaseResult, len(rawResults))
+	for i, raw := range rawResults {
+		res, err := toTestCaseResult(raw)
+		if err != nil {
+			return nil, fmt.Errorf("bad test result %d from backend: %w", i, err)
+		}
+		results[i] = *res
+	}
+
+	return results, nil
+}
+
+// Invalidate discards anything the backend has cached for the policy version
+// with the specified UUID (e.g. because it is no longer active).
+func (o *Agent) Invalidate(policyID uuid.UUID) {
+	o.Backend.Invalidate(policyID.String())
+}
+
 func (o *Agent) GetBackend() IBackend {
 	return o.Backend
 }
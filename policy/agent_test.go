@@ -26,6 +26,13 @@ func Test_CreateAgent(t *testing.T) {
 
 	assert.Equal(t, "opa", agent.GetBackendName())
 
+	v.Set("backend", "cel")
+
+	agent, err = CreateAgent(v, log.Named("test"))
+	require.Nil(t, err)
+
+	assert.Equal(t, "cel", agent.GetBackendName())
+
 	v.Set("backend", "nope")
 
 	agent, err = CreateAgent(v, log.Named("test"))
@@ -33,6 +40,28 @@ func Test_CreateAgent(t *testing.T) {
 	assert.EqualError(t, err, `backend "nope" is not supported`)
 }
 
+func Test_CreateAgent_backend_config(t *testing.T) {
+	v := viper.New()
+	v.Set("backend", "opa")
+	v.Set("opa.explain", "trace")
+
+	agent, err := CreateAgent(v, log.Named("test"))
+	require.NoError(t, err)
+	assert.Equal(t, ExplainTrace, agent.(*Agent).Backend.(*OPA).Explain)
+
+	v.Set("opa.explain", "everything")
+
+	_, err = CreateAgent(v, log.Named("test"))
+	assert.ErrorContains(t, err, "opa backend: ")
+
+	v = viper.New()
+	v.Set("backend", "opa")
+	v.Set("nope.explain", "trace")
+
+	_, err = CreateAgent(v, log.Named("test"))
+	assert.ErrorContains(t, err, "unexpected directives: nope")
+}
+
 type AgentEvaluateTestVector struct {
 	Name              string
 	ExpectedError     string
@@ -166,7 +195,10 @@ func Test_Agent_Evaluate(t *testing.T) {
 			Evaluate(gomock.Eq(ctx),
 				gomock.Any(),
 				gomock.Any(),
+				gomock.Eq(""), // no UUID, so not cached
 				gomock.Eq(policy.Rules),
+				gomock.Nil(),
+				gomock.Nil(),
 				gomock.Any(),
 				gomock.Any(),
 				gomock.Eq(endorsements)).
@@ -176,7 +208,7 @@ func Test_Agent_Evaluate(t *testing.T) {
 		agent := &Agent{Backend: backend, logger: logger}
 		submod := "test"
 		res, err := agent.Evaluate(ctx, map[string]interface{}{}, "test", policy,
-			submod, appraisal, evidence, endorsements)
+			nil, nil, submod, appraisal, evidence, endorsements)
 
 		if v.ExpectedError == "" {
 			require.NoError(t, err)
@@ -208,3 +240,115 @@ func Test_Agent_Evaluate(t *testing.T) {
 		}
 	}
 }
+
+func Test_Agent_Test(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	backend := mock_deps.NewMockIBackend(ctrl)
+	backend.EXPECT().
+		Test(gomock.Eq(ctx), gomock.Eq("rules"), gomock.Eq("tests")).
+		Return([]map[string]interface{}{
+			{"package": "data.policy_test", "name": "test_ok", "status": "pass"},
+			{"package": "data.policy_test", "name": "test_nok", "status": "fail",
+				"detail": "failed at policy_test.rego:4"},
+		}, nil)
+
+	agent := &Agent{Backend: backend, logger: log.Named("test")}
+
+	results, err := agent.Test(ctx, "rules", "tests")
+	require.NoError(t, err)
+	assert.Equal(t, []TestCaseResult{
+		{Package: "data.policy_test", Name: "test_ok", Status: TestPass},
+		{Package: "data.policy_test", Name: "test_nok", Status: TestFail,
+			Detail: "failed at policy_test.rego:4"},
+	}, results)
+	assert.False(t, TestsPassed(results))
+	assert.True(t, TestsPassed(results[:1]))
+
+	backend.EXPECT().
+		Test(gomock.Eq(ctx), gomock.Eq("rules"), gomock.Eq("tests")).
+		Return([]map[string]interface{}{{"name": "test_bad", "status": "unknown"}}, nil)
+
+	_, err = agent.Test(ctx, "rules", "tests")
+	assert.EqualError(t, err, `bad test result 0 from backend: unexpected status "unknown"`)
+}
+
+func Test_Agent_Evaluate_policyID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	policy, err := NewPolicy(PolicyKey{"0", "PSA_IOT", "opa"}, "test", "opa", "rules")
+	require.NoError(t, err)
+
+	status := ear.TrustTierAffirming
+	appraisal := &ear.Appraisal{Status: &status, TrustVector: &ear.TrustVector{}}
+
+	backend := mock_deps.NewMockIBackend(ctrl)
+	backend.EXPECT().
+		Evaluate(gomock.Eq(ctx), gomock.Any(), gomock.Eq("PSA_IOT"),
+			gomock.Eq(policy.UUID.String()), gomock.Eq("rules"),
+			gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(map[string]interface{}{
+			"ear.status":                 "",
+			"ear.trustworthiness-vector": map[string]interface{}{},
+		}, nil)
+	backend.EXPECT().Invalidate(policy.UUID.String())
+
+	agent := &Agent{Backend: backend, logger: log.Named("test")}
+
+	_, err = agent.Evaluate(ctx, map[string]interface{}{}, "PSA_IOT", policy, nil,
+		nil, "PSA_IOT", appraisal, &proto.EvidenceContext{}, nil)
+	require.NoError(t, err)
+
+	agent.Invalidate(policy.UUID)
+}
+
+func Test_Agent_Evaluate_explanations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	policy, err := NewPolicy(PolicyKey{"0", "PSA_IOT", "opa"}, "test", "opa", "rules")
+	require.NoError(t, err)
+
+	// the explanation of a policy applied in a previous layer
+	previous := map[string]interface{}{"policy-key": "::opa", "reasons": []interface{}{}}
+	status := ear.TrustTierAffirming
+	appraisal := &ear.Appraisal{Status: &status, TrustVector: &ear.TrustVector{}}
+	appraisal.VeraisonPolicyClaims = &map[string]interface{}{
+		ExplanationClaim: []interface{}{previous},
+	}
+
+	backend := mock_deps.NewMockIBackend(ctrl)
+	backend.EXPECT().
+		Evaluate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+			gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(map[string]interface{}{
+			"ear.status":                 "",
+			"ear.trustworthiness-vector": map[string]interface{}{},
+			"ear.veraison.policy-claims": &map[string]interface{}{
+				ExplanationClaim: map[string]interface{}{
+					"reasons": []interface{}{"unexpected hardware version"},
+				},
+			},
+		}, nil)
+
+	agent := &Agent{Backend: backend, logger: log.Named("test")}
+
+	res, err := agent.Evaluate(ctx, map[string]interface{}{}, "PSA_IOT", policy, nil,
+		nil, "PSA_IOT", appraisal, &proto.EvidenceContext{}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{
+		previous,
+		map[string]interface{}{
+			"policy-key":  "0:PSA_IOT:opa",
+			"policy-uuid": policy.UUID.String(),
+			"reasons":     []interface{}{"unexpected hardware version"},
+		},
+	}, (*res.VeraisonPolicyClaims)[ExplanationClaim])
+}
@@ -5,8 +5,11 @@ package policy
 // DefaultBackend will be used if backend is not explicitly specfied
 var DefaultBackend = "opa"
 
-var backends = map[string]IBackend{
-	"opa": &OPA{},
+// backends maps the names of the supported backends onto functions creating
+// new (uninitialized) instances of them.
+var backends = map[string]func() IBackend{
+	"opa": func() IBackend { return &OPA{} },
+	"cel": func() IBackend { return &CEL{} },
 }
 
 // IsValidAgentBackend returns True iff the specified string names a valid backend.
@@ -0,0 +1,153 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/veraison/services/handler"
+)
+
+// BindingsKeyName is the name of the store key under which the bindings for a
+// tenant's scheme are kept. It cannot clash with the name of a policy engine.
+const BindingsKeyName = "@bindings"
+
+var ErrNoBinding = errors.New("no binding found")
+var ErrPolicyBound = errors.New("policy is bound")
+
+// Binding selects a specific policy version for the appraisals that match
+// its criteria, in place of the active policy for the scheme. Criteria that
+// are not set match any appraisal; a binding with no criteria matches all
+// appraisals.
+type Binding struct {
+	// ID is the unique identifier of the binding.
+	ID uuid.UUID `json:"id"`
+
+	// CTime is the creation time of the binding.
+	CTime time.Time `json:"ctime"`
+
+	// Priority determines the order in which bindings are considered.
+	// Bindings with higher priority are considered first; bindings with
+	// the same priority are considered in the order they were created.
+	Priority int `json:"priority"`
+
+	// MediaType is the media type of the evidence. Media type parameters
+	// are taken into account.
+	MediaType string `json:"media-type,omitempty"`
+
+	// Submod is the name of the submod being appraised.
+	Submod string `json:"submod,omitempty"`
+
+	// Attributes maps the paths of claims in the evidence onto their
+	// expected values. A path is the slash-separated sequence of names
+	// leading to a claim in nested maps (e.g. "psa-implementation-id", or
+	// "a/b/c").
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// PolicyUUID is the UUID of the policy version applied to the matching
+	// appraisals.
+	PolicyUUID uuid.UUID `json:"policy-uuid"`
+}
+
+// NewBinding creates a new Binding to the policy version with the specified
+// UUID. Its criteria must be set before it is added to the store.
+func NewBinding(policyID uuid.UUID) (*Binding, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Binding{ID: id, CTime: time.Now(), PolicyUUID: policyID}, nil
+}
+
+// Validate returns an error if the binding is invalid.
+func (o Binding) Validate() error {
+	if o.PolicyUUID == uuid.Nil {
+		return errors.New("policy UUID not set")
+	}
+
+	if o.MediaType != "" {
+		if _, _, err := mime.ParseMediaType(o.MediaType); err != nil {
+			return fmt.Errorf("bad media type %q: %w", o.MediaType, err)
+		}
+	}
+
+	for path := range o.Attributes {
+		if path == "" {
+			return errors.New("empty attribute path")
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true if the appraisal of the specified submod of evidence
+// with the specified media type and claims matches the criteria of the
+// binding.
+func (o Binding) Matches(mediaType, submod string, evidence map[string]interface{}) bool {
+	if o.MediaType != "" && !mediaTypesEqual(o.MediaType, mediaType) {
+		return false
+	}
+
+	if o.Submod != "" && o.Submod != submod {
+		return false
+	}
+
+	for path, expected := range o.Attributes {
+		actual, ok := handler.LookupClaim(evidence, path)
+		if !ok || !reflect.DeepEqual(actual, expected) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SelectBinding returns the first of the (sorted) bindings that matches the
+// specified appraisal, or nil if none of them do.
+func SelectBinding(
+	bindings []*Binding,
+	mediaType string,
+	submod string,
+	evidence map[string]interface{},
+) *Binding {
+	for _, b := range bindings {
+		if b.Matches(mediaType, submod, evidence) {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// SortBindings sorts the bindings in the order in which they are considered:
+// highest priority first, and then oldest first.
+func SortBindings(bindings []*Binding) {
+	sort.SliceStable(bindings, func(i, j int) bool {
+		if bindings[i].Priority != bindings[j].Priority {
+			return bindings[i].Priority > bindings[j].Priority
+		}
+
+		return bindings[i].CTime.Before(bindings[j].CTime)
+	})
+}
+
+func mediaTypesEqual(a, b string) bool {
+	aType, aParams, err := mime.ParseMediaType(a)
+	if err != nil {
+		return false
+	}
+
+	bType, bParams, err := mime.ParseMediaType(b)
+	if err != nil {
+		return false
+	}
+
+	return aType == bType && reflect.DeepEqual(aParams, bParams)
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Binding_Matches(t *testing.T) {
+	evidence := map[string]interface{}{
+		"psa-implementation-id": "YWNtZS1pbXBsZW1lbnRhdGlvbi1pZC0wMDAwMDAwMDE=",
+		"psa-client-id":         float64(1),
+		"nested": map[string]interface{}{
+			"node-class": "edge",
+		},
+	}
+
+	mediaType := `application/eat-cwt; profile="http://arm.com/psa/2.0.0"`
+
+	vectors := []struct {
+		Name     string
+		Binding  Binding
+		Expected bool
+	}{
+		{"no criteria", Binding{}, true},
+		{"media type", Binding{MediaType: mediaType}, true},
+		{
+			"media type with different case and spacing",
+			Binding{MediaType: `Application/EAT-CWT;profile="http://arm.com/psa/2.0.0"`},
+			true,
+		},
+		{
+			"media type with different profile",
+			Binding{MediaType: `application/eat-cwt; profile="http://arm.com/psa/1.0.0"`},
+			false,
+		},
+		{"submod", Binding{Submod: "PSA_IOT"}, true},
+		{"other submod", Binding{Submod: "CCA_REALM"}, false},
+		{
+			"attributes",
+			Binding{Attributes: map[string]interface{}{
+				"psa-implementation-id": "YWNtZS1pbXBsZW1lbnRhdGlvbi1pZC0wMDAwMDAwMDE=",
+				"psa-client-id":         float64(1),
+			}},
+			true,
+		},
+		{
+			"nested attribute",
+			Binding{Attributes: map[string]interface{}{"nested/node-class": "edge"}},
+			true,
+		},
+		{
+			"attribute with other value",
+			Binding{Attributes: map[string]interface{}{"psa-client-id": float64(2)}},
+			false,
+		},
+		{
+			"missing attribute",
+			Binding{Attributes: map[string]interface{}{"nested/node-id": "edge"}},
+			false,
+		},
+		{
+			"all criteria, one not matching",
+			Binding{
+				MediaType:  mediaType,
+				Submod:     "CCA_REALM",
+				Attributes: map[string]interface{}{"nested/node-class": "edge"},
+			},
+			false,
+		},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			assert.Equal(t, v.Expected, v.Binding.Matches(mediaType, "PSA_IOT", evidence))
+		})
+	}
+}
+
+func Test_Binding_Validate(t *testing.T) {
+	b, err := NewBinding(uuid.Nil)
+	require.NoError(t, err)
+	assert.EqualError(t, b.Validate(), "policy UUID not set")
+
+	b.PolicyUUID = uuid.New()
+	assert.NoError(t, b.Validate())
+
+	b.MediaType = "application/"
+	assert.ErrorContains(t, b.Validate(), `bad media type "application/"`)
+
+	b.MediaType = ""
+	b.Attributes = map[string]interface{}{"": "x"}
+	assert.EqualError(t, b.Validate(), "empty attribute path")
+}
+
+func Test_SelectBinding(t *testing.T) {
+	now := time.Now()
+
+	catchAll := &Binding{ID: uuid.New(), CTime: now, PolicyUUID: uuid.New()}
+	older := &Binding{ID: uuid.New(), CTime: now.Add(-time.Hour), Submod: "PSA_IOT",
+		PolicyUUID: uuid.New()}
+	newer := &Binding{ID: uuid.New(), CTime: now, Submod: "PSA_IOT",
+		PolicyUUID: uuid.New()}
+	high := &Binding{ID: uuid.New(), CTime: now, Priority: 10, Submod: "CCA_REALM",
+		PolicyUUID: uuid.New()}
+
+	bindings := []*Binding{catchAll, newer, high, older}
+	SortBindings(bindings)
+	assert.Equal(t, []*Binding{high, older, catchAll, newer}, bindings)
+
+	assert.Equal(t, high, SelectBinding(bindings, "", "CCA_REALM", nil))
+	assert.Equal(t, older, SelectBinding(bindings, "", "PSA_IOT", nil))
+	assert.Equal(t, catchAll, SelectBinding(bindings, "", "CCA_PLATFORM", nil))
+	assert.Nil(t, SelectBinding(bindings[:2], "", "CCA_PLATFORM", nil))
+}
@@ -0,0 +1,555 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/types"
+)
+
+// Lookup returns the values stored under the specified key. If nothing is
+// stored under the key, it returns an empty slice (rather than an error).
+type Lookup func(key string) ([]string, error)
+
+type lookupContextKey int
+
+const (
+	endorsementLookupKey lookupContextKey = iota
+	trustAnchorLookupKey
+)
+
+// WithEndorsementLookup returns a copy of the context with the lookup used by
+// the veraison.endorsements() built-in to obtain endorsements.
+func WithEndorsementLookup(ctx context.Context, lookup Lookup) context.Context {
+	return context.WithValue(ctx, endorsementLookupKey, lookup)
+}
+
+// WithTrustAnchorLookup returns a copy of the context with the lookup used by
+// the veraison.x509_verify_chain() built-in to obtain trust anchors.
+func WithTrustAnchorLookup(ctx context.Context, lookup Lookup) context.Context {
+	return context.WithValue(ctx, trustAnchorLookupKey, lookup)
+}
+
+func getLookup(ctx context.Context, key lookupContextKey) (Lookup, error) {
+	if ctx != nil {
+		if lookup, ok := ctx.Value(key).(Lookup); ok && lookup != nil {
+			return lookup, nil
+		}
+	}
+
+	switch key {
+	case endorsementLookupKey:
+		return nil, errors.New("endorsement lookup not available")
+	case trustAnchorLookupKey:
+		return nil, errors.New("trust anchor lookup not available")
+	default:
+		return nil, fmt.Errorf("unexpected lookup: %d", key)
+	}
+}
+
+// The Veraison built-in functions are registered with OPA globally, so that
+// they are available to policies however they are compiled (see
+// compilePolicy, Validate, and Test).
+func init() {
+	rego.RegisterBuiltin2(
+		&rego.Function{
+			Name: "veraison.semver_cmp",
+			Decl: types.NewFunction(types.Args(types.S, types.S), types.N),
+		},
+		builtinSemverCmp,
+	)
+
+	rego.RegisterBuiltin2(
+		&rego.Function{
+			Name: "veraison.svn_cmp",
+			Decl: types.NewFunction(types.Args(types.A, types.A), types.N),
+		},
+		builtinSVNCmp,
+	)
+
+	rego.RegisterBuiltin2(
+		&rego.Function{
+			Name: "veraison.digest_equal",
+			Decl: types.NewFunction(types.Args(types.S, types.S), types.B),
+		},
+		builtinDigestEqual,
+	)
+
+	rego.RegisterBuiltin1(
+		&rego.Function{
+			Name:    "veraison.endorsements",
+			Decl:    types.NewFunction(types.Args(types.S), types.NewArray(nil, types.A)),
+			Memoize: true,
+		},
+		builtinEndorsements,
+	)
+
+	rego.RegisterBuiltin2(
+		&rego.Function{
+			Name:    "veraison.x509_verify_chain",
+			Decl:    types.NewFunction(types.Args(types.A, types.S), types.B),
+			Memoize: true,
+		},
+		builtinX509VerifyChain,
+	)
+}
+
+func builtinSemverCmp(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+	var sa, sb string
+
+	if err := ast.As(a.Value, &sa); err != nil {
+		return nil, err
+	}
+
+	if err := ast.As(b.Value, &sb); err != nil {
+		return nil, err
+	}
+
+	va, err := parseSemver(sa)
+	if err != nil {
+		return nil, err
+	}
+
+	vb, err := parseSemver(sb)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.IntNumberTerm(va.Compare(vb)), nil
+}
+
+func builtinSVNCmp(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+	na, err := parseSVN(a.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	nb, err := parseSVN(b.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.IntNumberTerm(na.Cmp(nb)), nil
+}
+
+func builtinDigestEqual(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+	var sa, sb string
+
+	if err := ast.As(a.Value, &sa); err != nil {
+		return nil, err
+	}
+
+	if err := ast.As(b.Value, &sb); err != nil {
+		return nil, err
+	}
+
+	da, err := decodeDigest(sa)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := decodeDigest(sb)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.BooleanTerm(string(da) == string(db)), nil
+}
+
+func builtinEndorsements(bctx rego.BuiltinContext, key *ast.Term) (*ast.Term, error) {
+	var k string
+
+	if err := ast.As(key.Value, &k); err != nil {
+		return nil, err
+	}
+
+	lookup, err := getLookup(bctx.Context, endorsementLookupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := lookup(k)
+	if err != nil {
+		return nil, err
+	}
+
+	endorsements := make([]interface{}, 0, len(values))
+	for i, value := range values {
+		var endorsement interface{}
+		if err := json.Unmarshal([]byte(value), &endorsement); err != nil {
+			return nil, fmt.Errorf("endorsement %d under %q: %w", i, k, err)
+		}
+
+		endorsements = append(endorsements, endorsement)
+	}
+
+	ret, err := ast.InterfaceToValue(endorsements)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.NewTerm(ret), nil
+}
+
+func builtinX509VerifyChain(bctx rego.BuiltinContext, chain, key *ast.Term) (*ast.Term, error) {
+	var k string
+
+	if err := ast.As(key.Value, &k); err != nil {
+		return nil, err
+	}
+
+	certs, err := parseCertChain(chain.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup, err := getLookup(bctx.Context, trustAnchorLookupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := lookup(k)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	numRoots := 0
+
+	for _, value := range values {
+		taCerts, err := trustAnchorCerts(value)
+		if err != nil {
+			return nil, fmt.Errorf("trust anchor under %q: %w", k, err)
+		}
+
+		for _, cert := range taCerts {
+			roots.AddCert(cert)
+			numRoots++
+		}
+	}
+
+	if numRoots == 0 {
+		return ast.BooleanTerm(false), nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+
+	return ast.BooleanTerm(err == nil), nil
+}
+
+// semver is a semantic version (https://semver.org). Build metadata is not
+// retained, as it does not take part in comparisons.
+type semver struct {
+	Core       [3]uint64
+	PreRelease []string
+}
+
+// parseSemver parses a semantic version. The leading "v" and the minor and
+// patch versions are optional (missing versions are taken to be 0), so that
+// "v1.2" is the same as "1.2.0".
+func parseSemver(s string) (*semver, error) {
+	var ret semver
+
+	text := strings.TrimPrefix(s, "v")
+
+	if i := strings.IndexByte(text, '+'); i != -1 {
+		if text[i+1:] == "" {
+			return nil, fmt.Errorf("bad semver %q: empty build metadata", s)
+		}
+		text = text[:i]
+	}
+
+	if i := strings.IndexByte(text, '-'); i != -1 {
+		ret.PreRelease = strings.Split(text[i+1:], ".")
+		for _, ident := range ret.PreRelease {
+			if ident == "" {
+				return nil, fmt.Errorf("bad semver %q: empty pre-release identifier", s)
+			}
+		}
+		text = text[:i]
+	}
+
+	parts := strings.Split(text, ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("bad semver %q: too many version numbers", s)
+	}
+
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad semver %q: %q is not a version number", s, part)
+		}
+		ret.Core[i] = n
+	}
+
+	return &ret, nil
+}
+
+// Compare returns -1, 0, or 1 depending on whether the version is lower than,
+// equal to, or higher than the other, following the precedence rules of
+// semantic versioning.
+func (o semver) Compare(other *semver) int {
+	for i := range o.Core {
+		if o.Core[i] < other.Core[i] {
+			return -1
+		} else if o.Core[i] > other.Core[i] {
+			return 1
+		}
+	}
+
+	// a pre-release has lower precedence than the associated normal version
+	switch {
+	case len(o.PreRelease) == 0 && len(other.PreRelease) == 0:
+		return 0
+	case len(o.PreRelease) == 0:
+		return 1
+	case len(other.PreRelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(o.PreRelease) && i < len(other.PreRelease); i++ {
+		if c := comparePreRelease(o.PreRelease[i], other.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(o.PreRelease) < len(other.PreRelease):
+		return -1
+	case len(o.PreRelease) > len(other.PreRelease):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparePreRelease compares pre-release identifiers: numeric identifiers are
+// compared numerically and have lower precedence than alphanumeric ones, which
+// are compared lexically.
+func comparePreRelease(a, b string) int {
+	na, aErr := strconv.ParseUint(a, 10, 64)
+	nb, bErr := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		default:
+			return 0
+		}
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// parseSVN parses a security version number, which may be either a number, or
+// a string containing a decimal number. SVNs are non-negative integers.
+func parseSVN(v ast.Value) (*big.Int, error) {
+	var text string
+
+	switch t := v.(type) {
+	case ast.Number:
+		text = string(t)
+	case ast.String:
+		text = strings.TrimSpace(string(t))
+	default:
+		return nil, fmt.Errorf("bad SVN %v: must be a number or a string", v)
+	}
+
+	n, ok := new(big.Int).SetString(text, 10)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("bad SVN %v: must be a non-negative integer", v)
+	}
+
+	return n, nil
+}
+
+// decodeDigest decodes a digest encoded as hex (optionally with a "0x"
+// prefix), or, failing that, as base64 (either the standard or the URL-safe
+// alphabet, with or without padding).
+func decodeDigest(s string) ([]byte, error) {
+	text := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if text == "" {
+		return nil, errors.New("empty digest")
+	}
+
+	if b, err := hex.DecodeString(text); err == nil {
+		return b, nil
+	}
+
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("bad digest %q: neither hex nor base64", s)
+}
+
+// parseCertChain parses a certificate chain, leaf first, that is either an
+// array of certificates, each PEM or base64-encoded DER, or a string
+// containing a sequence of PEM certificates.
+func parseCertChain(v ast.Value) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	switch t := v.(type) {
+	case ast.String:
+		parsed, err := parsePEMCerts(string(t))
+		if err != nil {
+			return nil, err
+		}
+		certs = parsed
+	case *ast.Array:
+		for i := 0; i < t.Len(); i++ {
+			s, ok := t.Elem(i).Value.(ast.String)
+			if !ok {
+				return nil, fmt.Errorf("certificate %d: must be a string", i)
+			}
+
+			cert, err := parseCert(string(s))
+			if err != nil {
+				return nil, fmt.Errorf("certificate %d: %w", i, err)
+			}
+
+			certs = append(certs, cert)
+		}
+	default:
+		return nil, fmt.Errorf("bad certificate chain %v: must be an array or a string", v)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("empty certificate chain")
+	}
+
+	return certs, nil
+}
+
+func parseCert(s string) (*x509.Certificate, error) {
+	if strings.Contains(s, "-----BEGIN") {
+		certs, err := parsePEMCerts(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(certs) != 1 {
+			return nil, fmt.Errorf("expected 1 certificate, found %d", len(certs))
+		}
+
+		return certs[0], nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+// parsePEMCerts parses the certificates among the PEM blocks in the string;
+// blocks of other types are skipped.
+func parsePEMCerts(s string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := []byte(s)
+	for {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// trustAnchorCerts returns the PEM certificates found in the string values
+// (at any depth) of a stored trust anchor.
+func trustAnchorCerts(value string) ([]*x509.Certificate, error) {
+	var ta interface{}
+	if err := json.Unmarshal([]byte(value), &ta); err != nil {
+		// not JSON, so it may be the certificate itself
+		return parsePEMCerts(value)
+	}
+
+	var certs []*x509.Certificate
+
+	var walk func(v interface{}) error
+	walk = func(v interface{}) error {
+		switch t := v.(type) {
+		case string:
+			parsed, err := parsePEMCerts(t)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, parsed...)
+		case map[string]interface{}:
+			for _, elem := range t {
+				if err := walk(elem); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, elem := range t {
+				if err := walk(elem); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(ta); err != nil {
+		return nil, err
+	}
+
+	return certs, nil
+}
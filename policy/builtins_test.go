@@ -0,0 +1,303 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/ear"
+)
+
+func evalBuiltin(ctx context.Context, query string) (interface{}, error) {
+	rs, err := rego.New(
+		rego.Query(query),
+		rego.StrictBuiltinErrors(true),
+	).Eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("undefined: %s", query)
+	}
+
+	return rs[0].Expressions[0].Value, nil
+}
+
+func Test_Builtin_semver_cmp(t *testing.T) {
+	vectors := []struct {
+		A, B     string
+		Expected json.Number
+	}{
+		{"1.0.0", "1.0.0", "0"},
+		{"v1.2", "1.2.0", "0"},
+		{"3.4.2", "3.5.1", "-1"},
+		{"3.10", "3.9.9", "1"},
+		{"1.0.0-alpha", "1.0.0", "-1"},
+		{"1.0.0-alpha", "1.0.0-alpha.1", "-1"},
+		{"1.0.0-alpha.beta", "1.0.0-alpha.1", "1"},
+		{"1.0.0-beta.11", "1.0.0-beta.2", "1"},
+		{"1.0.0-rc.1", "1.0.0-beta.11", "1"},
+		{"1.0.0+build.1", "1.0.0+build.2", "0"},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.A+" "+v.B, func(t *testing.T) {
+			res, err := evalBuiltin(context.Background(),
+				fmt.Sprintf("veraison.semver_cmp(%q, %q)", v.A, v.B))
+			require.NoError(t, err)
+			assert.Equal(t, v.Expected, res)
+		})
+	}
+
+	for _, bad := range []string{"", "1.x", "1.2.3.4", "1.0.0-", "1.0.0-a..b", "1.0+"} {
+		_, err := evalBuiltin(context.Background(),
+			fmt.Sprintf("veraison.semver_cmp(%q, %q)", bad, "1.0.0"))
+		assert.ErrorContains(t, err, "bad semver", bad)
+	}
+}
+
+func Test_Builtin_svn_cmp(t *testing.T) {
+	vectors := []struct {
+		A, B     string
+		Expected json.Number
+	}{
+		{`1`, `1`, "0"},
+		{`"2"`, `10`, "-1"},
+		{`"18446744073709551616"`, `18446744073709551615`, "1"},
+	}
+
+	for _, v := range vectors {
+		res, err := evalBuiltin(context.Background(),
+			fmt.Sprintf("veraison.svn_cmp(%s, %s)", v.A, v.B))
+		require.NoError(t, err)
+		assert.Equal(t, v.Expected, res)
+	}
+
+	for _, bad := range []string{`-1`, `1.5`, `"one"`, `true`} {
+		_, err := evalBuiltin(context.Background(),
+			fmt.Sprintf("veraison.svn_cmp(%s, 1)", bad))
+		assert.ErrorContains(t, err, "bad SVN", bad)
+	}
+}
+
+func Test_Builtin_digest_equal(t *testing.T) {
+	digest := []byte{0xde, 0xad, 0xbe, 0xef, 0xfe}
+
+	encodings := []string{
+		"deadbeeffe",
+		"0xDEADBEEFFE",
+		base64.StdEncoding.EncodeToString(digest),
+		base64.RawURLEncoding.EncodeToString(digest),
+	}
+
+	for _, a := range encodings {
+		for _, b := range encodings {
+			res, err := evalBuiltin(context.Background(),
+				fmt.Sprintf("veraison.digest_equal(%q, %q)", a, b))
+			require.NoError(t, err)
+			assert.Equal(t, true, res, "%s %s", a, b)
+		}
+	}
+
+	res, err := evalBuiltin(context.Background(),
+		`veraison.digest_equal("deadbeeffe", "deadbeefff")`)
+	require.NoError(t, err)
+	assert.Equal(t, false, res)
+
+	_, err = evalBuiltin(context.Background(), `veraison.digest_equal("", "00")`)
+	assert.ErrorContains(t, err, "empty digest")
+
+	_, err = evalBuiltin(context.Background(), `veraison.digest_equal("not a digest!", "00")`)
+	assert.ErrorContains(t, err, "neither hex nor base64")
+}
+
+func Test_Builtin_endorsements(t *testing.T) {
+	stored := map[string][]string{
+		"psa-iot://0/AQI=/": {
+			`{"attributes": {"psa.version": "3.5.1"}}`,
+			`{"attributes": {"psa.version": "1.0.0"}}`,
+		},
+	}
+
+	lookup := func(key string) ([]string, error) {
+		return stored[key], nil
+	}
+
+	_, err := evalBuiltin(context.Background(), `veraison.endorsements("psa-iot://0/AQI=/")`)
+	assert.ErrorContains(t, err, "endorsement lookup not available")
+
+	ctx := WithEndorsementLookup(context.Background(), lookup)
+
+	res, err := evalBuiltin(ctx, `veraison.endorsements("psa-iot://0/AQI=/")[1].attributes`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"psa.version": "1.0.0"}, res)
+
+	res, err = evalBuiltin(ctx, `veraison.endorsements("psa-iot://0/AwQ=/")`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{}, res)
+
+	ctx = WithEndorsementLookup(context.Background(), func(string) ([]string, error) {
+		return []string{"{"}, nil
+	})
+
+	_, err = evalBuiltin(ctx, `veraison.endorsements("psa-iot://0/AQI=/")`)
+	assert.ErrorContains(t, err, `endorsement 0 under "psa-iot://0/AQI=/"`)
+}
+
+type testCA struct {
+	Key  *ecdsa.PrivateKey
+	Cert *x509.Certificate
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCA, isCA bool) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.Cert, parent.Key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{Key: key, Cert: cert}
+}
+
+func certToPEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func Test_Builtin_x509_verify_chain(t *testing.T) {
+	root := newTestCert(t, "root", nil, true)
+	intermediate := newTestCert(t, "intermediate", root, true)
+	leaf := newTestCert(t, "leaf", intermediate, false)
+	other := newTestCert(t, "other", nil, true)
+
+	taValue := func(cert *x509.Certificate) string {
+		ta, err := json.Marshal(map[string]interface{}{
+			"scheme": "TEST",
+			"attributes": map[string]interface{}{
+				"test.root-cert": certToPEM(cert),
+			},
+		})
+		require.NoError(t, err)
+		return string(ta)
+	}
+
+	stored := map[string][]string{
+		"test://0/root":  {taValue(root.Cert)},
+		"test://0/other": {taValue(other.Cert)},
+		"test://0/raw":   {certToPEM(root.Cert)},
+	}
+
+	ctx := WithTrustAnchorLookup(context.Background(), func(key string) ([]string, error) {
+		return stored[key], nil
+	})
+
+	chainArray := fmt.Sprintf("[%q, %q]",
+		base64.StdEncoding.EncodeToString(leaf.Cert.Raw), certToPEM(intermediate.Cert))
+	chainString := fmt.Sprintf("%q", certToPEM(leaf.Cert)+certToPEM(intermediate.Cert))
+
+	vectors := []struct {
+		Chain    string
+		Key      string
+		Expected bool
+	}{
+		{chainArray, "test://0/root", true},
+		{chainString, "test://0/root", true},
+		{chainString, "test://0/raw", true},
+		{chainString, "test://0/other", false},
+		{chainString, "test://0/missing", false},
+		// the intermediate is needed to build the chain
+		{fmt.Sprintf("%q", certToPEM(leaf.Cert)), "test://0/root", false},
+	}
+
+	for i, v := range vectors {
+		res, err := evalBuiltin(ctx,
+			fmt.Sprintf("veraison.x509_verify_chain(%s, %q)", v.Chain, v.Key))
+		require.NoError(t, err, i)
+		assert.Equal(t, v.Expected, res, i)
+	}
+
+	_, err := evalBuiltin(ctx, `veraison.x509_verify_chain([], "test://0/root")`)
+	assert.ErrorContains(t, err, "empty certificate chain")
+
+	_, err = evalBuiltin(ctx, `veraison.x509_verify_chain(["AAAA"], "test://0/root")`)
+	assert.ErrorContains(t, err, "certificate 0")
+
+	_, err = evalBuiltin(context.Background(),
+		fmt.Sprintf("veraison.x509_verify_chain(%s, %q)", chainString, "test://0/root"))
+	assert.ErrorContains(t, err, "trust anchor lookup not available")
+}
+
+func Test_OPA_Evaluate_builtins(t *testing.T) {
+	ctx := WithEndorsementLookup(context.Background(), func(key string) ([]string, error) {
+		if key != "psa-iot://0/AQI=/" {
+			return nil, nil
+		}
+		return []string{`{"attributes": {"psa.version": "3.5.1"}}`}, nil
+	})
+
+	pa, err := NewOPA(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	result, err := jsonFileToResultMap("test/inputs/psa-result.json")
+	require.NoError(t, err)
+
+	policy := `package policy
+
+executables = APPROVED_RT {
+	e := veraison.endorsements("psa-iot://0/AQI=/")[_]
+	veraison.semver_cmp(e.attributes["psa.version"], "3.5") >= 0
+} else = UNSAFE_RT
+`
+	res, err := pa.Evaluate(ctx, map[string]interface{}{}, "PSA_IOT", "", policy,
+		nil, nil, result, map[string]interface{}{}, nil)
+	require.NoError(t, err)
+
+	tv := res["ear.trustworthiness-vector"].(map[string]interface{})
+	assert.Equal(t, ear.ApprovedRuntimeClaim, tv["executables"])
+
+	// without a lookup, the built-in is undefined rather than failing the
+	// evaluation
+	res, err = pa.Evaluate(context.Background(), map[string]interface{}{}, "PSA_IOT", "",
+		policy, nil, nil, result, map[string]interface{}{}, nil)
+	require.NoError(t, err)
+
+	tv = res["ear.trustworthiness-vector"].(map[string]interface{})
+	assert.Equal(t, ear.UnsafeRuntimeClaim, tv["executables"])
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the maximum number of compiled policies cached by a
+// backend.
+const DefaultCacheSize = 128
+
+// compiledCache caches the compiled forms of policies by policy ID. Cached
+// policies are never stale, as the rules of a policy version never change;
+// they are only evicted (least recently used first) to bound the size of the
+// cache, or when invalidated.
+type compiledCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry[T]
+}
+
+type cacheEntry[T any] struct {
+	compiled T
+	lastUsed time.Time
+}
+
+// Get returns the compiled form of the policy with the specified ID and
+// rules, compiling it with the provided function if it has not been cached.
+// Policies with an empty ID (e.g. candidate policies that have not been added
+// to the store) are not cached.
+func (o *compiledCache[T]) Get(
+	policyID string,
+	policy string,
+	compile func(string) (T, error),
+) (T, error) {
+	if policyID == "" {
+		return compile(policy)
+	}
+
+	o.mu.Lock()
+	cached, ok := o.entries[policyID]
+	if ok {
+		cached.lastUsed = time.Now()
+	}
+	o.mu.Unlock()
+
+	if ok {
+		return cached.compiled, nil
+	}
+
+	compiled, err := compile(policy)
+	if err != nil {
+		return compiled, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.entries == nil {
+		o.entries = make(map[string]*cacheEntry[T])
+	}
+
+	if len(o.entries) >= DefaultCacheSize {
+		o.evictLeastRecentlyUsed()
+	}
+
+	o.entries[policyID] = &cacheEntry[T]{compiled: compiled, lastUsed: time.Now()}
+
+	return compiled, nil
+}
+
+// Invalidate removes the policy with the specified ID from the cache.
+func (o *compiledCache[T]) Invalidate(policyID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.entries, policyID)
+}
+
+// Clear removes all the policies from the cache.
+func (o *compiledCache[T]) Clear() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = nil
+}
+
+// evictLeastRecentlyUsed removes the policy that has gone unused the longest
+// from the cache. o.mu must be held.
+func (o *compiledCache[T]) evictLeastRecentlyUsed() {
+	var oldestID string
+	var oldest time.Time
+
+	for id, cached := range o.entries {
+		if oldestID == "" || cached.lastUsed.Before(oldest) {
+			oldestID, oldest = id, cached.lastUsed
+		}
+	}
+
+	delete(o.entries, oldestID)
+}
@@ -0,0 +1,297 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/ext"
+	"github.com/spf13/viper"
+)
+
+var ErrBadCELResult = errors.New("bad result update from policy")
+
+// celConstants are the names of the trust tiers and trust claim values that
+// may be used by CEL policies. They are the same as those defined for OPA
+// policies (see opa.rego).
+var celConstants = map[string]int64{
+	"NO_CLAIM":           0,
+	"UNEXECTED_EVIDENCE": 1,
+
+	"AFFIRMING":       2,
+	"WARNING":         32,
+	"CONTRAINDICATED": 96,
+
+	"RECOGNIZED_INSTANCE":    2,
+	"UNTRUSTWORTHY_INSTANCE": 96,
+	"UNRECOGNIZED_INSTANCE":  97,
+
+	"APPROVED_CONFIG":      2,
+	"SAFE_CONFIG":          3,
+	"UNSAFE_CONFIG":        32,
+	"UNSUPPORTABLE_CONFIG": 96,
+
+	"APPROVED_RT":        2,
+	"APPROVED_BOOT":      3,
+	"UNSAFE_RT":          32,
+	"UNRECOGNIZED_RT":    33,
+	"CONTRAINDICATED_RT": 96,
+
+	"APPROVED_FS":        2,
+	"UNRECOGNIZED_FS":    32,
+	"CONTRAINDICATED_FS": 96,
+
+	"GENUINE_HW":         2,
+	"UNSAFE_HW":          32,
+	"CONTRAINDICATED_HW": 96,
+	"UNRECOGNIZED_HW":    97,
+
+	"ENCRYPTED_RT": 2,
+	"ISOLATED_RT":  32,
+	"VISIBLE_RT":   96,
+
+	"HW_ENCRYPTED_SECRETS": 2,
+	"SW_ENCRYPTED_SECRETS": 32,
+	"UNENCRYPTED_SECRETES": 96,
+
+	"TRUSTED_SOURCES":         2,
+	"UNTRUSTED_SOURCES":       32,
+	"CONTRAINDICATED_SOURCES": 96,
+}
+
+// CEL is a policy backend for policies written in the Common Expression
+// Language (https://github.com/google/cel-spec). A policy is a single
+// expression that evaluates to a map with (optionally) the updated "status",
+// the updated "trust-vector" claims, and the "added-claims".
+type CEL struct {
+	env *cel.Env
+
+	// compiled caches the programs of policies by policy ID.
+	compiled compiledCache[cel.Program]
+}
+
+func NewCEL(v *viper.Viper) (*CEL, error) {
+	var o CEL
+	if err := o.Init(v); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (o *CEL) Init(v *viper.Viper) error {
+	options := []cel.EnvOption{
+		cel.Variable("scheme", cel.StringType),
+		cel.Variable("session", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("result", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("evidence", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("endorsements", cel.ListType(cel.DynType)),
+		cel.Variable("data", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("history", cel.ListType(cel.DynType)),
+		ext.Strings(),
+		ext.Encoders(),
+	}
+
+	for name, value := range celConstants {
+		options = append(options, cel.Constant(name, cel.IntType, types.Int(value)))
+	}
+
+	env, err := cel.NewEnv(options...)
+	if err != nil {
+		return err
+	}
+
+	o.env = env
+
+	return nil
+}
+
+func (o *CEL) GetName() string {
+	return "cel"
+}
+
+func (o *CEL) Evaluate(
+	ctx context.Context,
+	sessionContext map[string]interface{},
+	scheme string,
+	policyID string,
+	policy string,
+	data map[string]interface{},
+	history []map[string]interface{},
+	result map[string]interface{},
+	evidence map[string]interface{},
+	endorsements []string,
+) (map[string]interface{}, error) {
+	input, err := constructInput(scheme, sessionContext, result, evidence, endorsements, history)
+	if err != nil {
+		return nil, fmt.Errorf("could not construct policy input: %w", err)
+	}
+
+	// all the variables must be defined, even if there is nothing to put in
+	// them
+	for _, name := range []string{"session", "result", "evidence"} {
+		if m, ok := input[name].(map[string]interface{}); !ok || m == nil {
+			input[name] = map[string]interface{}{}
+		}
+	}
+
+	if es, ok := input["endorsements"].([]map[string]interface{}); !ok || es == nil {
+		input["endorsements"] = []map[string]interface{}{}
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	input["data"] = data
+
+	program, err := o.compiled.Get(policyID, policy, o.compile)
+	if err != nil {
+		return nil, fmt.Errorf("could not Eval policy: %w", err)
+	}
+
+	out, _, err := program.ContextEval(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("could not Eval policy: %w", err)
+	}
+
+	value, err := celToNative(out)
+	if err != nil {
+		return nil, fmt.Errorf("policy returned bad update: %w", err)
+	}
+
+	rawUpdate, err := celUpdateValue(value)
+	if err != nil {
+		return nil, fmt.Errorf("policy returned bad update: %w", err)
+	}
+
+	resultUpdate, err := processUpdateValue(rawUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("policy returned bad update: %w", err)
+	}
+
+	return resultUpdate, nil
+}
+
+func (o *CEL) Validate(ctx context.Context, policy string) error {
+	_, err := o.compile(policy)
+	return err
+}
+
+// Test is not supported by the CEL backend.
+func (o *CEL) Test(
+	ctx context.Context,
+	policy string,
+	tests string,
+) ([]map[string]interface{}, error) {
+	return nil, errors.New("tests are not supported by the cel backend")
+}
+
+// Invalidate removes the policy with the specified ID from the cache of
+// compiled policies.
+func (o *CEL) Invalidate(policyID string) {
+	o.compiled.Invalidate(policyID)
+}
+
+func (o *CEL) Close() {
+	o.compiled.Clear()
+}
+
+func (o *CEL) compile(policy string) (cel.Program, error) {
+	if o.env == nil {
+		return nil, errors.New("backend not initialized")
+	}
+
+	ast, issues := o.env.Compile(policy)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	outputType := ast.OutputType()
+	if !outputType.IsExactType(cel.DynType) &&
+		!cel.MapType(cel.StringType, cel.DynType).IsAssignableType(outputType) {
+		return nil, fmt.Errorf("policy must evaluate to a map, but evaluates to %s",
+			outputType)
+	}
+
+	return o.env.Program(ast)
+}
+
+// celUpdateValue converts the value a CEL policy evaluated to into the
+// update expected from the OPA preamble's outcome (see processUpdateValue),
+// filling in whatever the policy did not set.
+func celUpdateValue(value interface{}) (map[string]interface{}, error) {
+	policyUpdate, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: expected a map, but got %T", ErrBadCELResult, value)
+	}
+
+	update := map[string]interface{}{
+		"status":       0,
+		"trust-vector": map[string]interface{}{},
+		"added-claims": map[string]interface{}{},
+	}
+
+	for k, v := range policyUpdate {
+		if _, ok := update[k]; !ok {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrBadCELResult, k)
+		}
+
+		update[k] = v
+	}
+
+	return update, nil
+}
+
+// celToNative converts the value produced by a CEL program into its native
+// Go (JSON-like) representation.
+func celToNative(val ref.Val) (interface{}, error) {
+	switch v := val.(type) {
+	case types.Null:
+		return nil, nil
+	case traits.Mapper:
+		ret := map[string]interface{}{}
+
+		it := v.Iterator()
+		for it.HasNext() == types.True {
+			key := it.Next()
+
+			name, ok := key.Value().(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: non-string map key %v", ErrBadCELResult, key)
+			}
+
+			elem, err := celToNative(v.Get(key))
+			if err != nil {
+				return nil, err
+			}
+
+			ret[name] = elem
+		}
+
+		return ret, nil
+	case traits.Lister:
+		size, ok := v.Size().(types.Int)
+		if !ok {
+			return nil, fmt.Errorf("%w: bad list size", ErrBadCELResult)
+		}
+
+		ret := make([]interface{}, 0, size)
+		for i := types.Int(0); i < size; i++ {
+			elem, err := celToNative(v.Get(i))
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, elem)
+		}
+
+		return ret, nil
+	default:
+		return val.Value(), nil
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/ear"
+)
+
+func celEvaluate(
+	t *testing.T,
+	pa *CEL,
+	policyID string,
+	policy string,
+	data map[string]interface{},
+	history []map[string]interface{},
+	evidencePath string,
+) (map[string]interface{}, error) {
+	result, err := jsonFileToResultMap("test/inputs/psa-result.json")
+	require.NoError(t, err)
+
+	evidence, err := jsonFileToMap(evidencePath)
+	require.NoError(t, err)
+
+	endorsements, err := jsonFileToStringSlice("test/inputs/psa-endorsements.json")
+	require.NoError(t, err)
+
+	return pa.Evaluate(context.Background(), map[string]interface{}{}, "PSA_IOT",
+		policyID, policy, data, history, result, evidence["evidence"].(map[string]interface{}),
+		endorsements)
+}
+
+func Test_CEL_GetName(t *testing.T) {
+	pa, err := NewCEL(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	assert.Equal(t, "cel", pa.GetName())
+}
+
+func Test_CEL_Evaluate(t *testing.T) {
+	pa, err := NewCEL(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	policy, err := os.ReadFile("test/policies/sw-up-to-dateness.cel")
+	require.NoError(t, err)
+
+	res, err := celEvaluate(t, pa, "", string(policy), nil, nil, "test/inputs/psa-evidence.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, ear.TrustTierWarning, *res["ear.status"].(*ear.TrustTier))
+	tv := res["ear.trustworthiness-vector"].(map[string]interface{})
+	assert.Equal(t, ear.UnsafeRuntimeClaim, tv["executables"])
+	assert.Equal(t, 0, tv["hardware"])
+	assert.Equal(t, &map[string]interface{}{"outdated-bl": true},
+		res["ear.veraison.policy-claims"])
+
+	res, err = celEvaluate(t, pa, "", string(policy), nil, nil,
+		"test/inputs/psa-evidence-updatedBL.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, ear.TrustTierNone, *res["ear.status"].(*ear.TrustTier))
+	tv = res["ear.trustworthiness-vector"].(map[string]interface{})
+	assert.Equal(t, ear.ApprovedRuntimeClaim, tv["executables"])
+	assert.Equal(t, &map[string]interface{}{}, res["ear.veraison.policy-claims"])
+}
+
+func Test_CEL_Evaluate_input(t *testing.T) {
+	pa, err := NewCEL(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	policy := `{
+		"added-claims": {
+			"scheme": scheme,
+			"bl-version": evidence["psa-software-components"][0]["version"],
+			"endorsed-bl": endorsements[0]["attributes"]["psa.version"],
+			"profile": result["eat_profile"],
+			"min-version": data["min-version"]
+		}
+	}`
+	data := map[string]interface{}{"min-version": "3.5"}
+
+	res, err := celEvaluate(t, pa, "", policy, data, nil, "test/inputs/psa-evidence.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, &map[string]interface{}{
+		"scheme":      "PSA_IOT",
+		"bl-version":  "3.4.2",
+		"endorsed-bl": "3.4.2",
+		"profile":     "tag:github.com,2023:veraison/ear",
+		"min-version": "3.5",
+	}, res["ear.veraison.policy-claims"])
+}
+
+func Test_CEL_Evaluate_history(t *testing.T) {
+	pa, err := NewCEL(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	history, err := jsonFileToMapSlice("test/inputs/psa-history.json")
+	require.NoError(t, err)
+
+	policy := `{
+		"added-claims": {
+			"attestations": size(history),
+			"last-bl-version": history[size(history) - 1].evidence["psa-software-components"][0].version
+		}
+	}`
+
+	res, err := celEvaluate(t, pa, "", policy, nil, history, "test/inputs/psa-evidence.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, &map[string]interface{}{
+		"attestations":    int64(2),
+		"last-bl-version": "3.5.1",
+	}, res["ear.veraison.policy-claims"])
+
+	// there may be no history
+	res, err = celEvaluate(t, pa, "", `{"added-claims": {"attestations": size(history)}}`,
+		nil, nil, "test/inputs/psa-evidence.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, &map[string]interface{}{"attestations": int64(0)},
+		res["ear.veraison.policy-claims"])
+}
+
+func Test_CEL_Evaluate_bad_update(t *testing.T) {
+	pa, err := NewCEL(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	_, err = celEvaluate(t, pa, "", `{"outcome": AFFIRMING}`, nil, nil,
+		"test/inputs/psa-evidence.json")
+	assert.ErrorContains(t, err, `unexpected entry "outcome"`)
+
+	_, err = celEvaluate(t, pa, "", `{"trust-vector": {"hardware": "bogus"}}`, nil, nil,
+		"test/inputs/psa-evidence.json")
+	assert.ErrorContains(t, err, `bad value`)
+
+	_, err = celEvaluate(t, pa, "", `{"trust-vector": {"hardware": evidence["nope"]}}`,
+		nil, nil, "test/inputs/psa-evidence.json")
+	assert.ErrorContains(t, err, "could not Eval policy")
+}
+
+func Test_CEL_Evaluate_cache(t *testing.T) {
+	pa, err := NewCEL(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	genuine := `{"trust-vector": {"hardware": GENUINE_HW}}`
+	unsafe := `{"trust-vector": {"hardware": UNSAFE_HW}}`
+
+	evaluate := func(policyID, policy string) ear.TrustClaim {
+		res, err := celEvaluate(t, pa, policyID, policy, nil, nil, "test/inputs/psa-evidence.json")
+		require.NoError(t, err)
+
+		tv := res["ear.trustworthiness-vector"].(map[string]interface{})
+		return tv["hardware"].(ear.TrustClaim)
+	}
+
+	assert.Equal(t, ear.GenuineHardwareClaim, evaluate("1", genuine))
+	assert.Equal(t, ear.GenuineHardwareClaim, evaluate("1", unsafe))
+
+	pa.Invalidate("1")
+	assert.Equal(t, ear.UnsafeHardwareClaim, evaluate("1", unsafe))
+
+	assert.Equal(t, ear.GenuineHardwareClaim, evaluate("", genuine))
+	assert.Len(t, pa.compiled.entries, 1)
+}
+
+func Test_CEL_Validate(t *testing.T) {
+	pa, err := NewCEL(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	ctx := context.Background()
+
+	for _, path := range []string{
+		"test/policies/psa-hw.cel",
+		"test/policies/sw-up-to-dateness.cel",
+	} {
+		policy, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		assert.NoError(t, pa.Validate(ctx, string(policy)), path)
+	}
+
+	err = pa.Validate(ctx, `{"trust-vector": {"hardware": GENUINE_HW}`)
+	assert.ErrorContains(t, err, "Syntax error")
+
+	err = pa.Validate(ctx, `{"trust-vector": {"hardware": UNKNOWN_HW}}`)
+	assert.ErrorContains(t, err, "undeclared reference to 'UNKNOWN_HW'")
+
+	err = pa.Validate(ctx, `GENUINE_HW`)
+	assert.EqualError(t, err, "policy must evaluate to a map, but evaluates to int")
+}
+
+func Test_CEL_Test(t *testing.T) {
+	pa, err := NewCEL(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	_, err = pa.Test(context.Background(), "{}", "")
+	assert.EqualError(t, err, "tests are not supported by the cel backend")
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DataKeyName is the name of the store key under which the versions of the
+// data document for a tenant's scheme are kept.
+const DataKeyName = "@data"
+
+// ReservedDataRoot is the name of the package policies are defined in. Data
+// documents cannot define it, as it would clash with the policy rules.
+const ReservedDataRoot = "policy"
+
+var ErrNoData = errors.New("no data document found")
+
+// DataDocument is a version of the data made available to policies (e.g.
+// allow-lists, deny-lists, or thresholds), allowing the data to be updated
+// without changing the policies.
+type DataDocument struct {
+	// UUID is the unique identifier of this version of the data document.
+	UUID uuid.UUID `json:"uuid"`
+
+	// CTime is the creation time of this version.
+	CTime time.Time `json:"ctime"`
+
+	// Data is the JSON object made available to policies. For OPA
+	// policies, its top-level entries are available under data.
+	Data map[string]interface{} `json:"data"`
+}
+
+// NewDataDocument creates a new version of a data document with the specified
+// data.
+func NewDataDocument(data map[string]interface{}) (*DataDocument, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataDocument{UUID: id, CTime: time.Now(), Data: data}, nil
+}
+
+// Validate returns an error if the data document is invalid.
+func (o DataDocument) Validate() error {
+	if o.Data == nil {
+		return errors.New("no data")
+	}
+
+	if _, ok := o.Data[ReservedDataRoot]; ok {
+		return fmt.Errorf("%q is reserved and cannot be defined by data", ReservedDataRoot)
+	}
+
+	return nil
+}
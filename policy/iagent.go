@@ -5,6 +5,7 @@ package policy
 import (
 	"context"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"github.com/veraison/ear"
 	"github.com/veraison/services/proto"
@@ -17,11 +18,15 @@ type IAgent interface {
 		appraisalContext map[string]interface{},
 		scheme string,
 		policy *Policy,
+		data map[string]interface{},
+		history []map[string]interface{},
 		submod string,
 		appraisal *ear.Appraisal,
 		evidence *proto.EvidenceContext,
 		endorsements []string,
 	) (*ear.Appraisal, error)
 	Validate(ctx context.Context, policyRules string) error
+	Test(ctx context.Context, policyRules string, tests string) ([]TestCaseResult, error)
+	Invalidate(policyID uuid.UUID)
 	Close()
 }
@@ -15,11 +15,16 @@ type IBackend interface {
 		ctx context.Context,
 		sessionContext map[string]interface{},
 		scheme string,
+		policyID string,
 		policy string,
+		data map[string]interface{},
+		history []map[string]interface{},
 		result map[string]interface{},
 		evidence map[string]interface{},
 		endorsements []string,
 	) (map[string]interface{}, error)
 	Validate(ctx context.Context, policy string) error
+	Test(ctx context.Context, policy string, tests string) ([]map[string]interface{}, error)
+	Invalidate(policyID string)
 	Close()
 }
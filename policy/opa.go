@@ -8,10 +8,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/tester"
+	"github.com/open-policy-agent/opa/topdown"
 	"github.com/spf13/viper"
 	"github.com/veraison/ear"
+	"github.com/veraison/services/config"
 	"github.com/veraison/services/log"
 )
 
@@ -20,7 +26,38 @@ var ErrBadOPAResult = errors.New("bad result update from policy")
 //go:embed opa.rego
 var preambleText string
 
+// Explanation modes. With ExplainReasons, the reasons a policy gives for its
+// decision (the "reasons" it defines) are added to the policy claims in the
+// result. With ExplainTrace, the rules of the policy that set the claims, and
+// any notes emitted by the policy with trace(), are added as well.
+const (
+	ExplainNone    = ""
+	ExplainReasons = "reasons"
+	ExplainTrace   = "trace"
+)
+
+type opaCfg struct {
+	Explain string `mapstructure:"explain" config:"zerodefault"`
+}
+
+func (o opaCfg) Validate() error {
+	switch o.Explain {
+	case ExplainNone, ExplainReasons, ExplainTrace:
+		return nil
+	default:
+		return fmt.Errorf("unexpected explain mode %q (must be %q or %q)",
+			o.Explain, ExplainReasons, ExplainTrace)
+	}
+}
+
 type OPA struct {
+	// Explain is the mode in which decisions are explained (ExplainNone,
+	// ExplainReasons, or ExplainTrace).
+	Explain string
+
+	// compiled caches the compilers of policies (compiled along with the
+	// preamble) by policy ID.
+	compiled compiledCache[*ast.Compiler]
 }
 
 func NewOPA(v *viper.Viper) (*OPA, error) {
@@ -32,6 +69,19 @@ func NewOPA(v *viper.Viper) (*OPA, error) {
 }
 
 func (o *OPA) Init(v *viper.Viper) error {
+	// the backend does not have to be configured
+	if v == nil {
+		v = viper.New()
+	}
+
+	var cfg opaCfg
+	loader := config.NewLoader(&cfg)
+	if err := loader.LoadFromViper(v); err != nil {
+		return err
+	}
+
+	o.Explain = cfg.Explain
+
 	return nil
 }
 
@@ -43,25 +93,53 @@ func (o *OPA) Evaluate(
 	ctx context.Context,
 	sessionContext map[string]interface{},
 	scheme string,
+	policyID string,
 	policy string,
+	data map[string]interface{},
+	history []map[string]interface{},
 	result map[string]interface{},
 	evidence map[string]interface{},
 	endorsements []string,
 ) (map[string]interface{}, error) {
 
-	input, err := constructInput(scheme, sessionContext, result, evidence, endorsements)
+	input, err := constructInput(scheme, sessionContext, result, evidence, endorsements, history)
 	if err != nil {
 		return nil, fmt.Errorf("could not construct policy input: %w", err)
 	}
 
-	rego := rego.New(
+	compiler, err := o.compiled.Get(policyID, policy, compilePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("could not Eval policy: %w", err)
+	}
+
+	// the policy has already been compiled, so only the query is compiled
+	// here
+	options := []func(*rego.Rego){
 		rego.Package("policy"),
-		rego.Module("opa.rego", preambleText),
-		rego.Module("policy.rego", policy),
+		rego.Compiler(compiler),
 		rego.Input(input),
-		rego.Query("outcome"),
 		rego.Dump(log.NamedWriter("opa", log.DebugLevel)),
-	)
+	}
+
+	// the data document is made available to the policy under data.*
+	if data != nil {
+		options = append(options, rego.Store(inmem.NewFromObject(data)))
+	}
+
+	var tracer *topdown.BufferTracer
+
+	switch o.Explain {
+	case ExplainNone:
+		options = append(options, rego.Query("outcome"))
+	case ExplainReasons:
+		options = append(options, rego.Query("outcome; policy_reasons"))
+	case ExplainTrace:
+		tracer = topdown.NewBufferTracer()
+		options = append(options,
+			rego.Query("outcome; policy_reasons"), rego.QueryTracer(tracer))
+	}
+
+	rego := rego.New(options...)
 
 	resultSet, err := rego.Eval(ctx)
 	if err != nil {
@@ -76,6 +154,19 @@ func (o *OPA) Evaluate(
 		return nil, fmt.Errorf("policy returned bad update: %w", err)
 	}
 
+	if o.Explain != ExplainNone {
+		explanation := map[string]interface{}{
+			"reasons": resultSet[0].Expressions[1].Value,
+		}
+
+		if tracer != nil {
+			explanation["trace"] = processTrace(*tracer)
+		}
+
+		claims := resultUpdate["ear.veraison.policy-claims"].(*map[string]interface{})
+		(*claims)[ExplanationClaim] = explanation
+	}
+
 	return resultUpdate, nil
 }
 
@@ -92,7 +183,61 @@ func (o *OPA) Validate(ctx context.Context, policy string) error {
 	return err
 }
 
+// Test runs the test_* rules defined by the tests module against the policy,
+// using OPA's test runner. The tests module may be in any package; it will
+// typically import data.policy to access the rules under test.
+func (o *OPA) Test(
+	ctx context.Context,
+	policy string,
+	tests string,
+) ([]map[string]interface{}, error) {
+	if tests == "" {
+		return nil, ErrNoTests
+	}
+
+	sources := map[string]string{
+		"opa.rego":         preambleText,
+		"policy.rego":      policy,
+		"policy_test.rego": tests,
+	}
+
+	modules := make(map[string]*ast.Module, len(sources))
+	for name, text := range sources {
+		module, err := ast.ParseModule(name, text)
+		if err != nil {
+			return nil, err
+		}
+		modules[name] = module
+	}
+
+	ch, err := tester.NewRunner().SetModules(modules).RunTests(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not run tests: %w", err)
+	}
+
+	results := []map[string]interface{}{}
+	for res := range ch {
+		results = append(results, processTestResult(res))
+	}
+
+	return results, nil
+}
+
+// Invalidate removes the policy with the specified ID from the cache of
+// compiled policies.
+func (o *OPA) Invalidate(policyID string) {
+	o.compiled.Invalidate(policyID)
+}
+
 func (o *OPA) Close() {
+	o.compiled.Clear()
+}
+
+func compilePolicy(policy string) (*ast.Compiler, error) {
+	return ast.CompileModules(map[string]string{
+		"opa.rego":    preambleText,
+		"policy.rego": policy,
+	})
 }
 
 func constructInput(
@@ -101,6 +246,7 @@ func constructInput(
 	result map[string]interface{},
 	evidence map[string]interface{},
 	endorsementStrings []string,
+	history []map[string]interface{},
 ) (map[string]interface{}, error) {
 	var endorsements []map[string]interface{} // nolint:prealloc
 
@@ -114,12 +260,18 @@ func constructInput(
 		endorsements = append(endorsements, e)
 	}
 
+	// policies may always iterate over the history, even if there is none
+	if history == nil {
+		history = []map[string]interface{}{}
+	}
+
 	return map[string]interface{}{
 		"scheme":       scheme,
 		"session":      sessionContext,
 		"result":       result,
 		"evidence":     evidence,
 		"endorsements": endorsements,
+		"history":      history,
 	}, nil
 }
 
@@ -188,3 +340,109 @@ func processUpdateValue(value interface{}) (map[string]interface{}, error) {
 
 	return update, nil
 }
+
+// processTrace returns the steps of the decision recorded in the trace: the
+// rules of the policy that produced a value (identified by the location of the
+// rule, or of the else branch that applied), and the notes emitted with
+// trace(). Rules of the preamble are not included.
+func processTrace(events []*topdown.Event) []interface{} {
+	steps := []interface{}{}
+	seen := map[string]bool{}
+
+	for _, evt := range events {
+		var step map[string]interface{}
+
+		switch evt.Op { // nolint:exhaustive
+		case topdown.ExitOp:
+			rule, ok := evt.Node.(*ast.Rule)
+			if !ok || rule.Location == nil || rule.Location.File != "policy.rego" {
+				continue
+			}
+
+			step = map[string]interface{}{
+				"rule":     rule.Head.Name.String(),
+				"location": rule.Location.String(),
+			}
+
+			if value := traceValue(evt, rule.Head.Value); value != nil {
+				step["value"] = value
+			}
+		case topdown.NoteOp:
+			step = map[string]interface{}{"note": evt.Message}
+
+			if evt.Location != nil {
+				step["location"] = evt.Location.String()
+			}
+		default:
+			continue
+		}
+
+		// rules may be evaluated multiple times (e.g. if they are
+		// referenced by other rules), but are only reported once
+		key := fmt.Sprint(step)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		steps = append(steps, step)
+	}
+
+	return steps
+}
+
+// traceValue returns the value produced by the rule that exited, or nil if it
+// cannot be determined (e.g. for partial rules).
+func traceValue(evt *topdown.Event, term *ast.Term) interface{} {
+	if term == nil {
+		return nil
+	}
+
+	value := term.Value
+	if v, ok := value.(ast.Var); ok && evt.Locals != nil {
+		value = evt.Locals.Get(v)
+	}
+
+	if value == nil || !ast.IsConstant(value) {
+		return nil
+	}
+
+	ret, err := ast.JSON(value)
+	if err != nil {
+		return nil
+	}
+
+	return ret
+}
+
+func processTestResult(res *tester.Result) map[string]interface{} {
+	ret := map[string]interface{}{
+		"package": res.Package,
+		"name":    res.Name,
+	}
+
+	switch {
+	case res.Error != nil:
+		ret["status"] = TestError
+		ret["detail"] = res.Error.Error()
+	case res.Skip:
+		ret["status"] = TestSkip
+	case res.Fail:
+		ret["status"] = TestFail
+		ret["detail"] = fmt.Sprintf("test defined at %v failed", res.Location)
+	default:
+		ret["status"] = TestPass
+	}
+
+	// output of print() calls made by the test, which authors may use to
+	// explain failures
+	if output := strings.TrimSpace(string(res.Output)); output != "" {
+		if detail, ok := ret["detail"]; ok {
+			ret["detail"] = fmt.Sprintf("%s: %s", detail, output)
+		} else {
+			ret["detail"] = output
+		}
+	}
+
+	return ret
+}
@@ -50,6 +50,7 @@ result := input.result
 evidence := input.evidence
 endorsements := input.endorsements
 session := input.session
+history := input.history
 
 scheme := input.scheme
 
@@ -64,6 +65,11 @@ default storage_opaque = 0
 default sourced_data = 0
 default added_claims = {}
 
+# Policies may define a set of reasons for their decisions as "reasons". These
+# are reported in the result if explanations are enabled.
+default policy_reasons = set()
+policy_reasons = data.policy.reasons
+
 simple_semver_split (s) := res {
   parts :=  split(s, ".")
   res := [to_number(p) | p := parts[_]]
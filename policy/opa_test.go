@@ -10,6 +10,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/veraison/ear"
@@ -27,6 +28,8 @@ type EvaluateTestVector struct {
 	EvidencePath     string     `json:"evidence"`
 	EndorsementsPath string     `json:"endorsements"`
 	PolicyPath       string     `json:"policy"`
+	DataPath         string     `json:"data"`
+	HistoryPath      string     `json:"history"`
 	Expected         TestResult `json:"expected"`
 }
 
@@ -43,8 +46,20 @@ func (o EvaluateTestVector) Run(t *testing.T, ctx context.Context, pa *OPA) {
 	policy, err := os.ReadFile(o.PolicyPath)
 	require.NoError(t, err)
 
-	res, err := pa.Evaluate(ctx, map[string]interface{}{}, o.Scheme, string(policy),
-		resultMap, evidenceMap["evidence"].(map[string]interface{}), endorsements)
+	var data map[string]interface{}
+	if o.DataPath != "" {
+		data, err = jsonFileToMap(o.DataPath)
+		require.NoError(t, err)
+	}
+
+	var history []map[string]interface{}
+	if o.HistoryPath != "" {
+		history, err = jsonFileToMapSlice(o.HistoryPath)
+		require.NoError(t, err)
+	}
+
+	res, err := pa.Evaluate(ctx, map[string]interface{}{}, o.Scheme, "", string(policy), data,
+		history, resultMap, evidenceMap["evidence"].(map[string]interface{}), endorsements)
 	if o.Expected.Error == "" {
 		require.NoError(t, err)
 	} else {
@@ -113,6 +128,124 @@ func Test_OPA_Evaluate(t *testing.T) {
 
 }
 
+func Test_OPA_Evaluate_cache(t *testing.T) {
+	ctx := context.Background()
+
+	pa, err := NewOPA(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	result, err := jsonFileToResultMap("test/inputs/psa-result.json")
+	require.NoError(t, err)
+
+	genuine := "package policy\n\nhardware = GENUINE_HW\n"
+	unsafe := "package policy\n\nhardware = UNSAFE_HW\n"
+
+	evaluate := func(policyID, policy string) ear.TrustClaim {
+		res, err := pa.Evaluate(ctx, map[string]interface{}{}, "PSA_IOT", policyID,
+			policy, nil, nil, result, map[string]interface{}{}, nil)
+		require.NoError(t, err)
+
+		tv := res["ear.trustworthiness-vector"].(map[string]interface{})
+		return tv["hardware"].(ear.TrustClaim)
+	}
+
+	assert.Equal(t, ear.GenuineHardwareClaim, evaluate("1", genuine))
+
+	// the rules of a policy version never change, so the cached version is
+	// used
+	assert.Equal(t, ear.GenuineHardwareClaim, evaluate("1", unsafe))
+
+	pa.Invalidate("1")
+	assert.Equal(t, ear.UnsafeHardwareClaim, evaluate("1", unsafe))
+
+	// policies without an ID are not cached
+	assert.Equal(t, ear.GenuineHardwareClaim, evaluate("", genuine))
+	assert.Equal(t, ear.UnsafeHardwareClaim, evaluate("", unsafe))
+
+	for i := 0; i < DefaultCacheSize+1; i++ {
+		evaluate(fmt.Sprint(i+2), genuine)
+	}
+	assert.Len(t, pa.compiled.entries, DefaultCacheSize)
+
+	// the least recently used policy has been evicted
+	assert.NotContains(t, pa.compiled.entries, "1")
+}
+
+func Test_OPA_Evaluate_explain(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := jsonFileToResultMap("test/inputs/psa-result.json")
+	require.NoError(t, err)
+
+	policy := `package policy
+
+hardware = GENUINE_HW {
+	evidence["psa-hardware-version"] == "1.0"
+} else = UNSAFE_HW {
+	trace("unexpected hardware version")
+}
+
+reasons[reason] {
+	hardware == UNSAFE_HW
+	reason := sprintf("hardware version %v", [evidence["psa-hardware-version"]])
+}
+`
+	evidence := map[string]interface{}{"psa-hardware-version": "2.0"}
+
+	evaluate := func(explain string) map[string]interface{} {
+		v := viper.New()
+		v.Set("explain", explain)
+
+		pa, err := NewOPA(v)
+		require.NoError(t, err)
+		defer pa.Close()
+
+		res, err := pa.Evaluate(ctx, map[string]interface{}{}, "PSA_IOT", "", policy,
+			nil, nil, result, evidence, nil)
+		require.NoError(t, err)
+
+		return *res["ear.veraison.policy-claims"].(*map[string]interface{})
+	}
+
+	claims := evaluate(ExplainNone)
+	assert.NotContains(t, claims, ExplanationClaim)
+
+	claims = evaluate(ExplainReasons)
+	assert.Equal(t, map[string]interface{}{
+		"reasons": []interface{}{"hardware version 2.0"},
+	}, claims[ExplanationClaim])
+
+	claims = evaluate(ExplainTrace)
+	assert.Equal(t, map[string]interface{}{
+		"reasons": []interface{}{"hardware version 2.0"},
+		"trace": []interface{}{
+			map[string]interface{}{
+				"note":     "unexpected hardware version",
+				"location": "policy.rego:6",
+			},
+			// the location is that of the else branch that applied
+			map[string]interface{}{
+				"rule":     "hardware",
+				"location": "policy.rego:5",
+				"value":    json.Number("32"),
+			},
+			map[string]interface{}{
+				"rule":     "reasons",
+				"location": "policy.rego:9",
+			},
+		},
+	}, claims[ExplanationClaim])
+}
+
+func Test_OPA_Init_bad_explain(t *testing.T) {
+	v := viper.New()
+	v.Set("explain", "everything")
+
+	_, err := NewOPA(v)
+	assert.ErrorContains(t, err, `unexpected explain mode "everything"`)
+}
+
 func Test_OPA_Validate(t *testing.T) {
 	bytes, err := os.ReadFile("test/validate-vectors.json")
 	require.NoError(t, err)
@@ -180,6 +313,21 @@ func jsonFileToStringSlice(path string) ([]string, error) {
 	return result, nil
 }
 
+func jsonFileToMapSlice(path string) ([]map[string]interface{}, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	err = json.Unmarshal(bytes, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 func getUpdateMap(ar *ear.AttestationResult) map[string]interface{} {
 	if ar == nil {
 		return nil
@@ -204,3 +352,45 @@ func getUpdateMap(ar *ear.AttestationResult) map[string]interface{} {
 		"ear.veraison.policy-claims": app.VeraisonPolicyClaims,
 	}
 }
+
+func Test_OPA_Test(t *testing.T) {
+	policy, err := os.ReadFile("test/policies/psa-hw.rego")
+	require.NoError(t, err)
+
+	tests, err := os.ReadFile("test/tests/psa-hw_test.rego")
+	require.NoError(t, err)
+
+	pa, err := NewOPA(nil)
+	require.NoError(t, err)
+	defer pa.Close()
+
+	results, err := pa.Test(context.Background(), string(policy), string(tests))
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	statuses := make(map[string]string)
+	details := make(map[string]interface{})
+	for _, res := range results {
+		assert.Equal(t, "data.policy_test", res["package"])
+		statuses[res["name"].(string)] = res["status"].(string)
+		details[res["name"].(string)] = res["detail"]
+	}
+
+	assert.Equal(t, map[string]string{
+		"test_genuine_hw":        TestPass,
+		"test_unsafe_hw":         TestPass,
+		"test_wrong_expectation": TestFail,
+		"test_printed":           TestFail,
+	}, statuses)
+	assert.Nil(t, details["test_genuine_hw"])
+	assert.Equal(t, "test defined at policy_test.rego:17 failed",
+		details["test_wrong_expectation"])
+	assert.Equal(t, "test defined at policy_test.rego:23 failed: hardware version must be 1.0",
+		details["test_printed"])
+
+	_, err = pa.Test(context.Background(), string(policy), "")
+	assert.ErrorIs(t, err, ErrNoTests)
+
+	_, err = pa.Test(context.Background(), string(policy), "package policy_test\n\ntest_x { undefined_var }\n")
+	assert.ErrorContains(t, err, "could not run tests")
+}
@@ -33,6 +33,19 @@ type Policy struct {
 	// agent.
 	Rules string `json:"rules"`
 
+	// Signature is the optional signature of the Rules by a policy author,
+	// either a JWS (in compact serialization) or a base64-encoded COSE_Sign1.
+	// See SignatureVerifier.
+	Signature string `json:"signature,omitempty"`
+
+	// Tests is the optional test module of the policy, exercising its
+	// rules. It is interpreted by the policy engine, just like the Rules.
+	Tests string `json:"tests,omitempty"`
+
+	// TestResults are the results of running the Tests when this policy
+	// version was added.
+	TestResults []TestCaseResult `json:"test-results,omitempty"`
+
 	// Active indicates whether this policy instance is currently active
 	// for the associated key.
 	Active bool `json:"active"`
@@ -61,3 +61,34 @@ func (o PolicyKey) Validate() error {
 func (o PolicyKey) String() string {
 	return fmt.Sprintf("%s:%s:%s", o.TenantId, o.Scheme, o.Name)
 }
+
+// BaselinePolicyKey returns the key of the operator-wide baseline policy with
+// the specified name. The baseline policy is applied to all appraisals, before
+// any other policy.
+func BaselinePolicyKey(name string) PolicyKey {
+	return PolicyKey{Name: name}
+}
+
+// TenantPolicyKey returns the key of the tenant-wide policy with the specified
+// name. The tenant policy is applied to all of the tenant's appraisals, after
+// the baseline policy, and before the policy for the attestation scheme.
+func TenantPolicyKey(tenantID, name string) PolicyKey {
+	return PolicyKey{TenantId: tenantID, Name: name}
+}
+
+// IsBaseline returns true if this is the key of a baseline policy.
+func (o PolicyKey) IsBaseline() bool {
+	return o.TenantId == "" && o.Scheme == ""
+}
+
+// IsTenantWide returns true if this is the key of a tenant-wide policy.
+func (o PolicyKey) IsTenantWide() bool {
+	return o.TenantId != "" && o.Scheme == ""
+}
+
+// IsReserved returns true if this is not the key of a policy, but of other
+// entries kept in the policy store (e.g. bindings, or data documents). The
+// names of such keys start with "@".
+func (o PolicyKey) IsReserved() bool {
+	return strings.HasPrefix(o.Name, "@")
+}
@@ -35,3 +35,23 @@ func Test_PolicyKey(t *testing.T) {
 	assert.EqualError(t, err,
 		"bad Name \"name<\": must be a valid URI path segment")
 }
+
+func Test_PolicyKey_layers(t *testing.T) {
+	baseline := BaselinePolicyKey("opa")
+	assert.Equal(t, "::opa", baseline.String())
+	assert.True(t, baseline.IsBaseline())
+	assert.False(t, baseline.IsTenantWide())
+
+	key, err := PolicyKeyFromString(baseline.String())
+	require.NoError(t, err)
+	assert.Equal(t, baseline, key)
+
+	tenant := TenantPolicyKey("0", "opa")
+	assert.Equal(t, "0::opa", tenant.String())
+	assert.False(t, tenant.IsBaseline())
+	assert.True(t, tenant.IsTenantWide())
+
+	scheme := PolicyKey{"0", "PSA_IOT", "opa"}
+	assert.False(t, scheme.IsBaseline())
+	assert.False(t, scheme.IsTenantWide())
+}
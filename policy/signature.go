@@ -0,0 +1,204 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/spf13/viper"
+	cose "github.com/veraison/go-cose"
+	"github.com/veraison/services/config"
+)
+
+var (
+	// ErrBadSignature is returned when the signature of a policy cannot be
+	// verified against the keys of the trusted policy authors.
+	ErrBadSignature = errors.New("bad policy signature")
+
+	// ErrUnsignedPolicy is returned when a policy is not signed, while
+	// signatures are enforced.
+	ErrUnsignedPolicy = errors.New("policy is not signed")
+)
+
+type signingCfg struct {
+	Keys    string `mapstructure:"keys" config:"zerodefault"`
+	Enforce bool   `mapstructure:"enforce" config:"zerodefault"`
+}
+
+func (o signingCfg) Validate() error {
+	if o.Enforce && o.Keys == "" {
+		return errors.New("policy signatures cannot be enforced without keys")
+	}
+
+	return nil
+}
+
+// SignatureVerifier verifies the signatures of policies against the public
+// keys of the trusted policy authors. A signature is over the rules of the
+// policy, and is either a JWS (in compact serialization), or a base64-encoded
+// COSE_Sign1. In both cases, the payload may be detached (in which case the
+// rules are the payload) or not (in which case it must be identical to the
+// rules).
+type SignatureVerifier struct {
+	// Keys are the public keys of the trusted policy authors.
+	Keys jwk.Set
+
+	// Enforce, if set, causes unsigned policies to be refused. Otherwise,
+	// only the policies that are signed are verified.
+	Enforce bool
+}
+
+// NewSignatureVerifier creates a new SignatureVerifier from the "signing"
+// configuration of the policy agent. If no keys are configured, policy
+// signatures are not verified, and the returned verifier is nil.
+func NewSignatureVerifier(v *viper.Viper) (*SignatureVerifier, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	var cfg signingCfg
+	loader := config.NewLoader(&cfg)
+	if err := loader.LoadFromViper(v); err != nil {
+		return nil, err
+	}
+
+	if cfg.Keys == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(cfg.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not read policy author keys: %w", err)
+	}
+
+	keys, err := jwk.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse policy author keys: %w", err)
+	}
+
+	if keys.Len() == 0 {
+		return nil, fmt.Errorf("no policy author keys in %s", cfg.Keys)
+	}
+
+	// private keys are allowed in the file, however only their public parts
+	// are needed
+	keys, err = jwk.PublicSetOf(keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not get public policy author keys: %w", err)
+	}
+
+	return &SignatureVerifier{Keys: keys, Enforce: cfg.Enforce}, nil
+}
+
+// Verify returns an error if the policy is signed, and the signature cannot
+// be verified, or if the policy is not signed, and signatures are enforced. A
+// nil verifier does not verify anything.
+func (o *SignatureVerifier) Verify(p *Policy) error {
+	if o == nil {
+		return nil
+	}
+
+	if p.Signature == "" {
+		if o.Enforce {
+			return ErrUnsignedPolicy
+		}
+		return nil
+	}
+
+	return o.VerifyRules(p.Rules, p.Signature)
+}
+
+// VerifyRules returns an error if the signature is not a valid signature of
+// the rules by one of the policy authors.
+func (o *SignatureVerifier) VerifyRules(rules, signature string) error {
+	if strings.Count(signature, ".") == 2 {
+		return o.verifyJWS([]byte(rules), signature)
+	}
+
+	return o.verifyCOSE([]byte(rules), signature)
+}
+
+func (o *SignatureVerifier) verifyJWS(rules []byte, signature string) error {
+	options := []jws.VerifyOption{
+		jws.WithKeySet(o.Keys, jws.WithRequireKid(false), jws.WithInferAlgorithmFromKey(true)),
+	}
+
+	detached := strings.Split(signature, ".")[1] == ""
+	if detached {
+		options = append(options, jws.WithDetachedPayload(rules))
+	}
+
+	payload, err := jws.Verify([]byte(signature), options...)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+
+	if !detached && !bytes.Equal(payload, rules) {
+		return fmt.Errorf("%w: signed payload does not match the rules", ErrBadSignature)
+	}
+
+	return nil
+}
+
+func (o *SignatureVerifier) verifyCOSE(rules []byte, signature string) error {
+	data, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		if data, err = base64.RawURLEncoding.DecodeString(signature); err != nil {
+			return fmt.Errorf("%w: neither a JWS nor a base64-encoded COSE_Sign1",
+				ErrBadSignature)
+		}
+	}
+
+	var msg cose.Sign1Message
+	if err = msg.UnmarshalCBOR(data); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+
+	if msg.Payload == nil {
+		msg.Payload = rules
+	} else if !bytes.Equal(msg.Payload, rules) {
+		return fmt.Errorf("%w: signed payload does not match the rules", ErrBadSignature)
+	}
+
+	alg, err := msg.Headers.Protected.Algorithm()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+
+	kid, _ := msg.Headers.Protected[cose.HeaderLabelKeyID].([]byte)
+	if kid == nil {
+		kid, _ = msg.Headers.Unprotected[cose.HeaderLabelKeyID].([]byte)
+	}
+
+	for i := 0; i < o.Keys.Len(); i++ {
+		key, _ := o.Keys.Key(i)
+
+		if kid != nil && key.KeyID() != "" && key.KeyID() != string(kid) {
+			continue
+		}
+
+		var pub interface{}
+		if err := key.Raw(&pub); err != nil {
+			continue
+		}
+
+		// keys that do not suit the algorithm are skipped
+		verifier, err := cose.NewVerifier(alg, pub)
+		if err != nil {
+			continue
+		}
+
+		if err := msg.Verify(nil, verifier); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: not signed by a policy author", ErrBadSignature)
+}
@@ -0,0 +1,201 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cose "github.com/veraison/go-cose"
+)
+
+const signedRules = "package policy\n\nhardware = GENUINE_HW\n"
+
+func newAuthorKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+// writeAuthorKeys writes the (private) keys to a JWK set file, and returns
+// its path.
+func writeAuthorKeys(t *testing.T, keys ...*ecdsa.PrivateKey) string {
+	set := jwk.NewSet()
+	for _, key := range keys {
+		k, err := jwk.FromRaw(key)
+		require.NoError(t, err)
+		require.NoError(t, set.AddKey(k))
+	}
+
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.jwks")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	return path
+}
+
+func newTestVerifier(t *testing.T, enforce bool, keys ...*ecdsa.PrivateKey) *SignatureVerifier {
+	v := viper.New()
+	v.Set("keys", writeAuthorKeys(t, keys...))
+	v.Set("enforce", enforce)
+
+	verifier, err := NewSignatureVerifier(v)
+	require.NoError(t, err)
+	require.NotNil(t, verifier)
+
+	return verifier
+}
+
+func signJWS(t *testing.T, key *ecdsa.PrivateKey, payload string, detached bool) string {
+	options := []jws.SignOption{jws.WithKey(jwa.ES256, key)}
+
+	var signed []byte
+	var err error
+	if detached {
+		options = append(options, jws.WithDetachedPayload([]byte(payload)))
+		signed, err = jws.Sign(nil, options...)
+	} else {
+		signed, err = jws.Sign([]byte(payload), options...)
+	}
+	require.NoError(t, err)
+
+	return string(signed)
+}
+
+func signCOSE(t *testing.T, key *ecdsa.PrivateKey, payload string, detached bool) string {
+	signer, err := cose.NewSigner(cose.AlgorithmES256, key)
+	require.NoError(t, err)
+
+	msg := cose.NewSign1Message()
+	msg.Headers.Protected.SetAlgorithm(cose.AlgorithmES256)
+	msg.Payload = []byte(payload)
+	require.NoError(t, msg.Sign(rand.Reader, nil, signer))
+
+	if detached {
+		msg.Payload = nil
+	}
+
+	data, err := msg.MarshalCBOR()
+	require.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func Test_NewSignatureVerifier(t *testing.T) {
+	verifier, err := NewSignatureVerifier(nil)
+	require.NoError(t, err)
+	assert.Nil(t, verifier)
+
+	verifier, err = NewSignatureVerifier(viper.New())
+	require.NoError(t, err)
+	assert.Nil(t, verifier)
+
+	verifier = newTestVerifier(t, true, newAuthorKey(t), newAuthorKey(t))
+	assert.True(t, verifier.Enforce)
+	assert.Equal(t, 2, verifier.Keys.Len())
+
+	// only the public keys are retained
+	key, _ := verifier.Keys.Key(0)
+	assert.Implements(t, (*jwk.ECDSAPublicKey)(nil), key)
+}
+
+func Test_NewSignatureVerifier_bad_config(t *testing.T) {
+	v := viper.New()
+	v.Set("enforce", true)
+
+	_, err := NewSignatureVerifier(v)
+	assert.ErrorContains(t, err, "cannot be enforced without keys")
+
+	v = viper.New()
+	v.Set("keys", filepath.Join(t.TempDir(), "missing.jwks"))
+
+	_, err = NewSignatureVerifier(v)
+	assert.ErrorContains(t, err, "could not read policy author keys")
+
+	path := filepath.Join(t.TempDir(), "bad.jwks")
+	require.NoError(t, os.WriteFile(path, []byte("not keys"), 0600))
+	v.Set("keys", path)
+
+	_, err = NewSignatureVerifier(v)
+	assert.ErrorContains(t, err, "could not parse policy author keys")
+
+	v = viper.New()
+	v.Set("keys", writeAuthorKeys(t, newAuthorKey(t)))
+	v.Set("signatures", "yes")
+
+	_, err = NewSignatureVerifier(v)
+	assert.ErrorContains(t, err, "signatures")
+}
+
+func Test_SignatureVerifier_Verify(t *testing.T) {
+	author := newAuthorKey(t)
+	other := newAuthorKey(t)
+
+	verifier := newTestVerifier(t, false, author)
+
+	vectors := []struct {
+		Title     string
+		Signature string
+		Expected  error
+	}{
+		{"unsigned", "", nil},
+		{"JWS", signJWS(t, author, signedRules, false), nil},
+		{"detached JWS", signJWS(t, author, signedRules, true), nil},
+		{"COSE", signCOSE(t, author, signedRules, false), nil},
+		{"detached COSE", signCOSE(t, author, signedRules, true), nil},
+		{"JWS by other", signJWS(t, other, signedRules, true), ErrBadSignature},
+		{"COSE by other", signCOSE(t, other, signedRules, true), ErrBadSignature},
+		{"JWS of other rules", signJWS(t, author, "package policy\n", false), ErrBadSignature},
+		{"detached JWS of other rules", signJWS(t, author, "package policy\n", true), ErrBadSignature},
+		{"COSE of other rules", signCOSE(t, author, "package policy\n", false), ErrBadSignature},
+		{"detached COSE of other rules", signCOSE(t, author, "package policy\n", true), ErrBadSignature},
+		{"garbage", "not a signature!", ErrBadSignature},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.Title, func(t *testing.T) {
+			pol := &Policy{Rules: signedRules, Signature: v.Signature}
+
+			err := verifier.Verify(pol)
+			if v.Expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, v.Expected)
+			}
+		})
+	}
+}
+
+func Test_SignatureVerifier_Verify_enforce(t *testing.T) {
+	author := newAuthorKey(t)
+
+	verifier := newTestVerifier(t, true, author)
+
+	err := verifier.Verify(&Policy{Rules: signedRules})
+	assert.ErrorIs(t, err, ErrUnsignedPolicy)
+
+	err = verifier.Verify(&Policy{
+		Rules:     signedRules,
+		Signature: signJWS(t, author, signedRules, true),
+	})
+	assert.NoError(t, err)
+
+	// a nil verifier does not verify anything
+	verifier = nil
+	err = verifier.Verify(&Policy{Rules: signedRules, Signature: "bad"})
+	assert.NoError(t, err)
+}
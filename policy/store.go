@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
@@ -16,6 +18,7 @@ import (
 
 var ErrNoPolicy = errors.New("no policy found")
 var ErrNoActivePolicy = errors.New("no active policy for key")
+var ErrPolicyActive = errors.New("policy is active")
 
 // NewStore returns a new policy store. Config options are the same as those
 // used for kvstore.New().
@@ -57,7 +60,18 @@ func (o *Store) Update(key PolicyKey, name, typ, rules string) (*Policy, error)
 		return newPolicy, err
 	}
 
-	return newPolicy, o.addPolicy(newPolicy)
+	return newPolicy, o.AddVersion(newPolicy)
+}
+
+// AddVersion adds the provided policy as the latest version of the policy
+// under its StoreKey. This allows adding policies that have been created with
+// NewPolicy and then further populated (e.g. with tests).
+func (o *Store) AddVersion(policy *Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	return o.addPolicy(policy)
 }
 
 // Get returns the slice of all Policies associated with the specified ID. Each
@@ -135,20 +149,26 @@ func (o *Store) ListAllVersions() ([]*Policy, error) {
 }
 
 // GetPolicyKeys returns a []PolicyID of the policies currently in the store.
+// The reserved keys, under which bindings and data documents are kept, are not
+// included.
 func (o *Store) GetPolicyKeys() ([]PolicyKey, error) {
 	keys, err := o.KVStore.GetKeys()
 	if err != nil {
 		return nil, err
 	}
 
-	ids := make([]PolicyKey, len(keys))
-	for i, k := range keys {
+	ids := make([]PolicyKey, 0, len(keys))
+	for _, k := range keys {
 		key, err := PolicyKeyFromString(k)
 		if err != nil {
 			return nil, fmt.Errorf("bad key in store: %w", err)
 		}
 
-		ids[i] = key
+		if key.IsReserved() {
+			continue
+		}
+
+		ids = append(ids, key)
 	}
 
 	return ids, nil
@@ -176,17 +196,7 @@ func (o *Store) Activate(key PolicyKey, id uuid.UUID) error {
 		return fmt.Errorf("%w with UUID %q for key %q", ErrNoPolicy, id, key.String())
 	}
 
-	if err := o.Del(key); err != nil {
-		return err
-	}
-
-	for _, pol := range policies {
-		if err := o.addPolicy(pol); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return o.replaceVersions(key, policies)
 }
 
 // DeactivateAll deactivates all policies associated with the key.
@@ -200,17 +210,7 @@ func (o *Store) DeactivateAll(key PolicyKey) error {
 		pol.Active = false
 	}
 
-	if err := o.Del(key); err != nil {
-		return err
-	}
-
-	for _, pol := range policies {
-		if err := o.addPolicy(pol); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return o.replaceVersions(key, policies)
 }
 
 // GetActive returns the current active version of the policy with the
@@ -253,11 +253,382 @@ func (o *Store) Del(key PolicyKey) error {
 	return o.KVStore.Del(key.String())
 }
 
+// DelPolicy removes the policy version with the specified UUID under the
+// specified key. The active version, and versions that are bound, cannot be
+// removed.
+func (o *Store) DelPolicy(key PolicyKey, id uuid.UUID) error {
+	policies, err := o.Get(key)
+	if err != nil {
+		return err
+	}
+
+	var remaining []*Policy // nolint:prealloc
+	found := false
+
+	bound, err := o.boundPolicies(key)
+	if err != nil {
+		return err
+	}
+
+	for _, pol := range policies {
+		if bytes.Equal(id[:], pol.UUID[:]) {
+			if pol.Active {
+				return fmt.Errorf("%w: cannot delete UUID %q under key %q",
+					ErrPolicyActive, id.String(), key.String())
+			}
+
+			if bound[pol.UUID] {
+				return fmt.Errorf("%w: cannot delete UUID %q under key %q",
+					ErrPolicyBound, id.String(), key.String())
+			}
+			found = true
+			continue
+		}
+
+		remaining = append(remaining, pol)
+	}
+
+	if !found {
+		return fmt.Errorf("%w with UUID %q under key %q",
+			ErrNoPolicy, id.String(), key.String())
+	}
+
+	return o.replaceVersions(key, remaining)
+}
+
+// Retention specifies which inactive policy versions are kept in the store.
+// Zero-valued fields mean no limit. The active version, and versions that are
+// bound, are always kept.
+type Retention struct {
+	// MaxVersions is the maximum number of inactive versions kept for a
+	// key. The most recent ones are kept.
+	MaxVersions int
+	// MaxAge is the maximum age of the inactive versions kept.
+	MaxAge time.Duration
+}
+
+// IsZero returns true if the retention does not limit the versions kept.
+func (o Retention) IsZero() bool {
+	return o.MaxVersions == 0 && o.MaxAge == 0
+}
+
+// Prune removes the inactive versions of the policy with the specified key
+// that are not retained at the specified time, and returns them.
+func (o *Store) Prune(key PolicyKey, retention Retention, now time.Time) ([]*Policy, error) {
+	if retention.IsZero() {
+		return nil, nil
+	}
+
+	policies, err := o.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	bound, err := o.boundPolicies(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var inactive []*Policy
+	for _, pol := range policies {
+		if !pol.Active && !bound[pol.UUID] {
+			inactive = append(inactive, pol)
+		}
+	}
+
+	// most recent first
+	sort.SliceStable(inactive, func(i, j int) bool {
+		return inactive[i].CTime.After(inactive[j].CTime)
+	})
+
+	pruned := make(map[uuid.UUID]bool)
+	var prunedPolicies []*Policy
+
+	for i, pol := range inactive {
+		if (retention.MaxVersions > 0 && i >= retention.MaxVersions) ||
+			(retention.MaxAge > 0 && now.Sub(pol.CTime) > retention.MaxAge) {
+			pruned[pol.UUID] = true
+			prunedPolicies = append(prunedPolicies, pol)
+		}
+	}
+
+	if len(prunedPolicies) == 0 {
+		return nil, nil
+	}
+
+	var remaining []*Policy // nolint:prealloc
+	for _, pol := range policies {
+		if !pruned[pol.UUID] {
+			remaining = append(remaining, pol)
+		}
+	}
+
+	return prunedPolicies, o.replaceVersions(key, remaining)
+}
+
+// PruneAll prunes the versions of all the policies in the store (see Prune),
+// and returns the pruned versions.
+func (o *Store) PruneAll(retention Retention, now time.Time) ([]*Policy, error) {
+	if retention.IsZero() {
+		return nil, nil
+	}
+
+	keys, err := o.GetPolicyKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []*Policy
+	for _, key := range keys {
+		policies, err := o.Prune(key, retention, now)
+		if err != nil {
+			return pruned, err
+		}
+
+		pruned = append(pruned, policies...)
+	}
+
+	return pruned, nil
+}
+
+// GetBindings returns the bindings for the specified tenant's scheme, in the
+// order in which they are considered (see SortBindings).
+func (o *Store) GetBindings(tenantID, scheme string) ([]*Binding, error) {
+	vals, err := o.KVStore.Get(bindingsKey(tenantID, scheme).String())
+	if err != nil {
+		if errors.Is(err, kvstore.ErrKeyNotFound) {
+			return []*Binding{}, nil
+		}
+		return nil, err
+	}
+
+	bindings := make([]*Binding, 0, len(vals))
+	for _, v := range vals {
+		var b Binding
+		if err = json.Unmarshal([]byte(v), &b); err != nil {
+			return nil, err
+		}
+
+		bindings = append(bindings, &b)
+	}
+
+	SortBindings(bindings)
+
+	return bindings, nil
+}
+
+// GetBinding returns the binding with the specified ID for the specified
+// tenant's scheme.
+func (o *Store) GetBinding(tenantID, scheme string, id uuid.UUID) (*Binding, error) {
+	bindings, err := o.GetBindings(tenantID, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range bindings {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w with ID %q for %q",
+		ErrNoBinding, id.String(), bindingsKey(tenantID, scheme).String())
+}
+
+// AddBinding adds the binding for the specified tenant's scheme. It is up to
+// the caller to ensure that the bound policy version exists.
+func (o *Store) AddBinding(tenantID, scheme string, binding *Binding) error {
+	if err := binding.Validate(); err != nil {
+		return err
+	}
+
+	key := bindingsKey(tenantID, scheme)
+	if err := key.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := o.GetBinding(tenantID, scheme, binding.ID); err == nil {
+		return fmt.Errorf("binding with ID %q already exists", binding.ID.String())
+	}
+
+	bindingBytes, err := json.Marshal(binding)
+	if err != nil {
+		return err
+	}
+
+	return o.KVStore.Add(key.String(), string(bindingBytes))
+}
+
+// DelBinding removes the binding with the specified ID for the specified
+// tenant's scheme.
+func (o *Store) DelBinding(tenantID, scheme string, id uuid.UUID) error {
+	bindings, err := o.GetBindings(tenantID, scheme)
+	if err != nil {
+		return err
+	}
+
+	var remaining []*Binding // nolint:prealloc
+	found := false
+
+	for _, b := range bindings {
+		if b.ID == id {
+			found = true
+			continue
+		}
+
+		remaining = append(remaining, b)
+	}
+
+	if !found {
+		return fmt.Errorf("%w with ID %q for %q",
+			ErrNoBinding, id.String(), bindingsKey(tenantID, scheme).String())
+	}
+
+	if err := o.DelBindings(tenantID, scheme); err != nil {
+		return err
+	}
+
+	for _, b := range remaining {
+		if err := o.AddBinding(tenantID, scheme, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DelBindings removes all the bindings for the specified tenant's scheme.
+func (o *Store) DelBindings(tenantID, scheme string) error {
+	err := o.KVStore.Del(bindingsKey(tenantID, scheme).String())
+	if err != nil && !errors.Is(err, kvstore.ErrKeyNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// AddData adds a new version of the data document for the specified tenant's
+// scheme, and returns it. The latest version is the one made available to
+// policies.
+func (o *Store) AddData(
+	tenantID string,
+	scheme string,
+	data map[string]interface{},
+) (*DataDocument, error) {
+	doc, err := NewDataDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := dataKey(tenantID, scheme)
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	docBytes, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, o.KVStore.Add(key.String(), string(docBytes))
+}
+
+// GetData returns the latest version of the data document for the specified
+// tenant's scheme.
+func (o *Store) GetData(tenantID, scheme string) (*DataDocument, error) {
+	docs, err := o.GetDataVersions(tenantID, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("%w for %q", ErrNoData, dataKey(tenantID, scheme).String())
+	}
+
+	return docs[len(docs)-1], nil
+}
+
+// GetDataVersion returns the version of the data document with the specified
+// UUID for the specified tenant's scheme.
+func (o *Store) GetDataVersion(tenantID, scheme string, id uuid.UUID) (*DataDocument, error) {
+	docs, err := o.GetDataVersions(tenantID, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		if doc.UUID == id {
+			return doc, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w with UUID %q for %q",
+		ErrNoData, id.String(), dataKey(tenantID, scheme).String())
+}
+
+// GetDataVersions returns all the versions of the data document for the
+// specified tenant's scheme, from the oldest to the latest.
+func (o *Store) GetDataVersions(tenantID, scheme string) ([]*DataDocument, error) {
+	vals, err := o.KVStore.Get(dataKey(tenantID, scheme).String())
+	if err != nil {
+		if errors.Is(err, kvstore.ErrKeyNotFound) {
+			return []*DataDocument{}, nil
+		}
+		return nil, err
+	}
+
+	docs := make([]*DataDocument, 0, len(vals))
+	for _, v := range vals {
+		var doc DataDocument
+		if err = json.Unmarshal([]byte(v), &doc); err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, &doc)
+	}
+
+	return docs, nil
+}
+
+// DelData removes all the versions of the data document for the specified
+// tenant's scheme.
+func (o *Store) DelData(tenantID, scheme string) error {
+	err := o.KVStore.Del(dataKey(tenantID, scheme).String())
+	if err != nil {
+		if errors.Is(err, kvstore.ErrKeyNotFound) {
+			return fmt.Errorf("%w for %q", ErrNoData, dataKey(tenantID, scheme).String())
+		}
+		return err
+	}
+
+	return nil
+}
+
 // Close the connection to the underlying kvstore.
 func (o *Store) Close() error {
 	return o.KVStore.Close()
 }
 
+// replaceVersions replaces the policy versions under the specified key with
+// the provided ones.
+func (o *Store) replaceVersions(key PolicyKey, policies []*Policy) error {
+	if err := o.Del(key); err != nil {
+		return err
+	}
+
+	for _, pol := range policies {
+		if err := o.addPolicy(pol); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (o *Store) addPolicy(policy *Policy) error {
 	policyBytes, err := json.Marshal(policy)
 	if err != nil {
@@ -266,3 +637,27 @@ func (o *Store) addPolicy(policy *Policy) error {
 
 	return o.KVStore.Add(policy.StoreKey.String(), string(policyBytes))
 }
+
+// boundPolicies returns the set of the UUIDs of the policy versions under the
+// specified key that are bound.
+func (o *Store) boundPolicies(key PolicyKey) (map[uuid.UUID]bool, error) {
+	bindings, err := o.GetBindings(key.TenantId, key.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := make(map[uuid.UUID]bool, len(bindings))
+	for _, b := range bindings {
+		bound[b.PolicyUUID] = true
+	}
+
+	return bound, nil
+}
+
+func bindingsKey(tenantID, scheme string) PolicyKey {
+	return PolicyKey{TenantId: tenantID, Scheme: scheme, Name: BindingsKeyName}
+}
+
+func dataKey(tenantID, scheme string) PolicyKey {
+	return PolicyKey{TenantId: tenantID, Scheme: scheme, Name: DataKeyName}
+}
@@ -4,7 +4,9 @@ package policy
 
 import (
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -87,3 +89,248 @@ func Test_Store_CRUD(t *testing.T) {
 	_, err = store.GetActive(key)
 	assert.ErrorIs(t, err, ErrNoPolicy)
 }
+
+func newTestStore(t *testing.T) *Store {
+	v := viper.New()
+	v.Set("backend", "memory")
+
+	store, err := NewStore(v, log.Named("test"))
+	require.NoError(t, err)
+
+	return store
+}
+
+func Test_Store_DelPolicy(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+
+	key := PolicyKey{"1", "scheme", "policy"}
+
+	first, err := store.Add(key, "test", "test", "first")
+	require.NoError(t, err)
+
+	second, err := store.Update(key, "test", "test", "second")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Activate(key, second.UUID))
+
+	err = store.DelPolicy(key, second.UUID)
+	assert.ErrorIs(t, err, ErrPolicyActive)
+
+	err = store.DelPolicy(key, first.UUID)
+	require.NoError(t, err)
+
+	versions, err := store.Get(key)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, second.UUID, versions[0].UUID)
+	assert.True(t, versions[0].Active)
+
+	err = store.DelPolicy(key, first.UUID)
+	assert.ErrorIs(t, err, ErrNoPolicy)
+
+	require.NoError(t, store.DeactivateAll(key))
+	require.NoError(t, store.DelPolicy(key, second.UUID))
+
+	_, err = store.Get(key)
+	assert.ErrorIs(t, err, ErrNoPolicy)
+}
+
+func Test_Store_Prune(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+
+	key := PolicyKey{"1", "scheme", "policy"}
+	now := time.Now()
+
+	var policies []*Policy
+	for i := 0; i < 5; i++ {
+		pol, err := NewPolicy(key, "test", "test", "rules")
+		require.NoError(t, err)
+
+		// from oldest (4 days) to newest (0 days)
+		pol.CTime = now.Add(-time.Duration(4-i) * 24 * time.Hour)
+		pol.Active = i == 0
+
+		require.NoError(t, store.addPolicy(pol))
+		policies = append(policies, pol)
+	}
+
+	pruned, err := store.Prune(key, Retention{}, now)
+	require.NoError(t, err)
+	assert.Nil(t, pruned)
+
+	// keep the two most recent inactive versions; the oldest version is
+	// active, so it is kept too
+	pruned, err = store.Prune(key, Retention{MaxVersions: 2}, now)
+	require.NoError(t, err)
+	require.Len(t, pruned, 2)
+	assert.Equal(t, policies[2].UUID, pruned[0].UUID)
+	assert.Equal(t, policies[1].UUID, pruned[1].UUID)
+
+	versions, err := store.Get(key)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, policies[0].UUID, versions[0].UUID)
+	assert.True(t, versions[0].Active)
+
+	pruned, err = store.PruneAll(Retention{MaxAge: 12 * time.Hour}, now)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.Equal(t, policies[3].UUID, pruned[0].UUID)
+
+	versions, err = store.Get(key)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, policies[0].UUID, versions[0].UUID)
+	assert.Equal(t, policies[4].UUID, versions[1].UUID)
+}
+
+func Test_Store_AddVersion(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+
+	key := PolicyKey{"1", "scheme", "policy"}
+
+	pol, err := NewPolicy(key, "test", "test", "rules")
+	require.NoError(t, err)
+
+	pol.Tests = "tests"
+	pol.TestResults = []TestCaseResult{
+		{Package: "data.policy_test", Name: "test_rules", Status: TestPass},
+	}
+
+	require.NoError(t, store.AddVersion(pol))
+
+	ret, err := store.GetPolicy(key, pol.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "tests", ret.Tests)
+	assert.Equal(t, pol.TestResults, ret.TestResults)
+
+	bad, err := NewPolicy(PolicyKey{"1", "bad/scheme", "policy"}, "test", "test", "rules")
+	require.NoError(t, err)
+
+	assert.EqualError(t, store.AddVersion(bad),
+		`bad Scheme "bad/scheme": must be a valid URI path segment`)
+}
+
+func Test_Store_Bindings(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+
+	key := PolicyKey{"1", "scheme", "policy"}
+
+	bindings, err := store.GetBindings("1", "scheme")
+	require.NoError(t, err)
+	assert.Empty(t, bindings)
+
+	active, err := store.Add(key, "test", "test", "active")
+	require.NoError(t, err)
+	require.NoError(t, store.Activate(key, active.UUID))
+
+	bound, err := store.Update(key, "test", "test", "bound")
+	require.NoError(t, err)
+
+	unbound, err := store.Update(key, "test", "test", "unbound")
+	require.NoError(t, err)
+
+	first, err := NewBinding(bound.UUID)
+	require.NoError(t, err)
+	first.Submod = "submod"
+
+	second, err := NewBinding(bound.UUID)
+	require.NoError(t, err)
+	second.Priority = 1
+
+	require.NoError(t, store.AddBinding("1", "scheme", first))
+	require.NoError(t, store.AddBinding("1", "scheme", second))
+
+	err = store.AddBinding("1", "scheme", first)
+	assert.ErrorContains(t, err, "already exists")
+
+	bindings, err = store.GetBindings("1", "scheme")
+	require.NoError(t, err)
+	require.Len(t, bindings, 2)
+	assert.Equal(t, second.ID, bindings[0].ID)
+	assert.Equal(t, first.ID, bindings[1].ID)
+
+	ret, err := store.GetBinding("1", "scheme", first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "submod", ret.Submod)
+
+	// bindings are not policies
+	keys, err := store.GetPolicyKeys()
+	require.NoError(t, err)
+	assert.Equal(t, []PolicyKey{key}, keys)
+
+	// bound versions are neither deleted...
+	err = store.DelPolicy(key, bound.UUID)
+	assert.ErrorIs(t, err, ErrPolicyBound)
+
+	// ...nor pruned
+	pruned, err := store.Prune(key, Retention{MaxVersions: 0, MaxAge: time.Nanosecond},
+		time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.Equal(t, unbound.UUID, pruned[0].UUID)
+
+	require.NoError(t, store.DelBinding("1", "scheme", first.ID))
+
+	err = store.DelBinding("1", "scheme", first.ID)
+	assert.ErrorIs(t, err, ErrNoBinding)
+
+	require.NoError(t, store.DelBindings("1", "scheme"))
+	require.NoError(t, store.DelPolicy(key, bound.UUID))
+
+	bindings, err = store.GetBindings("1", "scheme")
+	require.NoError(t, err)
+	assert.Empty(t, bindings)
+}
+
+func Test_Store_Data(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+
+	_, err := store.GetData("1", "scheme")
+	assert.ErrorIs(t, err, ErrNoData)
+
+	docs, err := store.GetDataVersions("1", "scheme")
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+
+	_, err = store.AddData("1", "scheme", map[string]interface{}{"policy": "clash"})
+	assert.ErrorContains(t, err, `"policy" is reserved`)
+
+	first, err := store.AddData("1", "scheme", map[string]interface{}{"threshold": 1.0})
+	require.NoError(t, err)
+
+	second, err := store.AddData("1", "scheme", map[string]interface{}{"threshold": 2.0})
+	require.NoError(t, err)
+
+	latest, err := store.GetData("1", "scheme")
+	require.NoError(t, err)
+	assert.Equal(t, second.UUID, latest.UUID)
+	assert.Equal(t, 2.0, latest.Data["threshold"])
+
+	ret, err := store.GetDataVersion("1", "scheme", first.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, ret.Data["threshold"])
+
+	_, err = store.GetDataVersion("1", "scheme", uuid.New())
+	assert.ErrorIs(t, err, ErrNoData)
+
+	docs, err = store.GetDataVersions("1", "scheme")
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, first.UUID, docs[0].UUID)
+
+	// data documents are not policies
+	keys, err := store.GetPolicyKeys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, store.DelData("1", "scheme"))
+
+	err = store.DelData("1", "scheme")
+	assert.ErrorIs(t, err, ErrNoData)
+}
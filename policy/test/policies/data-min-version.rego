@@ -0,0 +1,9 @@
+package policy
+
+# The minimum version of the PSA boot loader is taken from the data document,
+# so that it can be raised without changing the policy.
+executables = APPROVED_RT {
+  some i
+  evidence["psa-software-components"][i]["measurement-type"] == "BL"
+  semver_cmp(evidence["psa-software-components"][i].version, data.min_bl_version) >= 0
+} else = UNSAFE_RT
@@ -0,0 +1,15 @@
+package policy
+
+# The boot loader has been rolled back if its version is older than the one
+# reported in any of the previous attestations of the device.
+bl_rolled_back {
+  previous := history[_].evidence["psa-software-components"][_]
+  previous["measurement-type"] == "BL"
+
+  current := evidence["psa-software-components"][_]
+  current["measurement-type"] == "BL"
+
+  semver_cmp(current.version, previous.version) < 0
+}
+
+executables = UNSAFE_RT { bl_rolled_back } else = APPROVED_RT
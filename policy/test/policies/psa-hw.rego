@@ -0,0 +1,5 @@
+package policy
+
+hardware = GENUINE_HW {
+	input.evidence["psa-hardware-version"] == "1.0"
+} else = UNSAFE_HW
@@ -0,0 +1,28 @@
+package policy_test
+
+import data.policy
+
+test_genuine_hw {
+	policy.hardware == policy.GENUINE_HW with input as {
+		"evidence": {"psa-hardware-version": "1.0"}
+	}
+}
+
+test_unsafe_hw {
+	policy.hardware == policy.UNSAFE_HW with input as {
+		"evidence": {"psa-hardware-version": "2.0"}
+	}
+}
+
+test_wrong_expectation {
+	policy.hardware == policy.GENUINE_HW with input as {
+		"evidence": {"psa-hardware-version": "2.0"}
+	}
+}
+
+test_printed {
+	print("hardware version must be 1.0")
+	policy.hardware == policy.GENUINE_HW with input as {
+		"evidence": {"psa-hardware-version": "3.0"}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package policy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoTests is returned when running the tests of a policy that has none.
+var ErrNoTests = errors.New("policy has no tests")
+
+const (
+	TestPass  = "pass"
+	TestFail  = "fail"
+	TestError = "error"
+	TestSkip  = "skip"
+)
+
+// TestCaseResult is the outcome of running a single test defined in the test
+// module of a policy.
+type TestCaseResult struct {
+	// Package is the package the test is defined in.
+	Package string `json:"package"`
+
+	// Name is the name of the test rule.
+	Name string `json:"name"`
+
+	// Status is one of "pass", "fail", "error", or "skip".
+	Status string `json:"status"`
+
+	// Detail provides additional information as to why the test did not
+	// pass (e.g. the failed expression, or the error encountered).
+	Detail string `json:"detail,omitempty"`
+}
+
+// TestsPassed returns true if none of the specified test results has failed.
+// Skipped tests are not considered to have failed.
+func TestsPassed(results []TestCaseResult) bool {
+	for _, res := range results {
+		if res.Status == TestFail || res.Status == TestError {
+			return false
+		}
+	}
+
+	return true
+}
+
+func toTestCaseResult(raw map[string]interface{}) (*TestCaseResult, error) {
+	var res TestCaseResult
+
+	fields := map[string]*string{
+		"package": &res.Package,
+		"name":    &res.Name,
+		"status":  &res.Status,
+		"detail":  &res.Detail,
+	}
+
+	for field, dest := range fields {
+		v, ok := raw[field]
+		if !ok {
+			continue
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a string, but got %T", field, v)
+		}
+		*dest = s
+	}
+
+	switch res.Status {
+	case TestPass, TestFail, TestError, TestSkip:
+	default:
+		return nil, fmt.Errorf("unexpected status %q", res.Status)
+	}
+
+	return &res, nil
+}
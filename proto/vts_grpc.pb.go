@@ -24,11 +24,29 @@ type VTSClient interface {
 	// Returns attestation information -- evidences, endorsed claims, trust
 	// vector, etc -- for the provided attestation token data.
 	GetAttestation(ctx context.Context, in *AttestationToken, opts ...grpc.CallOption) (*AppraisalContext, error)
+	// Returns a single attestation result for all the members of the
+	// provided evidence collection, with one submod per member.
+	GetCollectionAttestation(ctx context.Context, in *AttestationTokenCollection, opts ...grpc.CallOption) (*AppraisalContext, error)
 	GetSupportedVerificationMediaTypes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MediaTypeList, error)
 	GetSupportedProvisioningMediaTypes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MediaTypeList, error)
 	SubmitEndorsements(ctx context.Context, in *SubmitEndorsementsRequest, opts ...grpc.CallOption) (*SubmitEndorsementsResponse, error)
+	// Decodes the endorsements and synthesizes their keys, like
+	// SubmitEndorsements, but does not store anything. This is a separate RPC,
+	// rather than a flag of SubmitEndorsementsRequest, so that a VTS that does
+	// not support dry runs fails them as unimplemented, instead of ignoring
+	// the flag and storing the endorsements.
+	DryRunEndorsements(ctx context.Context, in *SubmitEndorsementsRequest, opts ...grpc.CallOption) (*SubmitEndorsementsResponse, error)
+	// Exports (a subset of) the trust anchor and endorsement stores to a
+	// portable archive.
+	ExportEndorsements(ctx context.Context, in *ExportEndorsementsRequest, opts ...grpc.CallOption) (*StoreArchive, error)
+	// Imports the contents of an archive produced by ExportEndorsements,
+	// synthesizing the store keys using the evidence handlers of the schemes.
+	ImportEndorsements(ctx context.Context, in *ImportEndorsementsRequest, opts ...grpc.CallOption) (*ImportEndorsementsResponse, error)
 	// Returns the public key used to sign evidence.
 	GetEARSigningPublicKey(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PublicKey, error)
+	// Returns the media types of the attestation result formats (EAR-JWT,
+	// EAR-CWT) the service can produce. The first one is the default.
+	GetSupportedResultMediaTypes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MediaTypeList, error)
 }
 
 type vTSClient struct {
@@ -57,6 +75,15 @@ func (c *vTSClient) GetAttestation(ctx context.Context, in *AttestationToken, op
 	return out, nil
 }
 
+func (c *vTSClient) GetCollectionAttestation(ctx context.Context, in *AttestationTokenCollection, opts ...grpc.CallOption) (*AppraisalContext, error) {
+	out := new(AppraisalContext)
+	err := c.cc.Invoke(ctx, "/proto.VTS/GetCollectionAttestation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *vTSClient) GetSupportedVerificationMediaTypes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MediaTypeList, error) {
 	out := new(MediaTypeList)
 	err := c.cc.Invoke(ctx, "/proto.VTS/GetSupportedVerificationMediaTypes", in, out, opts...)
@@ -84,6 +111,33 @@ func (c *vTSClient) SubmitEndorsements(ctx context.Context, in *SubmitEndorsemen
 	return out, nil
 }
 
+func (c *vTSClient) DryRunEndorsements(ctx context.Context, in *SubmitEndorsementsRequest, opts ...grpc.CallOption) (*SubmitEndorsementsResponse, error) {
+	out := new(SubmitEndorsementsResponse)
+	err := c.cc.Invoke(ctx, "/proto.VTS/DryRunEndorsements", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vTSClient) ExportEndorsements(ctx context.Context, in *ExportEndorsementsRequest, opts ...grpc.CallOption) (*StoreArchive, error) {
+	out := new(StoreArchive)
+	err := c.cc.Invoke(ctx, "/proto.VTS/ExportEndorsements", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vTSClient) ImportEndorsements(ctx context.Context, in *ImportEndorsementsRequest, opts ...grpc.CallOption) (*ImportEndorsementsResponse, error) {
+	out := new(ImportEndorsementsResponse)
+	err := c.cc.Invoke(ctx, "/proto.VTS/ImportEndorsements", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *vTSClient) GetEARSigningPublicKey(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PublicKey, error) {
 	out := new(PublicKey)
 	err := c.cc.Invoke(ctx, "/proto.VTS/GetEARSigningPublicKey", in, out, opts...)
@@ -93,6 +147,15 @@ func (c *vTSClient) GetEARSigningPublicKey(ctx context.Context, in *emptypb.Empt
 	return out, nil
 }
 
+func (c *vTSClient) GetSupportedResultMediaTypes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*MediaTypeList, error) {
+	out := new(MediaTypeList)
+	err := c.cc.Invoke(ctx, "/proto.VTS/GetSupportedResultMediaTypes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // VTSServer is the server API for VTS service.
 // All implementations must embed UnimplementedVTSServer
 // for forward compatibility
@@ -102,11 +165,29 @@ type VTSServer interface {
 	// Returns attestation information -- evidences, endorsed claims, trust
 	// vector, etc -- for the provided attestation token data.
 	GetAttestation(context.Context, *AttestationToken) (*AppraisalContext, error)
+	// Returns a single attestation result for all the members of the
+	// provided evidence collection, with one submod per member.
+	GetCollectionAttestation(context.Context, *AttestationTokenCollection) (*AppraisalContext, error)
 	GetSupportedVerificationMediaTypes(context.Context, *emptypb.Empty) (*MediaTypeList, error)
 	GetSupportedProvisioningMediaTypes(context.Context, *emptypb.Empty) (*MediaTypeList, error)
 	SubmitEndorsements(context.Context, *SubmitEndorsementsRequest) (*SubmitEndorsementsResponse, error)
+	// Decodes the endorsements and synthesizes their keys, like
+	// SubmitEndorsements, but does not store anything. This is a separate RPC,
+	// rather than a flag of SubmitEndorsementsRequest, so that a VTS that does
+	// not support dry runs fails them as unimplemented, instead of ignoring
+	// the flag and storing the endorsements.
+	DryRunEndorsements(context.Context, *SubmitEndorsementsRequest) (*SubmitEndorsementsResponse, error)
+	// Exports (a subset of) the trust anchor and endorsement stores to a
+	// portable archive.
+	ExportEndorsements(context.Context, *ExportEndorsementsRequest) (*StoreArchive, error)
+	// Imports the contents of an archive produced by ExportEndorsements,
+	// synthesizing the store keys using the evidence handlers of the schemes.
+	ImportEndorsements(context.Context, *ImportEndorsementsRequest) (*ImportEndorsementsResponse, error)
 	// Returns the public key used to sign evidence.
 	GetEARSigningPublicKey(context.Context, *emptypb.Empty) (*PublicKey, error)
+	// Returns the media types of the attestation result formats (EAR-JWT,
+	// EAR-CWT) the service can produce. The first one is the default.
+	GetSupportedResultMediaTypes(context.Context, *emptypb.Empty) (*MediaTypeList, error)
 	mustEmbedUnimplementedVTSServer()
 }
 
@@ -120,6 +201,9 @@ func (UnimplementedVTSServer) GetServiceState(context.Context, *emptypb.Empty) (
 func (UnimplementedVTSServer) GetAttestation(context.Context, *AttestationToken) (*AppraisalContext, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAttestation not implemented")
 }
+func (UnimplementedVTSServer) GetCollectionAttestation(context.Context, *AttestationTokenCollection) (*AppraisalContext, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCollectionAttestation not implemented")
+}
 func (UnimplementedVTSServer) GetSupportedVerificationMediaTypes(context.Context, *emptypb.Empty) (*MediaTypeList, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetSupportedVerificationMediaTypes not implemented")
 }
@@ -129,9 +213,21 @@ func (UnimplementedVTSServer) GetSupportedProvisioningMediaTypes(context.Context
 func (UnimplementedVTSServer) SubmitEndorsements(context.Context, *SubmitEndorsementsRequest) (*SubmitEndorsementsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SubmitEndorsements not implemented")
 }
+func (UnimplementedVTSServer) DryRunEndorsements(context.Context, *SubmitEndorsementsRequest) (*SubmitEndorsementsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DryRunEndorsements not implemented")
+}
+func (UnimplementedVTSServer) ExportEndorsements(context.Context, *ExportEndorsementsRequest) (*StoreArchive, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportEndorsements not implemented")
+}
+func (UnimplementedVTSServer) ImportEndorsements(context.Context, *ImportEndorsementsRequest) (*ImportEndorsementsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportEndorsements not implemented")
+}
 func (UnimplementedVTSServer) GetEARSigningPublicKey(context.Context, *emptypb.Empty) (*PublicKey, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetEARSigningPublicKey not implemented")
 }
+func (UnimplementedVTSServer) GetSupportedResultMediaTypes(context.Context, *emptypb.Empty) (*MediaTypeList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSupportedResultMediaTypes not implemented")
+}
 func (UnimplementedVTSServer) mustEmbedUnimplementedVTSServer() {}
 
 // UnsafeVTSServer may be embedded to opt out of forward compatibility for this service.
@@ -181,6 +277,24 @@ func _VTS_GetAttestation_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VTS_GetCollectionAttestation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AttestationTokenCollection)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VTSServer).GetCollectionAttestation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.VTS/GetCollectionAttestation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VTSServer).GetCollectionAttestation(ctx, req.(*AttestationTokenCollection))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VTS_GetSupportedVerificationMediaTypes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(emptypb.Empty)
 	if err := dec(in); err != nil {
@@ -235,6 +349,60 @@ func _VTS_SubmitEndorsements_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VTS_DryRunEndorsements_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitEndorsementsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VTSServer).DryRunEndorsements(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.VTS/DryRunEndorsements",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VTSServer).DryRunEndorsements(ctx, req.(*SubmitEndorsementsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VTS_ExportEndorsements_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportEndorsementsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VTSServer).ExportEndorsements(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.VTS/ExportEndorsements",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VTSServer).ExportEndorsements(ctx, req.(*ExportEndorsementsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VTS_ImportEndorsements_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportEndorsementsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VTSServer).ImportEndorsements(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.VTS/ImportEndorsements",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VTSServer).ImportEndorsements(ctx, req.(*ImportEndorsementsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VTS_GetEARSigningPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(emptypb.Empty)
 	if err := dec(in); err != nil {
@@ -253,6 +421,24 @@ func _VTS_GetEARSigningPublicKey_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VTS_GetSupportedResultMediaTypes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VTSServer).GetSupportedResultMediaTypes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.VTS/GetSupportedResultMediaTypes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VTSServer).GetSupportedResultMediaTypes(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // VTS_ServiceDesc is the grpc.ServiceDesc for VTS service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -268,6 +454,10 @@ var VTS_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAttestation",
 			Handler:    _VTS_GetAttestation_Handler,
 		},
+		{
+			MethodName: "GetCollectionAttestation",
+			Handler:    _VTS_GetCollectionAttestation_Handler,
+		},
 		{
 			MethodName: "GetSupportedVerificationMediaTypes",
 			Handler:    _VTS_GetSupportedVerificationMediaTypes_Handler,
@@ -280,10 +470,26 @@ var VTS_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SubmitEndorsements",
 			Handler:    _VTS_SubmitEndorsements_Handler,
 		},
+		{
+			MethodName: "DryRunEndorsements",
+			Handler:    _VTS_DryRunEndorsements_Handler,
+		},
+		{
+			MethodName: "ExportEndorsements",
+			Handler:    _VTS_ExportEndorsements_Handler,
+		},
+		{
+			MethodName: "ImportEndorsements",
+			Handler:    _VTS_ImportEndorsements_Handler,
+		},
 		{
 			MethodName: "GetEARSigningPublicKey",
 			Handler:    _VTS_GetEARSigningPublicKey_Handler,
 		},
+		{
+			MethodName: "GetSupportedResultMediaTypes",
+			Handler:    _VTS_GetSupportedResultMediaTypes_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "vts.proto",
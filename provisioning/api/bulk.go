@@ -0,0 +1,425 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package api
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/veraison/services/provisioning/provisioner"
+)
+
+const (
+	ProvisioningReportMediaType = "application/vnd.veraison.provisioning-report+json"
+
+	// BulkManifestName is the name of the archive entry that maps the names
+	// of the other entries onto their media types.
+	BulkManifestName = "manifest.json"
+
+	BulkModeValidateFirst = "validate-first"
+	BulkModeBestEffort    = "best-effort"
+
+	ProvisioningReportStatusPartial = "partial"
+
+	PartStatusSkipped = "skipped"
+)
+
+var (
+	// MaxBulkSubmissionSize is the maximum size, in bytes, of the body of a
+	// bulk submission.
+	MaxBulkSubmissionSize int64 = 64 << 20
+
+	// MaxBulkPartSize is the maximum size, in bytes, of each of the parts (or
+	// archive entries) of a bulk submission.
+	MaxBulkPartSize int64 = 16 << 20
+
+	// ErrBulkPartTooLarge is returned when a part of a bulk submission
+	// exceeds MaxBulkPartSize.
+	ErrBulkPartTooLarge = errors.New("part too large")
+)
+
+// ProvisioningReport reports the outcome of a bulk submission.
+type ProvisioningReport struct {
+	// Status is "success" if all the parts were provisioned, "failed" if
+	// none was, and "partial" otherwise.
+	Status string       `json:"status"`
+	Mode   string       `json:"mode"`
+	Parts  []PartReport `json:"parts"`
+	// FailureReason is set if storing a part of a "validate-first"
+	// submission failed after all the parts had been validated. Storing is
+	// best-effort: the parts stored before the failure are not rolled back.
+	FailureReason *string `json:"failure-reason,omitempty"`
+}
+
+// PartReport reports the outcome of provisioning one of the parts of a bulk
+// submission.
+type PartReport struct {
+	Label         string               `json:"label"`
+	MediaType     string               `json:"media-type"`
+	Status        string               `json:"status"`
+	FailureReason *string              `json:"failure-reason,omitempty"`
+	Endorsements  []EndorsementOutcome `json:"endorsements,omitempty"`
+}
+
+func (o *PartReport) fail(reason string) {
+	o.Status = ProvisioningSessionStatusFailed
+	o.FailureReason = &reason
+}
+
+type bulkPart struct {
+	Label     string
+	MediaType string
+	Data      []byte
+}
+
+// parseBulkParts extracts the parts of a bulk submission from a multipart
+// body, or a tar archive.
+func parseBulkParts(mediaType string, body io.Reader) ([]bulkPart, error) {
+	mt, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Type: %w", err)
+	}
+
+	var parts []bulkPart
+
+	switch {
+	case strings.HasPrefix(mt, "multipart/"):
+		parts, err = parseMultipartParts(body, params["boundary"])
+	case mt == "application/x-tar":
+		parts, err = parseTarParts(body)
+	default:
+		return nil, fmt.Errorf("unsupported bulk submission media type %s", mt)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 0 {
+		return nil, errors.New("no parts in bulk submission")
+	}
+
+	return parts, nil
+}
+
+func parseMultipartParts(body io.Reader, boundary string) ([]bulkPart, error) {
+	if boundary == "" {
+		return nil, errors.New("missing multipart boundary")
+	}
+
+	var parts []bulkPart
+
+	reader := multipart.NewReader(body, boundary)
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read multipart body: %w", err)
+		}
+
+		label := p.FileName()
+		if label == "" {
+			label = p.FormName()
+		}
+		if label == "" {
+			label = fmt.Sprintf("part-%d", len(parts))
+		}
+
+		part := bulkPart{Label: label, MediaType: p.Header.Get("Content-Type")}
+		if part.MediaType == "" {
+			return nil, fmt.Errorf("part %q: missing Content-Type", label)
+		}
+
+		if part.Data, err = readBulkPart(p); err != nil {
+			return nil, fmt.Errorf("part %q: %w", label, err)
+		}
+
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+func parseTarParts(body io.Reader) ([]bulkPart, error) {
+	var (
+		parts     []bulkPart
+		mediaType map[string]string
+	)
+
+	reader := tar.NewReader(body)
+	for {
+		hdr, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read archive: %w", err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if hdr.Size > MaxBulkPartSize {
+			return nil, fmt.Errorf("entry %q: %w (max %d bytes)",
+				hdr.Name, ErrBulkPartTooLarge, MaxBulkPartSize)
+		}
+
+		data, err := readBulkPart(reader)
+		if err != nil {
+			return nil, fmt.Errorf("entry %q: %w", hdr.Name, err)
+		}
+
+		if hdr.Name == BulkManifestName {
+			if err := json.Unmarshal(data, &mediaType); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", BulkManifestName, err)
+			}
+			continue
+		}
+
+		parts = append(parts, bulkPart{Label: hdr.Name, Data: data})
+	}
+
+	if mediaType == nil {
+		return nil, fmt.Errorf("%s not found in archive", BulkManifestName)
+	}
+
+	for i := range parts {
+		mt, ok := mediaType[parts[i].Label]
+		if !ok {
+			return nil, fmt.Errorf("entry %q: no media type in %s", parts[i].Label, BulkManifestName)
+		}
+		parts[i].MediaType = mt
+		delete(mediaType, parts[i].Label)
+	}
+
+	if len(mediaType) != 0 {
+		var missing []string
+		for name := range mediaType {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+
+		return nil, fmt.Errorf("entries in %s not found in archive: %s",
+			BulkManifestName, strings.Join(missing, ", "))
+	}
+
+	return parts, nil
+}
+
+// readBulkPart reads a part of a bulk submission, up to MaxBulkPartSize bytes.
+func readBulkPart(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxBulkPartSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > MaxBulkPartSize {
+		return nil, fmt.Errorf("%w (max %d bytes)", ErrBulkPartTooLarge, MaxBulkPartSize)
+	}
+
+	return data, nil
+}
+
+// BulkSubmit provisions the endorsements in each of the parts of a multipart
+// body, or of a tar archive, routing each to the endorsement handler for its
+// media type. With the "validate-first" mode (the default), all the parts are
+// validated with a dry run before any is stored, and nothing is stored if any
+// fails validation. Storing the validated parts is best-effort, not
+// transactional: if storing a part fails anyway, the parts stored before it
+// are kept, and the report says so. With the "best-effort" mode, each part is
+// submitted independently.
+func (o *Handler) BulkSubmit(c *gin.Context) {
+	offered := c.NegotiateFormat(ProvisioningReportMediaType)
+	if offered != ProvisioningReportMediaType {
+		ReportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s", ProvisioningReportMediaType),
+		)
+		return
+	}
+
+	mode := c.DefaultQuery("mode", BulkModeValidateFirst)
+	if mode != BulkModeValidateFirst && mode != BulkModeBestEffort {
+		ReportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("mode must be one of %s, %s; got %q",
+				BulkModeValidateFirst, BulkModeBestEffort, mode),
+		)
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, MaxBulkSubmissionSize)
+
+	parts, err := parseBulkParts(c.Request.Header.Get("Content-Type"), body)
+	if err != nil {
+		status := http.StatusBadRequest
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, ErrBulkPartTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+
+		ReportProblem(c,
+			status,
+			fmt.Sprintf("could not read bulk submission: %v", err),
+		)
+		return
+	}
+
+	report := &ProvisioningReport{
+		Mode:  mode,
+		Parts: make([]PartReport, len(parts)),
+	}
+
+	for i, part := range parts {
+		report.Parts[i] = PartReport{
+			Label:     part.Label,
+			MediaType: part.MediaType,
+			Status:    PartStatusSkipped,
+		}
+
+		isSupported, err := o.Provisioner.IsSupportedMediaType(part.MediaType)
+		if err != nil {
+			ReportProblem(c,
+				http.StatusInternalServerError,
+				fmt.Sprintf("could not check media type with provisioner: %v", err),
+			)
+			return
+		}
+
+		if !isSupported {
+			report.Parts[i].fail(fmt.Sprintf("no active plugin found for %s", part.MediaType))
+		}
+	}
+
+	if mode == BulkModeValidateFirst {
+		if err := o.bulkSubmitValidateFirst(parts, report); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, provisioner.ErrDryRunNotSupported) {
+				status = http.StatusNotImplemented
+			}
+
+			ReportProblem(c,
+				status,
+				fmt.Sprintf("could not validate bulk submission: %v", err),
+			)
+			return
+		}
+	} else {
+		o.bulkSubmitBestEffort(parts, report)
+	}
+
+	report.Status = bulkReportStatus(report.Parts)
+
+	o.logger.Infow("bulk submission processed", "mode", mode,
+		"parts", len(parts), "status", report.Status)
+
+	c.Header("Content-Type", ProvisioningReportMediaType)
+	c.JSON(http.StatusOK, report)
+}
+
+// bulkSubmitValidateFirst validates all the parts with a dry run, and submits
+// them only if they are all valid. Only the parts rejected by VTS fail
+// validation: any other dry run error (e.g. VTS cannot be reached) is
+// returned, as the validity of the parts is then unknown. A failure to store a
+// part (rather than to decode it) stops the submission of the remaining parts,
+// but the parts already stored are not rolled back: VTS offers no way of
+// removing them.
+func (o *Handler) bulkSubmitValidateFirst(parts []bulkPart, report *ProvisioningReport) error {
+	valid := true
+
+	for i, part := range parts {
+		if report.Parts[i].Status == ProvisioningSessionStatusFailed {
+			valid = false
+			continue
+		}
+
+		outcomes, err := o.Provisioner.DryRunEndorsements(tenantID, part.Data, part.MediaType)
+		if err != nil {
+			var rejected provisioner.RejectedError
+			if !errors.As(err, &rejected) {
+				o.logger.Errorw("dry run failed", "part", part.Label, "error", err)
+				return fmt.Errorf("part %q: %w", part.Label, err)
+			}
+
+			report.Parts[i].fail(fmt.Sprintf("dry run returned error: %s", err))
+			report.Parts[i].Endorsements = newEndorsementOutcomes(outcomes, EndorsementStatusValid)
+			valid = false
+		}
+	}
+
+	if !valid {
+		return nil
+	}
+
+	for i, part := range parts {
+		outcomes, err := o.Provisioner.SubmitEndorsements(tenantID, part.Data, part.MediaType)
+		report.Parts[i].Endorsements = newEndorsementOutcomes(outcomes, EndorsementStatusStored)
+
+		if err != nil {
+			o.logger.Errorw("submit endorsement failed", "part", part.Label, "error", err)
+			report.Parts[i].fail(fmt.Sprintf("submit endorsement returned error: %s", err))
+
+			reason := fmt.Sprintf("storing part %q failed after all the parts were validated: "+
+				"the parts stored before it have not been rolled back", part.Label)
+			report.FailureReason = &reason
+
+			return nil
+		}
+
+		report.Parts[i].Status = ProvisioningSessionStatusSuccess
+	}
+
+	return nil
+}
+
+// bulkSubmitBestEffort submits each of the parts independently.
+func (o *Handler) bulkSubmitBestEffort(parts []bulkPart, report *ProvisioningReport) {
+	for i, part := range parts {
+		if report.Parts[i].Status == ProvisioningSessionStatusFailed {
+			continue
+		}
+
+		outcomes, err := o.Provisioner.SubmitEndorsements(tenantID, part.Data, part.MediaType)
+		report.Parts[i].Endorsements = newEndorsementOutcomes(outcomes, EndorsementStatusStored)
+
+		if err != nil {
+			o.logger.Errorw("submit endorsement failed", "part", part.Label, "error", err)
+			report.Parts[i].fail(fmt.Sprintf("submit endorsement returned error: %s", err))
+			continue
+		}
+
+		report.Parts[i].Status = ProvisioningSessionStatusSuccess
+	}
+}
+
+func bulkReportStatus(parts []PartReport) string {
+	var succeeded int
+
+	for _, part := range parts {
+		if part.Status == ProvisioningSessionStatusSuccess {
+			succeeded++
+		}
+	}
+
+	switch succeeded {
+	case len(parts):
+		return ProvisioningSessionStatusSuccess
+	case 0:
+		return ProvisioningSessionStatusFailed
+	default:
+		return ProvisioningReportStatusPartial
+	}
+}
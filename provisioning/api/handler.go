@@ -3,6 +3,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -12,7 +13,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/veraison/services/capability"
+	"github.com/veraison/services/proto"
 	"github.com/veraison/services/provisioning/provisioner"
+	"github.com/veraison/services/sessionmanager"
 	"go.uber.org/zap"
 )
 
@@ -22,36 +25,138 @@ var (
 
 type IHandler interface {
 	Submit(c *gin.Context)
+	BulkSubmit(c *gin.Context)
+	DryRun(c *gin.Context)
+	GetSession(c *gin.Context)
 	GetWellKnownProvisioningInfo(c *gin.Context)
 }
 
 type Handler struct {
 	Provisioner provisioner.IProvisioner
+	// SessionManager holds the asynchronous provisioning sessions. If it is
+	// nil, submissions are always processed synchronously.
+	SessionManager sessionmanager.ISessionManager
+	// SessionTTL is how long asynchronous provisioning sessions are kept
+	// once processed.
+	SessionTTL time.Duration
 
 	logger *zap.SugaredLogger
 }
 
 func NewHandler(
 	p provisioner.IProvisioner,
+	sm sessionmanager.ISessionManager,
+	sessionTTL time.Duration,
 	logger *zap.SugaredLogger,
 ) IHandler {
 	return &Handler{
-		Provisioner: p,
-		logger:      logger,
+		Provisioner:    p,
+		SessionManager: sm,
+		SessionTTL:     sessionTTL,
+		logger:         logger,
 	}
 }
 
 type ProvisioningSession struct {
-	Status        string  `json:"status"`
-	Expiry        string  `json:"expiry"`
-	FailureReason *string `json:"failure-reason,omitempty"`
+	Status        string               `json:"status"`
+	Expiry        string               `json:"expiry"`
+	FailureReason *string              `json:"failure-reason,omitempty"`
+	Endorsements  []EndorsementOutcome `json:"endorsements,omitempty"`
+}
+
+// EndorsementOutcome summarizes the outcome of provisioning one of the
+// endorsements in a submission.
+type EndorsementOutcome struct {
+	Scheme        string   `json:"scheme"`
+	Type          string   `json:"type"`
+	SubType       string   `json:"sub-type,omitempty"`
+	Keys          []string `json:"keys,omitempty"`
+	Status        string   `json:"status"`
+	FailureReason *string  `json:"failure-reason,omitempty"`
+	// Attributes are the decoded attributes of the endorsement. They are
+	// only reported by dry runs.
+	Attributes json.RawMessage `json:"attributes,omitempty"`
 }
 
 const (
 	ProvisioningSessionMediaType = "application/vnd.veraison.provisioning-session+json"
+
+	ProvisioningSessionStatusProcessing = "processing"
+	ProvisioningSessionStatusSuccess    = "success"
+	ProvisioningSessionStatusFailed     = "failed"
+
+	EndorsementStatusStored  = "stored"
+	EndorsementStatusValid   = "valid"
+	EndorsementStatusFailed  = "failed"
+	EndorsementStatusSkipped = "skipped"
 )
 
-func (o *Handler) Submit(c *gin.Context) {
+// newEndorsementOutcomes converts the outcomes returned by the provisioner.
+// successStatus is the status of the endorsements that were processed
+// successfully (i.e. stored, or validated in a dry run).
+func newEndorsementOutcomes(
+	outcomes []*proto.EndorsementOutcome,
+	successStatus string,
+) []EndorsementOutcome {
+	var ret []EndorsementOutcome
+
+	for _, o := range outcomes {
+		outcome := EndorsementOutcome{
+			Scheme:  o.GetScheme(),
+			Type:    o.GetType(),
+			SubType: o.GetSubType(),
+			Keys:    o.GetKeys(),
+		}
+
+		if len(o.GetAttributes()) != 0 {
+			outcome.Attributes = o.GetAttributes()
+		}
+
+		switch {
+		case o.GetStatus() == nil:
+			outcome.Status = EndorsementStatusSkipped
+		case o.GetStatus().GetResult():
+			outcome.Status = successStatus
+		default:
+			reason := o.GetStatus().GetErrorDetail()
+			outcome.Status = EndorsementStatusFailed
+			outcome.FailureReason = &reason
+		}
+
+		ret = append(ret, outcome)
+	}
+
+	return ret
+}
+
+func newFailedProvisioningSession(
+	failureReason string,
+	outcomes []*proto.EndorsementOutcome,
+	expiry time.Time,
+) *ProvisioningSession {
+	return &ProvisioningSession{
+		Status:        ProvisioningSessionStatusFailed,
+		Expiry:        expiry.Format(time.RFC3339),
+		FailureReason: &failureReason,
+		Endorsements:  newEndorsementOutcomes(outcomes, EndorsementStatusStored),
+	}
+}
+
+func newSuccessfulProvisioningSession(
+	outcomes []*proto.EndorsementOutcome,
+	expiry time.Time,
+) *ProvisioningSession {
+	return &ProvisioningSession{
+		Status:       ProvisioningSessionStatusSuccess,
+		Expiry:       expiry.Format(time.RFC3339),
+		Endorsements: newEndorsementOutcomes(outcomes, EndorsementStatusStored),
+	}
+}
+
+// readEndorsements negotiates the response format, and reads the endorsements
+// from the request body, checking that their media type is supported. If there
+// is a problem, it is reported and false is returned.
+func (o *Handler) readEndorsements(c *gin.Context) ([]byte, string, bool) {
 	// read the accept header and make sure that it's compatible with what we
 	// support
 	offered := c.NegotiateFormat(ProvisioningSessionMediaType)
@@ -60,7 +165,7 @@ func (o *Handler) Submit(c *gin.Context) {
 			http.StatusNotAcceptable,
 			fmt.Sprintf("the only supported output format is %s", ProvisioningSessionMediaType),
 		)
-		return
+		return nil, "", false
 	}
 
 	// read media type
@@ -72,7 +177,7 @@ func (o *Handler) Submit(c *gin.Context) {
 			http.StatusInternalServerError,
 			fmt.Sprintf("could not check media type with verifier: %v", err),
 		)
-		return
+		return nil, "", false
 	}
 
 	if !isSupported {
@@ -83,7 +188,7 @@ func (o *Handler) Submit(c *gin.Context) {
 				fmt.Sprintf("could not get supported media types from provisioner: %v",
 					err),
 			)
-			return
+			return nil, "", false
 		}
 
 		c.Header("Accept", strings.Join(supportedMediaTypes, ", "))
@@ -91,7 +196,7 @@ func (o *Handler) Submit(c *gin.Context) {
 			http.StatusUnsupportedMediaType,
 			fmt.Sprintf("no active plugin found for %s", mediaType),
 		)
-		return
+		return nil, "", false
 	}
 
 	// read body
@@ -101,7 +206,7 @@ func (o *Handler) Submit(c *gin.Context) {
 			http.StatusBadRequest,
 			fmt.Sprintf("error reading body: %s", err),
 		)
-		return
+		return nil, "", false
 	}
 
 	if len(payload) == 0 {
@@ -109,10 +214,26 @@ func (o *Handler) Submit(c *gin.Context) {
 			http.StatusBadRequest,
 			"empty body",
 		)
+		return nil, "", false
+	}
+
+	return payload, mediaType, true
+}
+
+func (o *Handler) Submit(c *gin.Context) {
+	payload, mediaType, ok := o.readEndorsements(c)
+	if !ok {
 		return
 	}
 
-	err = o.Provisioner.SubmitEndorsements(tenantID, payload, mediaType)
+	// the preference for asynchronous processing is ignored if sessions
+	// cannot be stored
+	if o.SessionManager != nil && preferAsync(c) {
+		o.submitAsync(c, payload, mediaType)
+		return
+	}
+
+	outcomes, err := o.Provisioner.SubmitEndorsements(tenantID, payload, mediaType)
 	if err != nil {
 		o.logger.Errorw("submit endorsement failed", "error", err)
 
@@ -127,33 +248,63 @@ func (o *Handler) Submit(c *gin.Context) {
 		sendFailedProvisioningSession(
 			c,
 			fmt.Sprintf("submit endorsement returned error: %s", err),
+			outcomes,
 		)
 		return
 	}
 
-	sendSuccessfulProvisioningSession(c)
+	sendSuccessfulProvisioningSession(c, outcomes)
+}
+
+// DryRun decodes the submitted endorsements, and returns them along with the
+// keys they would be stored under, without storing them.
+func (o *Handler) DryRun(c *gin.Context) {
+	payload, mediaType, ok := o.readEndorsements(c)
+	if !ok {
+		return
+	}
+
+	outcomes, err := o.Provisioner.DryRunEndorsements(tenantID, payload, mediaType)
+	if errors.Is(err, provisioner.ErrDryRunNotSupported) {
+		ReportProblem(c, http.StatusNotImplemented, err.Error())
+		return
+	}
+
+	session := &ProvisioningSession{
+		Status:       ProvisioningSessionStatusSuccess,
+		Expiry:       time.Now().Format(time.RFC3339),
+		Endorsements: newEndorsementOutcomes(outcomes, EndorsementStatusValid),
+	}
+
+	if err != nil {
+		o.logger.Infow("dry run failed", "error", err)
+
+		failureReason := fmt.Sprintf("dry run returned error: %s", err)
+		session.Status = ProvisioningSessionStatusFailed
+		session.FailureReason = &failureReason
+	}
+
+	c.Header("Content-Type", ProvisioningSessionMediaType)
+	c.JSON(http.StatusOK, session)
 }
 
-func sendFailedProvisioningSession(c *gin.Context, failureReason string) {
+func sendFailedProvisioningSession(
+	c *gin.Context,
+	failureReason string,
+	outcomes []*proto.EndorsementOutcome,
+) {
 	c.Header("Content-Type", ProvisioningSessionMediaType)
 	c.JSON(
 		http.StatusOK,
-		&ProvisioningSession{
-			Status:        "failed",
-			Expiry:        time.Now().Format(time.RFC3339),
-			FailureReason: &failureReason,
-		},
+		newFailedProvisioningSession(failureReason, outcomes, time.Now()),
 	)
 }
 
-func sendSuccessfulProvisioningSession(c *gin.Context) {
+func sendSuccessfulProvisioningSession(c *gin.Context, outcomes []*proto.EndorsementOutcome) {
 	c.Header("Content-Type", ProvisioningSessionMediaType)
 	c.JSON(
 		http.StatusOK,
-		&ProvisioningSession{
-			Status: "success",
-			Expiry: time.Now().Format(time.RFC3339),
-		},
+		newSuccessfulProvisioningSession(outcomes, time.Now()),
 	)
 }
 
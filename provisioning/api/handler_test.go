@@ -3,24 +3,33 @@
 package api
 
 import (
+	"archive/tar"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/moogar0880/problems"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/veraison/services/auth"
 	"github.com/veraison/services/capability"
 	"github.com/veraison/services/log"
 	"github.com/veraison/services/proto"
 	mock_deps "github.com/veraison/services/provisioning/api/mocks"
+	"github.com/veraison/services/provisioning/provisioner"
 )
 
 var (
@@ -28,6 +37,51 @@ var (
 		Status:        2,
 		ServerVersion: "3.2",
 	}
+
+	testOutcomes = []*proto.EndorsementOutcome{
+		{
+			Scheme:  "PSA_IOT",
+			Type:    "trust anchor",
+			SubType: "PSA_IOT.iak-public-key",
+			Keys:    []string{"psa://0/ta"},
+			Status:  &proto.Status{Result: true},
+		},
+		{
+			Scheme:  "PSA_IOT",
+			Type:    "reference value",
+			SubType: "PSA_IOT.sw-component",
+			Status:  &proto.Status{Result: false, ErrorDetail: "store failed"},
+		},
+		{
+			Scheme:  "PSA_IOT",
+			Type:    "reference value",
+			SubType: "PSA_IOT.sw-component",
+		},
+	}
+
+	testStoreFailure         = "store failed"
+	testExpectedEndorsements = []EndorsementOutcome{
+		{
+			Scheme:  "PSA_IOT",
+			Type:    "trust anchor",
+			SubType: "PSA_IOT.iak-public-key",
+			Keys:    []string{"psa://0/ta"},
+			Status:  EndorsementStatusStored,
+		},
+		{
+			Scheme:        "PSA_IOT",
+			Type:          "reference value",
+			SubType:       "PSA_IOT.sw-component",
+			Status:        EndorsementStatusFailed,
+			FailureReason: &testStoreFailure,
+		},
+		{
+			Scheme:  "PSA_IOT",
+			Type:    "reference value",
+			SubType: "PSA_IOT.sw-component",
+			Status:  EndorsementStatusSkipped,
+		},
+	}
 )
 
 func TestHandler_Submit_UnsupportedAccept(t *testing.T) {
@@ -75,7 +129,7 @@ func TestHandler_Submit_UnsupportedMediaType(t *testing.T) {
 		SupportedMediaTypes().
 		Return(supportedMediaTypes, nil)
 
-	h := NewHandler(dm, log.Named("test"))
+	h := NewHandler(dm, nil, 0, log.Named("test"))
 
 	expectedCode := http.StatusUnsupportedMediaType
 	expectedType := "application/problem+json"
@@ -118,7 +172,7 @@ func TestHandler_Submit_NoBody(t *testing.T) {
 		).
 		Return(true, nil)
 
-	h := NewHandler(dm, log.Named("test"))
+	h := NewHandler(dm, nil, 0, log.Named("test"))
 
 	expectedCode := http.StatusBadRequest
 	expectedType := "application/problem+json"
@@ -166,9 +220,9 @@ func TestHandler_Submit_DecodeFailure(t *testing.T) {
 		SubmitEndorsements(
 			tenantID, endo, gomock.Eq(mediaType),
 		).
-		Return(errors.New(handlerError))
+		Return(nil, errors.New(handlerError))
 
-	h := NewHandler(dm, log.Named("test"))
+	h := NewHandler(dm, nil, 0, log.Named("test"))
 
 	expectedCode := http.StatusOK
 	expectedType := ProvisioningSessionMediaType
@@ -204,7 +258,7 @@ func TestHandler_Submit_ok(t *testing.T) {
 	expectedType := ProvisioningSessionMediaType
 	expectedStatus := "success"
 	dm := mock_deps.NewMockIProvisioner(ctrl)
-	h := NewHandler(dm, log.Named("api"))
+	h := NewHandler(dm, nil, 0, log.Named("api"))
 
 	w := httptest.NewRecorder()
 	g, _ := gin.CreateTestContext(w)
@@ -218,7 +272,7 @@ func TestHandler_Submit_ok(t *testing.T) {
 		SubmitEndorsements(
 			tenantID, endo, gomock.Eq(mediaType),
 		).
-		Return(nil)
+		Return(testOutcomes[:1], nil)
 	g.Request, _ = http.NewRequest(http.MethodPost, "/", bytes.NewReader(endo))
 	g.Request.Header.Add("Content-Type", mediaType)
 	g.Request.Header.Add("Accept", ProvisioningSessionMediaType)
@@ -232,6 +286,7 @@ func TestHandler_Submit_ok(t *testing.T) {
 	assert.Equal(t, expectedType, w.Result().Header.Get("Content-Type"))
 	assert.Nil(t, body.FailureReason)
 	assert.Equal(t, expectedStatus, body.Status)
+	assert.Equal(t, testExpectedEndorsements[:1], body.Endorsements)
 }
 
 func TestHandler_GetWellKnownProvisioningInfo_ok(t *testing.T) {
@@ -249,7 +304,7 @@ func TestHandler_GetWellKnownProvisioningInfo_ok(t *testing.T) {
 		GetVTSState().
 		Return(&testGoodServiceState, nil)
 
-	h := NewHandler(dm, log.Named("test"))
+	h := NewHandler(dm, nil, 0, log.Named("test"))
 
 	expectedCode := http.StatusOK
 	expectedType := capability.WellKnownMediaType
@@ -291,7 +346,7 @@ func TestHandler_GetWellKnownProvisioningInfo_GetRegisteredMediaTypes_empty(t *t
 		GetVTSState().
 		Return(&testGoodServiceState, nil)
 
-	h := NewHandler(dm, log.Named("test"))
+	h := NewHandler(dm, nil, 0, log.Named("test"))
 
 	expectedCode := http.StatusOK
 	expectedType := capability.WellKnownMediaType
@@ -332,7 +387,7 @@ func TestHandler_GetWellKnownProvisioningInfo_GetServiceState_fail(t *testing.T)
 		GetVTSState().
 		Return(nil, errors.New("blah"))
 
-	h := NewHandler(dm, log.Named("test"))
+	h := NewHandler(dm, nil, 0, log.Named("test"))
 
 	expectedCode := http.StatusInternalServerError
 	expectedType := "application/problem+json"
@@ -382,3 +437,794 @@ func TestHandler_GetWellKnownProvisioningInfo_UnsupportedAccept(t *testing.T) {
 	assert.Equal(t, expectedType, w.Result().Header.Get("Content-Type"))
 	assert.Equal(t, expectedBody, body)
 }
+
+func TestHandler_Submit_async(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mediaType := "application/good+json"
+	endo := []byte("some data")
+	sessionTTL := 10 * time.Minute
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().
+		IsSupportedMediaType(gomock.Eq(mediaType)).
+		Return(true, nil)
+	dm.EXPECT().
+		SubmitEndorsements(tenantID, endo, gomock.Eq(mediaType)).
+		Return(testOutcomes, errors.New(testStoreFailure))
+
+	var (
+		sessionID uuid.UUID
+		processed ProvisioningSession
+	)
+	done := make(chan struct{})
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	gomock.InOrder(
+		sm.EXPECT().
+			SetSession(gomock.Any(), tenantID, gomock.Any(), sessionTTL).
+			DoAndReturn(func(id uuid.UUID, _ string, session json.RawMessage, _ time.Duration) error {
+				var s ProvisioningSession
+				require.NoError(t, json.Unmarshal(session, &s))
+				assert.Equal(t, ProvisioningSessionStatusProcessing, s.Status)
+				sessionID = id
+				return nil
+			}),
+		sm.EXPECT().
+			SetSession(gomock.Any(), tenantID, gomock.Any(), sessionTTL).
+			DoAndReturn(func(id uuid.UUID, _ string, session json.RawMessage, _ time.Duration) error {
+				defer close(done)
+				assert.Equal(t, sessionID, id)
+				return json.Unmarshal(session, &processed)
+			}),
+	)
+
+	h := NewHandler(dm, sm, sessionTTL, log.Named("test"))
+
+	w := httptest.NewRecorder()
+	g, _ := gin.CreateTestContext(w)
+
+	g.Request, _ = http.NewRequest(http.MethodPost, "/", bytes.NewReader(endo))
+	g.Request.Header.Add("Content-Type", mediaType)
+	g.Request.Header.Add("Accept", ProvisioningSessionMediaType)
+	g.Request.Header.Add("Prefer", "respond-async, wait=10")
+
+	h.Submit(g)
+
+	var body ProvisioningSession
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, ProvisioningSessionMediaType, w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, ProvisioningSessionStatusProcessing, body.Status)
+	assert.Nil(t, body.Endorsements)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("submission was not processed")
+	}
+
+	assert.Equal(t, "session/"+sessionID.String(), w.Result().Header.Get("Location"))
+	assert.Equal(t, ProvisioningSessionStatusFailed, processed.Status)
+	require.NotNil(t, processed.FailureReason)
+	assert.Equal(t, "submit endorsement returned error: store failed", *processed.FailureReason)
+	assert.Equal(t, testExpectedEndorsements, processed.Endorsements)
+}
+
+func TestHandler_Submit_async_long_running(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mediaType := "application/good+json"
+	endo := []byte("some data")
+	sessionTTL := 20 * time.Millisecond
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().
+		IsSupportedMediaType(gomock.Eq(mediaType)).
+		Return(true, nil)
+	dm.EXPECT().
+		SubmitEndorsements(tenantID, endo, gomock.Eq(mediaType)).
+		DoAndReturn(func(string, []byte, string) ([]*proto.EndorsementOutcome, error) {
+			// the submission takes longer than the session TTL
+			time.Sleep(5 * sessionTTL)
+			return testOutcomes, nil
+		})
+
+	var (
+		mu       sync.Mutex
+		statuses []string
+	)
+	done := make(chan struct{})
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		SetSession(gomock.Any(), tenantID, gomock.Any(), sessionTTL).
+		DoAndReturn(func(_ uuid.UUID, _ string, session json.RawMessage, _ time.Duration) error {
+			var s ProvisioningSession
+			require.NoError(t, json.Unmarshal(session, &s))
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			statuses = append(statuses, s.Status)
+			if s.Status != ProvisioningSessionStatusProcessing {
+				close(done)
+			}
+			return nil
+		}).
+		MinTimes(3)
+
+	h := NewHandler(dm, sm, sessionTTL, log.Named("test"))
+
+	w := httptest.NewRecorder()
+	g, _ := gin.CreateTestContext(w)
+
+	g.Request, _ = http.NewRequest(http.MethodPost, "/", bytes.NewReader(endo))
+	g.Request.Header.Add("Content-Type", mediaType)
+	g.Request.Header.Add("Accept", ProvisioningSessionMediaType)
+	g.Request.Header.Add("Prefer", "respond-async")
+
+	h.Submit(g)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("submission was not processed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the processing session is stored again (extending its expiry) while
+	// the submission is processed, and never after its outcome
+	require.GreaterOrEqual(t, len(statuses), 3)
+	for _, status := range statuses[:len(statuses)-1] {
+		assert.Equal(t, ProvisioningSessionStatusProcessing, status)
+	}
+	assert.Equal(t, ProvisioningSessionStatusSuccess, statuses[len(statuses)-1])
+}
+
+func TestHandler_Submit_async_no_session_manager(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mediaType := "application/good+json"
+	endo := []byte("some data")
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().
+		IsSupportedMediaType(gomock.Eq(mediaType)).
+		Return(true, nil)
+	dm.EXPECT().
+		SubmitEndorsements(tenantID, endo, gomock.Eq(mediaType)).
+		Return(nil, nil)
+
+	h := NewHandler(dm, nil, 0, log.Named("test"))
+
+	w := httptest.NewRecorder()
+	g, _ := gin.CreateTestContext(w)
+
+	g.Request, _ = http.NewRequest(http.MethodPost, "/", bytes.NewReader(endo))
+	g.Request.Header.Add("Content-Type", mediaType)
+	g.Request.Header.Add("Accept", ProvisioningSessionMediaType)
+	g.Request.Header.Add("Prefer", "respond-async")
+
+	h.Submit(g)
+
+	var body ProvisioningSession
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ProvisioningSessionStatusSuccess, body.Status)
+}
+
+func TestHandler_GetSession_ok(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id := uuid.New()
+	session := []byte(`{"status":"processing","expiry":"2023-01-01T00:00:00Z"}`)
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		GetSession(id, tenantID).
+		Return(session, nil)
+
+	h := NewHandler(nil, sm, 0, log.Named("test"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/endorsement-provisioning/v1/session/"+id.String(), http.NoBody)
+	req.Header.Add("Accept", ProvisioningSessionMediaType)
+
+	u := auth.NewPassthroughAuthorizer(log.Named("auth"))
+	NewRouter(h, u).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ProvisioningSessionMediaType, w.Result().Header.Get("Content-Type"))
+	assert.JSONEq(t, string(session), w.Body.String())
+}
+
+func TestHandler_GetSession_not_found(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id := uuid.New()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		GetSession(id, tenantID).
+		Return(nil, errors.New("session not found"))
+
+	h := NewHandler(nil, sm, 0, log.Named("test"))
+
+	expectedBody := problems.DefaultProblem{
+		Type:   "about:blank",
+		Title:  "Not Found",
+		Status: http.StatusNotFound,
+		Detail: fmt.Sprintf("no provisioning session found with id %s", id),
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/endorsement-provisioning/v1/session/"+id.String(), http.NoBody)
+	req.Header.Add("Accept", ProvisioningSessionMediaType)
+
+	u := auth.NewPassthroughAuthorizer(log.Named("auth"))
+	NewRouter(h, u).ServeHTTP(w, req)
+
+	var body problems.DefaultProblem
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, expectedBody, body)
+}
+
+func TestHandler_GetSession_bad_id(t *testing.T) {
+	h := NewHandler(nil, nil, 0, log.Named("test"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/endorsement-provisioning/v1/session/xyz", http.NoBody)
+	req.Header.Add("Accept", ProvisioningSessionMediaType)
+
+	u := auth.NewPassthroughAuthorizer(log.Named("auth"))
+	NewRouter(h, u).ServeHTTP(w, req)
+
+	var body problems.DefaultProblem
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, body.Detail, "invalid session id")
+}
+
+func Test_preferAsync(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"":                       false,
+		"respond-async":          true,
+		"Respond-Async":          true,
+		"wait=10, respond-async": true,
+		"respond-async; foo=bar": true,
+		"return=minimal":         false,
+	} {
+		w := httptest.NewRecorder()
+		g, _ := gin.CreateTestContext(w)
+
+		g.Request, _ = http.NewRequest(http.MethodPost, "/", http.NoBody)
+		if header != "" {
+			g.Request.Header.Add("Prefer", header)
+		}
+
+		assert.Equal(t, expected, preferAsync(g), header)
+	}
+}
+
+func TestHandler_DryRun_ok(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mediaType := "application/good+json"
+	endo := []byte("some data")
+	attributes := []byte(`{"PSA_IOT.iak-pub":"-----BEGIN PUBLIC KEY-----"}`)
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().
+		IsSupportedMediaType(gomock.Eq(mediaType)).
+		Return(true, nil)
+	dm.EXPECT().
+		DryRunEndorsements(tenantID, endo, gomock.Eq(mediaType)).
+		Return([]*proto.EndorsementOutcome{
+			{
+				Scheme:     "PSA_IOT",
+				Type:       "trust anchor",
+				SubType:    "PSA_IOT.iak-public-key",
+				Keys:       []string{"psa://0/ta"},
+				Status:     &proto.Status{Result: true},
+				Attributes: attributes,
+			},
+		}, nil)
+
+	h := NewHandler(dm, nil, 0, log.Named("test"))
+
+	expectedEndorsements := []EndorsementOutcome{
+		{
+			Scheme:     "PSA_IOT",
+			Type:       "trust anchor",
+			SubType:    "PSA_IOT.iak-public-key",
+			Keys:       []string{"psa://0/ta"},
+			Status:     EndorsementStatusValid,
+			Attributes: attributes,
+		},
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/endorsement-provisioning/v1/dry-run", bytes.NewReader(endo))
+	req.Header.Add("Content-Type", mediaType)
+	req.Header.Add("Accept", ProvisioningSessionMediaType)
+
+	u := auth.NewPassthroughAuthorizer(log.Named("auth"))
+	NewRouter(h, u).ServeHTTP(w, req)
+
+	var body ProvisioningSession
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ProvisioningSessionMediaType, w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, ProvisioningSessionStatusSuccess, body.Status)
+	assert.Nil(t, body.FailureReason)
+	assert.Equal(t, expectedEndorsements, body.Endorsements)
+}
+
+func TestHandler_DryRun_failed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mediaType := "application/good+json"
+	endo := []byte("some data")
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().
+		IsSupportedMediaType(gomock.Eq(mediaType)).
+		Return(true, nil)
+	dm.EXPECT().
+		DryRunEndorsements(tenantID, endo, gomock.Eq(mediaType)).
+		Return(nil, errors.New("decoding failure: doh!"))
+
+	h := NewHandler(dm, nil, 0, log.Named("test"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/endorsement-provisioning/v1/dry-run", bytes.NewReader(endo))
+	req.Header.Add("Content-Type", mediaType)
+	req.Header.Add("Accept", ProvisioningSessionMediaType)
+
+	u := auth.NewPassthroughAuthorizer(log.Named("auth"))
+	NewRouter(h, u).ServeHTTP(w, req)
+
+	var body ProvisioningSession
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ProvisioningSessionStatusFailed, body.Status)
+	require.NotNil(t, body.FailureReason)
+	assert.Equal(t, "dry run returned error: decoding failure: doh!", *body.FailureReason)
+	assert.Nil(t, body.Endorsements)
+}
+
+func TestHandler_DryRun_not_supported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mediaType := "application/good+json"
+	endo := []byte("some data")
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().
+		IsSupportedMediaType(gomock.Eq(mediaType)).
+		Return(true, nil)
+	dm.EXPECT().
+		DryRunEndorsements(tenantID, endo, gomock.Eq(mediaType)).
+		Return(nil, provisioner.ErrDryRunNotSupported)
+
+	h := NewHandler(dm, nil, 0, log.Named("test"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/endorsement-provisioning/v1/dry-run", bytes.NewReader(endo))
+	req.Header.Add("Content-Type", mediaType)
+	req.Header.Add("Accept", ProvisioningSessionMediaType)
+
+	u := auth.NewPassthroughAuthorizer(log.Named("auth"))
+	NewRouter(h, u).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func newMultipartBody(t *testing.T, parts map[string]string) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hdr := textproto.MIMEHeader{}
+		hdr.Set("Content-Disposition", fmt.Sprintf(`attachment; filename=%q`, name))
+		hdr.Set("Content-Type", parts[name])
+
+		w, err := mw.CreatePart(hdr)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("data of " + name))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, mw.Close())
+
+	return &body, "multipart/mixed; boundary=" + mw.Boundary()
+}
+
+func newTarBody(t *testing.T, entries map[string][]byte) *bytes.Buffer {
+	var body bytes.Buffer
+
+	tw := tar.NewWriter(&body)
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(entries[name])),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write(entries[name])
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+
+	return &body
+}
+
+func doBulkSubmit(h IHandler, query string, body *bytes.Buffer, mediaType string) (*httptest.ResponseRecorder, ProvisioningReport) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/endorsement-provisioning/v1/bulk-submit"+query, body)
+	req.Header.Add("Content-Type", mediaType)
+	req.Header.Add("Accept", ProvisioningReportMediaType)
+
+	u := auth.NewPassthroughAuthorizer(log.Named("auth"))
+	NewRouter(h, u).ServeHTTP(w, req)
+
+	var report ProvisioningReport
+	_ = json.Unmarshal(w.Body.Bytes(), &report)
+
+	return w, report
+}
+
+func TestHandler_BulkSubmit_validate_first_ok(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	body, mediaType := newMultipartBody(t, map[string]string{
+		"a.cbor": "application/type-1",
+		"b.cbor": "application/type-2",
+	})
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().IsSupportedMediaType("application/type-1").Return(true, nil)
+	dm.EXPECT().IsSupportedMediaType("application/type-2").Return(true, nil)
+	gomock.InOrder(
+		dm.EXPECT().
+			DryRunEndorsements(tenantID, []byte("data of a.cbor"), "application/type-1").
+			Return(testOutcomes[:1], nil),
+		dm.EXPECT().
+			DryRunEndorsements(tenantID, []byte("data of b.cbor"), "application/type-2").
+			Return(nil, nil),
+		dm.EXPECT().
+			SubmitEndorsements(tenantID, []byte("data of a.cbor"), "application/type-1").
+			Return(testOutcomes[:1], nil),
+		dm.EXPECT().
+			SubmitEndorsements(tenantID, []byte("data of b.cbor"), "application/type-2").
+			Return(nil, nil),
+	)
+
+	h := NewHandler(dm, nil, 0, log.Named("test"))
+
+	w, report := doBulkSubmit(h, "", body, mediaType)
+
+	expected := ProvisioningReport{
+		Status: ProvisioningSessionStatusSuccess,
+		Mode:   BulkModeValidateFirst,
+		Parts: []PartReport{
+			{
+				Label:        "a.cbor",
+				MediaType:    "application/type-1",
+				Status:       ProvisioningSessionStatusSuccess,
+				Endorsements: testExpectedEndorsements[:1],
+			},
+			{
+				Label:     "b.cbor",
+				MediaType: "application/type-2",
+				Status:    ProvisioningSessionStatusSuccess,
+			},
+		},
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ProvisioningReportMediaType, w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, expected, report)
+}
+
+func TestHandler_BulkSubmit_validate_first_invalid_part(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	body, mediaType := newMultipartBody(t, map[string]string{
+		"a.cbor": "application/type-1",
+		"b.cbor": "application/type-2",
+		"c.cbor": "application/unsupported",
+	})
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().IsSupportedMediaType("application/type-1").Return(true, nil)
+	dm.EXPECT().IsSupportedMediaType("application/type-2").Return(true, nil)
+	dm.EXPECT().IsSupportedMediaType("application/unsupported").Return(false, nil)
+	dm.EXPECT().
+		DryRunEndorsements(tenantID, []byte("data of a.cbor"), "application/type-1").
+		Return(nil, nil)
+	dm.EXPECT().
+		DryRunEndorsements(tenantID, []byte("data of b.cbor"), "application/type-2").
+		Return(nil, provisioner.RejectedError{Context: "dry run", Detail: "decoding failure: doh!"})
+
+	h := NewHandler(dm, nil, 0, log.Named("test"))
+
+	w, report := doBulkSubmit(h, "?mode=validate-first", body, mediaType)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ProvisioningSessionStatusFailed, report.Status)
+	require.Len(t, report.Parts, 3)
+	assert.Equal(t, PartStatusSkipped, report.Parts[0].Status)
+	assert.Equal(t, ProvisioningSessionStatusFailed, report.Parts[1].Status)
+	assert.Equal(t, "dry run returned error: dry run failed: decoding failure: doh!", *report.Parts[1].FailureReason)
+	assert.Equal(t, ProvisioningSessionStatusFailed, report.Parts[2].Status)
+	assert.Equal(t, "no active plugin found for application/unsupported", *report.Parts[2].FailureReason)
+}
+
+func TestHandler_BulkSubmit_validate_first_dry_run_error(t *testing.T) {
+	for _, tc := range []struct {
+		err          error
+		expectedCode int
+	}{
+		{err: errors.New("no connection"), expectedCode: http.StatusInternalServerError},
+		{err: provisioner.ErrDryRunNotSupported, expectedCode: http.StatusNotImplemented},
+	} {
+		ctrl := gomock.NewController(t)
+
+		body, mediaType := newMultipartBody(t, map[string]string{
+			"a.cbor": "application/type-1",
+		})
+
+		// the validity of the part is unknown, so nothing is submitted
+		dm := mock_deps.NewMockIProvisioner(ctrl)
+		dm.EXPECT().IsSupportedMediaType("application/type-1").Return(true, nil)
+		dm.EXPECT().
+			DryRunEndorsements(tenantID, []byte("data of a.cbor"), "application/type-1").
+			Return(nil, tc.err)
+
+		h := NewHandler(dm, nil, 0, log.Named("test"))
+
+		w, _ := doBulkSubmit(h, "", body, mediaType)
+
+		assert.Equal(t, tc.expectedCode, w.Code, tc.err.Error())
+		assert.Contains(t, w.Body.String(), tc.err.Error())
+
+		ctrl.Finish()
+	}
+}
+
+func TestHandler_BulkSubmit_validate_first_store_failure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	body, mediaType := newMultipartBody(t, map[string]string{
+		"a.cbor": "application/type-1",
+		"b.cbor": "application/type-2",
+	})
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().IsSupportedMediaType("application/type-1").Return(true, nil)
+	dm.EXPECT().IsSupportedMediaType("application/type-2").Return(true, nil)
+	gomock.InOrder(
+		dm.EXPECT().
+			DryRunEndorsements(tenantID, []byte("data of a.cbor"), "application/type-1").
+			Return(nil, nil),
+		dm.EXPECT().
+			DryRunEndorsements(tenantID, []byte("data of b.cbor"), "application/type-2").
+			Return(nil, nil),
+		dm.EXPECT().
+			SubmitEndorsements(tenantID, []byte("data of a.cbor"), "application/type-1").
+			Return(nil, nil),
+		dm.EXPECT().
+			SubmitEndorsements(tenantID, []byte("data of b.cbor"), "application/type-2").
+			Return(nil, errors.New("store unavailable")),
+	)
+
+	h := NewHandler(dm, nil, 0, log.Named("test"))
+
+	w, report := doBulkSubmit(h, "", body, mediaType)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ProvisioningReportStatusPartial, report.Status)
+	require.Len(t, report.Parts, 2)
+	assert.Equal(t, ProvisioningSessionStatusSuccess, report.Parts[0].Status)
+	assert.Equal(t, ProvisioningSessionStatusFailed, report.Parts[1].Status)
+	require.NotNil(t, report.FailureReason)
+	assert.Equal(t,
+		`storing part "b.cbor" failed after all the parts were validated: `+
+			`the parts stored before it have not been rolled back`,
+		*report.FailureReason)
+}
+
+func TestHandler_BulkSubmit_best_effort_tar(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	body := newTarBody(t, map[string][]byte{
+		"manifest.json": []byte(`{"a.cbor": "application/type-1", "b.cbor": "application/type-2"}`),
+		"a.cbor":        []byte("data of a.cbor"),
+		"b.cbor":        []byte("data of b.cbor"),
+	})
+
+	dm := mock_deps.NewMockIProvisioner(ctrl)
+	dm.EXPECT().IsSupportedMediaType("application/type-1").Return(true, nil)
+	dm.EXPECT().IsSupportedMediaType("application/type-2").Return(true, nil)
+	dm.EXPECT().
+		SubmitEndorsements(tenantID, []byte("data of a.cbor"), "application/type-1").
+		Return(nil, errors.New("store failed"))
+	dm.EXPECT().
+		SubmitEndorsements(tenantID, []byte("data of b.cbor"), "application/type-2").
+		Return(nil, nil)
+
+	h := NewHandler(dm, nil, 0, log.Named("test"))
+
+	w, report := doBulkSubmit(h, "?mode=best-effort", body, "application/x-tar")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ProvisioningReportStatusPartial, report.Status)
+	assert.Equal(t, BulkModeBestEffort, report.Mode)
+	require.Len(t, report.Parts, 2)
+	assert.Equal(t, ProvisioningSessionStatusFailed, report.Parts[0].Status)
+	assert.Equal(t, "submit endorsement returned error: store failed", *report.Parts[0].FailureReason)
+	assert.Equal(t, ProvisioningSessionStatusSuccess, report.Parts[1].Status)
+}
+
+func TestHandler_BulkSubmit_bad_request(t *testing.T) {
+	h := NewHandler(nil, nil, 0, log.Named("test"))
+
+	multipartBody, multipartType := newMultipartBody(t, map[string]string{"a.cbor": ""})
+
+	for _, tc := range []struct {
+		name      string
+		query     string
+		body      *bytes.Buffer
+		mediaType string
+		detail    string
+	}{
+		{
+			name:      "bad mode",
+			query:     "?mode=some",
+			body:      &bytes.Buffer{},
+			mediaType: "application/x-tar",
+			detail:    `mode must be one of validate-first, best-effort; got "some"`,
+		},
+		{
+			name:      "unsupported media type",
+			body:      &bytes.Buffer{},
+			mediaType: "application/json",
+			detail:    "could not read bulk submission: unsupported bulk submission media type application/json",
+		},
+		{
+			name:      "missing part media type",
+			body:      multipartBody,
+			mediaType: multipartType,
+			detail:    `could not read bulk submission: part "a.cbor": missing Content-Type`,
+		},
+		{
+			name:      "no manifest",
+			body:      newTarBody(t, map[string][]byte{"a.cbor": []byte("data")}),
+			mediaType: "application/x-tar",
+			detail:    "could not read bulk submission: manifest.json not found in archive",
+		},
+		{
+			name: "manifest mismatch",
+			body: newTarBody(t, map[string][]byte{
+				"manifest.json": []byte(`{"a.cbor": "application/type-1", "b.cbor": "application/type-2"}`),
+				"a.cbor":        []byte("data"),
+			}),
+			mediaType: "application/x-tar",
+			detail:    "could not read bulk submission: entries in manifest.json not found in archive: b.cbor",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/endorsement-provisioning/v1/bulk-submit"+tc.query, tc.body)
+			req.Header.Add("Content-Type", tc.mediaType)
+			req.Header.Add("Accept", ProvisioningReportMediaType)
+
+			u := auth.NewPassthroughAuthorizer(log.Named("auth"))
+			NewRouter(h, u).ServeHTTP(w, req)
+
+			var body problems.DefaultProblem
+			_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, tc.detail, body.Detail)
+		})
+	}
+}
+
+func TestHandler_BulkSubmit_too_large(t *testing.T) {
+	defer func(submission, part int64) {
+		MaxBulkSubmissionSize, MaxBulkPartSize = submission, part
+	}(MaxBulkSubmissionSize, MaxBulkPartSize)
+
+	MaxBulkPartSize = 8
+
+	h := NewHandler(nil, nil, 0, log.Named("test"))
+
+	multipartBody, multipartType := newMultipartBody(t, map[string]string{
+		"a.cbor": "application/type-1",
+	})
+
+	for _, tc := range []struct {
+		name      string
+		body      *bytes.Buffer
+		mediaType string
+		detail    string
+	}{
+		{
+			name:      "multipart part",
+			body:      multipartBody,
+			mediaType: multipartType,
+			detail:    `could not read bulk submission: part "a.cbor": part too large (max 8 bytes)`,
+		},
+		{
+			name: "tar entry",
+			body: newTarBody(t, map[string][]byte{
+				"manifest.json": []byte(`{"a.cbor": "application/type-1"}`),
+				"a.cbor":        []byte("data of a.cbor"),
+			}),
+			mediaType: "application/x-tar",
+			detail:    `could not read bulk submission: entry "a.cbor": part too large (max 8 bytes)`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w, _ := doBulkSubmit(h, "", tc.body, tc.mediaType)
+
+			var body problems.DefaultProblem
+			_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+			assert.Equal(t, tc.detail, body.Detail)
+		})
+	}
+
+	MaxBulkPartSize = 1 << 20
+	MaxBulkSubmissionSize = 1024
+
+	body := newTarBody(t, map[string][]byte{
+		"manifest.json": []byte(`{"a.cbor": "application/type-1"}`),
+		"a.cbor":        bytes.Repeat([]byte{0}, 4096),
+	})
+
+	w, _ := doBulkSubmit(h, "", body, "application/x-tar")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -34,6 +34,21 @@ func (m *MockIProvisioner) EXPECT() *MockIProvisionerMockRecorder {
 	return m.recorder
 }
 
+// DryRunEndorsements mocks base method.
+func (m *MockIProvisioner) DryRunEndorsements(tenantID string, data []byte, mt string) ([]*proto.EndorsementOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DryRunEndorsements", tenantID, data, mt)
+	ret0, _ := ret[0].([]*proto.EndorsementOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DryRunEndorsements indicates an expected call of DryRunEndorsements.
+func (mr *MockIProvisionerMockRecorder) DryRunEndorsements(tenantID, data, mt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DryRunEndorsements", reflect.TypeOf((*MockIProvisioner)(nil).DryRunEndorsements), tenantID, data, mt)
+}
+
 // GetVTSState mocks base method.
 func (m *MockIProvisioner) GetVTSState() (*proto.ServiceState, error) {
 	m.ctrl.T.Helper()
@@ -65,11 +80,12 @@ func (mr *MockIProvisionerMockRecorder) IsSupportedMediaType(mt interface{}) *go
 }
 
 // SubmitEndorsements mocks base method.
-func (m *MockIProvisioner) SubmitEndorsements(tenantID string, data []byte, mt string) error {
+func (m *MockIProvisioner) SubmitEndorsements(tenantID string, data []byte, mt string) ([]*proto.EndorsementOutcome, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SubmitEndorsements", tenantID, data, mt)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].([]*proto.EndorsementOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // SubmitEndorsements indicates an expected call of SubmitEndorsements.
@@ -11,6 +11,9 @@ var publicApiMap = make(map[string]string)
 
 const (
 	provisioningSubmitUrl           = "/endorsement-provisioning/v1/submit"
+	provisioningBulkSubmitUrl       = "/endorsement-provisioning/v1/bulk-submit"
+	provisioningDryRunUrl           = "/endorsement-provisioning/v1/dry-run"
+	provisioningSessionUrl          = "/endorsement-provisioning/v1/session/:id"
 	getWellKnownProvisioningInfoUrl = "/.well-known/veraison/provisioning"
 )
 
@@ -24,6 +27,15 @@ func NewRouter(handler IHandler, authorizer auth.IAuthorizer) *gin.Engine {
 	router.POST(provisioningSubmitUrl, handler.Submit)
 	publicApiMap["provisioningSubmit"] = provisioningSubmitUrl
 
+	router.POST(provisioningBulkSubmitUrl, handler.BulkSubmit)
+	publicApiMap["provisioningBulkSubmit"] = provisioningBulkSubmitUrl
+
+	router.POST(provisioningDryRunUrl, handler.DryRun)
+	publicApiMap["provisioningDryRun"] = provisioningDryRunUrl
+
+	router.GET(provisioningSessionUrl, handler.GetSession)
+	publicApiMap["provisioningSession"] = provisioningSessionUrl
+
 	router.GET(getWellKnownProvisioningInfoUrl, handler.GetWellKnownProvisioningInfo)
 
 	return router
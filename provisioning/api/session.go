@@ -0,0 +1,195 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	// DefaultSessionTTL is how long asynchronous provisioning sessions are
+	// kept once their submission has been processed, if not configured
+	// otherwise.
+	DefaultSessionTTL = time.Hour
+)
+
+// preferAsync returns true if the client has expressed a preference for
+// asynchronous processing of the request, using "Prefer: respond-async"
+// (RFC 7240).
+func preferAsync(c *gin.Context) bool {
+	for _, header := range c.Request.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(strings.TrimSpace(pref), ";")
+			if strings.EqualFold(strings.TrimSpace(token), "respond-async") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (o *Handler) sessionTTL() time.Duration {
+	if o.SessionTTL == 0 {
+		return DefaultSessionTTL
+	}
+
+	return o.SessionTTL
+}
+
+func (o *Handler) storeSession(id uuid.UUID, session *ProvisioningSession, ttl time.Duration) error {
+	b, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return o.SessionManager.SetSession(id, tenantID, b, ttl)
+}
+
+// newProcessingSession returns a provisioning session in the processing
+// state, expiring after ttl.
+func newProcessingSession(ttl time.Duration) *ProvisioningSession {
+	return &ProvisioningSession{
+		Status: ProvisioningSessionStatusProcessing,
+		Expiry: time.Now().Add(ttl).Format(time.RFC3339),
+	}
+}
+
+// submitAsync creates a provisioning session in the processing state, and
+// submits the endorsements in the background. The session is returned to the
+// client with 202 Accepted and its location, so that it can be polled for the
+// outcome of the submission.
+func (o *Handler) submitAsync(c *gin.Context, payload []byte, mediaType string) {
+	id := uuid.New()
+	ttl := o.sessionTTL()
+
+	session := newProcessingSession(ttl)
+
+	if err := o.storeSession(id, session, ttl); err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("could not store provisioning session: %v", err),
+		)
+		return
+	}
+
+	go o.processSubmission(id, payload, mediaType)
+
+	c.Header("Location", path.Join("session", id.String()))
+	c.Header("Content-Type", ProvisioningSessionMediaType)
+	c.JSON(http.StatusAccepted, session)
+}
+
+// processSubmission submits the endorsements of an asynchronous provisioning
+// session, and records the outcome in the session. The session is kept alive
+// while the submission is processed, however long that takes, and for the
+// session TTL after that. The outcome is recorded even if the session has
+// expired meanwhile (e.g. because it could not be kept alive).
+func (o *Handler) processSubmission(id uuid.UUID, payload []byte, mediaType string) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		o.keepSessionAlive(id, done)
+	}()
+
+	outcomes, err := o.Provisioner.SubmitEndorsements(tenantID, payload, mediaType)
+
+	// make sure that the processing session is not stored again once the
+	// outcome has been recorded
+	close(done)
+	<-stopped
+
+	var session *ProvisioningSession
+	ttl := o.sessionTTL()
+	expiry := time.Now().Add(ttl)
+
+	if err != nil {
+		o.logger.Errorw("submit endorsement failed", "session", id, "error", err)
+
+		session = newFailedProvisioningSession(
+			fmt.Sprintf("submit endorsement returned error: %s", err),
+			outcomes,
+			expiry,
+		)
+	} else {
+		session = newSuccessfulProvisioningSession(outcomes, expiry)
+	}
+
+	if err := o.storeSession(id, session, ttl); err != nil {
+		o.logger.Errorw("could not store provisioning session", "session", id, "error", err)
+		return
+	}
+
+	o.logger.Infow("provisioning session processed", "session", id, "status", session.Status)
+}
+
+// keepSessionAlive stores the processing session again every half session
+// TTL, extending its expiry, until done is closed.
+func (o *Handler) keepSessionAlive(id uuid.UUID, done <-chan struct{}) {
+	ttl := o.sessionTTL()
+
+	ticker := time.NewTicker(ttl / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := o.storeSession(id, newProcessingSession(ttl), ttl); err != nil {
+				o.logger.Warnw("could not extend provisioning session", "session", id, "error", err)
+			}
+		}
+	}
+}
+
+// GetSession returns the provisioning session identified by the "id" path
+// segment.
+func (o *Handler) GetSession(c *gin.Context) {
+	offered := c.NegotiateFormat(ProvisioningSessionMediaType)
+	if offered != ProvisioningSessionMediaType {
+		ReportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the only supported output format is %s", ProvisioningSessionMediaType),
+		)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ReportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("invalid session id (%s) in path segment: %v", c.Request.URL.Path, err),
+		)
+		return
+	}
+
+	if o.SessionManager == nil {
+		ReportProblem(c,
+			http.StatusNotFound,
+			fmt.Sprintf("no provisioning session found with id %s", id),
+		)
+		return
+	}
+
+	session, err := o.SessionManager.GetSession(id, tenantID)
+	if err != nil {
+		ReportProblem(c,
+			http.StatusNotFound,
+			fmt.Sprintf("no provisioning session found with id %s", id),
+		)
+		return
+	}
+
+	c.Data(http.StatusOK, ProvisioningSessionMediaType, session)
+}
@@ -8,12 +8,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/veraison/services/auth"
 	"github.com/veraison/services/config"
 	"github.com/veraison/services/log"
 	"github.com/veraison/services/provisioning/api"
 	"github.com/veraison/services/provisioning/provisioner"
+	"github.com/veraison/services/sessionmanager"
 	"github.com/veraison/services/vtsclient"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -24,6 +26,7 @@ var (
 
 type cfg struct {
 	ListenAddr string `mapstructure:"listen-addr" valid:"dialstring"`
+	SessionTTL string `mapstructure:"session-ttl"`
 }
 
 func main() {
@@ -36,6 +39,7 @@ func main() {
 
 	cfg := cfg{
 		ListenAddr: DefaultListenAddr,
+		SessionTTL: api.DefaultSessionTTL.String(),
 	}
 
 	subs, err := config.GetSubs(v, "provisioning", "vts", "*logging", "*auth")
@@ -56,6 +60,11 @@ func main() {
 		log.Fatalf("Could not load config: %v", err)
 	}
 
+	sessionTTL, err := time.ParseDuration(cfg.SessionTTL)
+	if err != nil {
+		log.Fatalf("Could not load config: invalid session-ttl: %q", cfg.SessionTTL)
+	}
+
 	log.Info("initializing VTS client")
 	vtsClient := vtsclient.NewGRPC()
 	if err := vtsClient.Init(subs["vts"]); err != nil {
@@ -85,7 +94,14 @@ func main() {
 		}
 	}()
 
-	apiHandler := api.NewHandler(provisioner, log.Named("api"))
+	log.Info("initializing provisioning session manager")
+	sessionManager := sessionmanager.NewSessionManagerTTLCache()
+	if err := sessionManager.Init(sessionmanager.Config{"ttl": sessionTTL.String()}); err != nil {
+		log.Fatalf("could not init session manager: %v", err)
+	}
+	defer sessionManager.Close()
+
+	apiHandler := api.NewHandler(provisioner, sessionManager, sessionTTL, log.Named("api"))
 	go apiServer(apiHandler, authorizer, cfg.ListenAddr)
 
 	sigs := make(chan os.Signal, 1)
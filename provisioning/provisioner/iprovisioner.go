@@ -8,5 +8,11 @@ type IProvisioner interface {
 	GetVTSState() (*proto.ServiceState, error)
 	IsSupportedMediaType(mt string) (bool, error)
 	SupportedMediaTypes() ([]string, error)
-	SubmitEndorsements(tenantID string, data []byte, mt string) error
+	// SubmitEndorsements submits the endorsements to the VTS, returning
+	// the outcome of storing each of them. Outcomes may be returned
+	// alongside an error, if the submission failed part way through.
+	SubmitEndorsements(tenantID string, data []byte, mt string) ([]*proto.EndorsementOutcome, error)
+	// DryRunEndorsements decodes the endorsements, and synthesizes the keys
+	// they would be stored under, without storing them.
+	DryRunEndorsements(tenantID string, data []byte, mt string) ([]*proto.EndorsementOutcome, error)
 }
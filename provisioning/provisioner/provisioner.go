@@ -10,9 +10,27 @@ import (
 
 	"github.com/veraison/services/proto"
 	"github.com/veraison/services/vtsclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+// ErrDryRunNotSupported is returned by DryRunEndorsements if VTS does not
+// support dry runs (i.e. it predates them).
+var ErrDryRunNotSupported = errors.New("dry runs are not supported by VTS")
+
+// RejectedError is returned by SubmitEndorsements and DryRunEndorsements when
+// VTS rejects the endorsements (e.g. because they could not be decoded), as
+// opposed to failing to process them (e.g. because it could not be reached).
+type RejectedError struct {
+	Context string
+	Detail  string
+}
+
+func (o RejectedError) Error() string {
+	return fmt.Sprintf("%s failed: %s", o.Context, o.Detail)
+}
+
 type Provisioner struct {
 	VTSClient vtsclient.IVTSClient
 }
@@ -53,24 +71,46 @@ func (p *Provisioner) SupportedMediaTypes() ([]string, error) {
 	return mts.GetMediaTypes(), nil
 }
 
-func (p *Provisioner) SubmitEndorsements(tenantID string, data []byte, mt string) error {
-	// return p.VTSClient.SubmitEndorsements(context.Background(),)
+func (p *Provisioner) SubmitEndorsements(
+	tenantID string, data []byte, mt string,
+) ([]*proto.EndorsementOutcome, error) {
 	sReq := &proto.SubmitEndorsementsRequest{MediaType: mt, Data: data}
+
 	sRes, err := p.VTSClient.SubmitEndorsements(context.Background(), sReq)
+
+	return submitOutcomes(sRes, err, "submit endorsements")
+}
+
+func (p *Provisioner) DryRunEndorsements(
+	tenantID string, data []byte, mt string,
+) ([]*proto.EndorsementOutcome, error) {
+	sReq := &proto.SubmitEndorsementsRequest{MediaType: mt, Data: data}
+
+	sRes, err := p.VTSClient.DryRunEndorsements(context.Background(), sReq)
+	if status.Code(err) == codes.Unimplemented {
+		return nil, ErrDryRunNotSupported
+	}
+
+	return submitOutcomes(sRes, err, "dry run")
+}
+
+func submitOutcomes(
+	sRes *proto.SubmitEndorsementsResponse, err error, what string,
+) ([]*proto.EndorsementOutcome, error) {
 	if err != nil {
 		if errors.As(err, &vtsclient.NoConnectionError{}) {
-			return errors.New("no connection")
+			return nil, errors.New("no connection")
 		}
-		return fmt.Errorf("submit endorsements failed: %w", err)
+		return nil, fmt.Errorf("%s failed: %w", what, err)
 	}
 
 	if !sRes.GetStatus().Result {
-		return fmt.Errorf(
-			"submit endorsements failed: %s",
-			sRes.Status.GetErrorDetail(),
-		)
+		return sRes.GetOutcomes(), RejectedError{
+			Context: what,
+			Detail:  sRes.Status.GetErrorDetail(),
+		}
 	}
-	return nil
+	return sRes.GetOutcomes(), nil
 }
 
 func (p *Provisioner) GetVTSState() (*proto.ServiceState, error) {
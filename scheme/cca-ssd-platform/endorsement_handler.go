@@ -8,6 +8,12 @@ import (
 	"github.com/veraison/services/scheme/common/arm"
 )
 
+func init() {
+	common.MustRegisterExtractor(SchemeName, EndorsementProfile, func() common.IExtractor {
+		return &arm.Extractor{Scheme: SchemeName}
+	})
+}
+
 type EndorsementHandler struct{}
 
 func (o EndorsementHandler) Init(params handler.EndorsementHandlerParams) error {
@@ -31,5 +37,5 @@ func (o EndorsementHandler) GetSupportedMediaTypes() []string {
 }
 
 func (o EndorsementHandler) Decode(data []byte) (*handler.EndorsementHandlerResponse, error) {
-	return common.UnsignedCorimDecoder(data, &arm.Extractor{Scheme: SchemeName})
+	return common.UnsignedCorimProfileDecoder(data, SchemeName, &arm.Extractor{Scheme: SchemeName})
 }
@@ -2,11 +2,14 @@
 // SPDX-License-Identifier: Apache-2.0
 package cca_ssd_platform
 
-const SchemeName = "CCA_SSD_PLATFORM"
+const (
+	SchemeName         = "CCA_SSD_PLATFORM"
+	EndorsementProfile = "http://arm.com/cca/ssd/1"
+)
 
 var (
 	EndorsementMediaTypes = []string{
-		"application/corim-unsigned+cbor; profile=http://arm.com/cca/ssd/1",
+		"application/corim-unsigned+cbor; profile=" + EndorsementProfile,
 	}
 
 	EvidenceMediaTypes = []string{
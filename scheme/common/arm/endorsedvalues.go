@@ -0,0 +1,197 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package arm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/services/handler"
+)
+
+// endValClaims are the paths of the evidence claims that the conditions of
+// endorsed values are matched against.
+type endValClaims struct {
+	ImplID  string
+	InstID  string
+	SwComps string
+}
+
+var endValClaimsByScheme = map[string]endValClaims{
+	"PSA_IOT": {
+		ImplID:  "psa-implementation-id",
+		InstID:  "psa-instance-id",
+		SwComps: "psa-software-components",
+	},
+	"CCA_SSD_PLATFORM": {
+		ImplID:  "platform/cca-platform-implementation-id",
+		InstID:  "platform/cca-platform-instance-id",
+		SwComps: "platform/cca-platform-sw-components",
+	},
+	"PARSEC_CCA": {
+		ImplID:  "cca.platform/cca-platform-implementation-id",
+		InstID:  "cca.platform/cca-platform-instance-id",
+		SwComps: "cca.platform/cca-platform-sw-components",
+	},
+}
+
+// EndValExtractor extracts the measurements of an endorsed-value triple (e.g.
+// certification level, or supplier data) into an endorsement that only
+// applies to evidence matching the environment of the triple (its
+// implementation ID and, if specified, instance ID), and containing the
+// software components identified by the keys of its measurements (if any).
+// The endorsement is stored under the implementation ID, just like the
+// reference values of the same platform, so that it is retrieved alongside
+// them at appraisal time, and its condition is then checked against the
+// evidence.
+func (o Extractor) EndValExtractor(ev comid.EndorsedValue) ([]*handler.Endorsement, error) {
+	var (
+		classAttrs ClassAttributes
+		instAttrs  *InstanceAttributes
+	)
+
+	claims, ok := endValClaimsByScheme[o.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("endorsed values are not supported for scheme %s", o.Scheme)
+	}
+
+	if err := classAttrs.FromEnvironment(ev.Environment); err != nil {
+		return nil, fmt.Errorf("could not extract PSA class attributes: %w", err)
+	}
+
+	if ev.Environment.Instance != nil {
+		instAttrs = &InstanceAttributes{}
+		if err := instAttrs.FromEnvironment(ev.Environment); err != nil {
+			return nil, fmt.Errorf("could not extract PSA instance attributes: %w", err)
+		}
+	}
+
+	if ev.Environment.Group != nil {
+		return nil, errors.New("group environments are not supported for endorsed values")
+	}
+
+	if len(ev.Measurements) == 0 {
+		return nil, errors.New("no endorsed values found")
+	}
+
+	attrs, err := makeEndValAttrs(classAttrs, instAttrs, ev.Measurements, o.Scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create endorsed values attributes: %w", err)
+	}
+
+	cond, err := makeEndValCondition(classAttrs, instAttrs, ev.Measurements, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create endorsed values condition: %w", err)
+	}
+
+	endVal := &handler.Endorsement{
+		Scheme:     o.Scheme,
+		Type:       handler.EndorsementType_ENDORSED_VALUE,
+		SubType:    o.Scheme + ".endorsed-values",
+		Attributes: attrs,
+		Condition:  cond,
+	}
+
+	return []*handler.Endorsement{endVal}, nil
+}
+
+func makeEndValAttrs(
+	c ClassAttributes,
+	i *InstanceAttributes,
+	measurements comid.Measurements,
+	scheme string,
+) (json.RawMessage, error) {
+	attrs := map[string]interface{}{
+		scheme + ".impl-id":         c.ImplID,
+		scheme + ".endorsed-values": measurements,
+	}
+
+	if i != nil {
+		attrs[scheme+".inst-id"] = []byte(i.InstID)
+	}
+
+	if c.Vendor != "" {
+		attrs[scheme+".hw-vendor"] = c.Vendor
+	}
+
+	if c.Model != "" {
+		attrs[scheme+".hw-model"] = c.Model
+	}
+
+	msg, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal endorsed values attributes: %w", err)
+	}
+	return msg, nil
+}
+
+// makeEndValCondition returns the condition on the evidence claims under
+// which the endorsed values apply: the implementation ID (and instance ID, if
+// specified) must match the environment, and the evidence must contain the
+// software components identified by the measurement keys, with the specified
+// measurement value, if any.
+func makeEndValCondition(
+	c ClassAttributes,
+	i *InstanceAttributes,
+	measurements comid.Measurements,
+	claims endValClaims,
+) (*handler.Condition, error) {
+	cond := handler.Condition{
+		Claims: map[string]interface{}{
+			claims.ImplID: c.ImplID,
+		},
+	}
+
+	if i != nil {
+		cond.Claims[claims.InstID] = []byte(i.InstID)
+	}
+
+	for idx, m := range measurements {
+		if m.Key == nil || !m.Key.IsSet() {
+			continue
+		}
+
+		swComp, err := makeSwCompEntry(m)
+		if err != nil {
+			return nil, fmt.Errorf("measurement at index %d: %w", idx, err)
+		}
+
+		if cond.Entries == nil {
+			cond.Entries = make(map[string][]map[string]interface{})
+		}
+		cond.Entries[claims.SwComps] = append(cond.Entries[claims.SwComps], swComp)
+	}
+
+	return &cond, nil
+}
+
+func makeSwCompEntry(m comid.Measurement) (map[string]interface{}, error) {
+	id, err := m.Key.GetPSARefValID()
+	if err != nil {
+		return nil, fmt.Errorf("failed extracting psa-swcomp-id: %w", err)
+	}
+
+	entry := map[string]interface{}{
+		"signer-id": id.SignerID,
+	}
+
+	if id.Label != nil {
+		entry["measurement-type"] = *id.Label
+	}
+
+	if id.Version != nil {
+		entry["version"] = *id.Version
+	}
+
+	if d := m.Val.Digests; d != nil {
+		if len(*d) != 1 {
+			return nil, errors.New("expecting at most one digest")
+		}
+
+		entry["measurement-value"] = (*d)[0].HashValue
+	}
+
+	return entry, nil
+}
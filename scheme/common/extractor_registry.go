@@ -0,0 +1,107 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ExtractorFactory returns a new instance of the IExtractor for a CoRIM
+// profile.
+type ExtractorFactory func() IExtractor
+
+// IProfileSetter may be implemented by extractors that need to know the
+// profile of the CoRIM they are extracting from.
+type IProfileSetter interface {
+	SetProfile(profile string)
+}
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = make(map[string]map[string]ExtractorFactory)
+)
+
+// RegisterExtractor registers the factory of the extractor for the specified
+// CoRIM profile (an OID or URI, as found in the CoRIM profile field) of the
+// specified attestation scheme. It is an error to register more than one
+// extractor for the same profile of a scheme.
+//
+// Extractors are only ever looked up by their own scheme, so that the
+// extractor used for a CoRIM does not depend on which other schemes happen to
+// be linked into the same binary (all of them, when using the builtin loader,
+// only the handler's own, when using go-plugin).
+func RegisterExtractor(scheme, profile string, factory ExtractorFactory) error {
+	if scheme == "" {
+		return errors.New("empty attestation scheme")
+	}
+
+	if profile == "" {
+		return errors.New("empty CoRIM profile")
+	}
+
+	if factory == nil {
+		return fmt.Errorf("nil extractor factory for CoRIM profile %q", profile)
+	}
+
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	if _, ok := extractors[scheme][profile]; ok {
+		return fmt.Errorf("an extractor is already registered for CoRIM profile %q of scheme %s",
+			profile, scheme)
+	}
+
+	if extractors[scheme] == nil {
+		extractors[scheme] = make(map[string]ExtractorFactory)
+	}
+	extractors[scheme][profile] = factory
+
+	return nil
+}
+
+// MustRegisterExtractor is like RegisterExtractor, but panics on error. It is
+// meant to be used in the init() of attestation scheme packages.
+func MustRegisterExtractor(scheme, profile string, factory ExtractorFactory) {
+	if err := RegisterExtractor(scheme, profile, factory); err != nil {
+		panic(err)
+	}
+}
+
+// NewExtractor returns a new instance of the extractor registered for the
+// specified CoRIM profile of the specified attestation scheme.
+func NewExtractor(scheme, profile string) (IExtractor, error) {
+	extractorsMu.RLock()
+	factory, ok := extractors[scheme][profile]
+	extractorsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no extractor registered for CoRIM profile %q of scheme %s",
+			profile, scheme)
+	}
+
+	xtr := factory()
+
+	if setter, ok := xtr.(IProfileSetter); ok {
+		setter.SetProfile(profile)
+	}
+
+	return xtr, nil
+}
+
+// RegisteredProfiles returns the sorted list of the CoRIM profiles for which
+// an extractor has been registered for the specified attestation scheme.
+func RegisteredProfiles(scheme string) []string {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	profiles := make([]string, 0, len(extractors[scheme]))
+	for profile := range extractors[scheme] {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+
+	return profiles
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+	"github.com/veraison/services/handler"
+)
+
+type testExtractor struct {
+	Profile string
+}
+
+func (o *testExtractor) SetProfile(p string) {
+	o.Profile = p
+}
+
+func (o testExtractor) RefValExtractor(comid.ReferenceValue) ([]*handler.Endorsement, error) {
+	return nil, nil
+}
+
+func (o testExtractor) TaExtractor(comid.AttestVerifKey) (*handler.Endorsement, error) {
+	return nil, nil
+}
+
+func newTestExtractor() IExtractor {
+	return &testExtractor{}
+}
+
+const testScheme = "TEST_SCHEME"
+
+func TestRegisterExtractor(t *testing.T) {
+	profile := "http://example.com/test/register"
+
+	err := RegisterExtractor(testScheme, profile, newTestExtractor)
+	require.NoError(t, err)
+	assert.Contains(t, RegisteredProfiles(testScheme), profile)
+	assert.NotContains(t, RegisteredProfiles("OTHER_SCHEME"), profile)
+
+	err = RegisterExtractor(testScheme, profile, newTestExtractor)
+	assert.EqualError(t, err,
+		`an extractor is already registered for CoRIM profile "http://example.com/test/register" of scheme TEST_SCHEME`)
+
+	// the same profile may be registered by another scheme
+	err = RegisterExtractor("OTHER_SCHEME", profile, newTestExtractor)
+	assert.NoError(t, err)
+
+	err = RegisterExtractor("", profile, newTestExtractor)
+	assert.EqualError(t, err, "empty attestation scheme")
+
+	err = RegisterExtractor(testScheme, "", newTestExtractor)
+	assert.EqualError(t, err, "empty CoRIM profile")
+
+	err = RegisterExtractor(testScheme, "http://example.com/test/nil", nil)
+	assert.EqualError(t, err, `nil extractor factory for CoRIM profile "http://example.com/test/nil"`)
+}
+
+func TestNewExtractor(t *testing.T) {
+	profile := "1.2.3.4"
+
+	MustRegisterExtractor(testScheme, profile, newTestExtractor)
+
+	xtr, err := NewExtractor(testScheme, profile)
+	require.NoError(t, err)
+	assert.Equal(t, profile, xtr.(*testExtractor).Profile)
+
+	// each call returns a new instance
+	other, err := NewExtractor(testScheme, profile)
+	require.NoError(t, err)
+	assert.NotSame(t, xtr, other)
+
+	_, err = NewExtractor(testScheme, "http://example.com/test/unknown")
+	assert.EqualError(t, err,
+		`no extractor registered for CoRIM profile "http://example.com/test/unknown" of scheme TEST_SCHEME`)
+
+	_, err = NewExtractor("OTHER_SCHEME", profile)
+	assert.EqualError(t, err,
+		`no extractor registered for CoRIM profile "1.2.3.4" of scheme OTHER_SCHEME`)
+}
+
+func TestMustRegisterExtractor_panics(t *testing.T) {
+	profile := "http://example.com/test/must"
+
+	MustRegisterExtractor(testScheme, profile, newTestExtractor)
+
+	assert.Panics(t, func() { MustRegisterExtractor(testScheme, profile, newTestExtractor) })
+}
+
+func TestUnsignedCorimProfileDecoder_empty(t *testing.T) {
+	_, err := UnsignedCorimProfileDecoder(nil, testScheme, nil)
+	assert.EqualError(t, err, "empty data")
+}
+
+func newTestCorim(t *testing.T, profile string) []byte {
+	var c comid.Comid
+	require.NoError(t, c.FromJSON([]byte(`{
+		"tag-identity": {"id": "00000000-0000-0000-0000-000000000000"},
+		"triples": {
+			"reference-values": [{
+				"environment": {
+					"instance": {"type": "uuid", "value": "ffffffff-ffff-ffff-ffff-ffffffffffff"}
+				},
+				"measurements": [{
+					"value": {"digests": ["sha-256:h0KPxSKAPTEGXnvOPPA/5HUJZjHl4Hu9eg/eYMTPJcc="]}
+				}]
+			}]
+		}
+	}`)))
+
+	uc := corim.NewUnsignedCorim().
+		SetID("11111111-1111-1111-1111-111111111111").
+		AddProfile(profile).
+		AddComid(c)
+	require.NotNil(t, uc)
+
+	data, err := uc.ToCBOR()
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestUnsignedCorimProfileDecoder_fallback(t *testing.T) {
+	profile := "http://example.com/test/registered"
+	MustRegisterExtractor(testScheme, profile, newTestExtractor)
+
+	_, err := UnsignedCorimProfileDecoder(newTestCorim(t, profile), testScheme, nil)
+	assert.NoError(t, err)
+
+	data := newTestCorim(t, "http://example.com/test/unregistered")
+
+	_, err = UnsignedCorimProfileDecoder(data, testScheme, nil)
+	assert.EqualError(t, err,
+		`no extractor registered for CoRIM profile "http://example.com/test/unregistered" of scheme TEST_SCHEME`)
+
+	_, err = UnsignedCorimProfileDecoder(data, testScheme, &testExtractor{})
+	assert.NoError(t, err)
+}
+
+func TestUnsignedCorimProfileDecoder_other_scheme(t *testing.T) {
+	profile := "http://example.com/test/other"
+	MustRegisterExtractor("OTHER_SCHEME", profile, func() IExtractor {
+		panic("extractor of another scheme used")
+	})
+
+	data := newTestCorim(t, profile)
+
+	// the extractors registered by other schemes are never used
+	_, err := UnsignedCorimProfileDecoder(data, testScheme, nil)
+	assert.EqualError(t, err,
+		`no extractor registered for CoRIM profile "http://example.com/test/other" of scheme TEST_SCHEME`)
+
+	_, err = UnsignedCorimProfileDecoder(data, testScheme, &testExtractor{})
+	assert.NoError(t, err)
+}
+
+func TestUnsignedCorimProfileDecoder_endorsed_values_not_supported(t *testing.T) {
+	var c comid.Comid
+	require.NoError(t, c.FromJSON([]byte(`{
+		"tag-identity": {"id": "00000000-0000-0000-0000-000000000000"},
+		"triples": {
+			"endorsed-values": [{
+				"environment": {
+					"instance": {"type": "uuid", "value": "ffffffff-ffff-ffff-ffff-ffffffffffff"}
+				},
+				"measurements": [{
+					"value": {"digests": ["sha-256:h0KPxSKAPTEGXnvOPPA/5HUJZjHl4Hu9eg/eYMTPJcc="]}
+				}]
+			}]
+		}
+	}`)))
+
+	uc := corim.NewUnsignedCorim().
+		SetID("11111111-1111-1111-1111-111111111111").
+		AddProfile("http://example.com/test/endval").
+		AddComid(c)
+	require.NotNil(t, uc)
+
+	data, err := uc.ToCBOR()
+	require.NoError(t, err)
+
+	// testExtractor does not implement IEndValExtractor
+	_, err = UnsignedCorimProfileDecoder(data, testScheme, &testExtractor{})
+	assert.ErrorIs(t, err, ErrEndValNotSupported)
+	assert.EqualError(t, err,
+		"endorsed values in CoMID at index 0: endorsed values are not supported by the attestation scheme")
+}
@@ -21,3 +21,11 @@ type IExtractor interface {
 	RefValExtractor(comid.ReferenceValue) ([]*handler.Endorsement, error)
 	TaExtractor(comid.AttestVerifKey) (*handler.Endorsement, error)
 }
+
+// IEndValExtractor may be implemented by extractors that support CoMID
+// endorsed-value triples. CoRIMs containing endorsed-value triples are
+// rejected by the decoder (with ErrEndValNotSupported) if the extractor does
+// not implement it.
+type IEndValExtractor interface {
+	EndValExtractor(comid.EndorsedValue) ([]*handler.Endorsement, error)
+}
@@ -13,42 +13,105 @@ import (
 	"github.com/veraison/services/handler"
 )
 
+// UnsignedCorimMediaType is the media type of unsigned CoRIMs. Endorsement
+// handlers advertise it qualified by the "profile" parameter of the CoRIM
+// profiles they support.
+const UnsignedCorimMediaType = "application/corim-unsigned+cbor"
+
+// ErrEndValNotSupported is returned when decoding a CoRIM containing endorsed
+// values, using an extractor that does not support them.
+var ErrEndValNotSupported = errors.New("endorsed values are not supported by the attestation scheme")
+
+// UnsignedCorimDecoder decodes an unsigned CoRIM, and uses the supplied
+// extractor to turn its triples into endorsements.
 func UnsignedCorimDecoder(
 	data []byte,
 	xtr IExtractor,
 ) (*handler.EndorsementHandlerResponse, error) {
+	uc, _, err := decodeUnsignedCorim(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractEndorsements(uc, xtr)
+}
+
+// UnsignedCorimProfileDecoder decodes an unsigned CoRIM, and uses the
+// extractor registered (see RegisterExtractor) for the CoRIM's profile by the
+// specified scheme to turn its triples into endorsements. If the scheme has
+// no extractor registered for the profile (even if another scheme does), the
+// supplied fallback extractor (typically, the handler's own) is used instead,
+// so that CoRIMs carrying a legacy or unregistered profile are still accepted.
+// A nil fallback causes such CoRIMs to be rejected.
+func UnsignedCorimProfileDecoder(
+	data []byte,
+	scheme string,
+	fallback IExtractor,
+) (*handler.EndorsementHandlerResponse, error) {
+	uc, profile, err := decodeUnsignedCorim(data)
+	if err != nil {
+		return nil, err
+	}
+
+	xtr, err := NewExtractor(scheme, profile)
+	if err != nil {
+		if fallback == nil {
+			return nil, err
+		}
+		xtr = fallback
+	}
+
+	return extractEndorsements(uc, xtr)
+}
+
+// GetUnsignedCorimProfile returns the profile of an unsigned CoRIM.
+func GetUnsignedCorimProfile(data []byte) (string, error) {
+	_, profile, err := decodeUnsignedCorim(data)
+
+	return profile, err
+}
+
+// decodeUnsignedCorim decodes and validates an unsigned CoRIM, which must
+// have exactly one profile, and returns it along with the profile.
+func decodeUnsignedCorim(data []byte) (*corim.UnsignedCorim, string, error) {
 	if len(data) == 0 {
-		return nil, errors.New("empty data")
+		return nil, "", errors.New("empty data")
 	}
 
 	var uc corim.UnsignedCorim
 	if err := uc.FromCBOR(data); err != nil {
-		return nil, fmt.Errorf("CBOR decoding failed: %w", err)
+		return nil, "", fmt.Errorf("CBOR decoding failed: %w", err)
 	}
 
 	if err := uc.Valid(); err != nil {
-		return nil, fmt.Errorf("invalid unsigned corim: %w", err)
+		return nil, "", fmt.Errorf("invalid unsigned corim: %w", err)
 	}
 
-	if uc.Profiles != nil {
-		// get the profile
-		if len(*uc.Profiles) > 1 {
-			var profiles []string
-			for _, p := range *uc.Profiles {
-				name, _ := p.Get()
-				profiles = append(profiles, name)
-			}
-			return nil, fmt.Errorf("found multiple profiles (expected exactly one): %s", strings.Join(profiles, ", "))
-		}
-		p := (*uc.Profiles)[0]
-		_, err := p.Get()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get the profile information: %w", err)
+	if uc.Profiles == nil {
+		return nil, "", fmt.Errorf("no profile information set in CoRIM")
+	}
+
+	if len(*uc.Profiles) > 1 {
+		var profiles []string
+		for _, p := range *uc.Profiles {
+			name, _ := p.Get()
+			profiles = append(profiles, name)
 		}
-	} else {
-		return nil, fmt.Errorf("no profile information set in CoRIM")
+		return nil, "", fmt.Errorf("found multiple profiles (expected exactly one): %s", strings.Join(profiles, ", "))
+	}
+
+	profile, err := (*uc.Profiles)[0].Get()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get the profile information: %w", err)
 	}
 
+	return &uc, profile, nil
+}
+
+func extractEndorsements(
+	uc *corim.UnsignedCorim,
+	xtr IExtractor,
+) (*handler.EndorsementHandlerResponse, error) {
 	rsp := handler.EndorsementHandlerResponse{}
 
 	for i, tag := range uc.Tags {
@@ -60,7 +123,7 @@ func UnsignedCorimDecoder(
 		// split tag from data
 		cborTag, cborData := tag[:3], tag[3:]
 
-		// The EnactTrust profile only knows about CoMIDs
+		// only CoMIDs are supported at the moment
 		if !bytes.Equal(cborTag, corim.ComidTag) {
 			return nil, fmt.Errorf("unknown CBOR tag %x detected at index %d", cborTag, i)
 		}
@@ -100,6 +163,26 @@ func UnsignedCorimDecoder(
 			}
 		}
 
+		if c.Triples.EndorsedValues != nil && len(*c.Triples.EndorsedValues) != 0 {
+			// rather than silently dropping endorsed values the scheme
+			// cannot make use of, reject the CoRIM altogether
+			evx, ok := xtr.(IEndValExtractor)
+			if !ok {
+				return nil, fmt.Errorf("endorsed values in CoMID at index %d: %w", i, ErrEndValNotSupported)
+			}
+
+			for _, ev := range *c.Triples.EndorsedValues {
+				endVals, err := evx.EndValExtractor(ev)
+				if err != nil {
+					return nil, fmt.Errorf("bad endorsed values in CoMID at index %d: %w", i, err)
+				}
+
+				for _, endVal := range endVals {
+					rsp.EndorsedValues = append(rsp.EndorsedValues, *endVal)
+				}
+			}
+		}
+
 		// silently ignore any other triples
 	}
 
@@ -4,11 +4,11 @@ package parsec_cca
 
 const (
 	SchemeName         = "PARSEC_CCA"
-	EndorsementProfile = `"tag:github.com/parallaxsecond,2023-03-03:cca"`
+	EndorsementProfile = "tag:github.com/parallaxsecond,2023-03-03:cca"
 )
 
 var EndorsementMediaTypes = []string{
-	`application/corim-unsigned+cbor; profile=` + EndorsementProfile,
+	`application/corim-unsigned+cbor; profile="` + EndorsementProfile + `"`,
 }
 
 var EvidenceMediaTypes = []string{
@@ -7,6 +7,12 @@ import (
 	"github.com/veraison/services/scheme/common"
 )
 
+func init() {
+	common.MustRegisterExtractor(SchemeName, EndorsementProfile, func() common.IExtractor {
+		return &CorimExtractor{}
+	})
+}
+
 type EndorsementHandler struct{}
 
 func (o EndorsementHandler) Init(params handler.EndorsementHandlerParams) error {
@@ -30,5 +36,5 @@ func (o EndorsementHandler) GetSupportedMediaTypes() []string {
 }
 
 func (o EndorsementHandler) Decode(data []byte) (*handler.EndorsementHandlerResponse, error) {
-	return common.UnsignedCorimDecoder(data, &CorimExtractor{})
+	return common.UnsignedCorimProfileDecoder(data, SchemeName, &CorimExtractor{})
 }
@@ -4,11 +4,11 @@ package parsec_tpm
 
 const (
 	SchemeName         = "PARSEC_TPM"
-	EndorsementProfile = `"tag:github.com/parallaxsecond,2023-03-03:tpm"`
+	EndorsementProfile = "tag:github.com/parallaxsecond,2023-03-03:tpm"
 )
 
 var EndorsementMediaTypes = []string{
-	`application/corim-unsigned+cbor; profile=` + EndorsementProfile,
+	`application/corim-unsigned+cbor; profile="` + EndorsementProfile + `"`,
 }
 
 var EvidenceMediaTypes = []string{
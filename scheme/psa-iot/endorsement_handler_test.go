@@ -3,10 +3,16 @@
 package psa_iot
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+	"github.com/veraison/services/handler"
+	"github.com/veraison/services/scheme/common/arm"
 )
 
 func TestDecoder_GetAttestationScheme(t *testing.T) {
@@ -127,3 +133,162 @@ func TestDecoder_Decode_negative_tests(t *testing.T) {
 		assert.EqualError(t, err, tv.expectedErr)
 	}
 }
+
+// newCorimWithEndorsedValues returns an unsigned CoRIM based on the supplied
+// one, with an endorsed-value triple of the specified measurements added for
+// the environment of its first reference value, and the specified instance
+// (if any).
+func newCorimWithEndorsedValues(
+	t *testing.T,
+	tv string,
+	instance *comid.Instance,
+	measurements comid.Measurements,
+) []byte {
+	var uc corim.UnsignedCorim
+	require.NoError(t, uc.FromCBOR(comid.MustHexDecode(t, tv)))
+
+	var c comid.Comid
+	require.NoError(t, c.FromCBOR(uc.Tags[0][len(corim.ComidTag):]))
+
+	env := (*c.Triples.ReferenceValues)[0].Environment
+	env.Instance = instance
+
+	c.AddEndorsedValue(comid.EndorsedValue{
+		Environment:  env,
+		Measurements: measurements,
+	})
+
+	data, err := c.ToCBOR()
+	require.NoError(t, err)
+
+	uc.Tags[0] = append(append(corim.Tag{}, corim.ComidTag...), data...)
+
+	data, err = uc.ToCBOR()
+	require.NoError(t, err)
+
+	return data
+}
+
+var testEndorsedRawValue = comid.Measurements{
+	comid.Measurement{
+		Val: comid.Mval{RawValue: comid.NewRawValue().SetBytes([]byte{0x02})},
+	},
+}
+
+func TestDecoder_Decode_endorsed_values(t *testing.T) {
+	d := &EndorsementHandler{}
+
+	rsp, err := d.Decode(newCorimWithEndorsedValues(
+		t, unsignedCorimComidPsaRefValOne, nil, testEndorsedRawValue))
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, rsp.ReferenceValues)
+	require.Len(t, rsp.EndorsedValues, 1)
+
+	endVal := rsp.EndorsedValues[0]
+	assert.Equal(t, SchemeName, endVal.Scheme)
+	assert.Equal(t, handler.EndorsementType_ENDORSED_VALUE, endVal.Type)
+	assert.Equal(t, SchemeName+".endorsed-values", endVal.SubType)
+
+	var attrs map[string]interface{}
+	require.NoError(t, json.Unmarshal(endVal.Attributes, &attrs))
+	assert.Contains(t, attrs, SchemeName+".impl-id")
+	assert.Len(t, attrs[SchemeName+".endorsed-values"], 1)
+	assert.NotContains(t, attrs, SchemeName+".inst-id")
+
+	// with no instance and no measurement keys, the endorsed values apply to
+	// any evidence from the platform
+	require.NotNil(t, endVal.Condition)
+	assert.Len(t, endVal.Condition.Claims, 1)
+	assert.Contains(t, endVal.Condition.Claims, "psa-implementation-id")
+	assert.Empty(t, endVal.Condition.Entries)
+
+	// endorsed values are keyed like the reference values of the platform
+	keys, err := EvidenceHandler{}.SynthKeysFromRefValue("0", &endVal)
+	require.NoError(t, err)
+	refValKeys, err := EvidenceHandler{}.SynthKeysFromRefValue("0", &rsp.ReferenceValues[0])
+	require.NoError(t, err)
+	assert.Equal(t, refValKeys, keys)
+}
+
+func TestDecoder_Decode_endorsed_values_condition(t *testing.T) {
+	d := &EndorsementHandler{}
+
+	instID := comid.TestUEID
+	signerID := comid.MustHexDecode(t,
+		"acbb11c7e4da217205523ce4ce1a245ae1a239ae3c6bfd9e7871f7e5d8bae86b")
+	digest := comid.MustHexDecode(t,
+		"87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7")
+
+	refValID := comid.NewPSARefValID(signerID).SetLabel("BL").SetVersion("2.1.0")
+	require.NotNil(t, refValID)
+
+	measurement := comid.NewPSAMeasurement(*refValID).AddDigest(1, digest)
+	require.NotNil(t, measurement)
+	measurement.Val.RawValue = comid.NewRawValue().SetBytes([]byte{0x02})
+
+	rsp, err := d.Decode(newCorimWithEndorsedValues(
+		t,
+		unsignedCorimComidPsaRefValOne,
+		comid.NewInstanceUEID(instID),
+		comid.Measurements{*measurement},
+	))
+	require.NoError(t, err)
+	require.Len(t, rsp.EndorsedValues, 1)
+
+	endVal := rsp.EndorsedValues[0]
+
+	var attrs map[string]interface{}
+	require.NoError(t, json.Unmarshal(endVal.Attributes, &attrs))
+	assert.Contains(t, attrs, SchemeName+".inst-id")
+
+	implID := attrs[SchemeName+".impl-id"]
+
+	// the condition is checked against the evidence claims as they are
+	// available at appraisal time, i.e. JSON-decoded
+	data, err := json.Marshal(endVal.Condition)
+	require.NoError(t, err)
+
+	var cond handler.Condition
+	require.NoError(t, json.Unmarshal(data, &cond))
+
+	component := map[string]interface{}{
+		"measurement-type":  "BL",
+		"measurement-value": base64.StdEncoding.EncodeToString(digest),
+		"signer-id":         base64.StdEncoding.EncodeToString(signerID),
+		"version":           "2.1.0",
+	}
+
+	evidence := map[string]interface{}{
+		"psa-implementation-id":   implID,
+		"psa-instance-id":         base64.StdEncoding.EncodeToString(instID),
+		"psa-software-components": []interface{}{component},
+	}
+	assert.True(t, cond.Matches(evidence))
+
+	// different instance
+	evidence["psa-instance-id"] = base64.StdEncoding.EncodeToString([]byte{0x01, 0x02})
+	assert.False(t, cond.Matches(evidence))
+	evidence["psa-instance-id"] = base64.StdEncoding.EncodeToString(instID)
+
+	// the endorsed component is not measured
+	component["measurement-value"] = base64.StdEncoding.EncodeToString(signerID)
+	assert.False(t, cond.Matches(evidence))
+}
+
+func TestDecoder_Decode_endorsed_values_group(t *testing.T) {
+	var uc corim.UnsignedCorim
+	require.NoError(t, uc.FromCBOR(comid.MustHexDecode(t, unsignedCorimComidPsaRefValOne)))
+
+	var c comid.Comid
+	require.NoError(t, c.FromCBOR(uc.Tags[0][len(corim.ComidTag):]))
+
+	env := (*c.Triples.ReferenceValues)[0].Environment
+	env.Group = comid.NewGroupUUID(comid.TestUUID)
+
+	_, err := arm.Extractor{Scheme: SchemeName}.EndValExtractor(comid.EndorsedValue{
+		Environment:  env,
+		Measurements: testEndorsedRawValue,
+	})
+	assert.EqualError(t, err, "group environments are not supported for endorsed values")
+}
@@ -4,11 +4,12 @@ package psa_iot
 
 const (
 	SchemeName              = "PSA_IOT"
+	EndorsementProfile      = "http://arm.com/psa/iot/1"
 	CCAEndorsementMediaType = "application/corim-unsigned+cbor; profile=http://arm.com/cca/ssd/1"
 )
 
 var EndorsementMediaTypes = []string{
-	"application/corim-unsigned+cbor; profile=http://arm.com/psa/iot/1",
+	"application/corim-unsigned+cbor; profile=" + EndorsementProfile,
 }
 
 var EvidenceMediaTypes = []string{
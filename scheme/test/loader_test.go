@@ -0,0 +1,126 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package test
+
+import (
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/services/builtin"
+	"github.com/veraison/services/handler"
+	"github.com/veraison/services/log"
+	"github.com/veraison/services/plugin"
+)
+
+const psaMediaType = `application/corim-unsigned+cbor; profile=http://arm.com/psa/iot/1`
+
+// CoRIM with the CCA SSD platform profile, containing a CCA platform
+// configuration reference value (which PSA endorsements cannot carry)
+//
+// automatically generated from:
+// ComidCcaRefValOne.json and corimCca.json
+var ccaCorim = `
+a600505c57e8f446cd421b91c908cf93e13cfc018158b3d901faa4006565
+6e2d474201a1005043bbe37f2e614b33aed353cff1428b160281a3006941
+434d45204c74642e01d8207468747470733a2f2f61636d652e6578616d70
+6c65028300010204a1008182a100a300d90258582061636d652d696d706c
+656d656e746174696f6e2d69642d303030303030303031016441434d4502
+6a526f616452756e6e657281a200d9025a69616e792d6c6162656c01a104
+d902305272617776616c75650a72617776616c75650a0281a200d8207840
+68747470733a2f2f706172656e742e6578616d706c652f72696d732f6363
+6233616138352d363162342d343066312d383438652d3032616436653861
+323534620182015820e45b72f5c0c0b572db4d8d3ab7e97f368ff74e6234
+7a824decb67a84e5224d7503817818687474703a2f2f61726d2e636f6d2f
+6363612f7373642f3104a200c11a61ce480001c11a695467800581a30069
+41434d45204c74642e01d8206c61636d652e6578616d706c65028101
+`
+
+func mustHexDecode(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(regexp.MustCompile(`\s`).ReplaceAllString(s, ""))
+	require.NoError(t, err)
+	return data
+}
+
+func getBuiltinHandler(t *testing.T) handler.IEndorsementHandler {
+	ldr, err := builtin.CreateBuiltinLoader(nil, log.Named("builtin"))
+	require.NoError(t, err)
+
+	err = builtin.DiscoverBuiltinUsing[handler.IEndorsementHandler](ldr)
+	require.NoError(t, err)
+
+	h, err := builtin.GetBuiltinHandleByMediaTypeUsing[handler.IEndorsementHandler](
+		ldr, psaMediaType)
+	require.NoError(t, err)
+
+	return h
+}
+
+func getGoPluginHandler(t *testing.T) handler.IEndorsementHandler {
+	ldr, err := plugin.CreateGoPluginLoader(
+		map[string]interface{}{"dir": "bin"}, log.Named("plugin"))
+	require.NoError(t, err)
+	t.Cleanup(ldr.Close)
+
+	err = plugin.RegisterGoPluginUsing(ldr, "endorsement-handler", handler.EndorsementHandlerRPC)
+	require.NoError(t, err)
+
+	err = plugin.DiscoverGoPluginUsing[handler.IEndorsementHandler](ldr)
+	require.NoError(t, err)
+
+	h, err := plugin.GetGoPluginHandleByMediaTypeUsing[handler.IEndorsementHandler](
+		ldr, psaMediaType)
+	require.NoError(t, err)
+
+	return h
+}
+
+// A CoRIM carrying another scheme's profile must be handled the same way
+// whether the other scheme's extractor is linked into the same binary
+// (builtin) or lives in a separate plugin (go-plugin).
+func TestEndorsementHandler_cross_profile_corim(t *testing.T) {
+	require.NoError(t, buildPlugins(map[string]string{
+		"psa-endorsement-handler": "psa-iot",
+		"cca-endorsement-handler": "cca-ssd-platform",
+	}))
+
+	data := mustHexDecode(t, ccaCorim)
+
+	for name, h := range map[string]handler.IEndorsementHandler{
+		"builtin":   getBuiltinHandler(t),
+		"go-plugin": getGoPluginHandler(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// the PSA handler's own extractor is used, which does not
+			// support the CCA platform configuration
+			_, err := h.Decode(data)
+			assert.ErrorContains(t, err, "incorrect profile PSA_IOT")
+		})
+	}
+}
+
+func buildPlugins(plugins map[string]string) error {
+	for name, scheme := range plugins {
+		cmd := exec.Command("go", "build",
+			"-o", filepath.Join("bin", name+".plugin"),
+			"github.com/veraison/services/scheme/"+scheme+"/plugin/endorsement-handler")
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	ret := m.Run()
+
+	os.RemoveAll("bin")
+
+	os.Exit(ret)
+}
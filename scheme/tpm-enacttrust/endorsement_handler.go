@@ -7,6 +7,14 @@ import (
 	"github.com/veraison/services/scheme/common"
 )
 
+func init() {
+	for _, profile := range []string{EndorsementProfile, LegacyEndorsementProfile} {
+		common.MustRegisterExtractor(SchemeName, profile, func() common.IExtractor {
+			return &Extractor{}
+		})
+	}
+}
+
 type EndorsementHandler struct{}
 
 func (o EndorsementHandler) Init(params handler.EndorsementHandlerParams) error {
@@ -30,5 +38,5 @@ func (o EndorsementHandler) GetSupportedMediaTypes() []string {
 }
 
 func (o EndorsementHandler) Decode(data []byte) (*handler.EndorsementHandlerResponse, error) {
-	return common.UnsignedCorimDecoder(data, &Extractor{})
+	return common.UnsignedCorimProfileDecoder(data, SchemeName, &Extractor{})
 }
@@ -2,11 +2,18 @@
 // SPDX-License-Identifier: Apache-2.0
 package tpm_enacttrust
 
-const SchemeName = "TPM_ENACTTRUST"
+const (
+	SchemeName         = "TPM_ENACTTRUST"
+	EndorsementProfile = "http://enacttrust.com/veraison/1.0.0"
+
+	// LegacyEndorsementProfile is the profile carried by the CoRIMs created
+	// before EndorsementProfile was settled on. It is still accepted.
+	LegacyEndorsementProfile = "https://enacttrust.com/veraison/1.0.0"
+)
 
 var (
 	EndorsementMediaTypes = []string{
-		"application/corim-unsigned+cbor; profile=http://enacttrust.com/veraison/1.0.0",
+		"application/corim-unsigned+cbor; profile=" + EndorsementProfile,
 	}
 
 	EvidenceMediaTypes = []string{
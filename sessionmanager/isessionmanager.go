@@ -11,10 +11,24 @@ import (
 
 type Config map[string]string
 
+// SessionInfo describes a session held by a session manager.
+type SessionInfo struct {
+	ID      uuid.UUID
+	Tenant  string
+	Session json.RawMessage
+	// Created is the time the session was first set.
+	Created time.Time
+	// Expiry is the time the session will be removed from the manager.
+	Expiry time.Time
+}
+
 type ISessionManager interface {
 	Init(cfg Config) error
 	SetSession(id uuid.UUID, tenant string, session json.RawMessage, ttl time.Duration) error
 	GetSession(id uuid.UUID, tenant string) (json.RawMessage, error)
 	DelSession(id uuid.UUID, tenant string) error
+	// ListSessions returns the sessions that have not expired. If tenant is
+	// not empty, only the sessions of that tenant are returned.
+	ListSessions(tenant string) ([]SessionInfo, error)
 	Close() error
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,13 +15,20 @@ import (
 
 const DefaultTTL = time.Minute
 
+type sessionEntry struct {
+	id      uuid.UUID
+	tenant  string
+	session json.RawMessage
+	created time.Time
+}
+
 type SessionManagerTTLCache struct {
-	cache *ttlcache.Cache[string, json.RawMessage]
+	cache *ttlcache.Cache[string, *sessionEntry]
 }
 
 func NewSessionManagerTTLCache() *SessionManagerTTLCache {
 	return &SessionManagerTTLCache{
-		cache: ttlcache.New[string, json.RawMessage](),
+		cache: ttlcache.New[string, *sessionEntry](),
 	}
 }
 
@@ -36,8 +44,8 @@ func (o *SessionManagerTTLCache) Init(cfg Config) error {
 		}
 	}
 
-	o.cache = ttlcache.New[string, json.RawMessage](
-		ttlcache.WithTTL[string, json.RawMessage](ttl),
+	o.cache = ttlcache.New[string, *sessionEntry](
+		ttlcache.WithTTL[string, *sessionEntry](ttl),
 	)
 
 	go o.cache.Start()
@@ -52,7 +60,15 @@ func (o *SessionManagerTTLCache) Close() error {
 }
 
 func (o *SessionManagerTTLCache) SetSession(id uuid.UUID, tenant string, session json.RawMessage, ttl time.Duration) error {
-	_ = o.cache.Set(makeKey(id, tenant), session, ttl)
+	key := makeKey(id, tenant)
+	entry := &sessionEntry{id: id, tenant: tenant, session: session, created: time.Now()}
+
+	// updating a session does not change its creation time
+	if item := o.cache.Get(key, ttlcache.WithDisableTouchOnHit[string, *sessionEntry]()); item != nil {
+		entry.created = item.Value().created
+	}
+
+	_ = o.cache.Set(key, entry, ttl)
 
 	return nil
 }
@@ -64,12 +80,39 @@ func (o *SessionManagerTTLCache) DelSession(id uuid.UUID, tenant string) error {
 
 func (o *SessionManagerTTLCache) GetSession(id uuid.UUID, tenant string) (json.RawMessage, error) {
 	if item := o.cache.Get(makeKey(id, tenant)); item != nil {
-		return item.Value(), nil
+		return item.Value().session, nil
 	}
 
 	return nil, fmt.Errorf("session not found for (id, tenant)=(%s, %s)", id, tenant)
 }
 
+func (o *SessionManagerTTLCache) ListSessions(tenant string) ([]SessionInfo, error) {
+	var sessions []SessionInfo
+
+	for _, item := range o.cache.Items() {
+		entry := item.Value()
+
+		if tenant != "" && entry.tenant != tenant {
+			continue
+		}
+
+		sessions = append(sessions, SessionInfo{
+			ID:      entry.id,
+			Tenant:  entry.tenant,
+			Session: entry.session,
+			Created: entry.created,
+			Expiry:  item.ExpiresAt(),
+		})
+	}
+
+	// oldest first
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created.Before(sessions[j].Created)
+	})
+
+	return sessions, nil
+}
+
 func makeKey(id uuid.UUID, tenant string) string {
 	// session://{tenant}/{uuid}
 	u := url.URL{
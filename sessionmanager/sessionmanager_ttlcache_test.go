@@ -80,3 +80,50 @@ func Test_SessionManagerTTLCache_Eviction(t *testing.T) {
 	_, err = sm.GetSession(testUUID, testTenant)
 	assert.EqualError(t, err, expectedErr)
 }
+
+func Test_SessionManagerTTLCache_ListSessions(t *testing.T) {
+	sm := SessionManagerTTLCache{}
+	cfg := Config{}
+
+	err := sm.Init(cfg)
+	defer sm.Close()
+
+	assert.NoError(t, err)
+
+	otherUUID := uuid.New()
+	otherTenant := "9876543210"
+
+	err = sm.SetSession(testUUID, testTenant, testSession, testTTL)
+	assert.NoError(t, err)
+
+	err = sm.SetSession(otherUUID, otherTenant, testSession, testTTL)
+	assert.NoError(t, err)
+
+	sessions, err := sm.ListSessions("")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	sessions, err = sm.ListSessions(testTenant)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, testUUID, sessions[0].ID)
+	assert.Equal(t, testTenant, sessions[0].Tenant)
+	assert.JSONEq(t, string(testSession), string(sessions[0].Session))
+	assert.WithinDuration(t, time.Now().Add(testTTL), sessions[0].Expiry, time.Second)
+
+	created := sessions[0].Created
+
+	// updating the session does not change its creation time
+	err = sm.SetSession(testUUID, testTenant, []byte(`{ "a": 2 }`), testTTL)
+	assert.NoError(t, err)
+
+	sessions, err = sm.ListSessions(testTenant)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, created, sessions[0].Created)
+	assert.JSONEq(t, `{ "a": 2 }`, string(sessions[0].Session))
+
+	sessions, err = sm.ListSessions("no-such-tenant")
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+}
@@ -6,8 +6,10 @@
 package api
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -77,6 +79,9 @@ type ChallengeResponseSession struct {
 	Accept   []string      `json:"accept"`
 	Evidence *EvidenceBlob `json:"evidence,omitempty"`
 	Result   *string       `json:"result,omitempty"`
+	// ResultMediaType is the media type of the attestation result. Binary
+	// results (e.g. EAR-CWT) are base64url-encoded in Result.
+	ResultMediaType *string `json:"result-media-type,omitempty"`
 }
 
 func (o *ChallengeResponseSession) SetEvidence(mt string, evidence []byte) {
@@ -87,7 +92,39 @@ func (o *ChallengeResponseSession) SetStatus(status Status) {
 	o.Status = status
 }
 
-func (o *ChallengeResponseSession) SetResult(result []byte) {
-	rs := string(result)
+func (o *ChallengeResponseSession) SetResult(result []byte, mediaType string) {
+	var rs string
+
+	if isBinaryResult(mediaType) {
+		rs = base64.RawURLEncoding.EncodeToString(result)
+	} else {
+		rs = string(result)
+	}
+
 	o.Result = &rs
+	o.ResultMediaType = &mediaType
+}
+
+// GetResult returns the attestation result as it was produced by the
+// verifier, or nil if the session does not have a result.
+func (o ChallengeResponseSession) GetResult() ([]byte, error) {
+	if o.Result == nil {
+		return nil, nil
+	}
+
+	if o.ResultMediaType != nil && isBinaryResult(*o.ResultMediaType) {
+		return base64.RawURLEncoding.DecodeString(*o.Result)
+	}
+
+	return []byte(*o.Result), nil
+}
+
+func isBinaryResult(mediaType string) bool {
+	base := baseMediaType(mediaType)
+	return strings.HasSuffix(base, "+cwt") || strings.HasSuffix(base, "+cbor")
+}
+
+// baseMediaType returns the media type without parameters.
+func baseMediaType(mediaType string) string {
+	return strings.TrimSpace(strings.Split(mediaType, ";")[0])
 }
@@ -0,0 +1,111 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/cmw"
+	"github.com/veraison/services/proto"
+)
+
+// parseCMWCollection extracts the members of a CMW collection, i.e., a JSON
+// object or a CBOR map whose values are CMW records, keyed by a label. The
+// members are returned sorted by label. If data is not a CMW collection (e.g.
+// it is a CMW record), nil is returned.
+func parseCMWCollection(data []byte) ([]*proto.AttestationTokenMember, error) {
+	var (
+		records map[string][]byte
+		err     error
+	)
+
+	switch {
+	case isJSONObject(data):
+		records, err = decodeJSONCollection(data)
+	case isCBORMap(data):
+		records, err = decodeCBORCollection(data)
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("empty collection")
+	}
+
+	labels := make([]string, 0, len(records))
+	for label := range records {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	members := make([]*proto.AttestationTokenMember, 0, len(labels))
+	for _, label := range labels {
+		record := records[label]
+
+		if isJSONObject(record) || isCBORMap(record) {
+			return nil, fmt.Errorf("member %q: nested collections are not supported", label)
+		}
+
+		var w cmw.CMW
+		if err := w.Deserialize(record); err != nil {
+			return nil, fmt.Errorf("member %q: %w", label, err)
+		}
+
+		members = append(members, &proto.AttestationTokenMember{
+			Label:     label,
+			Data:      w.GetValue(),
+			MediaType: w.GetType(),
+		})
+	}
+
+	return members, nil
+}
+
+func decodeJSONCollection(data []byte) (map[string][]byte, error) {
+	var raw map[string]json.RawMessage
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	records := make(map[string][]byte, len(raw))
+	for label, record := range raw {
+		records[label] = record
+	}
+
+	return records, nil
+}
+
+func decodeCBORCollection(data []byte) (map[string][]byte, error) {
+	var raw map[string]cbor.RawMessage
+
+	if err := cbor.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	records := make(map[string][]byte, len(raw))
+	for label, record := range raw {
+		records[label] = record
+	}
+
+	return records, nil
+}
+
+func isJSONObject(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func isCBORMap(data []byte) bool {
+	// CBOR major type 5
+	return len(data) > 0 && data[0]&0xe0 == 0xa0
+}
@@ -20,10 +20,14 @@ import (
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/veraison/cmw"
+	"github.com/veraison/services/auth"
 	"github.com/veraison/services/capability"
 	"github.com/veraison/services/log"
-	"github.com/veraison/services/verification/sessionmanager"
+	"github.com/veraison/services/proto"
+	"github.com/veraison/services/sessionmanager"
+	"github.com/veraison/services/verification/sealedsession"
 	"github.com/veraison/services/verification/verifier"
+	"github.com/veraison/services/vts/earsigner"
 	"go.uber.org/zap"
 )
 
@@ -31,12 +35,27 @@ const (
 	ChallengeResponseSessionMediaType = "application/vnd.veraison.challenge-response-session+json"
 )
 
+// resultMediaTypes are the attestation result formats that may be requested
+// via the Accept header. Whether a format is actually available depends on the
+// configuration of the verifier.
+var resultMediaTypes = []string{earsigner.EarJWTMediaType, earsigner.EarCWTMediaType}
+
 var (
 	ErrInternal = errors.New("internal error")
 )
 
 var (
+	// tenantID is the tenant used for requests that have not been
+	// associated with a tenant by the authorizer.
 	tenantID = "0"
+
+	// vtsTenantID is the tenant evidence is appraised for by the VTS,
+	// whatever the tenant of the session. Provisioning is not
+	// tenant-aware yet, and stores all the trust anchors and endorsements
+	// under this tenant, so appraising evidence for any other tenant would
+	// always fail to find them. Sessions remain scoped to the tenant of the
+	// request.
+	vtsTenantID = "0"
 )
 
 type IHandler interface {
@@ -45,27 +64,56 @@ type IHandler interface {
 	GetSession(c *gin.Context)
 	DelSession(c *gin.Context)
 	GetWellKnownVerificationInfo(c *gin.Context)
+
+	ListSessions(c *gin.Context)
+	GetSessionsSummary(c *gin.Context)
+	ExpireSessions(c *gin.Context)
 }
 
 type Handler struct {
 	SessionManager sessionmanager.ISessionManager
 	Verifier       verifier.IVerifier
 
+	// Sealer is set when the handler issues stateless sealed sessions
+	// instead of keeping them in the SessionManager.
+	Sealer sealedsession.ISealer
+
+	// SessionPolicies control the session TTL and nonces of each tenant.
+	SessionPolicies *SessionPolicies
+
 	logger *zap.SugaredLogger
 }
 
-func NewHandler(sm sessionmanager.ISessionManager, v verifier.IVerifier) IHandler {
+func NewHandler(
+	sm sessionmanager.ISessionManager,
+	v verifier.IVerifier,
+	policies *SessionPolicies,
+) IHandler {
 	return &Handler{
-		SessionManager: sm,
-		Verifier:       v,
-		logger:         log.Named("api-handler"),
+		SessionManager:  sm,
+		Verifier:        v,
+		SessionPolicies: policies,
+		logger:          log.Named("api-handler"),
 	}
 }
 
-var (
-	ConfigNonceSize     uint8 = 32
-	ConfigSessionTTL, _       = time.ParseDuration("2m30s")
-)
+// NewSealedSessionHandler returns a handler that does not store sessions.
+// Instead, the session ID is a token, sealed with the provided sealer, that
+// carries the session's nonce, expiry and tenant. Since there is no session
+// state, the attestation result is only returned in the response to the
+// evidence submission.
+func NewSealedSessionHandler(
+	sealer sealedsession.ISealer,
+	v verifier.IVerifier,
+	policies *SessionPolicies,
+) IHandler {
+	return &Handler{
+		Sealer:          sealer,
+		Verifier:        v,
+		SessionPolicies: policies,
+		logger:          log.Named("api-handler"),
+	}
+}
 
 // mintSessionID creates a version 1 UUID based on a unique machine ID, clock
 // sequence and current time.  Routing to the correct node can therefore happen
@@ -120,8 +168,8 @@ func b64ToBytes(v string) ([]byte, error) {
 }
 
 // parseNonceRequest tries to devise the nonce value to be used for the session
-// given the user-supplied query parameters
-func parseNonceRequest(nonceParam, nonceSizeParam string) ([]byte, error) {
+// given the user-supplied query parameters and the tenant's session policy
+func parseNonceRequest(nonceParam, nonceSizeParam string, policy SessionPolicy) ([]byte, error) {
 	// both nonce and nonceSize have been supplied
 	if nonceParam != "" && nonceSizeParam != "" {
 		return nil, errors.New("nonce and nonceSize are mutually exclusive")
@@ -130,18 +178,23 @@ func parseNonceRequest(nonceParam, nonceSizeParam string) ([]byte, error) {
 	// no explicit request was made, use the default nonce size to mint a
 	// new nonce
 	if nonceParam == "" && nonceSizeParam == "" {
-		return mintNonce(ConfigNonceSize)
+		return mintNonce(policy.NonceSize)
 	}
 
 	// a nonceSize was supplied, try to use it to mint a new nonce
 	if nonceSizeParam != "" {
 		nonceSize, err := aToU8(nonceSizeParam)
-		if err != nil || nonceSize < 8 || nonceSize > 64 {
-			return nil, errors.New("nonceSize must be in range 8..64")
+		if err != nil || nonceSize < policy.MinNonceSize || nonceSize > policy.MaxNonceSize {
+			return nil, fmt.Errorf("nonceSize must be in range %d..%d",
+				policy.MinNonceSize, policy.MaxNonceSize)
 		}
 		return mintNonce(nonceSize)
 	}
 
+	if !policy.AllowClientNonce {
+		return nil, errors.New("nonce may not be supplied by the client")
+	}
+
 	// nonce was supplied, try to see if the encoding is valid
 	nonce, err := b64ToBytes(nonceParam)
 	if err != nil {
@@ -149,16 +202,35 @@ func parseNonceRequest(nonceParam, nonceSizeParam string) ([]byte, error) {
 	}
 
 	nonceLen := len(nonce)
-	if nonceLen < 8 || nonceLen > 64 {
+	if nonceLen < int(policy.MinNonceSize) || nonceLen > int(policy.MaxNonceSize) {
 		return nil, fmt.Errorf(
-			"nonce must be between 8 and 64 bytes long; got %d",
-			nonceLen,
+			"nonce must be between %d and %d bytes long; got %d",
+			policy.MinNonceSize, policy.MaxNonceSize, nonceLen,
 		)
 	}
 
 	return nonce, nil
 }
 
+// parseTTLRequest returns the session TTL, which is the one in the tenant's
+// session policy, unless a shorter one has been requested by the client
+func parseTTLRequest(ttlParam string, policy SessionPolicy) (time.Duration, error) {
+	if ttlParam == "" {
+		return policy.TTL, nil
+	}
+
+	ttl, err := time.ParseDuration(ttlParam)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl: %q", ttlParam)
+	}
+
+	if ttl < policy.MinTTL || ttl > policy.TTL {
+		return 0, fmt.Errorf("ttl must be in range %s..%s", policy.MinTTL, policy.TTL)
+	}
+
+	return ttl, nil
+}
+
 func newSession(nonce []byte, supportedMediaTypes []string, ttl time.Duration) (uuid.UUID, []byte, error) {
 	id, err := mintSessionID()
 	if err != nil {
@@ -197,13 +269,13 @@ func lookupSession(sm sessionmanager.ISessionManager, id uuid.UUID, tenantID str
 	return &s, nil
 }
 
-func storeSession(sm sessionmanager.ISessionManager, session *ChallengeResponseSession, id uuid.UUID, tenantID string) ([]byte, error) {
+func storeSession(sm sessionmanager.ISessionManager, session *ChallengeResponseSession, id uuid.UUID, tenantID string, ttl time.Duration) ([]byte, error) {
 	b, err := json.Marshal(session)
 	if err != nil {
 		return nil, err
 	}
 
-	err = sm.SetSession(id, tenantID, b, ConfigSessionTTL)
+	err = sm.SetSession(id, tenantID, b, ttl)
 	if err != nil {
 		return nil, err
 	}
@@ -211,8 +283,8 @@ func storeSession(sm sessionmanager.ISessionManager, session *ChallengeResponseS
 	return b, nil
 }
 
-func mustStoreSession(sm sessionmanager.ISessionManager, session *ChallengeResponseSession, id uuid.UUID, tenantID string) []byte {
-	s, err := storeSession(sm, session, id, tenantID)
+func mustStoreSession(sm sessionmanager.ISessionManager, session *ChallengeResponseSession, id uuid.UUID, tenantID string, ttl time.Duration) []byte {
+	s, err := storeSession(sm, session, id, tenantID, ttl)
 	if err != nil {
 		panic(err)
 	}
@@ -220,6 +292,27 @@ func mustStoreSession(sm sessionmanager.ISessionManager, session *ChallengeRespo
 	return s
 }
 
+// sessionPolicy returns the session policy of the specified tenant, falling
+// back to the default policy if the handler has not been configured with any.
+func (o *Handler) sessionPolicy(tenant string) SessionPolicy {
+	if o.SessionPolicies == nil {
+		return DefaultSessionPolicies().Default
+	}
+
+	return o.SessionPolicies.Get(tenant)
+}
+
+// requestTenantID returns the ID of the tenant the authorizer associated with
+// the request, falling back to the default tenantID for requests that are
+// not associated with a tenant (e.g. anonymous evidence submission).
+func requestTenantID(c *gin.Context) string {
+	if tenant, ok := auth.GetTenantID(c); ok {
+		return tenant
+	}
+
+	return tenantID
+}
+
 func readSessionIDFromRequestURI(c *gin.Context) (uuid.UUID, error) {
 	uriPathSegment := c.Param("id")
 
@@ -232,13 +325,26 @@ func readSessionIDFromRequestURI(c *gin.Context) (uuid.UUID, error) {
 }
 
 func (o *Handler) GetSession(c *gin.Context) {
-	// do content negotiation (accept application/vnd.veraison.challenge-response-session+json)
-	offered := c.NegotiateFormat(ChallengeResponseSessionMediaType)
-	if offered != ChallengeResponseSessionMediaType {
-		ReportProblem(c,
-			http.StatusNotAcceptable,
-			fmt.Sprintf("the only supported output format is %s", ChallengeResponseSessionMediaType),
-		)
+	// do content negotiation (accept application/vnd.veraison.challenge-response-session+json,
+	// or one of the attestation result formats)
+	offered, _, ok := negotiateResponseFormat(c)
+	if !ok {
+		return
+	}
+
+	if o.Sealer != nil {
+		session, ok := o.unsealSessionFromRequestURI(c)
+		if !ok {
+			return
+		}
+
+		if offered != ChallengeResponseSessionMediaType {
+			sendResult(c, session, offered)
+			return
+		}
+
+		c.Header("Content-Type", ChallengeResponseSessionMediaType)
+		c.JSON(http.StatusOK, session)
 		return
 	}
 
@@ -252,7 +358,8 @@ func (o *Handler) GetSession(c *gin.Context) {
 	}
 
 	// load session from request URI
-	session, err := lookupSession(o.SessionManager, id, tenantID)
+	tenant := requestTenantID(c)
+	session, err := lookupSession(o.SessionManager, id, tenant)
 	if err != nil {
 		ReportProblem(c,
 			http.StatusNotFound,
@@ -261,11 +368,27 @@ func (o *Handler) GetSession(c *gin.Context) {
 		return
 	}
 
+	if offered != ChallengeResponseSessionMediaType {
+		sendResult(c, session, offered)
+		return
+	}
+
 	c.Header("Content-Type", ChallengeResponseSessionMediaType)
 	c.JSON(http.StatusOK, session)
 }
 
 func (o *Handler) DelSession(c *gin.Context) {
+	if o.Sealer != nil {
+		// There is no state associated with a sealed session: it cannot
+		// be revoked, and remains usable until it expires. This is
+		// reported, rather than pretending that the session was deleted.
+		ReportProblem(c,
+			http.StatusNotImplemented,
+			"sealed sessions cannot be deleted: they remain valid until they expire",
+		)
+		return
+	}
+
 	id, err := readSessionIDFromRequestURI(c)
 	if err != nil {
 		ReportProblem(c,
@@ -275,7 +398,7 @@ func (o *Handler) DelSession(c *gin.Context) {
 		return
 	}
 
-	if err = o.SessionManager.DelSession(id, tenantID); err != nil {
+	if err = o.SessionManager.DelSession(id, requestTenantID(c)); err != nil {
 		ReportProblem(c,
 			http.StatusInternalServerError,
 			err.Error(),
@@ -302,12 +425,8 @@ func isCMW(mt string) bool {
 
 func (o *Handler) SubmitEvidence(c *gin.Context) {
 	// do content negotiation (accept application/vnd.veraison.challenge-response-session+json)
-	offered := c.NegotiateFormat(ChallengeResponseSessionMediaType)
-	if offered != ChallengeResponseSessionMediaType {
-		ReportProblem(c,
-			http.StatusNotAcceptable,
-			fmt.Sprintf("the only supported output format is %s", ChallengeResponseSessionMediaType),
-		)
+	offered, requestedResultMediaType, ok := negotiateResponseFormat(c)
+	if !ok {
 		return
 	}
 
@@ -325,46 +444,55 @@ func (o *Handler) SubmitEvidence(c *gin.Context) {
 	// read content-type and check against supported attestation formats
 	mediaType := c.Request.Header.Get("Content-Type")
 
-	if isCMW(mediaType) {
-		var w cmw.CMW
+	// members is only set if the evidence is a CMW collection, in which case
+	// evidence and mediaType are left as submitted
+	var members []*proto.AttestationTokenMember
 
-		if err := w.Deserialize(evidence); err != nil {
+	if isCMW(mediaType) {
+		members, err = parseCMWCollection(evidence)
+		if err != nil {
 			ReportProblem(c,
 				http.StatusBadRequest,
-				fmt.Sprintf("could not unwrap the CMW: %v", err),
+				fmt.Sprintf("could not unwrap the CMW collection: %v", err),
 			)
 			return
 		}
 
-		mediaType = w.GetType()
-		evidence = w.GetValue()
-	}
+		if members == nil {
+			var w cmw.CMW
 
-	isSupported, err := o.Verifier.IsSupportedMediaType(mediaType)
-	if err != nil {
-		ReportProblem(c,
-			http.StatusInternalServerError,
-			fmt.Sprintf("could not check media type with verifier: %v", err),
-		)
-		return
+			if err := w.Deserialize(evidence); err != nil {
+				ReportProblem(c,
+					http.StatusBadRequest,
+					fmt.Sprintf("could not unwrap the CMW: %v", err),
+				)
+				return
+			}
+
+			mediaType = w.GetType()
+			evidence = w.GetValue()
+		}
 	}
 
-	if !isSupported {
-		supportedMediaTypes, err := o.Verifier.SupportedMediaTypes()
-		if err != nil {
-			ReportProblem(c,
-				http.StatusInternalServerError,
-				fmt.Sprintf("could not get supported media types from verifier: %v",
-					err),
-			)
+	if members == nil {
+		if !o.checkSupportedMediaType(c, mediaType) {
 			return
 		}
+	} else {
+		for _, member := range members {
+			if !o.checkSupportedMediaType(c, member.MediaType) {
+				return
+			}
+		}
+	}
 
-		c.Header("Accept", strings.Join(supportedMediaTypes, ", "))
-		ReportProblem(c,
-			http.StatusUnsupportedMediaType,
-			fmt.Sprintf("no active plugin found for %s", mediaType),
-		)
+	resultMediaType, ok := o.resolveResultMediaType(c, requestedResultMediaType)
+	if !ok {
+		return
+	}
+
+	if o.Sealer != nil {
+		o.submitEvidenceSealed(c, evidence, mediaType, members, offered, resultMediaType)
 		return
 	}
 
@@ -378,7 +506,8 @@ func (o *Handler) SubmitEvidence(c *gin.Context) {
 	}
 
 	// load session from request URI
-	session, err := lookupSession(o.SessionManager, id, tenantID)
+	tenant := requestTenantID(c)
+	session, err := lookupSession(o.SessionManager, id, tenant)
 	if err != nil {
 		ReportProblem(c,
 			http.StatusNotFound,
@@ -387,17 +516,21 @@ func (o *Handler) SubmitEvidence(c *gin.Context) {
 		return
 	}
 
+	// once evidence has been submitted, the session (and its result) is
+	// retained for the full TTL of the tenant's sessions
+	policy := o.sessionPolicy(tenant)
+
 	// Forward the evidence to the verifier. We expect the verifier to be
 	// able to cope with bad evidence, so the error here should only be
 	// reported if something in the verifier or the connection goes wrong.
 	// Any problems with the evidence are expected to be reported via the
 	// attestation result.
-	attestationResult, err := o.Verifier.ProcessEvidence(tenantID, session.Nonce,
-		evidence, mediaType)
+	attestationResult, err := o.processEvidence(session.Nonce,
+		evidence, mediaType, members, resultMediaType)
 	if err != nil {
 		o.logger.Error(err)
 		session.SetStatus(StatusFailed)
-		mustStoreSession(o.SessionManager, session, id, tenantID)
+		mustStoreSession(o.SessionManager, session, id, tenant, policy.TTL)
 		ReportProblem(c,
 			http.StatusInternalServerError,
 			"error encountered while processing evidence",
@@ -413,18 +546,76 @@ func (o *Handler) SubmitEvidence(c *gin.Context) {
 	// async (202)
 	if attestationResult == nil {
 		session.SetStatus(StatusProcessing)
-		s := mustStoreSession(o.SessionManager, session, id, tenantID)
+		s := mustStoreSession(o.SessionManager, session, id, tenant, policy.TTL)
 		sendChallengeResponseSessionWithStatus(c, http.StatusAccepted, s)
 		return
 	}
 
 	// sync (200)
 	session.SetStatus(StatusComplete)
-	session.SetResult(attestationResult)
-	s := mustStoreSession(o.SessionManager, session, id, tenantID)
+	session.SetResult(attestationResult, resultMediaType)
+	s := mustStoreSession(o.SessionManager, session, id, tenant, policy.TTL)
+
+	if offered != ChallengeResponseSessionMediaType {
+		c.Data(http.StatusOK, resultMediaType, attestationResult)
+		return
+	}
+
 	sendChallengeResponseSessionWithStatus(c, http.StatusOK, s)
 }
 
+// checkSupportedMediaType checks that the verifier has an active plugin for
+// the specified evidence media type. If not, the problem is reported and false
+// is returned.
+func (o *Handler) checkSupportedMediaType(c *gin.Context, mediaType string) bool {
+	isSupported, err := o.Verifier.IsSupportedMediaType(mediaType)
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("could not check media type with verifier: %v", err),
+		)
+		return false
+	}
+
+	if !isSupported {
+		supportedMediaTypes, err := o.Verifier.SupportedMediaTypes()
+		if err != nil {
+			ReportProblem(c,
+				http.StatusInternalServerError,
+				fmt.Sprintf("could not get supported media types from verifier: %v",
+					err),
+			)
+			return false
+		}
+
+		c.Header("Accept", strings.Join(supportedMediaTypes, ", "))
+		ReportProblem(c,
+			http.StatusUnsupportedMediaType,
+			fmt.Sprintf("no active plugin found for %s", mediaType),
+		)
+		return false
+	}
+
+	return true
+}
+
+// processEvidence forwards the evidence to the verifier, to be appraised for
+// vtsTenantID. If members is set, the evidence is a collection whose members
+// are appraised together.
+func (o *Handler) processEvidence(
+	nonce []byte,
+	evidence []byte,
+	mediaType string,
+	members []*proto.AttestationTokenMember,
+	resultMediaType string,
+) ([]byte, error) {
+	if members != nil {
+		return o.Verifier.ProcessEvidenceCollection(vtsTenantID, nonce, members, resultMediaType)
+	}
+
+	return o.Verifier.ProcessEvidence(vtsTenantID, nonce, evidence, mediaType, resultMediaType)
+}
+
 func (o *Handler) NewChallengeResponse(c *gin.Context) {
 	offered := c.NegotiateFormat(ChallengeResponseSessionMediaType)
 	if offered != ChallengeResponseSessionMediaType {
@@ -435,8 +626,11 @@ func (o *Handler) NewChallengeResponse(c *gin.Context) {
 		return
 	}
 
-	// parse query to devise the nonce
-	nonce, err := parseNonceRequest(c.Query("nonce"), c.Query("nonceSize"))
+	tenant := requestTenantID(c)
+	policy := o.sessionPolicy(tenant)
+
+	// parse query to devise the nonce and session TTL
+	nonce, err := parseNonceRequest(c.Query("nonce"), c.Query("nonceSize"), policy)
 	if err != nil {
 		status := http.StatusBadRequest
 
@@ -451,6 +645,15 @@ func (o *Handler) NewChallengeResponse(c *gin.Context) {
 		return
 	}
 
+	ttl, err := parseTTLRequest(c.Query("ttl"), policy)
+	if err != nil {
+		ReportProblem(c,
+			http.StatusBadRequest,
+			fmt.Sprintf("failed handling ttl request: %s", err),
+		)
+		return
+	}
+
 	supportedMediaTypes, err := o.Verifier.SupportedMediaTypes()
 	if err != nil {
 		ReportProblem(c,
@@ -460,7 +663,12 @@ func (o *Handler) NewChallengeResponse(c *gin.Context) {
 		return
 	}
 
-	id, session, err := newSession(nonce, supportedMediaTypes, ConfigSessionTTL)
+	if o.Sealer != nil {
+		o.newSealedSession(c, nonce, supportedMediaTypes, ttl)
+		return
+	}
+
+	id, session, err := newSession(nonce, supportedMediaTypes, ttl)
 	if err != nil {
 		ReportProblem(c,
 			http.StatusInternalServerError,
@@ -469,7 +677,7 @@ func (o *Handler) NewChallengeResponse(c *gin.Context) {
 		return
 	}
 
-	err = o.SessionManager.SetSession(id, tenantID, session, ConfigSessionTTL)
+	err = o.SessionManager.SetSession(id, tenant, session, ttl)
 	if err != nil {
 		ReportProblem(c,
 			http.StatusInternalServerError,
@@ -481,6 +689,256 @@ func (o *Handler) NewChallengeResponse(c *gin.Context) {
 	sendChallengeResponseSessionCreated(c, id.String(), session)
 }
 
+func (o *Handler) newSealedSession(
+	c *gin.Context,
+	nonce []byte,
+	supportedMediaTypes []string,
+	ttl time.Duration,
+) {
+	session := &ChallengeResponseSession{
+		Status: StatusWaiting,
+		Nonce:  nonce,
+		Expiry: time.Now().Add(ttl),
+		Accept: supportedMediaTypes,
+	}
+
+	token, err := o.Sealer.Seal(sealedsession.Claims{
+		Tenant: requestTenantID(c),
+		Nonce:  session.Nonce,
+		Expiry: session.Expiry,
+	})
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("could not seal session: %v", err),
+		)
+		return
+	}
+
+	jsonSession, err := json.Marshal(session)
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	sendChallengeResponseSessionCreated(c, token, jsonSession)
+}
+
+// unsealSessionFromRequestURI reconstructs the session from the sealed token
+// in the request URI. If the token cannot be unsealed, or belongs to a
+// different tenant, the problem is reported and false is returned.
+func (o *Handler) unsealSessionFromRequestURI(c *gin.Context) (*ChallengeResponseSession, bool) {
+	claims, err := o.Sealer.Unseal(c.Param("id"))
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, sealedsession.ErrMalformed) {
+			status = http.StatusBadRequest
+		}
+
+		ReportProblem(c,
+			status,
+			fmt.Sprintf("invalid session id (%s) in path segment: %v", c.Request.URL.Path, err),
+		)
+		return nil, false
+	}
+
+	if tenant := requestTenantID(c); claims.Tenant != tenant {
+		ReportProblem(c,
+			http.StatusNotFound,
+			fmt.Sprintf("session not found for tenant %s", tenant),
+		)
+		return nil, false
+	}
+
+	supportedMediaTypes, err := o.Verifier.SupportedMediaTypes()
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("could not get media types form verifier: %v", err),
+		)
+		return nil, false
+	}
+
+	return &ChallengeResponseSession{
+		Status: StatusWaiting,
+		Nonce:  claims.Nonce,
+		Expiry: claims.Expiry,
+		Accept: supportedMediaTypes,
+	}, true
+}
+
+// submitEvidenceSealed processes evidence submitted against a sealed session.
+// The freshness of the session is established by unsealing the token;
+// however, as nothing is stored, a token may be used for multiple
+// submissions until it expires.
+func (o *Handler) submitEvidenceSealed(
+	c *gin.Context,
+	evidence []byte,
+	mediaType string,
+	members []*proto.AttestationTokenMember,
+	offered string,
+	resultMediaType string,
+) {
+	session, ok := o.unsealSessionFromRequestURI(c)
+	if !ok {
+		return
+	}
+
+	attestationResult, err := o.processEvidence(session.Nonce,
+		evidence, mediaType, members, resultMediaType)
+	if err != nil {
+		o.logger.Error(err)
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			"error encountered while processing evidence",
+		)
+		return
+	}
+
+	session.SetEvidence(mediaType, evidence)
+
+	status := http.StatusOK
+	if attestationResult == nil {
+		// There is nowhere to store the result once the verifier
+		// completes, so the client will not be able to retrieve it.
+		o.logger.Warn("verifier returned no result for a sealed session")
+		session.SetStatus(StatusProcessing)
+		status = http.StatusAccepted
+	} else {
+		session.SetStatus(StatusComplete)
+		session.SetResult(attestationResult, resultMediaType)
+
+		if offered != ChallengeResponseSessionMediaType {
+			c.Data(http.StatusOK, resultMediaType, attestationResult)
+			return
+		}
+	}
+
+	jsonSession, err := json.Marshal(session)
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	sendChallengeResponseSessionWithStatus(c, status, jsonSession)
+}
+
+// negotiateResponseFormat works out from the Accept header whether the client
+// wants a ChallengeResponseSession (the default) or just the attestation
+// result. It returns the negotiated response media type, and the attestation
+// result format explicitly requested by the client, if any (which, in case of
+// a ChallengeResponseSession, is the format of the embedded result). If none
+// of the supported formats is acceptable, the problem is reported and false is
+// returned.
+func negotiateResponseFormat(c *gin.Context) (string, string, bool) {
+	offers := append([]string{ChallengeResponseSessionMediaType}, resultMediaTypes...)
+
+	offered := c.NegotiateFormat(offers...)
+	switch offered {
+	case "":
+		ReportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the supported output formats are %s", strings.Join(offers, ", ")),
+		)
+		return "", "", false
+	case ChallengeResponseSessionMediaType:
+		// wildcards do not count as an explicit request for a result format
+		for _, accepted := range c.Accepted {
+			for _, mt := range resultMediaTypes {
+				if accepted == baseMediaType(mt) {
+					return offered, mt, true
+				}
+			}
+		}
+		return offered, "", true
+	default:
+		return offered, offered, true
+	}
+}
+
+// resolveResultMediaType returns the attestation result format supported by the
+// verifier that matches the requested one, or the default format if none was
+// requested. If the requested format is not supported, the problem is
+// reported and false is returned.
+func (o *Handler) resolveResultMediaType(c *gin.Context, requested string) (string, bool) {
+	supported, err := o.Verifier.SupportedResultMediaTypes()
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("could not get result media types from verifier: %v", err),
+		)
+		return "", false
+	}
+
+	if len(supported) == 0 {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			"verifier does not support any result media type",
+		)
+		return "", false
+	}
+
+	if requested == "" {
+		return supported[0], true
+	}
+
+	for _, mt := range supported {
+		if baseMediaType(mt) == baseMediaType(requested) {
+			return mt, true
+		}
+	}
+
+	ReportProblem(c,
+		http.StatusNotAcceptable,
+		fmt.Sprintf("attestation result format %s is not supported; supported formats are %s",
+			requested, strings.Join(supported, ", ")),
+	)
+	return "", false
+}
+
+// sendResult sends the attestation result of the session, provided it is
+// available in the requested format.
+func sendResult(c *gin.Context, session *ChallengeResponseSession, mediaType string) {
+	if session.Result == nil {
+		ReportProblem(c,
+			http.StatusNotFound,
+			fmt.Sprintf("no attestation result available for session in %s state", session.Status),
+		)
+		return
+	}
+
+	if session.ResultMediaType == nil ||
+		baseMediaType(*session.ResultMediaType) != baseMediaType(mediaType) {
+		available := earsigner.EarJWTMediaType
+		if session.ResultMediaType != nil {
+			available = *session.ResultMediaType
+		}
+
+		ReportProblem(c,
+			http.StatusNotAcceptable,
+			fmt.Sprintf("the attestation result is only available as %s", available),
+		)
+		return
+	}
+
+	result, err := session.GetResult()
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("could not decode attestation result: %v", err),
+		)
+		return
+	}
+
+	c.Data(http.StatusOK, *session.ResultMediaType, result)
+}
+
 func sendChallengeResponseSessionWithStatus(c *gin.Context, status int, jsonSession []byte) {
 	c.Data(status, ChallengeResponseSessionMediaType, jsonSession)
 }
@@ -579,6 +1037,31 @@ func (o *Handler) GetWellKnownVerificationInfo(c *gin.Context) {
 		return
 	}
 
+	// Get attestation result formats and signing algorithms
+	obj.ResultMediaTypes, err = o.Verifier.SupportedResultMediaTypes()
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	if key != nil && key.Algorithm().String() != "" {
+		obj.ResultSigningAlgorithms = []string{key.Algorithm().String()}
+	}
+
+	// Get the session parameters in effect for the requesting tenant
+	policy := o.sessionPolicy(requestTenantID(c))
+	obj.SessionPolicy = &capability.SessionPolicy{
+		TTL:              policy.TTL.String(),
+		MinTTL:           policy.MinTTL.String(),
+		NonceSize:        policy.NonceSize,
+		MinNonceSize:     policy.MinNonceSize,
+		MaxNonceSize:     policy.MaxNonceSize,
+		AllowClientNonce: policy.AllowClientNonce,
+	}
+
 	c.Header("Content-Type", capability.WellKnownMediaType)
 	c.JSON(http.StatusOK, obj)
 }
@@ -4,6 +4,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,17 +15,26 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/moogar0880/problems"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/veraison/cmw"
+	"github.com/veraison/services/auth"
 	"github.com/veraison/services/capability"
+	"github.com/veraison/services/log"
 	"github.com/veraison/services/proto"
+	"github.com/veraison/services/sessionmanager"
 	mock_deps "github.com/veraison/services/verification/api/mocks"
+	"github.com/veraison/services/verification/sealedsession"
+	"github.com/veraison/services/vts/earsigner"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -86,7 +96,8 @@ var (
 		"type":"application/eat_cwt; profile=http://arm.com/psa/2.0.0",
 		"value":"eyAiayI6ICJ2IiB9"
 	},
-	"result": "{}"
+	"result": "{}",
+	"result-media-type": "application/eat+jwt; eat_profile=\"tag:github.com,2023:veraison/ear\""
 }`
 	testUUIDString     = "5c5bd88b-c922-482b-ad9f-097e187b42a1"
 	testUUID           = uuid.MustParse(testUUIDString)
@@ -115,8 +126,46 @@ var (
 	testKey = proto.PublicKey{
 		Key: testKeyJSON,
 	}
+
+	testResultMediaTypes = []string{earsigner.EarJWTMediaType, earsigner.EarCWTMediaType}
+
+	testAuthorizer = auth.NewPassthroughAuthorizer(log.Named("auth"))
+
+	testSessionPolicies = DefaultSessionPolicies()
 )
 
+// newTestBasicAuthorizer returns a basic authorizer with an attester user
+// ("att") and a relying party user ("rp"), both associated with tenant "7",
+// and with password "password".
+func newTestBasicAuthorizer(t *testing.T) auth.IAuthorizer {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	v := viper.New()
+	v.Set("backend", "basic")
+	v.Set("users", map[string]interface{}{
+		"att": map[string]interface{}{
+			"password": string(hash),
+			"roles":    []string{auth.AttesterRole},
+			"tenant":   "7",
+		},
+		"rp": map[string]interface{}{
+			"password": string(hash),
+			"roles":    []string{auth.RelyingPartyRole},
+			"tenant":   "7",
+		},
+		"mgr": map[string]interface{}{
+			"password": string(hash),
+			"roles":    []string{auth.ManagerRole},
+		},
+	})
+
+	a, err := auth.NewAuthorizer(v, log.Named("auth"))
+	require.NoError(t, err)
+
+	return a
+}
+
 func TestHandler_NewChallengeResponse_UnsupportedAccept(t *testing.T) {
 	h := &Handler{}
 
@@ -134,7 +183,7 @@ func TestHandler_NewChallengeResponse_UnsupportedAccept(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodPost, "/challenge-response/v1/newSession", http.NoBody)
 	req.Header.Set("Accept", "application/unsupported+ber")
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -162,7 +211,7 @@ func testHandler_NewChallengeResponse_BadNonce(t *testing.T, queryParams url.Val
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.URL.RawQuery = queryParams.Encode()
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -223,7 +272,7 @@ func TestHandler_NewChallengeResponse_NoNonceParameters(t *testing.T) {
 		SupportedMediaTypes().
 		Return(testSupportedMediaTypes, nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	expectedCode := http.StatusCreated
 	expectedType := ChallengeResponseSessionMediaType
@@ -235,7 +284,7 @@ func TestHandler_NewChallengeResponse_NoNonceParameters(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodPost, "/challenge-response/v1/newSession", http.NoBody)
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body ChallengeResponseSession
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -249,6 +298,104 @@ func TestHandler_NewChallengeResponse_NoNonceParameters(t *testing.T) {
 	assert.Equal(t, expectedSessionStatus, body.Status)
 }
 
+func TestHandler_NewChallengeResponse_TTLParameter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		SetSession(gomock.Any(), tenantID, gomock.Any(), 30*time.Second).
+		Return(nil)
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedMediaTypes().
+		Return(testSupportedMediaTypes, nil)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodPost, "/challenge-response/v1/newSession?ttl=30s", http.NoBody)
+	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	var body ChallengeResponseSession
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), body.Expiry, 5*time.Second)
+}
+
+func TestHandler_NewChallengeResponse_BadTTLParameter(t *testing.T) {
+	for ttl, expectedErr := range map[string]string{
+		"1h":    "failed handling ttl request: ttl must be in range 1s..2m30s",
+		"100ms": "failed handling ttl request: ttl must be in range 1s..2m30s",
+		"soon":  `failed handling ttl request: invalid ttl: "soon"`,
+	} {
+		q := url.Values{}
+		q.Add("ttl", ttl)
+
+		testHandler_NewChallengeResponse_BadNonce(t, q, expectedErr)
+	}
+}
+
+func TestHandler_NewChallengeResponse_tenant_policy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	policies := DefaultSessionPolicies()
+	policies.Tenants["7"] = SessionPolicy{
+		TTL:          time.Minute,
+		MinTTL:       time.Second,
+		NonceSize:    16,
+		MinNonceSize: 16,
+		MaxNonceSize: 32,
+	}
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		SetSession(gomock.Any(), "7", gomock.Any(), time.Minute).
+		Return(nil)
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedMediaTypes().
+		Return(testSupportedMediaTypes, nil)
+
+	h := NewHandler(sm, v, policies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodPost, testNewSessionURL, http.NoBody)
+	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+	req.SetBasicAuth("att", "password")
+
+	NewRouter(h, newTestBasicAuthorizer(t), "").ServeHTTP(w, req)
+
+	var body ChallengeResponseSession
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, body.Nonce, 16)
+
+	// the tenant does not allow client-supplied nonces
+	w = httptest.NewRecorder()
+
+	req, _ = http.NewRequest(http.MethodPost, testNewSessionURL+"?nonce=QUJDREVGR0hJSktMTU5PUA==", http.NoBody)
+	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+	req.SetBasicAuth("att", "password")
+
+	NewRouter(h, newTestBasicAuthorizer(t), "").ServeHTTP(w, req)
+
+	var problem problems.DefaultProblem
+	_ = json.Unmarshal(w.Body.Bytes(), &problem)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "failed handling nonce request: nonce may not be supplied by the client", problem.Detail)
+}
+
 func TestHandler_NewChallengeResponse_NonceParameter(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -263,7 +410,7 @@ func TestHandler_NewChallengeResponse_NonceParameter(t *testing.T) {
 		SupportedMediaTypes().
 		Return(testSupportedMediaTypes, nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	expectedCode := http.StatusCreated
 	expectedType := ChallengeResponseSessionMediaType
@@ -281,7 +428,7 @@ func TestHandler_NewChallengeResponse_NonceParameter(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.URL.RawQuery = qParams.Encode()
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body ChallengeResponseSession
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -309,7 +456,7 @@ func TestHandler_NewChallengeResponse_NonceSizeParameter(t *testing.T) {
 		SupportedMediaTypes().
 		Return(testSupportedMediaTypes, nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	expectedCode := http.StatusCreated
 	expectedType := ChallengeResponseSessionMediaType
@@ -326,7 +473,7 @@ func TestHandler_NewChallengeResponse_NonceSizeParameter(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.URL.RawQuery = qParams.Encode()
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body ChallengeResponseSession
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -365,7 +512,7 @@ func TestHandler_NewChallengeResponse_SetSessionFailure(t *testing.T) {
 		SupportedMediaTypes().
 		Return(testSupportedMediaTypes, nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	qParams := url.Values{}
 	qParams.Add("nonceSize", "32")
@@ -376,7 +523,7 @@ func TestHandler_NewChallengeResponse_SetSessionFailure(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.URL.RawQuery = qParams.Encode()
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -397,7 +544,8 @@ func testHandler_UnsupportedAccept(t *testing.T, method string) {
 		Type:   "about:blank",
 		Title:  "Not Acceptable",
 		Status: http.StatusNotAcceptable,
-		Detail: fmt.Sprintf("the only supported output format is %s", ChallengeResponseSessionMediaType),
+		Detail: fmt.Sprintf("the supported output formats are %s, %s, %s",
+			ChallengeResponseSessionMediaType, earsigner.EarJWTMediaType, earsigner.EarCWTMediaType),
 	}
 
 	w := httptest.NewRecorder()
@@ -405,7 +553,7 @@ func testHandler_UnsupportedAccept(t *testing.T, method string) {
 	req, _ := http.NewRequest(method, url, http.NoBody)
 	req.Header.Set("Accept", "application/unsupported+ber")
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -446,7 +594,7 @@ func TestHandler_SubmitEvidence_unsupported_evidence_format(t *testing.T) {
 		IsSupportedMediaType(testUnsupportedMediaType).
 		Return(false, nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -454,7 +602,7 @@ func TestHandler_SubmitEvidence_unsupported_evidence_format(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testUnsupportedMediaType)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -483,11 +631,14 @@ func TestHandler_SubmitEvidence_bad_session_id_url(t *testing.T) {
 	sm := mock_deps.NewMockISessionManager(ctrl)
 
 	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
 	v.EXPECT().
 		IsSupportedMediaType(testSupportedMediaTypeA).
 		Return(true, nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -495,7 +646,7 @@ func TestHandler_SubmitEvidence_bad_session_id_url(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -528,11 +679,14 @@ func TestHandler_SubmitEvidence_session_not_found(t *testing.T) {
 		Return(nil, errors.New(smErr))
 
 	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
 	v.EXPECT().
 		IsSupportedMediaType(testSupportedMediaTypeA).
 		Return(true, nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -540,7 +694,7 @@ func TestHandler_SubmitEvidence_session_not_found(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -567,7 +721,7 @@ func TestHandler_SubmitEvidence_no_body(t *testing.T) {
 
 	sm := mock_deps.NewMockISessionManager(ctrl)
 	v := mock_deps.NewMockIVerifier(ctrl)
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -575,7 +729,7 @@ func TestHandler_SubmitEvidence_no_body(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -609,14 +763,17 @@ func TestHandler_SubmitEvidence_process_evidence_failed(t *testing.T) {
 		Return(nil)
 
 	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
 	v.EXPECT().
 		IsSupportedMediaType(testSupportedMediaTypeA).
 		Return(true, nil)
 	v.EXPECT().
-		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA).
+		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA, earsigner.EarJWTMediaType).
 		Return(nil, errors.New(vmErr))
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -624,7 +781,7 @@ func TestHandler_SubmitEvidence_process_evidence_failed(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	body := w.Body.Bytes()
 
@@ -652,14 +809,17 @@ func TestHandler_SubmitEvidence_process_ok_sync(t *testing.T) {
 		Return(nil)
 
 	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
 	v.EXPECT().
 		IsSupportedMediaType(testSupportedMediaTypeA).
 		Return(true, nil)
 	v.EXPECT().
-		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA).
+		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA, earsigner.EarJWTMediaType).
 		Return([]byte(testResult), nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -667,7 +827,7 @@ func TestHandler_SubmitEvidence_process_ok_sync(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	body := w.Body.Bytes()
 
@@ -695,14 +855,17 @@ func TestHandler_SubmitEvidence_process_ok_async(t *testing.T) {
 		Return(nil)
 
 	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
 	v.EXPECT().
 		IsSupportedMediaType(testSupportedMediaTypeA).
 		Return(true, nil)
 	v.EXPECT().
-		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA).
+		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA, earsigner.EarJWTMediaType).
 		Return(nil, nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -710,7 +873,7 @@ func TestHandler_SubmitEvidence_process_ok_async(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	body := w.Body.Bytes()
 
@@ -741,7 +904,7 @@ func TestHandler_GetSession_bad_session_id_url(t *testing.T) {
 	sm := mock_deps.NewMockISessionManager(ctrl)
 	v := mock_deps.NewMockIVerifier(ctrl)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -749,7 +912,7 @@ func TestHandler_GetSession_bad_session_id_url(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -783,7 +946,7 @@ func TestHandler_GetSession_session_not_found(t *testing.T) {
 
 	v := mock_deps.NewMockIVerifier(ctrl)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -791,7 +954,7 @@ func TestHandler_GetSession_session_not_found(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -818,7 +981,7 @@ func TestHandler_GetSession_ok(t *testing.T) {
 
 	v := mock_deps.NewMockIVerifier(ctrl)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -826,7 +989,7 @@ func TestHandler_GetSession_ok(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", testSupportedMediaTypeA)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	body := w.Body.Bytes()
 
@@ -850,13 +1013,13 @@ func TestHandler_DelSession_ok(t *testing.T) {
 
 	v := mock_deps.NewMockIVerifier(ctrl)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
 	req, _ := http.NewRequest(http.MethodDelete, pathOK, http.NoBody)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	assert.Equal(t, expectedCode, w.Code)
 }
@@ -879,13 +1042,13 @@ func TestHandler_DelSession_bad_session_id(t *testing.T) {
 	sm := mock_deps.NewMockISessionManager(ctrl)
 	v := mock_deps.NewMockIVerifier(ctrl)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
 	req, _ := http.NewRequest(http.MethodDelete, badPath, http.NoBody)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -917,13 +1080,13 @@ func TestHandler_DelSession_session_id_does_not_exist(t *testing.T) {
 
 	v := mock_deps.NewMockIVerifier(ctrl)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
 	req, _ := http.NewRequest(http.MethodDelete, pathOK, http.NoBody)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -942,6 +1105,9 @@ func TestHandler_GetWellKnownVerificationInfo_ok(t *testing.T) {
 	sm := mock_deps.NewMockISessionManager(ctrl)
 
 	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
 	v.EXPECT().
 		GetPublicKey().
 		Return(&testKey, nil)
@@ -955,20 +1121,30 @@ func TestHandler_GetWellKnownVerificationInfo_ok(t *testing.T) {
 	expectedCode := http.StatusOK
 	expectedType := capability.WellKnownMediaType
 	expectedBody := capability.WellKnownInfo{
-		MediaTypes:   supportedMediaTypes,
+		MediaTypes:              supportedMediaTypes,
+		ResultMediaTypes:        testResultMediaTypes,
+		ResultSigningAlgorithms: []string{"ES256"},
+		SessionPolicy: &capability.SessionPolicy{
+			TTL:              "2m30s",
+			MinTTL:           "1s",
+			NonceSize:        32,
+			MinNonceSize:     8,
+			MaxNonceSize:     64,
+			AllowClientNonce: true,
+		},
 		Version:      testGoodServiceState.ServerVersion,
 		ServiceState: capability.ServiceStateToAPI(testGoodServiceState.Status.String()),
 		ApiEndpoints: publicApiMap,
 	}
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
 	req, _ := http.NewRequest(http.MethodGet, "/.well-known/veraison/verification", http.NoBody)
 	req.Header.Add("Accept", expectedType)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body capability.WellKnownInfo
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -993,13 +1169,13 @@ func TestHandler_GetWellKnownVerificationInfo_GetPublicKey_failure(t *testing.T)
 	expectedType := "application/problem+json"
 	expectedErrorTitle := "Internal Server Error"
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
 	req, _ := http.NewRequest(http.MethodGet, "/.well-known/veraison/verification", http.NoBody)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -1027,13 +1203,13 @@ func TestHandler_GetWellKnownVerificationInfo_Get_SupportedMediaTypes_fail(t *te
 	expectedType := "application/problem+json"
 	expectedErrorTitle := "Internal Server Error"
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
 	req, _ := http.NewRequest(http.MethodGet, "/.well-known/veraison/verification", http.NoBody)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -1065,13 +1241,13 @@ func TestHandler_GetWellKnownVerificationInfo_GetVTSState_fail(t *testing.T) {
 	expectedType := "application/problem+json"
 	expectedErrorTitle := "Internal Server Error"
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
 	req, _ := http.NewRequest(http.MethodGet, "/.well-known/veraison/verification", http.NoBody)
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -1099,7 +1275,7 @@ func TestHandler_GetWellKnownVerificationInfo_UnsupportedAccept(t *testing.T) {
 	g.Request, _ = http.NewRequest(http.MethodGet, "/.well-known/veraison/verification", http.NoBody)
 	g.Request.Header.Add("Accept", "application/unsupported+ber")
 
-	NewRouter(h).ServeHTTP(w, g.Request)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, g.Request)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -1138,14 +1314,17 @@ func TestHandler_SubmitEvidence_good_CMW(t *testing.T) {
 		Return(nil)
 
 	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
 	v.EXPECT().
 		IsSupportedMediaType(testSupportedMediaTypeA).
 		Return(true, nil)
 	v.EXPECT().
-		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA).
+		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA, earsigner.EarJWTMediaType).
 		Return([]byte(testResult), nil)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -1153,7 +1332,7 @@ func TestHandler_SubmitEvidence_good_CMW(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", "application/vnd.veraison.cmw")
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	_ = w.Body.Bytes()
 
@@ -1184,7 +1363,7 @@ func TestHandler_SubmitEvidence_bad_CMW(t *testing.T) {
 
 	v := mock_deps.NewMockIVerifier(ctrl)
 
-	h := NewHandler(sm, v)
+	h := NewHandler(sm, v, testSessionPolicies)
 
 	w := httptest.NewRecorder()
 
@@ -1192,7 +1371,7 @@ func TestHandler_SubmitEvidence_bad_CMW(t *testing.T) {
 	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
 	req.Header.Set("Content-Type", "application/vnd.veraison.cmw")
 
-	NewRouter(h).ServeHTTP(w, req)
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
 
 	var body problems.DefaultProblem
 	_ = json.Unmarshal(w.Body.Bytes(), &body)
@@ -1201,3 +1380,875 @@ func TestHandler_SubmitEvidence_bad_CMW(t *testing.T) {
 	assert.Equal(t, expectedType, w.Result().Header.Get("Content-Type"))
 	assert.Equal(t, expectedBody, body)
 }
+
+func goodCMWCollection(t *testing.T, s cmw.Serialization) []byte {
+	var a, b cmw.CMW
+
+	a.SetMediaType(testSupportedMediaTypeA)
+	a.SetValue([]byte(testJSONBody))
+
+	b.SetMediaType(testSupportedMediaTypeB)
+	b.SetValue([]byte{0xd2, 0x84})
+
+	collection := map[string]cmw.CMW{"realm": a, "platform": b}
+
+	var (
+		data []byte
+		err  error
+	)
+
+	if s == cmw.CBORArray {
+		data, err = cbor.Marshal(collection)
+	} else {
+		data, err = json.Marshal(collection)
+	}
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestHandler_SubmitEvidence_CMW_collection(t *testing.T) {
+	for _, s := range []cmw.Serialization{cmw.JSONArray, cmw.CBORArray} {
+		ctrl := gomock.NewController(t)
+
+		pathOK := path.Join(testSessionBaseURL, testUUIDString)
+
+		testCollection := goodCMWCollection(t, s)
+
+		sm := mock_deps.NewMockISessionManager(ctrl)
+		sm.EXPECT().
+			GetSession(testUUID, tenantID).
+			Return([]byte(testSession), nil)
+		sm.EXPECT().
+			SetSession(testUUID, tenantID, gomock.Any(), ConfigSessionTTL).
+			Return(nil)
+
+		v := mock_deps.NewMockIVerifier(ctrl)
+		v.EXPECT().
+			SupportedResultMediaTypes().
+			Return(testResultMediaTypes, nil)
+		v.EXPECT().
+			IsSupportedMediaType(testSupportedMediaTypeA).
+			Return(true, nil)
+		v.EXPECT().
+			IsSupportedMediaType(testSupportedMediaTypeB).
+			Return(true, nil)
+		v.EXPECT().
+			ProcessEvidenceCollection(tenantID, testNonce, gomock.Any(), earsigner.EarJWTMediaType).
+			DoAndReturn(func(_ string, _ []byte, members []*proto.AttestationTokenMember, _ string) ([]byte, error) {
+				require.Len(t, members, 2)
+				// members are sorted by label
+				assert.Equal(t, "platform", members[0].Label)
+				assert.Equal(t, testSupportedMediaTypeB, members[0].MediaType)
+				assert.Equal(t, []byte{0xd2, 0x84}, members[0].Data)
+				assert.Equal(t, "realm", members[1].Label)
+				assert.Equal(t, testSupportedMediaTypeA, members[1].MediaType)
+				assert.Equal(t, []byte(testJSONBody), members[1].Data)
+				return []byte(testResult), nil
+			})
+
+		h := NewHandler(sm, v, testSessionPolicies)
+
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodPost, pathOK, bytes.NewReader(testCollection))
+		req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+		req.Header.Set("Content-Type", "application/cmw")
+
+		NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+		var session ChallengeResponseSession
+		_ = json.Unmarshal(w.Body.Bytes(), &session)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, ChallengeResponseSessionMediaType, w.Result().Header.Get("Content-Type"))
+		// the collection is kept as submitted
+		require.NotNil(t, session.Evidence)
+		assert.Equal(t, "application/cmw", session.Evidence.Type)
+		assert.Equal(t, testCollection, session.Evidence.Value)
+
+		ctrl.Finish()
+	}
+}
+
+func TestHandler_SubmitEvidence_CMW_collection_unsupported_member(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	url := path.Join(testSessionBaseURL, testUUIDString)
+
+	expectedBody := problems.DefaultProblem{
+		Type:   "about:blank",
+		Title:  "Unsupported Media Type",
+		Status: http.StatusUnsupportedMediaType,
+		Detail: fmt.Sprintf("no active plugin found for %s", testSupportedMediaTypeB),
+	}
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		IsSupportedMediaType(testSupportedMediaTypeB).
+		Return(false, nil)
+	v.EXPECT().
+		SupportedMediaTypes().
+		Return([]string{testSupportedMediaTypeA}, nil)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(goodCMWCollection(t, cmw.JSONArray)))
+	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+	req.Header.Set("Content-Type", "application/cmw+json")
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	var body problems.DefaultProblem
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, testSupportedMediaTypeA, w.Result().Header.Get("Accept"))
+	assert.Equal(t, expectedBody, body)
+}
+
+func TestHandler_SubmitEvidence_bad_CMW_collection(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	url := path.Join(testSessionBaseURL, testUUIDString)
+
+	for body, detail := range map[string]string{
+		`{}`:                        "could not unwrap the CMW collection: empty collection",
+		`{"a": ["missing value"]}`:  `could not unwrap the CMW collection: member "a": wrong number of entries (1) in the CMW array`,
+		`{"a": {"b": ["x", "eQ"]}}`: `could not unwrap the CMW collection: member "a": nested collections are not supported`,
+	} {
+		sm := mock_deps.NewMockISessionManager(ctrl)
+		v := mock_deps.NewMockIVerifier(ctrl)
+
+		h := NewHandler(sm, v, testSessionPolicies)
+
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+		req.Header.Set("Content-Type", "application/cmw+json")
+
+		NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+		var problem problems.DefaultProblem
+		_ = json.Unmarshal(w.Body.Bytes(), &problem)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, detail, problem.Detail)
+	}
+}
+
+func newTestSealer(t *testing.T) sealedsession.ISealer {
+	sealer, err := sealedsession.NewSealer(
+		[]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	require.NoError(t, err)
+
+	return sealer
+}
+
+func TestHandler_Sealed_NewChallengeResponse_and_SubmitEvidence_ok(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
+	v.EXPECT().
+		SupportedMediaTypes().
+		Return(testSupportedMediaTypes, nil).
+		Times(2)
+	v.EXPECT().
+		IsSupportedMediaType(testSupportedMediaTypeA).
+		Return(true, nil)
+	v.EXPECT().
+		ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA, earsigner.EarJWTMediaType).
+		Return([]byte(testResult), nil)
+
+	// note: no session manager -- nothing is stored
+	h := NewSealedSessionHandler(newTestSealer(t), v, testSessionPolicies)
+
+	qParams := url.Values{}
+	qParams.Add("nonce", base64.URLEncoding.EncodeToString(testNonce))
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodPost, testNewSessionURL, http.NoBody)
+	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+	req.URL.RawQuery = qParams.Encode()
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	var created ChallengeResponseSession
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, StatusWaiting, created.Status)
+	assert.Equal(t, testNonce, created.Nonce)
+
+	location := w.Result().Header.Get("Location")
+	assert.Regexp(t, `^session/[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`, location)
+
+	w = httptest.NewRecorder()
+
+	req, _ = http.NewRequest(http.MethodPost,
+		path.Join("/challenge-response/v1", location), strings.NewReader(testJSONBody))
+	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+	req.Header.Set("Content-Type", testSupportedMediaTypeA)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	var complete ChallengeResponseSession
+	_ = json.Unmarshal(w.Body.Bytes(), &complete)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ChallengeResponseSessionMediaType, w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, StatusComplete, complete.Status)
+	assert.Equal(t, testNonce, complete.Nonce)
+	assert.True(t, created.Expiry.Equal(complete.Expiry))
+	require.NotNil(t, complete.Result)
+	assert.Equal(t, testResult, *complete.Result)
+}
+
+func TestHandler_Sealed_GetSession_bad_token(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+
+	h := NewSealedSessionHandler(newTestSealer(t), v, testSessionPolicies)
+
+	for _, tc := range []struct {
+		token        string
+		expectedCode int
+	}{
+		{token: testUUIDString, expectedCode: http.StatusBadRequest},
+		{token: "e30.AAAA", expectedCode: http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodGet,
+			path.Join(testSessionBaseURL, tc.token), http.NoBody)
+		req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+
+		NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+		assert.Equal(t, tc.expectedCode, w.Code, tc.token)
+		assert.Equal(t, "application/problem+json", w.Result().Header.Get("Content-Type"))
+	}
+}
+
+func TestHandler_Sealed_DelSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := NewSealedSessionHandler(newTestSealer(t), mock_deps.NewMockIVerifier(ctrl), testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodDelete,
+		path.Join(testSessionBaseURL, "e30.AAAA"), http.NoBody)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Equal(t, "application/problem+json", w.Result().Header.Get("Content-Type"))
+}
+
+func TestHandler_GetSession_tenant_from_authorizer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		GetSession(testUUID, "7").
+		Return([]byte(testCompleteSession), nil)
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodGet,
+		path.Join(testSessionBaseURL, testUUIDString), http.NoBody)
+	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+	req.SetBasicAuth("rp", "password")
+
+	NewRouter(h, newTestBasicAuthorizer(t), "").ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, testCompleteSession, w.Body.String())
+}
+
+func TestHandler_GetSession_requires_relying_party(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	v := mock_deps.NewMockIVerifier(ctrl)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	for _, user := range []string{"", "att"} {
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodGet,
+			path.Join(testSessionBaseURL, testUUIDString), http.NoBody)
+		req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+		if user != "" {
+			req.SetBasicAuth(user, "password")
+		}
+
+		// the session must be readable by relying parties only, even
+		// when anonymous evidence submission is allowed
+		NewRouter(h, newTestBasicAuthorizer(t), "7").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, user)
+	}
+}
+
+func TestHandler_NewChallengeResponse_requires_attester(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	v := mock_deps.NewMockIVerifier(ctrl)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	for _, user := range []string{"", "rp"} {
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodPost, testNewSessionURL, http.NoBody)
+		req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+		if user != "" {
+			req.SetBasicAuth(user, "password")
+		}
+
+		NewRouter(h, newTestBasicAuthorizer(t), "").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, user)
+	}
+}
+
+func TestHandler_NewChallengeResponse_tenant(t *testing.T) {
+	for _, tc := range []struct {
+		user            string
+		anonymousTenant string
+		expectedTenant  string
+	}{
+		{user: "att", anonymousTenant: "", expectedTenant: "7"},
+		{user: "", anonymousTenant: "7", expectedTenant: "7"},
+		{user: "", anonymousTenant: "anon", expectedTenant: "anon"},
+		// attesters are still associated with their own tenant
+		{user: "att", anonymousTenant: "anon", expectedTenant: "7"},
+	} {
+		ctrl := gomock.NewController(t)
+
+		sm := mock_deps.NewMockISessionManager(ctrl)
+		sm.EXPECT().
+			SetSession(gomock.Any(), tc.expectedTenant, gomock.Any(), ConfigSessionTTL).
+			Return(nil)
+
+		v := mock_deps.NewMockIVerifier(ctrl)
+		v.EXPECT().
+			SupportedMediaTypes().
+			Return(testSupportedMediaTypes, nil)
+
+		h := NewHandler(sm, v, testSessionPolicies)
+
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodPost, testNewSessionURL, http.NoBody)
+		req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, "password")
+		}
+
+		NewRouter(h, newTestBasicAuthorizer(t), tc.anonymousTenant).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code, tc.user)
+
+		ctrl.Finish()
+	}
+}
+
+func TestHandler_SubmitEvidence_tenant(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the session belongs to the attester's tenant, but the evidence is
+	// appraised against the endorsements provisioned for vtsTenantID
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		GetSession(testUUID, "7").
+		Return([]byte(testSession), nil)
+	sm.EXPECT().
+		SetSession(testUUID, "7", gomock.Any(), ConfigSessionTTL).
+		Return(nil)
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return(testResultMediaTypes, nil)
+	v.EXPECT().
+		IsSupportedMediaType(testSupportedMediaTypeA).
+		Return(true, nil)
+	v.EXPECT().
+		ProcessEvidence(vtsTenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA, earsigner.EarJWTMediaType).
+		Return([]byte(testResult), nil)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodPost,
+		path.Join(testSessionBaseURL, testUUIDString), strings.NewReader(testJSONBody))
+	req.Header.Set("Accept", ChallengeResponseSessionMediaType)
+	req.Header.Set("Content-Type", testSupportedMediaTypeA)
+	req.SetBasicAuth("att", "password")
+
+	NewRouter(h, newTestBasicAuthorizer(t), "").ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandler_DelSession_requires_attester(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	v := mock_deps.NewMockIVerifier(ctrl)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	for _, user := range []string{"", "rp"} {
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodDelete,
+			path.Join(testSessionBaseURL, testUUIDString), http.NoBody)
+		if user != "" {
+			req.SetBasicAuth(user, "password")
+		}
+
+		// sessions cannot be deleted anonymously, even when anonymous
+		// evidence submission is allowed
+		NewRouter(h, newTestBasicAuthorizer(t), "7").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, user)
+	}
+}
+
+func TestHandler_SubmitEvidence_result_format_negotiation(t *testing.T) {
+	testCWT := []byte{0xd2, 0x84, 0x43, 0xa1, 0x01, 0x26}
+
+	for _, tc := range []struct {
+		accept         string
+		expectedType   string
+		expectedResult string
+	}{
+		{
+			accept:       `application/eat+cwt; eat_profile="tag:github.com,2023:veraison/ear"`,
+			expectedType: earsigner.EarCWTMediaType,
+		},
+		{
+			accept:         ChallengeResponseSessionMediaType + ", application/eat+cwt",
+			expectedType:   ChallengeResponseSessionMediaType,
+			expectedResult: base64.RawURLEncoding.EncodeToString(testCWT),
+		},
+	} {
+		ctrl := gomock.NewController(t)
+
+		sm := mock_deps.NewMockISessionManager(ctrl)
+		sm.EXPECT().
+			GetSession(testUUID, tenantID).
+			Return([]byte(testSession), nil)
+		sm.EXPECT().
+			SetSession(testUUID, tenantID, gomock.Any(), ConfigSessionTTL).
+			Return(nil)
+
+		v := mock_deps.NewMockIVerifier(ctrl)
+		v.EXPECT().
+			SupportedResultMediaTypes().
+			Return(testResultMediaTypes, nil)
+		v.EXPECT().
+			IsSupportedMediaType(testSupportedMediaTypeA).
+			Return(true, nil)
+		v.EXPECT().
+			ProcessEvidence(tenantID, testNonce, []byte(testJSONBody), testSupportedMediaTypeA, earsigner.EarCWTMediaType).
+			Return(testCWT, nil)
+
+		h := NewHandler(sm, v, testSessionPolicies)
+
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodPost,
+			path.Join(testSessionBaseURL, testUUIDString), strings.NewReader(testJSONBody))
+		req.Header.Set("Accept", tc.accept)
+		req.Header.Set("Content-Type", testSupportedMediaTypeA)
+
+		NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, tc.accept)
+		assert.Equal(t, tc.expectedType, w.Result().Header.Get("Content-Type"), tc.accept)
+
+		if tc.expectedResult == "" {
+			assert.Equal(t, testCWT, w.Body.Bytes())
+		} else {
+			var session ChallengeResponseSession
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+			require.NotNil(t, session.Result)
+			assert.Equal(t, tc.expectedResult, *session.Result)
+			assert.Equal(t, earsigner.EarCWTMediaType, *session.ResultMediaType)
+		}
+
+		ctrl.Finish()
+	}
+}
+
+func TestHandler_SubmitEvidence_result_format_not_supported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+	v.EXPECT().
+		SupportedResultMediaTypes().
+		Return([]string{earsigner.EarJWTMediaType}, nil)
+	v.EXPECT().
+		IsSupportedMediaType(testSupportedMediaTypeA).
+		Return(true, nil)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodPost,
+		path.Join(testSessionBaseURL, testUUIDString), strings.NewReader(testJSONBody))
+	req.Header.Set("Accept", "application/eat+cwt")
+	req.Header.Set("Content-Type", testSupportedMediaTypeA)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+}
+
+func TestHandler_GetSession_result(t *testing.T) {
+	for _, tc := range []struct {
+		accept       string
+		expectedCode int
+	}{
+		{accept: "application/eat+jwt", expectedCode: http.StatusOK},
+		{accept: "application/eat+cwt", expectedCode: http.StatusNotAcceptable},
+	} {
+		ctrl := gomock.NewController(t)
+
+		sm := mock_deps.NewMockISessionManager(ctrl)
+		sm.EXPECT().
+			GetSession(testUUID, tenantID).
+			Return([]byte(testCompleteSession), nil)
+
+		v := mock_deps.NewMockIVerifier(ctrl)
+
+		h := NewHandler(sm, v, testSessionPolicies)
+
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodGet,
+			path.Join(testSessionBaseURL, testUUIDString), http.NoBody)
+		req.Header.Set("Accept", tc.accept)
+
+		NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+		assert.Equal(t, tc.expectedCode, w.Code, tc.accept)
+		if tc.expectedCode == http.StatusOK {
+			assert.Equal(t, earsigner.EarJWTMediaType, w.Result().Header.Get("Content-Type"))
+			assert.Equal(t, testResult, w.Body.String())
+		}
+
+		ctrl.Finish()
+	}
+}
+
+func testSessionInfos(t *testing.T) []sessionmanager.SessionInfo {
+	now := time.Now()
+
+	complete := ChallengeResponseSession{
+		Status:   StatusComplete,
+		Nonce:    testNonce,
+		Evidence: &EvidenceBlob{Type: testSupportedMediaTypeA, Value: []byte(testJSONBody)},
+	}
+	completeJSON, err := json.Marshal(complete)
+	require.NoError(t, err)
+
+	return []sessionmanager.SessionInfo{
+		{
+			ID:      uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+			Tenant:  "7",
+			Session: []byte(testSession),
+			Created: now.Add(-time.Hour),
+			Expiry:  now.Add(time.Minute),
+		},
+		{
+			ID:      uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+			Tenant:  "7",
+			Session: completeJSON,
+			Created: now.Add(-time.Minute),
+			Expiry:  now.Add(time.Minute),
+		},
+		{
+			ID:      uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+			Tenant:  tenantID,
+			Session: []byte(testSession),
+			Created: now,
+			Expiry:  now.Add(time.Minute),
+		},
+	}
+}
+
+func TestHandler_ListSessions(t *testing.T) {
+	for _, tc := range []struct {
+		query       string
+		expectedIDs []string
+	}{
+		{
+			query: "",
+			expectedIDs: []string{
+				"00000000-0000-0000-0000-000000000001",
+				"00000000-0000-0000-0000-000000000002",
+				"00000000-0000-0000-0000-000000000003",
+			},
+		},
+		{
+			query:       "?status=complete",
+			expectedIDs: []string{"00000000-0000-0000-0000-000000000002"},
+		},
+		{
+			query:       "?min-age=30m",
+			expectedIDs: []string{"00000000-0000-0000-0000-000000000001"},
+		},
+		{
+			query: "?max-age=30m",
+			expectedIDs: []string{
+				"00000000-0000-0000-0000-000000000002",
+				"00000000-0000-0000-0000-000000000003",
+			},
+		},
+		{
+			query:       "?status=waiting&max-age=30m",
+			expectedIDs: []string{"00000000-0000-0000-0000-000000000003"},
+		},
+	} {
+		ctrl := gomock.NewController(t)
+
+		sm := mock_deps.NewMockISessionManager(ctrl)
+		sm.EXPECT().
+			ListSessions("").
+			Return(testSessionInfos(t), nil)
+
+		v := mock_deps.NewMockIVerifier(ctrl)
+
+		h := NewHandler(sm, v, testSessionPolicies)
+
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodGet, "/challenge-response/v1/sessions"+tc.query, http.NoBody)
+
+		NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+		var body []SessionEntry
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+		assert.Equal(t, http.StatusOK, w.Code, tc.query)
+
+		var ids []string
+		for _, entry := range body {
+			ids = append(ids, entry.ID)
+		}
+		assert.Equal(t, tc.expectedIDs, ids, tc.query)
+
+		ctrl.Finish()
+	}
+}
+
+func TestHandler_ListSessions_tenant(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		ListSessions("7").
+		Return(testSessionInfos(t)[1:2], nil)
+
+	v := mock_deps.NewMockIVerifier(ctrl)
+
+	h := NewHandler(sm, v, testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodGet, "/challenge-response/v1/sessions?tenant=7", http.NoBody)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	var body []SessionEntry
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, body, 1)
+	assert.Equal(t, "7", body[0].Tenant)
+	assert.Equal(t, StatusComplete, body[0].Status)
+	assert.Equal(t, testSupportedMediaTypeA, body[0].EvidenceType)
+}
+
+func TestHandler_ListSessions_bad_filter(t *testing.T) {
+	for query, expectedErr := range map[string]string{
+		"?status=bored":          "unknown status bored",
+		"?min-age=old":           `invalid min-age: "old"`,
+		"?min-age=2h&max-age=1h": "min-age must not exceed max-age",
+		"?max-age=-1h":           `invalid max-age: "-1h"`,
+	} {
+		h := &Handler{}
+
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodGet, "/challenge-response/v1/sessions"+query, http.NoBody)
+
+		NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+		var body problems.DefaultProblem
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Equal(t, expectedErr, body.Detail, query)
+	}
+}
+
+func TestHandler_ListSessions_requires_manager(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		ListSessions("").
+		Return(nil, nil)
+
+	h := NewHandler(sm, mock_deps.NewMockIVerifier(ctrl), testSessionPolicies)
+
+	for user, expectedCode := range map[string]int{
+		"rp":  http.StatusUnauthorized,
+		"mgr": http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodGet, "/challenge-response/v1/sessions", http.NoBody)
+		req.SetBasicAuth(user, "password")
+
+		NewRouter(h, newTestBasicAuthorizer(t), "").ServeHTTP(w, req)
+
+		assert.Equal(t, expectedCode, w.Code, user)
+	}
+}
+
+func TestHandler_ListSessions_sealed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := NewSealedSessionHandler(newTestSealer(t), mock_deps.NewMockIVerifier(ctrl), testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodGet, "/challenge-response/v1/sessions", http.NoBody)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestHandler_GetSessionsSummary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		ListSessions("").
+		Return(testSessionInfos(t), nil)
+
+	h := NewHandler(sm, mock_deps.NewMockIVerifier(ctrl), testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodGet, "/challenge-response/v1/sessions/summary", http.NoBody)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	expectedBody := SessionsSummary{
+		Total:    3,
+		ByStatus: map[string]int{"waiting": 2, "complete": 1},
+		ByTenant: map[string]int{"7": 2, tenantID: 1},
+	}
+
+	var body SessionsSummary
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, expectedBody, body)
+}
+
+func TestHandler_ExpireSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+	sm.EXPECT().
+		ListSessions("").
+		Return(testSessionInfos(t), nil)
+	sm.EXPECT().
+		DelSession(uuid.MustParse("00000000-0000-0000-0000-000000000001"), "7").
+		Return(nil)
+	sm.EXPECT().
+		DelSession(uuid.MustParse("00000000-0000-0000-0000-000000000003"), tenantID).
+		Return(nil)
+
+	h := NewHandler(sm, mock_deps.NewMockIVerifier(ctrl), testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodDelete, "/challenge-response/v1/sessions?status=waiting", http.NoBody)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	var body SessionsExpired
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, body.Expired)
+}
+
+func TestHandler_ExpireSessions_no_filter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := mock_deps.NewMockISessionManager(ctrl)
+
+	h := NewHandler(sm, mock_deps.NewMockIVerifier(ctrl), testSessionPolicies)
+
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest(http.MethodDelete, "/challenge-response/v1/sessions", http.NoBody)
+
+	NewRouter(h, testAuthorizer, "").ServeHTTP(w, req)
+
+	var body problems.DefaultProblem
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "at least one of tenant, status, min-age or max-age must be specified", body.Detail)
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: ../sessionmanager/isessionmanager.go
+// Source: ../../sessionmanager/isessionmanager.go
 
 // Package mocks is a generated GoMock package.
 package mocks
@@ -11,7 +11,7 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	uuid "github.com/google/uuid"
-	sessionmanager "github.com/veraison/services/verification/sessionmanager"
+	sessionmanager "github.com/veraison/services/sessionmanager"
 )
 
 // MockISessionManager is a mock of ISessionManager interface.
@@ -94,6 +94,21 @@ func (mr *MockISessionManagerMockRecorder) Init(cfg interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockISessionManager)(nil).Init), cfg)
 }
 
+// ListSessions mocks base method.
+func (m *MockISessionManager) ListSessions(tenant string) ([]sessionmanager.SessionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", tenant)
+	ret0, _ := ret[0].([]sessionmanager.SessionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockISessionManagerMockRecorder) ListSessions(tenant interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockISessionManager)(nil).ListSessions), tenant)
+}
+
 // SetSession mocks base method.
 func (m *MockISessionManager) SetSession(id uuid.UUID, tenant string, session json.RawMessage, ttl time.Duration) error {
 	m.ctrl.T.Helper()
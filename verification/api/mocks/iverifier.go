@@ -80,18 +80,33 @@ func (mr *MockIVerifierMockRecorder) IsSupportedMediaType(mt interface{}) *gomoc
 }
 
 // ProcessEvidence mocks base method.
-func (m *MockIVerifier) ProcessEvidence(tenantID string, nonce, data []byte, mt string) ([]byte, error) {
+func (m *MockIVerifier) ProcessEvidence(tenantID string, nonce, data []byte, mt, resultMT string) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProcessEvidence", tenantID, nonce, data, mt)
+	ret := m.ctrl.Call(m, "ProcessEvidence", tenantID, nonce, data, mt, resultMT)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProcessEvidence indicates an expected call of ProcessEvidence.
-func (mr *MockIVerifierMockRecorder) ProcessEvidence(tenantID, nonce, data, mt interface{}) *gomock.Call {
+func (mr *MockIVerifierMockRecorder) ProcessEvidence(tenantID, nonce, data, mt, resultMT interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessEvidence", reflect.TypeOf((*MockIVerifier)(nil).ProcessEvidence), tenantID, nonce, data, mt)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessEvidence", reflect.TypeOf((*MockIVerifier)(nil).ProcessEvidence), tenantID, nonce, data, mt, resultMT)
+}
+
+// ProcessEvidenceCollection mocks base method.
+func (m *MockIVerifier) ProcessEvidenceCollection(tenantID string, nonce []byte, members []*proto.AttestationTokenMember, resultMT string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessEvidenceCollection", tenantID, nonce, members, resultMT)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessEvidenceCollection indicates an expected call of ProcessEvidenceCollection.
+func (mr *MockIVerifierMockRecorder) ProcessEvidenceCollection(tenantID, nonce, members, resultMT interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessEvidenceCollection", reflect.TypeOf((*MockIVerifier)(nil).ProcessEvidenceCollection), tenantID, nonce, members, resultMT)
 }
 
 // SupportedMediaTypes mocks base method.
@@ -108,3 +123,18 @@ func (mr *MockIVerifierMockRecorder) SupportedMediaTypes() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SupportedMediaTypes", reflect.TypeOf((*MockIVerifier)(nil).SupportedMediaTypes))
 }
+
+// SupportedResultMediaTypes mocks base method.
+func (m *MockIVerifier) SupportedResultMediaTypes() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SupportedResultMediaTypes")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SupportedResultMediaTypes indicates an expected call of SupportedResultMediaTypes.
+func (mr *MockIVerifierMockRecorder) SupportedResultMediaTypes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SupportedResultMediaTypes", reflect.TypeOf((*MockIVerifier)(nil).SupportedResultMediaTypes))
+}
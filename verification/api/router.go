@@ -4,6 +4,7 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/veraison/services/auth"
 )
 
 var publicApiMap = make(map[string]string)
@@ -14,24 +15,66 @@ const (
 	getSessionUrl                   = "/challenge-response/v1/session/:id"
 	delSessionUrl                   = "/challenge-response/v1/session/:id"
 	getWellKnownVerificationInfoUrl = "/.well-known/veraison/verification"
+	listSessionsUrl                 = "/challenge-response/v1/sessions"
+	getSessionsSummaryUrl           = "/challenge-response/v1/sessions/summary"
+	expireSessionsUrl               = "/challenge-response/v1/sessions"
 )
 
-func NewRouter(handler IHandler) *gin.Engine {
+// NewRouter creates the verification API router. Creating sessions,
+// submitting evidence and deleting sessions requires the attester role. If
+// anonymousTenant is set, sessions may also be created, and evidence
+// submitted, by requests with no credentials, which are associated with
+// anonymousTenant (requests with credentials still require the attester role,
+// and are associated with the attester's tenant). Reading a session (and hence its attestation result) always
+// requires the relying party role. Listing and bulk-expiring sessions requires
+// the manager role. The well-known endpoint is not authenticated.
+func NewRouter(
+	handler IHandler,
+	authorizer auth.IAuthorizer,
+	anonymousTenant string,
+) *gin.Engine {
 	router := gin.New()
 
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
-	router.POST(newChallengeResponseSessionUrl, handler.NewChallengeResponse)
+	attester := authorizer.GetGinHandler(auth.AttesterRole)
+	submitter := attester
+	if anonymousTenant != "" {
+		submitter = anonymousAttester(attester, anonymousTenant)
+	}
+	relyingParty := authorizer.GetGinHandler(auth.RelyingPartyRole)
+	manager := authorizer.GetGinHandler(auth.ManagerRole)
+
+	router.POST(newChallengeResponseSessionUrl,
+		submitter, handler.NewChallengeResponse)
 	publicApiMap["newChallengeResponseSession"] = newChallengeResponseSessionUrl
 
-	router.POST(submitEvidenceUrl, handler.SubmitEvidence)
+	router.POST(submitEvidenceUrl, submitter, handler.SubmitEvidence)
 
-	router.GET(getSessionUrl, handler.GetSession)
+	router.GET(getSessionUrl, relyingParty, handler.GetSession)
 
-	router.DELETE(delSessionUrl, handler.DelSession)
+	router.DELETE(delSessionUrl, attester, handler.DelSession)
 
 	router.GET(getWellKnownVerificationInfoUrl, handler.GetWellKnownVerificationInfo)
 
+	router.GET(listSessionsUrl, manager, handler.ListSessions)
+	router.GET(getSessionsSummaryUrl, manager, handler.GetSessionsSummary)
+	router.DELETE(expireSessionsUrl, manager, handler.ExpireSessions)
+
 	return router
 }
+
+// anonymousAttester authorizes the requests that carry credentials using the
+// supplied attester handler, and associates the others with the anonymous
+// tenant.
+func anonymousAttester(attester gin.HandlerFunc, tenant string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			attester(c)
+			return
+		}
+
+		c.Set(auth.TenantIDKey, tenant)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionEntry describes a session in the responses of the session management
+// endpoints.
+type SessionEntry struct {
+	id uuid.UUID
+
+	ID           string    `json:"id"`
+	Tenant       string    `json:"tenant"`
+	Status       Status    `json:"status"`
+	Created      time.Time `json:"created"`
+	Expiry       time.Time `json:"expiry"`
+	EvidenceType string    `json:"evidence-type,omitempty"`
+}
+
+// SessionsSummary provides summary counts of the sessions matching the
+// filters of a session management request.
+type SessionsSummary struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by-status"`
+	ByTenant map[string]int `json:"by-tenant"`
+}
+
+// SessionsExpired reports the outcome of a bulk force-expire request.
+type SessionsExpired struct {
+	Expired int `json:"expired"`
+}
+
+// sessionFilter selects the sessions a session management request applies to.
+// Zero-valued fields match any session.
+type sessionFilter struct {
+	Tenant string
+	Status *Status
+	MinAge time.Duration
+	MaxAge time.Duration
+}
+
+func parseSessionFilter(c *gin.Context) (*sessionFilter, error) {
+	filter := sessionFilter{Tenant: c.Query("tenant")}
+
+	if v := c.Query("status"); v != "" {
+		var status Status
+		if err := status.FromString(v); err != nil {
+			return nil, err
+		}
+		filter.Status = &status
+	}
+
+	for param, dest := range map[string]*time.Duration{
+		"min-age": &filter.MinAge,
+		"max-age": &filter.MaxAge,
+	} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+
+		age, err := time.ParseDuration(v)
+		if err != nil || age < 0 {
+			return nil, fmt.Errorf("invalid %s: %q", param, v)
+		}
+		*dest = age
+	}
+
+	if filter.MaxAge != 0 && filter.MinAge > filter.MaxAge {
+		return nil, errors.New("min-age must not exceed max-age")
+	}
+
+	return &filter, nil
+}
+
+func (o sessionFilter) IsEmpty() bool {
+	return o.Tenant == "" && o.Status == nil && o.MinAge == 0 && o.MaxAge == 0
+}
+
+func (o sessionFilter) Match(entry *SessionEntry, now time.Time) bool {
+	if o.Status != nil && entry.Status != *o.Status {
+		return false
+	}
+
+	age := now.Sub(entry.Created)
+
+	if age < o.MinAge {
+		return false
+	}
+
+	if o.MaxAge != 0 && age > o.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+// readSessionFilter parses the session filter from the request query. If
+// there is a problem, it is reported and false is returned.
+func readSessionFilter(c *gin.Context) (*sessionFilter, bool) {
+	filter, err := parseSessionFilter(c)
+	if err != nil {
+		ReportProblem(c,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return nil, false
+	}
+
+	return filter, true
+}
+
+// findSessions returns the sessions matching the filter. If there is a
+// problem, it is reported and false is returned.
+func (o *Handler) findSessions(c *gin.Context, filter *sessionFilter) ([]*SessionEntry, bool) {
+	if o.SessionManager == nil {
+		ReportProblem(c,
+			http.StatusNotImplemented,
+			"sessions are not stored when sealed sessions are in use",
+		)
+		return nil, false
+	}
+
+	sessions, err := o.SessionManager.ListSessions(filter.Tenant)
+	if err != nil {
+		ReportProblem(c,
+			http.StatusInternalServerError,
+			fmt.Sprintf("could not list sessions: %v", err),
+		)
+		return nil, false
+	}
+
+	now := time.Now()
+	entries := []*SessionEntry{}
+
+	for _, info := range sessions {
+		var session ChallengeResponseSession
+
+		if err := json.Unmarshal(info.Session, &session); err != nil {
+			o.logger.Warnw("skipping unparsable session", "id", info.ID,
+				"tenant", info.Tenant, "error", err)
+			continue
+		}
+
+		entry := &SessionEntry{
+			id:      info.ID,
+			ID:      info.ID.String(),
+			Tenant:  info.Tenant,
+			Status:  session.Status,
+			Created: info.Created,
+			Expiry:  info.Expiry,
+		}
+
+		if session.Evidence != nil {
+			entry.EvidenceType = session.Evidence.Type
+		}
+
+		if filter.Match(entry, now) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, true
+}
+
+// ListSessions returns the active sessions, filtered by the "tenant", "status",
+// "min-age" and "max-age" query parameters.
+func (o *Handler) ListSessions(c *gin.Context) {
+	filter, ok := readSessionFilter(c)
+	if !ok {
+		return
+	}
+
+	entries, ok := o.findSessions(c, filter)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetSessionsSummary returns counts of the active sessions by status and by
+// tenant. The same filters as ListSessions apply.
+func (o *Handler) GetSessionsSummary(c *gin.Context) {
+	filter, ok := readSessionFilter(c)
+	if !ok {
+		return
+	}
+
+	entries, ok := o.findSessions(c, filter)
+	if !ok {
+		return
+	}
+
+	summary := SessionsSummary{
+		Total:    len(entries),
+		ByStatus: map[string]int{},
+		ByTenant: map[string]int{},
+	}
+
+	for _, entry := range entries {
+		summary.ByStatus[entry.Status.String()]++
+		summary.ByTenant[entry.Tenant]++
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// ExpireSessions force-expires the sessions matching the filters (the same as
+// ListSessions). At least one filter must be specified, so that all sessions
+// are not expired by mistake.
+func (o *Handler) ExpireSessions(c *gin.Context) {
+	filter, ok := readSessionFilter(c)
+	if !ok {
+		return
+	}
+
+	if filter.IsEmpty() {
+		ReportProblem(c,
+			http.StatusBadRequest,
+			"at least one of tenant, status, min-age or max-age must be specified",
+		)
+		return
+	}
+
+	entries, ok := o.findSessions(c, filter)
+	if !ok {
+		return
+	}
+
+	var expired SessionsExpired
+
+	for _, entry := range entries {
+		if err := o.SessionManager.DelSession(entry.id, entry.Tenant); err != nil {
+			ReportProblem(c,
+				http.StatusInternalServerError,
+				fmt.Sprintf("could not expire session %s: %v", entry.ID, err),
+			)
+			return
+		}
+
+		expired.Expired++
+	}
+
+	o.logger.Infow("force-expired sessions", "count", expired.Expired)
+
+	c.JSON(http.StatusOK, expired)
+}
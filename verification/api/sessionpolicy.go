@@ -0,0 +1,202 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/veraison/services/config"
+)
+
+var (
+	// ConfigNonceSize and ConfigSessionTTL are the nonce size and session
+	// TTL used if not specified in the session config.
+	ConfigNonceSize     uint8 = 32
+	ConfigSessionTTL, _       = time.ParseDuration("2m30s")
+
+	DefaultMinSessionTTL       = time.Second
+	DefaultMinNonceSize  uint8 = 8
+	DefaultMaxNonceSize  uint8 = 64
+)
+
+// SessionPolicy controls the lifetime of the challenge-response sessions of a
+// tenant, and the nonces used with them.
+type SessionPolicy struct {
+	// TTL is the lifetime of a session, unless the client requests a
+	// shorter one.
+	TTL time.Duration
+	// MinTTL is the shortest session lifetime a client may request.
+	MinTTL time.Duration
+	// NonceSize is the size, in bytes, of the nonce minted for a session if
+	// the client does not request a specific size.
+	NonceSize uint8
+	// MinNonceSize and MaxNonceSize bound the size of the nonces requested
+	// or supplied by the clients.
+	MinNonceSize uint8
+	MaxNonceSize uint8
+	// AllowClientNonce specifies whether clients may supply their own
+	// nonce, rather than having one minted by the service.
+	AllowClientNonce bool
+}
+
+// Validate checks that the policy is consistent.
+func (o SessionPolicy) Validate() error {
+	if o.MinTTL <= 0 {
+		return fmt.Errorf("min-ttl must be positive; got %s", o.MinTTL)
+	}
+
+	if o.TTL < o.MinTTL {
+		return fmt.Errorf("ttl (%s) must not be shorter than min-ttl (%s)", o.TTL, o.MinTTL)
+	}
+
+	if o.MinNonceSize == 0 {
+		return errors.New("min-nonce-size must be positive")
+	}
+
+	if o.MinNonceSize > o.MaxNonceSize {
+		return fmt.Errorf("min-nonce-size (%d) must not exceed max-nonce-size (%d)",
+			o.MinNonceSize, o.MaxNonceSize)
+	}
+
+	if o.NonceSize < o.MinNonceSize || o.NonceSize > o.MaxNonceSize {
+		return fmt.Errorf("nonce-size must be in range %d..%d; got %d",
+			o.MinNonceSize, o.MaxNonceSize, o.NonceSize)
+	}
+
+	return nil
+}
+
+// SessionPolicies holds the default session policy, as well as the policies of
+// the tenants that override it.
+type SessionPolicies struct {
+	Default SessionPolicy
+	Tenants map[string]SessionPolicy
+}
+
+// DefaultSessionPolicies returns the policies used if no session config is
+// provided.
+func DefaultSessionPolicies() *SessionPolicies {
+	return &SessionPolicies{
+		Default: SessionPolicy{
+			TTL:              ConfigSessionTTL,
+			MinTTL:           DefaultMinSessionTTL,
+			NonceSize:        ConfigNonceSize,
+			MinNonceSize:     DefaultMinNonceSize,
+			MaxNonceSize:     DefaultMaxNonceSize,
+			AllowClientNonce: true,
+		},
+		Tenants: map[string]SessionPolicy{},
+	}
+}
+
+// Get returns the session policy of the specified tenant.
+func (o SessionPolicies) Get(tenant string) SessionPolicy {
+	if policy, ok := o.Tenants[tenant]; ok {
+		return policy
+	}
+
+	return o.Default
+}
+
+// MaxTTL returns the longest session TTL across all the tenants.
+func (o SessionPolicies) MaxTTL() time.Duration {
+	ttl := o.Default.TTL
+
+	for _, policy := range o.Tenants {
+		if policy.TTL > ttl {
+			ttl = policy.TTL
+		}
+	}
+
+	return ttl
+}
+
+type sessionPolicyCfg struct {
+	TTL              string `mapstructure:"ttl"`
+	MinTTL           string `mapstructure:"min-ttl"`
+	NonceSize        uint8  `mapstructure:"nonce-size"`
+	MinNonceSize     uint8  `mapstructure:"min-nonce-size"`
+	MaxNonceSize     uint8  `mapstructure:"max-nonce-size"`
+	AllowClientNonce bool   `mapstructure:"allow-client-nonce"`
+
+	// Tenants maps tenant IDs onto the settings they override.
+	Tenants map[string]map[string]interface{} `mapstructure:"tenants" config:"zerodefault"`
+}
+
+func (o sessionPolicyCfg) policy() (SessionPolicy, error) {
+	ttl, err := time.ParseDuration(o.TTL)
+	if err != nil {
+		return SessionPolicy{}, fmt.Errorf("invalid ttl: %q", o.TTL)
+	}
+
+	minTTL, err := time.ParseDuration(o.MinTTL)
+	if err != nil {
+		return SessionPolicy{}, fmt.Errorf("invalid min-ttl: %q", o.MinTTL)
+	}
+
+	policy := SessionPolicy{
+		TTL:              ttl,
+		MinTTL:           minTTL,
+		NonceSize:        o.NonceSize,
+		MinNonceSize:     o.MinNonceSize,
+		MaxNonceSize:     o.MaxNonceSize,
+		AllowClientNonce: o.AllowClientNonce,
+	}
+
+	return policy, policy.Validate()
+}
+
+// NewSessionPolicies loads the session policies from the provided Viper.
+// Settings inside "tenants" override the defaults for the tenant they are
+// keyed by; settings not overridden are inherited from the defaults. If v is
+// nil, the default policies are returned.
+func NewSessionPolicies(v *viper.Viper) (*SessionPolicies, error) {
+	policies := DefaultSessionPolicies()
+
+	if v == nil {
+		return policies, nil
+	}
+
+	defaults := policies.Default
+	cfg := sessionPolicyCfg{
+		TTL:              defaults.TTL.String(),
+		MinTTL:           defaults.MinTTL.String(),
+		NonceSize:        defaults.NonceSize,
+		MinNonceSize:     defaults.MinNonceSize,
+		MaxNonceSize:     defaults.MaxNonceSize,
+		AllowClientNonce: defaults.AllowClientNonce,
+	}
+
+	loader := config.NewLoader(&cfg)
+	if err := loader.LoadFromViper(v); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if policies.Default, err = cfg.policy(); err != nil {
+		return nil, err
+	}
+
+	for tenant, override := range cfg.Tenants {
+		tenantCfg := cfg
+		tenantCfg.Tenants = nil
+
+		loader := config.NewLoader(&tenantCfg)
+		if err := loader.LoadFromMap(override); err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+
+		if tenantCfg.Tenants != nil {
+			return nil, fmt.Errorf("tenant %q: tenant overrides cannot be nested", tenant)
+		}
+
+		if policies.Tenants[tenant], err = tenantCfg.policy(); err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+	}
+
+	return policies, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSessionPolicies_defaults(t *testing.T) {
+	policies, err := NewSessionPolicies(nil)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSessionPolicies(), policies)
+
+	policies, err = NewSessionPolicies(viper.New())
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSessionPolicies(), policies)
+}
+
+func TestNewSessionPolicies_tenant_overrides(t *testing.T) {
+	v := viper.New()
+	v.Set("ttl", "5m")
+	v.Set("nonce-size", 16)
+	v.Set("tenants", map[string]interface{}{
+		"7": map[string]interface{}{
+			"ttl":                "30s",
+			"allow-client-nonce": false,
+		},
+	})
+
+	policies, err := NewSessionPolicies(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5*time.Minute, policies.Default.TTL)
+	assert.Equal(t, uint8(16), policies.Default.NonceSize)
+	assert.True(t, policies.Default.AllowClientNonce)
+
+	tenant := policies.Get("7")
+	assert.Equal(t, 30*time.Second, tenant.TTL)
+	// not overridden, so inherited from the defaults
+	assert.Equal(t, uint8(16), tenant.NonceSize)
+	assert.False(t, tenant.AllowClientNonce)
+
+	assert.Equal(t, policies.Default, policies.Get("1"))
+	assert.Equal(t, 5*time.Minute, policies.MaxTTL())
+}
+
+func TestNewSessionPolicies_bad(t *testing.T) {
+	for _, tc := range []struct {
+		settings    map[string]interface{}
+		expectedErr string
+	}{
+		{
+			settings:    map[string]interface{}{"ttl": "soon"},
+			expectedErr: `invalid ttl: "soon"`,
+		},
+		{
+			settings:    map[string]interface{}{"ttl": "10s", "min-ttl": "1m"},
+			expectedErr: "ttl (10s) must not be shorter than min-ttl (1m0s)",
+		},
+		{
+			settings:    map[string]interface{}{"nonce-size": 100},
+			expectedErr: "nonce-size must be in range 8..64; got 100",
+		},
+		{
+			settings: map[string]interface{}{
+				"tenants": map[string]interface{}{
+					"7": map[string]interface{}{"min-nonce-size": 0},
+				},
+			},
+			expectedErr: `tenant "7": min-nonce-size must be positive`,
+		},
+		{
+			settings:    map[string]interface{}{"session-ttl": "1m"},
+			expectedErr: "unexpected directives: session-ttl",
+		},
+	} {
+		v := viper.New()
+		for key, val := range tc.settings {
+			v.Set(key, val)
+		}
+
+		_, err := NewSessionPolicies(v)
+		assert.EqualError(t, err, tc.expectedErr)
+	}
+}
@@ -4,11 +4,15 @@ package main
 
 import (
 	"context"
+	"errors"
 
+	"github.com/spf13/afero"
+	"github.com/veraison/services/auth"
 	"github.com/veraison/services/config"
 	"github.com/veraison/services/log"
+	"github.com/veraison/services/sessionmanager"
 	"github.com/veraison/services/verification/api"
-	"github.com/veraison/services/verification/sessionmanager"
+	"github.com/veraison/services/verification/sealedsession"
 	"github.com/veraison/services/verification/verifier"
 	"github.com/veraison/services/vtsclient"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -19,7 +23,17 @@ var (
 )
 
 type cfg struct {
-	ListenAddr string `mapstructure:"listen-addr" valid:"dialstring"`
+	ListenAddr             string `mapstructure:"listen-addr" valid:"dialstring"`
+	AllowAnonymousEvidence bool   `mapstructure:"allow-anonymous-evidence" config:"zerodefault"`
+	AnonymousTenant        string `mapstructure:"anonymous-tenant" config:"zerodefault"`
+}
+
+func (o cfg) Validate() error {
+	if o.AllowAnonymousEvidence && o.AnonymousTenant == "" {
+		return errors.New("anonymous-tenant must be set when allow-anonymous-evidence is true")
+	}
+
+	return nil
 }
 
 func main() {
@@ -30,7 +44,7 @@ func main() {
 		log.Fatalf("Could not read config: %v", err)
 	}
 
-	subs, err := config.GetSubs(v, "*vts", "*verifier", "*verification", "*logging")
+	subs, err := config.GetSubs(v, "*vts", "*verifier", "*verification", "*logging", "*auth", "*session")
 	if err != nil {
 		log.Fatalf("Could not read config: %v", err)
 	}
@@ -43,8 +57,6 @@ func main() {
 
 	log.Infow("Initializing Verification Service", "version", config.Version)
 
-	sessionManager := sessionmanager.NewSessionManagerTTLCache()
-
 	log.Info("initializing VTS client")
 	vtsClient := vtsclient.NewGRPC()
 	if err := vtsClient.Init(subs["vts"]); err != nil {
@@ -62,7 +74,38 @@ func main() {
 	log.Info("initializing verifier")
 	verifier := verifier.New(subs["verifier"], vtsClient)
 
-	apiHandler := api.NewHandler(sessionManager, verifier)
+	log.Info("loading session policies")
+	sessionPolicies, err := api.NewSessionPolicies(subs["session"])
+	if err != nil {
+		log.Fatalf("Could not load session config: %v", err)
+	}
+
+	var apiHandler api.IHandler
+	if v.IsSet("sealed-sessions") {
+		log.Info("initializing session sealer")
+		sealer, err := sealedsession.New(v.Sub("sealed-sessions"), afero.NewOsFs())
+		if err != nil {
+			log.Fatalf("Could not initialize session sealer: %v", err)
+		}
+
+		if sealer.GetRotationPeriod() < sessionPolicies.MaxTTL() {
+			log.Fatalf("sealed-sessions.rotation-period (%s) must not be shorter than the session TTL (%s)",
+				sealer.GetRotationPeriod(), sessionPolicies.MaxTTL())
+		}
+
+		apiHandler = api.NewSealedSessionHandler(sealer, verifier, sessionPolicies)
+	} else {
+		sessionManager := sessionmanager.NewSessionManagerTTLCache()
+		err := sessionManager.Init(sessionmanager.Config{
+			"ttl": sessionPolicies.Default.TTL.String(),
+		})
+		if err != nil {
+			log.Fatalf("Could not initialize session manager: %v", err)
+		}
+		defer sessionManager.Close()
+
+		apiHandler = api.NewHandler(sessionManager, verifier, sessionPolicies)
+	}
 
 	cfg := cfg{ListenAddr: DefaultListenAddr}
 	loader := config.NewLoader(&cfg)
@@ -71,12 +114,35 @@ func main() {
 
 	}
 
+	authorizer, err := auth.NewAuthorizer(subs["auth"], log.Named("auth"))
+	if err != nil {
+		log.Fatalf("could not init authorizer: %v", err)
+	}
+	defer func() {
+		err := authorizer.Close()
+		if err != nil {
+			log.Errorf("Could not close authorizer: %v", err)
+		}
+	}()
+
+	var anonymousTenant string
+	if cfg.AllowAnonymousEvidence {
+		anonymousTenant = cfg.AnonymousTenant
+		log.Warnw("anonymous evidence submission is allowed", "tenant", anonymousTenant)
+	}
+
 	log.Infow("initializing verification API service", "address", cfg.ListenAddr)
-	apiServer(apiHandler, cfg.ListenAddr)
+	apiServer(apiHandler, authorizer, anonymousTenant, cfg.ListenAddr)
 }
 
-func apiServer(apiHandler api.IHandler, listenAddr string) {
-	if err := api.NewRouter(apiHandler).Run(listenAddr); err != nil {
+func apiServer(
+	apiHandler api.IHandler,
+	authorizer auth.IAuthorizer,
+	anonymousTenant string,
+	listenAddr string,
+) {
+	router := api.NewRouter(apiHandler, authorizer, anonymousTenant)
+	if err := router.Run(listenAddr); err != nil {
 		log.Fatalf("Gin engine failed: %v", err)
 	}
 }
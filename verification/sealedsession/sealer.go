@@ -0,0 +1,208 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sealedsession implements stateless challenge-response sessions.
+// Instead of being kept by a session manager, the session parameters (nonce,
+// expiry and tenant) are carried by a self-contained token that is
+// authenticated using a server key. Any verification service instance that
+// shares the sealing secret can validate the freshness of a token without a
+// shared session store.
+package sealedsession
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/veraison/services/config"
+)
+
+const (
+	// MinSecretSize is the minimum size, in bytes, of the secret from
+	// which the sealing keys are derived.
+	MinSecretSize = 32
+
+	// DefaultRotationPeriod is how long a derived sealing key stays current
+	// if "rotation-period" is not specified in the config.
+	DefaultRotationPeriod = 24 * time.Hour
+
+	keyDerivationLabel = "veraison-sealed-session"
+)
+
+var (
+	ErrMalformed = errors.New("malformed session token")
+	ErrBadSeal   = errors.New("session token seal verification failed")
+	ErrExpired   = errors.New("session token expired")
+)
+
+// Claims are the session parameters sealed inside a session token.
+type Claims struct {
+	// KeyID identifies the key used to seal the token. It is set by
+	// Seal() and should not be set by the caller.
+	KeyID  int64     `json:"kid"`
+	Tenant string    `json:"tenant"`
+	Nonce  []byte    `json:"nonce"`
+	Expiry time.Time `json:"exp"`
+}
+
+type ISealer interface {
+	// Seal returns a session token authenticating the specified claims.
+	Seal(claims Claims) (string, error)
+	// Unseal verifies the seal and the expiry of the provided token and
+	// returns the claims contained therein.
+	Unseal(token string) (*Claims, error)
+	// GetRotationPeriod returns the period after which the sealing key is
+	// rotated. Tokens remain verifiable for one full period after the key
+	// that sealed them has been rotated out, so session lifetimes must not
+	// exceed this period.
+	GetRotationPeriod() time.Duration
+}
+
+// Cfg is the configuration of the sealer.
+type Cfg struct {
+	// Key is the path to the file containing the secret from which the
+	// sealing keys are derived.
+	Key string `mapstructure:"key"`
+	// RotationPeriod specifies how often the sealing key is rotated, as a
+	// duration string (e.g. "24h").
+	RotationPeriod string `mapstructure:"rotation-period"`
+}
+
+// Sealer seals sessions using HMAC-SHA256. Sealing keys are derived from a
+// secret and the index of the current rotation period (the key ID), so that
+// all instances configured with the same secret rotate to the same keys at
+// the same time without needing to coordinate.
+type Sealer struct {
+	secret   []byte
+	rotation time.Duration
+
+	// now returns the current time; it may be overridden in tests.
+	now func() time.Time
+}
+
+// New creates a Sealer based on the configuration inside the provided Viper.
+// The secret is read from the file specified by the "key" directive.
+func New(v *viper.Viper, fs afero.Fs) (*Sealer, error) {
+	cfg := Cfg{RotationPeriod: DefaultRotationPeriod.String()}
+
+	loader := config.NewLoader(&cfg)
+	if err := loader.LoadFromViper(v); err != nil {
+		return nil, err
+	}
+
+	rotation, err := time.ParseDuration(cfg.RotationPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rotation-period: %q", cfg.RotationPeriod)
+	}
+
+	secret, err := afero.ReadFile(fs, cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("loading session sealing secret from %q: %w", cfg.Key, err)
+	}
+
+	return NewSealer(secret, rotation)
+}
+
+// NewSealer creates a Sealer using the specified secret and key rotation
+// period.
+func NewSealer(secret []byte, rotation time.Duration) (*Sealer, error) {
+	if len(secret) < MinSecretSize {
+		return nil, fmt.Errorf(
+			"session sealing secret must be at least %d bytes long; got %d",
+			MinSecretSize, len(secret),
+		)
+	}
+
+	if rotation < time.Second {
+		return nil, fmt.Errorf("rotation period must be at least 1s; got %s", rotation)
+	}
+
+	return &Sealer{secret: secret, rotation: rotation, now: time.Now}, nil
+}
+
+func (o Sealer) GetRotationPeriod() time.Duration {
+	return o.rotation
+}
+
+func (o Sealer) Seal(claims Claims) (string, error) {
+	claims.KeyID = o.currentKeyID()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	mac := o.mac(claims.KeyID, encodedPayload)
+
+	return encodedPayload + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+func (o Sealer) Unseal(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%w: expected 2 .-separated parts, found %d",
+			ErrMalformed, len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformed, err)
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: seal: %v", ErrMalformed, err)
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformed, err)
+	}
+
+	// Only the current and the immediately preceding keys are accepted.
+	current := o.currentKeyID()
+	if claims.KeyID != current && claims.KeyID != current-1 {
+		return nil, fmt.Errorf("%w: key %d is not current", ErrBadSeal, claims.KeyID)
+	}
+
+	if !hmac.Equal(mac, o.mac(claims.KeyID, parts[0])) {
+		return nil, ErrBadSeal
+	}
+
+	if !o.now().Before(claims.Expiry) {
+		return nil, fmt.Errorf("%w at %s", ErrExpired, claims.Expiry.Format(time.RFC3339))
+	}
+
+	return &claims, nil
+}
+
+func (o Sealer) currentKeyID() int64 {
+	return o.now().UnixNano() / int64(o.rotation)
+}
+
+func (o Sealer) deriveKey(keyID int64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(keyID))
+
+	h := hmac.New(sha256.New, o.secret)
+	h.Write([]byte(keyDerivationLabel))
+	h.Write(b[:])
+
+	return h.Sum(nil)
+}
+
+func (o Sealer) mac(keyID int64, encodedPayload string) []byte {
+	h := hmac.New(sha256.New, o.deriveKey(keyID))
+	h.Write([]byte(encodedPayload))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package sealedsession
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testSecret   = []byte("0123456789abcdef0123456789abcdef")
+	testRotation = time.Hour
+	testNow      = time.Date(2023, 10, 1, 12, 30, 0, 0, time.UTC)
+	testClaims   = Claims{
+		Tenant: "0",
+		Nonce:  []byte{0xde, 0xad, 0xbe, 0xef, 0xde, 0xad, 0xbe, 0xef},
+		Expiry: testNow.Add(2 * time.Minute),
+	}
+)
+
+func newTestSealer(t *testing.T, now time.Time) *Sealer {
+	s, err := NewSealer(testSecret, testRotation)
+	require.NoError(t, err)
+
+	s.now = func() time.Time { return now }
+
+	return s
+}
+
+func Test_NewSealer_short_secret(t *testing.T) {
+	_, err := NewSealer([]byte("too short"), testRotation)
+	assert.EqualError(t, err, "session sealing secret must be at least 32 bytes long; got 9")
+}
+
+func Test_New_from_config(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "session.key", testSecret, 0600))
+
+	v := viper.New()
+	v.Set("key", "session.key")
+	v.Set("rotation-period", "2h")
+
+	s, err := New(v, fs)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, s.GetRotationPeriod())
+
+	v.Set("rotation-period", "soon")
+	_, err = New(v, fs)
+	assert.EqualError(t, err, `invalid rotation-period: "soon"`)
+
+	v = viper.New()
+	v.Set("key", "missing.key")
+	_, err = New(v, fs)
+	assert.ErrorContains(t, err, `loading session sealing secret from "missing.key"`)
+}
+
+func Test_Sealer_SealUnseal_ok(t *testing.T) {
+	s := newTestSealer(t, testNow)
+
+	token, err := s.Seal(testClaims)
+	require.NoError(t, err)
+
+	claims, err := s.Unseal(token)
+	require.NoError(t, err)
+
+	assert.Equal(t, testClaims.Tenant, claims.Tenant)
+	assert.Equal(t, testClaims.Nonce, claims.Nonce)
+	assert.True(t, testClaims.Expiry.Equal(claims.Expiry))
+}
+
+func Test_Sealer_Unseal_other_instance(t *testing.T) {
+	sealer := newTestSealer(t, testNow)
+	// a different instance sharing the secret, just after key rotation
+	verifier := newTestSealer(t, testNow.Add(time.Hour))
+
+	claims := testClaims
+	claims.Expiry = testNow.Add(2 * time.Hour)
+	token, err := sealer.Seal(claims)
+	require.NoError(t, err)
+
+	_, err = verifier.Unseal(token)
+	assert.NoError(t, err)
+}
+
+func Test_Sealer_Unseal_expired(t *testing.T) {
+	token, err := newTestSealer(t, testNow).Seal(testClaims)
+	require.NoError(t, err)
+
+	_, err = newTestSealer(t, testNow.Add(3*time.Minute)).Unseal(token)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func Test_Sealer_Unseal_rotated_out(t *testing.T) {
+	claims := testClaims
+	claims.Expiry = testNow.Add(24 * time.Hour)
+
+	token, err := newTestSealer(t, testNow).Seal(claims)
+	require.NoError(t, err)
+
+	_, err = newTestSealer(t, testNow.Add(2*time.Hour)).Unseal(token)
+	assert.ErrorIs(t, err, ErrBadSeal)
+}
+
+func Test_Sealer_Unseal_tampered(t *testing.T) {
+	s := newTestSealer(t, testNow)
+
+	token, err := s.Seal(testClaims)
+	require.NoError(t, err)
+
+	claims := testClaims
+	claims.Tenant = "1"
+	other, err := s.Seal(claims)
+	require.NoError(t, err)
+
+	// splice the payload of one token with the seal of the other
+	forged := strings.Split(other, ".")[0] + "." + strings.Split(token, ".")[1]
+
+	_, err = s.Unseal(forged)
+	assert.ErrorIs(t, err, ErrBadSeal)
+
+	wrongSecret, err := NewSealer([]byte("fedcba9876543210fedcba9876543210"), testRotation)
+	require.NoError(t, err)
+	wrongSecret.now = s.now
+
+	_, err = wrongSecret.Unseal(token)
+	assert.ErrorIs(t, err, ErrBadSeal)
+}
+
+func Test_Sealer_Unseal_malformed(t *testing.T) {
+	s := newTestSealer(t, testNow)
+
+	for _, token := range []string{
+		"",
+		"5c5bd88b-c922-482b-ad9f-097e187b42a1",
+		"a.b.c",
+		"!!!.AAAA",
+		"e30.!!!",
+		"bm90IGpzb24.AAAA",
+	} {
+		_, err := s.Unseal(token)
+		assert.ErrorIs(t, err, ErrMalformed, token)
+	}
+}
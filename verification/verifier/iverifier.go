@@ -11,5 +11,7 @@ type IVerifier interface {
 	GetPublicKey() (*proto.PublicKey, error)
 	IsSupportedMediaType(mt string) (bool, error)
 	SupportedMediaTypes() ([]string, error)
-	ProcessEvidence(tenantID string, nonce []byte, data []byte, mt string) ([]byte, error)
+	SupportedResultMediaTypes() ([]string, error)
+	ProcessEvidence(tenantID string, nonce []byte, data []byte, mt string, resultMT string) ([]byte, error)
+	ProcessEvidenceCollection(tenantID string, nonce []byte, members []*proto.AttestationTokenMember, resultMT string) ([]byte, error)
 }
@@ -55,17 +55,37 @@ func (o *Verifier) SupportedMediaTypes() ([]string, error) {
 	return mts.GetMediaTypes(), nil
 }
 
+// SupportedResultMediaTypes returns the media types of the attestation result
+// formats that can be requested from ProcessEvidence. The first one is the
+// default.
+func (o *Verifier) SupportedResultMediaTypes() ([]string, error) {
+	mts, err := o.VTSClient.GetSupportedResultMediaTypes(
+		context.Background(),
+		&emptypb.Empty{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return mts.GetMediaTypes(), nil
+}
+
+// ProcessEvidence submits evidence of media type mt for appraisal, and returns
+// the signed attestation result in the format identified by resultMT (or in
+// the default format, if resultMT is empty).
 func (o *Verifier) ProcessEvidence(
 	tenantID string,
 	nonce []byte,
 	data []byte,
 	mt string,
+	resultMT string,
 ) ([]byte, error) {
 	token := &proto.AttestationToken{
-		TenantId:  tenantID,
-		Data:      data,
-		MediaType: mt,
-		Nonce:     nonce,
+		TenantId:        tenantID,
+		Data:            data,
+		MediaType:       mt,
+		Nonce:           nonce,
+		ResultMediaType: resultMT,
 	}
 
 	appraisalCtx, err := o.VTSClient.GetAttestation(
@@ -79,6 +99,34 @@ func (o *Verifier) ProcessEvidence(
 	return appraisalCtx.Result, nil
 }
 
+// ProcessEvidenceCollection submits the members of an evidence collection for
+// appraisal, and returns a single signed attestation result with one submod
+// per member, in the format identified by resultMT (or in the default format,
+// if resultMT is empty).
+func (o *Verifier) ProcessEvidenceCollection(
+	tenantID string,
+	nonce []byte,
+	members []*proto.AttestationTokenMember,
+	resultMT string,
+) ([]byte, error) {
+	collection := &proto.AttestationTokenCollection{
+		TenantId:        tenantID,
+		Members:         members,
+		Nonce:           nonce,
+		ResultMediaType: resultMT,
+	}
+
+	appraisalCtx, err := o.VTSClient.GetCollectionAttestation(
+		context.Background(),
+		collection,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return appraisalCtx.Result, nil
+}
+
 func (o *Verifier) GetPublicKey() (*proto.PublicKey, error) {
 	return o.VTSClient.GetEARSigningPublicKey(context.Background(), &emptypb.Empty{})
 }
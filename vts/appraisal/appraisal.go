@@ -22,6 +22,16 @@ type Appraisal struct {
 	EvidenceContext *proto.EvidenceContext
 	Result          *ear.AttestationResult
 	SignedEAR       []byte
+	// MediaType is the media type of the evidence being appraised.
+	MediaType string
+	// ResultMediaType is the media type of the signed EAR format that has
+	// been requested (empty for the default format). Once the EAR has been
+	// signed, it is set to the media type of SignedEAR.
+	ResultMediaType string
+	// History is the prior attestations of the device whose evidence is
+	// being appraised, oldest first, as made available to policies (nil if
+	// history is not kept).
+	History []map[string]interface{}
 }
 
 func New(tenantID string, nonce []byte, scheme string) *Appraisal {
@@ -46,8 +56,9 @@ func New(tenantID string, nonce []byte, scheme string) *Appraisal {
 
 func (o Appraisal) GetContext() *proto.AppraisalContext {
 	return &proto.AppraisalContext{
-		Evidence: o.EvidenceContext,
-		Result:   o.SignedEAR,
+		Evidence:        o.EvidenceContext,
+		Result:          o.SignedEAR,
+		ResultMediaType: o.ResultMediaType,
 	}
 }
 
@@ -82,6 +93,24 @@ func (o *Appraisal) UpdatePolicyID(pol *policy.Policy) error {
 	return nil
 }
 
+// UpdateSubmodPolicyID is like UpdatePolicyID, but only updates the policy ID
+// of the specified submod.
+func (o *Appraisal) UpdateSubmodPolicyID(submod string, pol *policy.Policy) error {
+	if err := pol.Validate(); err != nil {
+		return err
+	}
+
+	submodAppraisal, ok := o.Result.Submods[submod]
+	if !ok {
+		return fmt.Errorf("no submod %q in result", submod)
+	}
+
+	updatedID := strings.Join([]string{*submodAppraisal.AppraisalPolicyID, pol.UUID.String()}, "/")
+	submodAppraisal.AppraisalPolicyID = &updatedID
+
+	return nil
+}
+
 func (o *Appraisal) InitPolicyID() {
 	for _, submod := range o.Result.Submods {
 		policyID := fmt.Sprintf("policy:%s", o.Scheme)
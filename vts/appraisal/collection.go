@@ -0,0 +1,165 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package appraisal
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/veraison/ear"
+)
+
+// CollectionScheme is the scheme of an appraisal combining the appraisals of
+// the members of an evidence collection.
+const CollectionScheme = "collection"
+
+// Member is the appraisal of a member of an evidence collection.
+type Member struct {
+	// Label identifies the member within the collection.
+	Label string
+	// Data is the member's evidence, as submitted.
+	Data []byte
+	// Appraisal is the outcome of the member's appraisal by the evidence
+	// handler of its scheme.
+	Appraisal *Appraisal
+}
+
+// NewCollection creates an appraisal combining the appraisals of the members
+// of an evidence collection. The result contains one submod per member, named
+// after the member's label. If the appraisal of a member produced more than
+// one submod, these are named "<label>/<submod>".
+func NewCollection(tenantID string, nonce []byte, members []Member) *Appraisal {
+	appraisal := New(tenantID, nonce, CollectionScheme)
+	appraisal.Result.Submods = make(map[string]*ear.Appraisal, len(members))
+
+	for _, member := range members {
+		result := member.Appraisal.Result
+
+		for name, submod := range result.Submods {
+			if len(result.Submods) == 1 {
+				name = member.Label
+			} else {
+				name = member.Label + "/" + name
+			}
+			appraisal.Result.Submods[name] = submod
+		}
+
+		if appraisal.Result.VeraisonTeeInfo == nil {
+			appraisal.Result.VeraisonTeeInfo = result.VeraisonTeeInfo
+		}
+	}
+
+	return appraisal
+}
+
+// CheckBinding confirms that the members of an evidence collection are bound
+// together. A member is bound to another if the claims extracted from one of
+// them contain the digest (SHA-256, SHA-384 or SHA-512) of the other's
+// evidence (e.g. the hash of a CCA realm token in the challenge of the
+// platform token). The check succeeds if the binding relation connects all
+// the members of the collection.
+func CheckBinding(members []Member) error {
+	if len(members) < 2 {
+		return nil
+	}
+
+	// union-find over member indices
+	parent := make([]int, len(members))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i, bound := range members {
+		encodings := digestEncodings(bound.Data)
+
+		for j, binder := range members {
+			if i == j || find(i) == find(j) {
+				continue
+			}
+
+			if containsAny(evidenceClaims(binder), encodings) {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	var unbound []string
+	for i, member := range members {
+		if find(i) != find(0) {
+			unbound = append(unbound, member.Label)
+		}
+	}
+
+	if len(unbound) != 0 {
+		sort.Strings(unbound)
+		return fmt.Errorf("collection members not bound to %q: %s",
+			members[0].Label, strings.Join(unbound, ", "))
+	}
+
+	return nil
+}
+
+func evidenceClaims(member Member) map[string]interface{} {
+	if member.Appraisal == nil || member.Appraisal.EvidenceContext == nil ||
+		member.Appraisal.EvidenceContext.Evidence == nil {
+		return nil
+	}
+
+	return member.Appraisal.EvidenceContext.Evidence.AsMap()
+}
+
+// digestEncodings returns the possible textual representations of the
+// digests of data. Binary claims are represented as strings inside the
+// evidence context, using an encoding that depends on the scheme.
+func digestEncodings(data []byte) map[string]bool {
+	s256 := sha256.Sum256(data)
+	s384 := sha512.Sum384(data)
+	s512 := sha512.Sum512(data)
+
+	ret := make(map[string]bool)
+
+	for _, digest := range [][]byte{s256[:], s384[:], s512[:]} {
+		ret[base64.StdEncoding.EncodeToString(digest)] = true
+		ret[base64.RawStdEncoding.EncodeToString(digest)] = true
+		ret[base64.URLEncoding.EncodeToString(digest)] = true
+		ret[base64.RawURLEncoding.EncodeToString(digest)] = true
+		ret[hex.EncodeToString(digest)] = true
+	}
+
+	return ret
+}
+
+func containsAny(claims interface{}, values map[string]bool) bool {
+	switch t := claims.(type) {
+	case string:
+		return values[t] || values[strings.ToLower(t)]
+	case map[string]interface{}:
+		for _, v := range t {
+			if containsAny(v, values) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, v := range t {
+			if containsAny(v, values) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package appraisal
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/ear"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestMember(t *testing.T, label, scheme string, data []byte, claims map[string]interface{}) Member {
+	appraisal := New("0", []byte{0xde, 0xad, 0xbe, 0xef}, scheme)
+
+	if claims != nil {
+		evidence, err := structpb.NewStruct(claims)
+		require.NoError(t, err)
+		appraisal.EvidenceContext.Evidence = evidence
+	}
+
+	return Member{Label: label, Data: data, Appraisal: appraisal}
+}
+
+func TestNewCollection(t *testing.T) {
+	platform := newTestMember(t, "platform", "CCA_PLATFORM", []byte("platform token"), nil)
+	realm := newTestMember(t, "realm", "CCA_REALM", []byte("realm token"), nil)
+
+	// a member whose appraisal produced more than one submod
+	realm.Appraisal.Result.Submods["extra"] = &ear.Appraisal{TrustVector: &ear.TrustVector{}}
+
+	collection := NewCollection("0", []byte{0xde, 0xad, 0xbe, 0xef}, []Member{platform, realm})
+
+	assert.Equal(t, CollectionScheme, collection.Scheme)
+	assert.Len(t, collection.Result.Submods, 3)
+	assert.Same(t, platform.Appraisal.Result.Submods["CCA_PLATFORM"], collection.Result.Submods["platform"])
+	assert.Same(t, realm.Appraisal.Result.Submods["CCA_REALM"], collection.Result.Submods["realm/CCA_REALM"])
+	assert.Contains(t, collection.Result.Submods, "realm/extra")
+	assert.Equal(t, "3q2-7w==", *collection.Result.Nonce)
+}
+
+func TestCheckBinding_ok(t *testing.T) {
+	realmToken := []byte("realm token")
+	digest := sha256.Sum256(realmToken)
+
+	members := []Member{
+		newTestMember(t, "realm", "CCA_REALM", realmToken, map[string]interface{}{
+			"cca-realm-challenge": "AAAA",
+		}),
+		newTestMember(t, "platform", "CCA_PLATFORM", []byte("platform token"), map[string]interface{}{
+			"cca-platform-challenge": base64.StdEncoding.EncodeToString(digest[:]),
+		}),
+	}
+
+	assert.NoError(t, CheckBinding(members))
+
+	// a single member is trivially bound
+	assert.NoError(t, CheckBinding(members[:1]))
+}
+
+func TestCheckBinding_nested_claim(t *testing.T) {
+	deviceToken := []byte("device token")
+	digest := sha256.Sum256(deviceToken)
+
+	members := []Member{
+		newTestMember(t, "device", "PSA_IOT", deviceToken, nil),
+		newTestMember(t, "workload", "TPM_ENACTTRUST", []byte("workload"), map[string]interface{}{
+			"binding": []interface{}{
+				map[string]interface{}{"digest": base64.RawURLEncoding.EncodeToString(digest[:])},
+			},
+		}),
+	}
+
+	assert.NoError(t, CheckBinding(members))
+}
+
+func TestCheckBinding_unbound(t *testing.T) {
+	members := []Member{
+		newTestMember(t, "realm", "CCA_REALM", []byte("realm token"), map[string]interface{}{
+			"cca-realm-challenge": "AAAA",
+		}),
+		newTestMember(t, "platform", "CCA_PLATFORM", []byte("platform token"), map[string]interface{}{
+			"cca-platform-challenge": "BBBB",
+		}),
+		newTestMember(t, "other", "PSA_IOT", []byte("other"), nil),
+	}
+
+	assert.EqualError(t, CheckBinding(members),
+		`collection members not bound to "realm": other, platform`)
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package appraisal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/veraison/services/handler"
+)
+
+// EndorsedValuesAnnotation is the name of the annotated evidence entry under
+// which the endorsed values that apply to the evidence are reported.
+const EndorsedValuesAnnotation = "endorsed-values"
+
+// SelectEndorsedValues returns those of the specified (JSON-encoded) endorsed
+// values whose condition matches the evidence being appraised. Endorsed values
+// with no condition always apply.
+func (o Appraisal) SelectEndorsedValues(endorsedValues []string) ([]string, error) {
+	var (
+		selected []string
+		evidence map[string]interface{}
+	)
+
+	if o.EvidenceContext.GetEvidence() != nil {
+		evidence = o.EvidenceContext.Evidence.AsMap()
+	}
+
+	for i, e := range endorsedValues {
+		var endorsement handler.Endorsement
+
+		if err := json.Unmarshal([]byte(e), &endorsement); err != nil {
+			return nil, fmt.Errorf("could not decode endorsed value at index %d: %w", i, err)
+		}
+
+		if endorsement.Condition != nil && !endorsement.Condition.Matches(evidence) {
+			continue
+		}
+
+		selected = append(selected, e)
+	}
+
+	return selected, nil
+}
+
+// AddEndorsedValues reports the attributes of the specified endorsed values
+// as annotated evidence in each of the submods of the result. Only the
+// endorsed values that apply to the evidence (see SelectEndorsedValues)
+// should be specified.
+func (o Appraisal) AddEndorsedValues(endorsedValues []string) error {
+	if len(endorsedValues) == 0 {
+		return nil
+	}
+
+	annotations := make([]interface{}, 0, len(endorsedValues))
+
+	for i, e := range endorsedValues {
+		var endorsement handler.Endorsement
+
+		if err := json.Unmarshal([]byte(e), &endorsement); err != nil {
+			return fmt.Errorf("could not decode endorsed value at index %d: %w", i, err)
+		}
+
+		var attrs interface{}
+		if err := json.Unmarshal(endorsement.Attributes, &attrs); err != nil {
+			return fmt.Errorf("could not decode attributes of endorsed value at index %d: %w", i, err)
+		}
+
+		annotations = append(annotations, attrs)
+	}
+
+	for _, submod := range o.Result.Submods {
+		if submod.AppraisalExtensions.VeraisonAnnotatedEvidence == nil {
+			evidenceMap := make(map[string]interface{})
+			submod.AppraisalExtensions.VeraisonAnnotatedEvidence = &evidenceMap
+		}
+		(*submod.AppraisalExtensions.VeraisonAnnotatedEvidence)[EndorsedValuesAnnotation] = annotations
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+package appraisal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var testEndVal = `{"scheme":"PSA_IOT","type":"endorsed value","subType":"PSA_IOT.endorsed-values",` +
+	`"attributes":{"PSA_IOT.impl-id":"YWNtZQ==","PSA_IOT.endorsed-values":[{"value":{"raw-value":{"type":"bytes","value":"AQ=="}}}]}}`
+
+func TestAppraisal_AddEndorsedValues(t *testing.T) {
+	appraisal := New("0", []byte{0xde, 0xad, 0xbe, 0xef}, "PSA_IOT")
+
+	err := appraisal.AddEndorsedValues([]string{testEndVal})
+	require.NoError(t, err)
+
+	annotated := appraisal.Result.Submods["PSA_IOT"].AppraisalExtensions.VeraisonAnnotatedEvidence
+	require.NotNil(t, annotated)
+
+	expected := []interface{}{
+		map[string]interface{}{
+			"PSA_IOT.impl-id": "YWNtZQ==",
+			"PSA_IOT.endorsed-values": []interface{}{
+				map[string]interface{}{
+					"value": map[string]interface{}{
+						"raw-value": map[string]interface{}{"type": "bytes", "value": "AQ=="},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, (*annotated)[EndorsedValuesAnnotation])
+}
+
+func TestAppraisal_AddEndorsedValues_none(t *testing.T) {
+	appraisal := New("0", []byte{0xde, 0xad, 0xbe, 0xef}, "PSA_IOT")
+
+	require.NoError(t, appraisal.AddEndorsedValues(nil))
+	assert.Nil(t, appraisal.Result.Submods["PSA_IOT"].AppraisalExtensions.VeraisonAnnotatedEvidence)
+}
+
+func TestAppraisal_AddEndorsedValues_bad(t *testing.T) {
+	appraisal := New("0", []byte{0xde, 0xad, 0xbe, 0xef}, "PSA_IOT")
+
+	err := appraisal.AddEndorsedValues([]string{"not JSON"})
+	assert.ErrorContains(t, err, "could not decode endorsed value at index 0")
+}
+
+func TestAppraisal_SelectEndorsedValues(t *testing.T) {
+	matching := `{"scheme":"PSA_IOT","type":"endorsed value","subType":"PSA_IOT.endorsed-values",` +
+		`"attributes":{"PSA_IOT.impl-id":"YWNtZQ=="},` +
+		`"condition":{"claims":{"psa-implementation-id":"YWNtZQ=="}}}`
+	other := `{"scheme":"PSA_IOT","type":"endorsed value","subType":"PSA_IOT.endorsed-values",` +
+		`"attributes":{"PSA_IOT.impl-id":"YWNtZQ=="},` +
+		`"condition":{"claims":{"psa-implementation-id":"YWNtZQ==","psa-instance-id":"AQI="}}}`
+
+	appraisal := New("0", []byte{0xde, 0xad, 0xbe, 0xef}, "PSA_IOT")
+
+	evidence, err := structpb.NewStruct(map[string]interface{}{
+		"psa-implementation-id": "YWNtZQ==",
+		"psa-instance-id":       "AQM=",
+	})
+	require.NoError(t, err)
+	appraisal.EvidenceContext.Evidence = evidence
+
+	selected, err := appraisal.SelectEndorsedValues([]string{matching, other, testEndVal})
+	require.NoError(t, err)
+	assert.Equal(t, []string{matching, testEndVal}, selected)
+
+	_, err = appraisal.SelectEndorsedValues([]string{"not JSON"})
+	assert.ErrorContains(t, err, "could not decode endorsed value at index 0")
+}
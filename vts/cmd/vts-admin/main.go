@@ -0,0 +1,160 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+// vts-admin performs administrative operations on a VTS instance: exporting
+// the contents of its trust anchor and endorsement stores to a portable
+// archive, and importing such an archive.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	flag "github.com/spf13/pflag"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/veraison/services/config"
+	"github.com/veraison/services/log"
+	"github.com/veraison/services/proto"
+	"github.com/veraison/services/vts/storearchive"
+	"github.com/veraison/services/vtsclient"
+)
+
+var (
+	schemes        = flag.StringSlice("scheme", nil, "export: only export the endorsements of the specified scheme(s)")
+	tenant         = flag.String("tenant", "", "export: only export the endorsements of the specified tenant")
+	validity       = flag.Duration("validity", 0, "export: how long the archive may be imported for (0 means forever)")
+	source         = flag.String("source", "", "export: identifier of the deployment, recorded in the archive")
+	output         = flag.StringP("output", "o", "", "export: file to write the archive to (default: standard output)")
+	ignoreValidity = flag.Bool("ignore-validity", false, "import: import the archive even if it is not (or no longer) valid")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  vts-admin [flags] export\n")
+	fmt.Fprintf(os.Stderr, "  vts-admin [flags] import <archive>\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	config.CmdLine()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	v, err := config.ReadRawConfig(*config.File, true)
+	if err != nil {
+		log.Fatalf("could not read config: %v", err)
+	}
+
+	subs, err := config.GetSubs(v, "*vts", "*logging")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	classifiers := map[string]interface{}{"service": "vts-admin"}
+	if err := log.Init(subs["logging"], classifiers); err != nil {
+		log.Fatalf("could not configure logging: %v", err)
+	}
+
+	vtsClient := vtsclient.NewGRPC()
+	if err := vtsClient.Init(subs["vts"]); err != nil {
+		log.Fatalf("could not initialize VTS client: %v", err)
+	}
+
+	switch args[0] {
+	case "export":
+		if len(args) != 1 {
+			usage()
+			os.Exit(2)
+		}
+		err = export(vtsClient)
+	case "import":
+		if len(args) != 2 {
+			usage()
+			os.Exit(2)
+		}
+		err = importArchive(vtsClient, args[1])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%s failed: %v", args[0], err)
+	}
+}
+
+func export(vtsClient vtsclient.IVTSClient) error {
+	req := &proto.ExportEndorsementsRequest{
+		Schemes:  *schemes,
+		TenantId: *tenant,
+		Source:   *source,
+	}
+
+	if *validity != 0 {
+		req.Validity = durationpb.New(*validity)
+	}
+
+	archive, err := vtsClient.ExportEndorsements(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(archive.GetData())
+		return err
+	}
+
+	if err := os.WriteFile(*output, archive.GetData(), 0600); err != nil {
+		return err
+	}
+
+	log.Infow("exported endorsements", "output", *output)
+
+	return nil
+}
+
+func importArchive(vtsClient vtsclient.IVTSClient, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// check the archive locally first, for a more helpful error message
+	archive, err := storearchive.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("importing archive", "entries", len(archive.Entries),
+		"exporter", archive.Provenance.Exporter,
+		"exporter-version", archive.Provenance.ExporterVersion,
+		"source", archive.Provenance.Source,
+		"created", archive.Provenance.Created)
+
+	req := &proto.ImportEndorsementsRequest{
+		Archive:        &proto.StoreArchive{Data: data},
+		IgnoreValidity: *ignoreValidity,
+	}
+
+	rsp, err := vtsClient.ImportEndorsements(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("imported endorsements", "imported", rsp.Imported,
+		"duplicates", rsp.Duplicates, "failed", rsp.Failed)
+
+	if !rsp.GetStatus().GetResult() {
+		return fmt.Errorf("%s", rsp.GetStatus().GetErrorDetail())
+	}
+
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/veraison/services/plugin"
 	"github.com/veraison/services/policy"
 	"github.com/veraison/services/vts/earsigner"
+	"github.com/veraison/services/vts/history"
 	"github.com/veraison/services/vts/policymanager"
 	"github.com/veraison/services/vts/trustedservices"
 )
@@ -31,7 +32,7 @@ func main() {
 	}
 
 	subs, err := config.GetSubs(v, "ta-store", "en-store", "po-store",
-		"*po-agent", "plugin", "*vts", "ear-signer", "*logging")
+		"*po-agent", "plugin", "*vts", "ear-signer", "*logging", "*history")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -57,6 +58,11 @@ func main() {
 		log.Fatalf("policy store initialization failed: %v", err)
 	}
 
+	historyStore, err := history.New(subs["history"], log.Named("history"))
+	if err != nil {
+		log.Fatalf("history store initialization failed: %v", err)
+	}
+
 	log.Info("initializing policy manager")
 	policyManager, err := policymanager.New(subs["po-agent"], poStore, log.Named("policy"))
 	if err != nil {
@@ -128,17 +134,23 @@ func main() {
 		log.Info("\t", mt)
 	}
 
-	log.Info("loading EAR signer")
-	earSigner, err := earsigner.New(subs["ear-signer"], afero.NewOsFs())
+	log.Info("loading EAR signers")
+	earSigners, err := earsigner.New(subs["ear-signer"], afero.NewOsFs())
 	if err != nil {
 		log.Fatalf("EAR signer initialization failed: %v", err)
 	}
 
+	log.Info("Attestation result media types:")
+	for _, signer := range earSigners {
+		log.Info("\t", signer.GetMediaType())
+	}
+
 	log.Info("initializing service")
-	// from this point onwards taStore, enStore, evPluginManager, endPluginManager,
-	// policyManager and earSigner are owned by vts
+	// from this point onwards taStore, enStore, historyStore, evPluginManager,
+	// endPluginManager, policyManager and earSigners are owned by vts
 	vts := trustedservices.NewGRPC(taStore, enStore,
-		evPluginManager, endPluginManager, policyManager, earSigner, log.Named("vts"))
+		evPluginManager, endPluginManager, policyManager, historyStore, earSigners,
+		log.Named("vts"))
 
 	if err = vts.Init(subs["vts"], evPluginManager, endPluginManager); err != nil {
 		log.Fatalf("VTS initialisation failed: %v", err)
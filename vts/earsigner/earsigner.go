@@ -3,31 +3,65 @@
 package earsigner
 
 import (
+	"fmt"
+
 	"github.com/spf13/afero"
 	"github.com/spf13/viper"
+	"github.com/veraison/ear"
 	"github.com/veraison/services/config"
 )
 
+const (
+	// EarJWTMediaType is the media type of an EAR signed as a JWT.
+	EarJWTMediaType = `application/eat+jwt; eat_profile="` + ear.EatProfile + `"`
+	// EarCWTMediaType is the media type of an EAR signed as a CWT.
+	EarCWTMediaType = `application/eat+cwt; eat_profile="` + ear.EatProfile + `"`
+)
+
 type Cfg struct {
-	Key string `mapstructure:"key"`
-	Alg string `mapstructure:"alg"`
+	Key     string   `mapstructure:"key"`
+	Alg     string   `mapstructure:"alg"`
+	Formats []string `mapstructure:"formats"`
 }
 
-func New(v *viper.Viper, fs afero.Fs) (IEarSigner, error) {
-	var cfg Cfg
+// New returns a signer for each of the EAR formats ("jwt", "cwt") specified
+// in the configuration, in the same order. The first signer produces the
+// default format. All signers use the same key and algorithm.
+func New(v *viper.Viper, fs afero.Fs) ([]IEarSigner, error) {
+	cfg := Cfg{Formats: []string{"jwt"}}
 
 	loader := config.NewLoader(&cfg)
 	if err := loader.LoadFromViper(v); err != nil {
 		return nil, err
 	}
 
-	// JWT is the only supported signing format for now
-	// (CWT is not yet implemented in veraison/ear)
-	es := &JWT{}
+	if len(cfg.Formats) == 0 {
+		return nil, fmt.Errorf("%q must specify at least one format", "ear-signer.formats")
+	}
 
-	if err := es.Init(cfg, fs); err != nil {
-		return nil, err
+	var signers []IEarSigner // nolint:prealloc
+
+	for _, format := range cfg.Formats {
+		var es IEarSigner
+
+		switch format {
+		case "jwt":
+			es = &JWT{}
+		case "cwt":
+			es = &CWT{}
+		default:
+			return nil, fmt.Errorf(
+				"%q is not a valid value for %q. Supported formats: jwt, cwt",
+				format, "ear-signer.formats",
+			)
+		}
+
+		if err := es.Init(cfg, fs); err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, es)
 	}
 
-	return es, nil
+	return signers, nil
 }
@@ -0,0 +1,271 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package earsigner
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/spf13/afero"
+	"github.com/veraison/ear"
+	cose "github.com/veraison/go-cose"
+)
+
+// CWT signs EARs as COSE_Sign1-wrapped CWT claims-sets. The EAR claims are
+// encoded using the integer labels defined for the CBOR serialization of EAR;
+// claims without a registered label (e.g. Veraison extensions) retain their
+// JSON names as text labels.
+type CWT struct {
+	JWT
+
+	signer cose.Signer
+}
+
+var (
+	jwaToCOSEAlg = map[jwa.SignatureAlgorithm]cose.Algorithm{
+		jwa.ES256: cose.AlgorithmES256,
+		jwa.ES384: cose.AlgorithmES384,
+		jwa.ES512: cose.AlgorithmES512,
+		jwa.PS256: cose.AlgorithmPS256,
+		jwa.PS384: cose.AlgorithmPS384,
+		jwa.PS512: cose.AlgorithmPS512,
+		jwa.EdDSA: cose.AlgorithmEdDSA,
+	}
+
+	earClaimLabels = map[string]interface{}{
+		"iat":                        6,
+		"eat_nonce":                  10,
+		"eat_profile":                265,
+		"submods":                    266,
+		"ear.status":                 1000,
+		"ear.trustworthiness-vector": 1001,
+		"ear.raw-evidence":           1002,
+		"ear.appraisal-policy-id":    1003,
+		"ear.verifier-id":            1004,
+	}
+
+	verifierIDLabels = map[string]interface{}{
+		"developer": 0,
+		"build":     1,
+	}
+
+	trustVectorLabels = map[string]interface{}{
+		"instance-identity": 0,
+		"configuration":     1,
+		"executables":       2,
+		"file-system":       3,
+		"hardware":          4,
+		"runtime-opaque":    5,
+		"storage-opaque":    6,
+		"sourced-data":      7,
+	}
+)
+
+func (o *CWT) Init(cfg Cfg, fs afero.Fs) error {
+	if err := o.JWT.Init(cfg, fs); err != nil {
+		return err
+	}
+
+	sigAlg, ok := o.Alg.(jwa.SignatureAlgorithm)
+	if !ok {
+		return fmt.Errorf("%q is not a signature algorithm", o.Alg)
+	}
+
+	alg, ok := jwaToCOSEAlg[sigAlg]
+	if !ok {
+		return fmt.Errorf(
+			"%q is not supported for CWT signing. Supported algorithms: %s",
+			o.Alg, coseAlgList(),
+		)
+	}
+
+	var raw interface{}
+	if err := o.Key.(jwk.Key).Raw(&raw); err != nil {
+		return fmt.Errorf("extracting raw signing key: %w", err)
+	}
+
+	key, ok := raw.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("signing key of type %T cannot be used for signing", raw)
+	}
+
+	signer, err := cose.NewSigner(alg, key)
+	if err != nil {
+		return fmt.Errorf("creating COSE signer: %w", err)
+	}
+
+	o.signer = signer
+
+	return nil
+}
+
+func (o CWT) Sign(earClaims ear.AttestationResult) ([]byte, error) {
+	// Serializing via JSON validates the EAR and normalizes the claim
+	// values in the same way as for EAR-JWT.
+	j, err := earClaims.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+
+	var claims map[string]interface{}
+	if err := dec.Decode(&claims); err != nil {
+		return nil, err
+	}
+
+	claimsSet := toCWTClaimsSet(claims)
+
+	// Byte string claims are base64url-encoded in the JSON serialization of
+	// EAR (EAT §7.2.2), and are encoded as CBOR byte strings.
+	if earClaims.RawEvidence != nil {
+		claimsSet[earClaimLabels["ear.raw-evidence"]] = []byte(*earClaims.RawEvidence)
+	}
+
+	if earClaims.Nonce != nil {
+		nonce, err := decodeB64Url(*earClaims.Nonce)
+		if err != nil {
+			return nil, fmt.Errorf("eat_nonce is not base64url-encoded: %w", err)
+		}
+		claimsSet[earClaimLabels["eat_nonce"]] = nonce
+	}
+
+	payload, err := cbor.Marshal(claimsSet)
+	if err != nil {
+		return nil, fmt.Errorf("encoding CWT claims-set: %w", err)
+	}
+
+	headers := cose.Headers{
+		Protected: cose.ProtectedHeader{
+			cose.HeaderLabelAlgorithm: o.signer.Algorithm(),
+		},
+	}
+
+	return cose.Sign1(rand.Reader, o.signer, headers, payload, nil)
+}
+
+func (o CWT) GetMediaType() string {
+	return EarCWTMediaType
+}
+
+func toCWTClaimsSet(claims map[string]interface{}) map[interface{}]interface{} {
+	ret := make(map[interface{}]interface{}, len(claims))
+
+	for name, value := range claims {
+		switch name {
+		case "eat_nonce", "ear.raw-evidence":
+			// set by Sign from the (decoded) claims
+			continue
+		case "ear.verifier-id":
+			value = relabel(value, verifierIDLabels, cborValue)
+		case "submods":
+			if submods, ok := value.(map[string]interface{}); ok {
+				converted := make(map[interface{}]interface{}, len(submods))
+				for submod, appraisal := range submods {
+					if a, ok := appraisal.(map[string]interface{}); ok {
+						converted[submod] = toCWTClaimsSet(a)
+					} else {
+						converted[submod] = cborValue(appraisal)
+					}
+				}
+				value = converted
+			}
+		case "ear.status":
+			if s, ok := value.(string); ok {
+				if tier, ok := ear.StringToTrustTier[s]; ok {
+					value = int(tier)
+				}
+			}
+		case "ear.trustworthiness-vector":
+			value = relabel(value, trustVectorLabels, cborValue)
+		default:
+			value = cborValue(value)
+		}
+
+		if label, ok := earClaimLabels[name]; ok {
+			ret[label] = value
+		} else {
+			ret[name] = value
+		}
+	}
+
+	return ret
+}
+
+// relabel replaces the keys of the provided map with the corresponding
+// labels, converting the values with the provided function.
+func relabel(
+	value interface{},
+	labels map[string]interface{},
+	convert func(interface{}) interface{},
+) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return cborValue(value)
+	}
+
+	ret := make(map[interface{}]interface{}, len(m))
+	for k, v := range m {
+		if label, ok := labels[k]; ok {
+			ret[label] = convert(v)
+		} else {
+			ret[k] = convert(v)
+		}
+	}
+
+	return ret
+}
+
+// cborValue converts JSON numbers into integers (where possible), so that
+// they are encoded as CBOR integers rather than floats.
+func cborValue(value interface{}) interface{} {
+	switch t := value.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			ret[k] = cborValue(v)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(t))
+		for i, v := range t {
+			ret[i] = cborValue(v)
+		}
+		return ret
+	default:
+		return value
+	}
+}
+
+// decodeB64Url decodes a base64url-encoded byte string. Padding is optional,
+// as it is used by some encoders (e.g. for the nonces of Veraison sessions).
+func decodeB64Url(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func coseAlgList() string {
+	var l []string
+	for a := range jwaToCOSEAlg {
+		l = append(l, a.String())
+	}
+	sort.Strings(l)
+	return strings.Join(l, ", ")
+}
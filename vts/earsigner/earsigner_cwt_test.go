@@ -0,0 +1,113 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package earsigner
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/ear"
+	cose "github.com/veraison/go-cose"
+)
+
+var testKey = `{
+	"kty": "EC",
+	"crv": "P-256",
+	"x": "usWxHK2PmfnHKwXPS54m0kTcGJ90UiglWiGahtagnv8",
+	"y": "IBOL-C3BttVivg-lSreASjpkttcsz-1rb7btKLv8EX4",
+	"d": "V8kgd2ZBRuh2dgyVINBUqpPDr7BOMGcF22CQMIUHtNM"
+}`
+
+func newTestSigners(t *testing.T, formats ...string) []IEarSigner {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "skey.jwk", []byte(testKey), 0600))
+
+	v := viper.New()
+	v.Set("alg", "ES256")
+	v.Set("key", "skey.jwk")
+	if formats != nil {
+		v.Set("formats", formats)
+	}
+
+	signers, err := New(v, fs)
+	require.NoError(t, err)
+
+	return signers
+}
+
+func Test_New_formats(t *testing.T) {
+	signers := newTestSigners(t)
+	require.Len(t, signers, 1)
+	assert.Equal(t, EarJWTMediaType, signers[0].GetMediaType())
+
+	signers = newTestSigners(t, "cwt", "jwt")
+	require.Len(t, signers, 2)
+	assert.Equal(t, EarCWTMediaType, signers[0].GetMediaType())
+	assert.Equal(t, EarJWTMediaType, signers[1].GetMediaType())
+
+	v := viper.New()
+	v.Set("alg", "ES256")
+	v.Set("key", "skey.jwk")
+	v.Set("formats", []string{"xml"})
+	_, err := New(v, afero.NewMemMapFs())
+	assert.ErrorContains(t, err, `"xml" is not a valid value for "ear-signer.formats"`)
+}
+
+func Test_CWT_Sign(t *testing.T) {
+	signer := newTestSigners(t, "cwt")[0]
+
+	result := ear.NewAttestationResult("test", "v1", "Veraison")
+	nonce := "3q2-7w=="
+	result.Nonce = &nonce
+	rawEvidence := ear.B64Url{0x01, 0x02, 0x03}
+	result.RawEvidence = &rawEvidence
+	status := ear.TrustTierAffirming
+	result.Submods["test"].Status = &status
+	result.Submods["test"].TrustVector.Executables = ear.ApprovedRuntimeClaim
+
+	token, err := signer.Sign(*result)
+	require.NoError(t, err)
+
+	_, key, err := signer.GetEARSigningPublicKey()
+	require.NoError(t, err)
+
+	var pub interface{}
+	require.NoError(t, key.Raw(&pub))
+
+	verifier, err := cose.NewVerifier(cose.AlgorithmES256, pub.(crypto.PublicKey))
+	require.NoError(t, err)
+
+	var msg cose.Sign1Message
+	require.NoError(t, msg.UnmarshalCBOR(token))
+	require.NoError(t, msg.Verify(nil, verifier))
+
+	var claims map[interface{}]interface{}
+	require.NoError(t, cbor.Unmarshal(msg.Payload, &claims))
+
+	assert.Equal(t, ear.EatProfile, claims[uint64(265)])
+	assert.Equal(t, []byte{0xde, 0xad, 0xbe, 0xef}, claims[uint64(10)])
+	assert.Equal(t, []byte{0x01, 0x02, 0x03}, claims[uint64(1002)])
+
+	submods := claims[uint64(266)].(map[interface{}]interface{})
+	appraisal := submods["test"].(map[interface{}]interface{})
+	assert.Equal(t, uint64(ear.TrustTierAffirming), appraisal[uint64(1000)])
+
+	tv := appraisal[uint64(1001)].(map[interface{}]interface{})
+	assert.Equal(t, uint64(ear.ApprovedRuntimeClaim), tv[uint64(2)])
+}
+
+func Test_CWT_Sign_bad_nonce(t *testing.T) {
+	signer := newTestSigners(t, "cwt")[0]
+
+	result := ear.NewAttestationResult("test", "v1", "Veraison")
+	nonce := "3q2+7w=="
+	result.Nonce = &nonce
+
+	_, err := signer.Sign(*result)
+	assert.ErrorContains(t, err, "eat_nonce is not base64url-encoded")
+}
@@ -40,6 +40,10 @@ func (o JWT) Sign(earClaims ear.AttestationResult) ([]byte, error) {
 	return earClaims.Sign(o.Alg, o.Key)
 }
 
+func (o JWT) GetMediaType() string {
+	return EarJWTMediaType
+}
+
 func (o JWT) GetEARSigningPublicKey() (jwa.KeyAlgorithm, jwk.Key, error) {
 	v, ok := o.Key.(jwk.Key)
 
@@ -13,5 +13,8 @@ type IEarSigner interface {
 	Init(cfg Cfg, fs afero.Fs) error
 	Sign(earClaims ear.AttestationResult) ([]byte, error)
 	GetEARSigningPublicKey() (jwa.KeyAlgorithm, jwk.Key, error)
+	// GetMediaType returns the media type of the signed EARs produced by
+	// Sign().
+	GetMediaType() string
 	Close() error
 }
@@ -0,0 +1,258 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package history implements the store of the attestation history of devices,
+// which is made available to policies so that they can check, e.g., that
+// counters do not go backwards, or that firmware has not been rolled back.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/veraison/services/config"
+	"github.com/veraison/services/kvstore"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxEntries is the number of the most recent attestations kept for
+// each device, unless otherwise configured.
+var DefaultMaxEntries = 10
+
+type cfg struct {
+	MaxEntries int                    `mapstructure:"max-entries"`
+	MaxAge     string                 `mapstructure:"max-age" config:"zerodefault"`
+	Store      map[string]interface{} `mapstructure:"store" config:"zerodefault"`
+}
+
+func (o cfg) Validate() error {
+	if o.MaxEntries < 1 {
+		return fmt.Errorf("max-entries must be at least 1, got %d", o.MaxEntries)
+	}
+
+	return nil
+}
+
+// Entry is the record of an attestation of a device.
+type Entry struct {
+	// Time is when the attestation took place.
+	Time time.Time `json:"time"`
+	// Evidence contains the claims extracted from the evidence.
+	Evidence map[string]interface{} `json:"evidence"`
+	// Result is the attestation result (as a JSON object).
+	Result map[string]interface{} `json:"result"`
+}
+
+// AsMap returns the representation of the entry made available to policies.
+func (o Entry) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"time":     o.Time.UTC().Format(time.RFC3339),
+		"evidence": o.Evidence,
+		"result":   o.Result,
+	}
+}
+
+// Store keeps a bounded window of the most recent attestations of each
+// device, keyed by the trust anchor IDs of its evidence. Recording an
+// attestation reads, updates and writes back the device's history, so the
+// records of the same device are serialized. This only applies within the
+// same Store: concurrent records made by other instances (e.g. other VTS
+// replicas sharing the kvstore) may still overwrite each other.
+type Store struct {
+	KVStore kvstore.IKVStore
+	// MaxEntries is the maximum number of attestations kept for each
+	// device.
+	MaxEntries int
+	// MaxAge is how long attestations are kept for. Zero means that
+	// attestations are kept until they are displaced by more recent ones.
+	MaxAge time.Duration
+
+	Logger *zap.SugaredLogger
+
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock serializes the records of a device. It is discarded once it is no
+// longer held, or waited for.
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// New returns a new history store created from the provided config. The
+// underlying kvstore is configured by the "store" entry, with the same options
+// as for kvstore.New(). If there is no "store" entry, history is not
+// kept, and nil is returned.
+func New(v *viper.Viper, logger *zap.SugaredLogger) (*Store, error) {
+	cfg := cfg{MaxEntries: DefaultMaxEntries}
+
+	loader := config.NewLoader(&cfg)
+	if err := loader.LoadFromViper(v); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Store) == 0 {
+		return nil, nil
+	}
+
+	var maxAge time.Duration
+	if cfg.MaxAge != "" {
+		var err error
+
+		maxAge, err = time.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("max-age: %w", err)
+		}
+	}
+
+	kvStore, err := kvstore.New(v.Sub("store"), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		KVStore:    kvStore,
+		MaxEntries: cfg.MaxEntries,
+		MaxAge:     maxAge,
+		Logger:     logger,
+	}, nil
+}
+
+// Setup the underyling kvstore. This is a one-time setup that only needs to be
+// performed once for a deployment.
+func (o *Store) Setup() error {
+	return o.KVStore.Setup()
+}
+
+// Close the underlying kvstore.
+func (o *Store) Close() error {
+	return o.KVStore.Close()
+}
+
+// Get returns the retained attestations of the device with the specified
+// trust anchor IDs, oldest first. If there are none, an empty slice is
+// returned.
+func (o *Store) Get(trustAnchorIDs []string) ([]Entry, error) {
+	key, err := historyKey(trustAnchorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := o.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.retain(entries, time.Now()), nil
+}
+
+// Record adds the entry as the most recent attestation of the device with
+// the specified trust anchor IDs, discarding the attestations that are no
+// longer retained.
+func (o *Store) Record(trustAnchorIDs []string, entry Entry) error {
+	key, err := historyKey(trustAnchorIDs)
+	if err != nil {
+		return err
+	}
+
+	unlock := o.lock(key)
+	defer unlock()
+
+	entries, err := o.get(key)
+	if err != nil {
+		return err
+	}
+
+	entries = o.retain(append(entries, entry), time.Now())
+
+	val, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("could not serialize history: %w", err)
+	}
+
+	return o.KVStore.Set(key, string(val))
+}
+
+// lock locks the history of the device with the specified key, and returns
+// the function that unlocks it.
+func (o *Store) lock(key string) func() {
+	o.mu.Lock()
+	if o.locks == nil {
+		o.locks = make(map[string]*keyLock)
+	}
+	l, ok := o.locks[key]
+	if !ok {
+		l = &keyLock{}
+		o.locks[key] = l
+	}
+	l.refs++
+	o.mu.Unlock()
+
+	l.mu.Lock()
+
+	return func() {
+		l.mu.Unlock()
+
+		o.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(o.locks, key)
+		}
+		o.mu.Unlock()
+	}
+}
+
+func (o *Store) get(key string) ([]Entry, error) {
+	vals, err := o.KVStore.Get(key)
+	if err != nil {
+		if errors.Is(err, kvstore.ErrKeyNotFound) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+
+	if len(vals) != 1 {
+		return nil, fmt.Errorf("found %d histories for %q, want 1", len(vals), key)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal([]byte(vals[0]), &entries); err != nil {
+		return nil, fmt.Errorf("could not parse history for %q: %w", key, err)
+	}
+
+	return entries, nil
+}
+
+// retain returns the most recent entries that are within the retention
+// limits, as of now.
+func (o *Store) retain(entries []Entry, now time.Time) []Entry {
+	if o.MaxAge != 0 {
+		cutoff := now.Add(-o.MaxAge)
+
+		var i int
+		for i < len(entries) && entries[i].Time.Before(cutoff) {
+			i++
+		}
+		entries = entries[i:]
+	}
+
+	if o.MaxEntries > 0 && len(entries) > o.MaxEntries {
+		entries = entries[len(entries)-o.MaxEntries:]
+	}
+
+	return entries
+}
+
+func historyKey(trustAnchorIDs []string) (string, error) {
+	if len(trustAnchorIDs) == 0 {
+		return "", errors.New("no trust anchor ID")
+	}
+
+	return strings.Join(trustAnchorIDs, " "), nil
+}
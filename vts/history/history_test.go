@@ -0,0 +1,186 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package history
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/services/kvstore"
+	"github.com/veraison/services/log"
+)
+
+var testTAIDs = []string{"PSA_IOT://1/BwYFBAMCAQAPDg0MCwoJCBcWFRQTEhEQHx4dHBsaGRg=/"}
+
+func newTestStore(t *testing.T, maxEntries int, maxAge string) *Store {
+	v := viper.New()
+	v.Set("store.backend", "memory")
+	v.Set("max-entries", maxEntries)
+	v.Set("max-age", maxAge)
+
+	store, err := New(v, log.Named("history"))
+	require.NoError(t, err)
+	require.NotNil(t, store)
+
+	return store
+}
+
+func newTestEntry(when time.Time, counter int) Entry {
+	return Entry{
+		Time:     when,
+		Evidence: map[string]interface{}{"counter": float64(counter)},
+		Result:   map[string]interface{}{"ear.status": "affirming"},
+	}
+}
+
+func TestNew(t *testing.T) {
+	store, err := New(viper.New(), log.Named("history"))
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	store = newTestStore(t, 3, "24h")
+	assert.Equal(t, 3, store.MaxEntries)
+	assert.Equal(t, 24*time.Hour, store.MaxAge)
+
+	v := viper.New()
+	v.Set("store.backend", "memory")
+
+	store, err = New(v, log.Named("history"))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxEntries, store.MaxEntries)
+	assert.Equal(t, time.Duration(0), store.MaxAge)
+}
+
+func TestNew_bad_config(t *testing.T) {
+	v := viper.New()
+	v.Set("store.backend", "memory")
+	v.Set("max-age", "forever")
+
+	_, err := New(v, log.Named("history"))
+	assert.ErrorContains(t, err, "max-age: ")
+
+	v = viper.New()
+	v.Set("store.backend", "memory")
+	v.Set("max-entries", -1)
+
+	_, err = New(v, log.Named("history"))
+	assert.ErrorContains(t, err, "max-entries must be at least 1, got -1")
+
+	v = viper.New()
+	v.Set("store.backend", "nope")
+
+	_, err = New(v, log.Named("history"))
+	assert.EqualError(t, err, `backend "nope" is not supported`)
+}
+
+func TestStore_Record_Get(t *testing.T) {
+	store := newTestStore(t, 3, "")
+
+	entries, err := store.Get(testTAIDs)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		err = store.Record(testTAIDs, newTestEntry(now.Add(time.Duration(i)*time.Second), i))
+		require.NoError(t, err)
+	}
+
+	entries, err = store.Get(testTAIDs)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		newTestEntry(now.Add(2*time.Second), 2),
+		newTestEntry(now.Add(3*time.Second), 3),
+		newTestEntry(now.Add(4*time.Second), 4),
+	}, entries)
+
+	// histories are kept per device
+	entries, err = store.Get([]string{"PSA_IOT://1/other/"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = store.Get(nil)
+	assert.EqualError(t, err, "no trust anchor ID")
+}
+
+// slowKVStore widens the window between reading and writing back a history.
+type slowKVStore struct {
+	kvstore.IKVStore
+}
+
+func (o slowKVStore) Get(key string) ([]string, error) {
+	vals, err := o.IKVStore.Get(key)
+	time.Sleep(time.Millisecond)
+	return vals, err
+}
+
+func TestStore_Record_concurrent(t *testing.T) {
+	const n = 20
+
+	store := newTestStore(t, n, "")
+	store.KVStore = slowKVStore{store.KVStore}
+	other := []string{"PSA_IOT://1/other/"}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		for _, taIDs := range [][]string{testTAIDs, other} {
+			wg.Add(1)
+			go func(taIDs []string, i int) {
+				defer wg.Done()
+				err := store.Record(taIDs, newTestEntry(now, i))
+				assert.NoError(t, err)
+			}(taIDs, i)
+		}
+	}
+	wg.Wait()
+
+	// no record has been lost
+	for _, taIDs := range [][]string{testTAIDs, other} {
+		entries, err := store.Get(taIDs)
+		require.NoError(t, err)
+		require.Len(t, entries, n, fmt.Sprint(taIDs))
+
+		counters := make(map[float64]bool)
+		for _, entry := range entries {
+			counters[entry.Evidence["counter"].(float64)] = true
+		}
+		assert.Len(t, counters, n)
+	}
+
+	// the locks are not retained
+	assert.Empty(t, store.locks)
+}
+
+func TestStore_maxAge(t *testing.T) {
+	store := newTestStore(t, 10, "1h")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, store.Record(testTAIDs, newTestEntry(now.Add(-2*time.Hour), 1)))
+	require.NoError(t, store.Record(testTAIDs, newTestEntry(now.Add(-30*time.Minute), 2)))
+
+	entries, err := store.Get(testTAIDs)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{newTestEntry(now.Add(-30*time.Minute), 2)}, entries)
+
+	// the expired entries are discarded from the store when recording
+	vals, err := store.KVStore.Get(testTAIDs[0])
+	require.NoError(t, err)
+	assert.NotContains(t, vals[0], `"counter":1`)
+}
+
+func TestEntry_AsMap(t *testing.T) {
+	when := time.Date(2023, 10, 1, 12, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, map[string]interface{}{
+		"time":     "2023-10-01T12:30:00Z",
+		"evidence": map[string]interface{}{"counter": float64(7)},
+		"result":   map[string]interface{}{"ear.status": "affirming"},
+	}, newTestEntry(when, 7).AsMap())
+}
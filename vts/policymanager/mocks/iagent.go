@@ -9,6 +9,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
 	viper "github.com/spf13/viper"
 	ear "github.com/veraison/ear"
 	policy "github.com/veraison/services/policy"
@@ -51,18 +52,18 @@ func (mr *MockIAgentMockRecorder) Close() *gomock.Call {
 }
 
 // Evaluate mocks base method.
-func (m *MockIAgent) Evaluate(ctx context.Context, appraisalContext map[string]interface{}, scheme string, policy *policy.Policy, submod string, appraisal *ear.Appraisal, evidence *proto.EvidenceContext, endorsements []string) (*ear.Appraisal, error) {
+func (m *MockIAgent) Evaluate(ctx context.Context, appraisalContext map[string]interface{}, scheme string, policy *policy.Policy, data map[string]interface{}, history []map[string]interface{}, submod string, appraisal *ear.Appraisal, evidence *proto.EvidenceContext, endorsements []string) (*ear.Appraisal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Evaluate", ctx, appraisalContext, scheme, policy, submod, appraisal, evidence, endorsements)
+	ret := m.ctrl.Call(m, "Evaluate", ctx, appraisalContext, scheme, policy, data, history, submod, appraisal, evidence, endorsements)
 	ret0, _ := ret[0].(*ear.Appraisal)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Evaluate indicates an expected call of Evaluate.
-func (mr *MockIAgentMockRecorder) Evaluate(ctx, appraisalContext, scheme, policy, submod, appraisal, evidence, endorsements interface{}) *gomock.Call {
+func (mr *MockIAgentMockRecorder) Evaluate(ctx, appraisalContext, scheme, policy, data, history, submod, appraisal, evidence, endorsements interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evaluate", reflect.TypeOf((*MockIAgent)(nil).Evaluate), ctx, appraisalContext, scheme, policy, submod, appraisal, evidence, endorsements)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evaluate", reflect.TypeOf((*MockIAgent)(nil).Evaluate), ctx, appraisalContext, scheme, policy, data, history, submod, appraisal, evidence, endorsements)
 }
 
 // GetBackendName mocks base method.
@@ -93,6 +94,33 @@ func (mr *MockIAgentMockRecorder) Init(v interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockIAgent)(nil).Init), v)
 }
 
+// Invalidate mocks base method.
+func (m *MockIAgent) Invalidate(policyID uuid.UUID) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Invalidate", policyID)
+}
+
+// Invalidate indicates an expected call of Invalidate.
+func (mr *MockIAgentMockRecorder) Invalidate(policyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invalidate", reflect.TypeOf((*MockIAgent)(nil).Invalidate), policyID)
+}
+
+// Test mocks base method.
+func (m *MockIAgent) Test(ctx context.Context, policyRules, tests string) ([]policy.TestCaseResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Test", ctx, policyRules, tests)
+	ret0, _ := ret[0].([]policy.TestCaseResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Test indicates an expected call of Test.
+func (mr *MockIAgentMockRecorder) Test(ctx, policyRules, tests interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Test", reflect.TypeOf((*MockIAgent)(nil).Test), ctx, policyRules, tests)
+}
+
 // Validate mocks base method.
 func (m *MockIAgent) Validate(ctx context.Context, policyRules string) error {
 	m.ctrl.T.Helper()
@@ -5,21 +5,72 @@ package policymanager
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
+	"github.com/veraison/ear"
+	"github.com/veraison/services/config"
 	"github.com/veraison/services/policy"
 	"github.com/veraison/services/vts/appraisal"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
+// DefaultActivePolicyTTL is how long the active policies are cached, unless
+// otherwise configured.
+var DefaultActivePolicyTTL = "5s"
+
+// cfg is read from the policy agent's configuration, which it extends.
+type cfg struct {
+	ActivePolicyTTL string `mapstructure:"active-policy-ttl"`
+}
+
 type PolicyManager struct {
 	Store *policy.Store
 	Agent policy.IAgent
 
+	// ActivePolicyTTL is how long the active policy for a key, along with
+	// its bindings, bound policies and data document, is cached before it
+	// is read from the store again. As these are changed by the management
+	// service, which runs in another process, changes (including
+	// activations and deactivations) take up to this long to be picked up:
+	// there is no other form of invalidation. Zero disables caching.
+	ActivePolicyTTL time.Duration
+
+	// Verifier verifies the signatures of policies as they are loaded from
+	// the store, so that policies that have been tampered with are not
+	// applied. If nil, signatures are not verified.
+	Verifier *policy.SignatureVerifier
+
 	logger *zap.SugaredLogger
+
+	mu     sync.Mutex
+	layers map[string]cachedLayer
+	reads  singleflight.Group
+}
+
+// cachedLayer is the layer for a key, as of the last time it was read from
+// the store.
+type cachedLayer struct {
+	layer   *policyLayer
+	expires time.Time
 }
 
 func New(v *viper.Viper, store *policy.Store, logger *zap.SugaredLogger) (*PolicyManager, error) {
+	cfg := cfg{ActivePolicyTTL: DefaultActivePolicyTTL}
+	loader := config.NewNonExclusiveLoader(&cfg)
+	if err := loader.LoadFromViper(v); err != nil {
+		return nil, err
+	}
+
+	ttl, err := time.ParseDuration(cfg.ActivePolicyTTL)
+	if err != nil {
+		return nil, fmt.Errorf("active-policy-ttl: %w", err)
+	}
+
 	agent, err := policy.CreateAgent(v, logger)
 	if err != nil {
 		return nil, err
@@ -27,57 +78,243 @@ func New(v *viper.Viper, store *policy.Store, logger *zap.SugaredLogger) (*Polic
 
 	logger.Infow("agent created", "agent", agent.GetBackendName())
 
-	pm := &PolicyManager{Agent: agent, Store: store, logger: logger}
+	verifier, err := policy.NewSignatureVerifier(v.Sub("signing"))
+	if err != nil {
+		return nil, err
+	}
+
+	pm := &PolicyManager{
+		Agent:           agent,
+		Store:           store,
+		ActivePolicyTTL: ttl,
+		Verifier:        verifier,
+		logger:          logger,
+	}
 
 	return pm, nil
 }
 
+// policyLayer is a stage of the evaluation of an appraisal: the active policy
+// for a key (which may be nil), the data document made available to the
+// policy (which may be nil) and, for the attestation scheme's layer, the
+// bindings that may select another policy version instead, along with the
+// versions they are bound to (indexed by UUID).
+type policyLayer struct {
+	key      policy.PolicyKey
+	active   *policy.Policy
+	data     map[string]interface{}
+	bindings []*policy.Binding
+	bound    map[uuid.UUID]*policy.Policy
+}
+
+// Evaluate applies the policies for the appraisal to each of its submods, in
+// order: the operator-wide baseline policy, the tenant-wide policy, and the
+// policy for the attestation scheme. Each policy is evaluated against the
+// result of the previous one, and its UUID is added to the submod's appraisal
+// policy ID. The policy for the scheme is selected by the first of the scheme's
+// bindings matching the submod, if any; otherwise, the active policy for the
+// scheme is used.
 func (o *PolicyManager) Evaluate(
 	ctx context.Context,
 	scheme string,
 	appraisal *appraisal.Appraisal,
 	endorsements []string,
 ) error {
-	policyKey := o.getPolicyKey(appraisal)
+	return o.evaluate(ctx, scheme, appraisal, endorsements, nil)
+}
 
-	pol, err := o.getPolicy(policyKey)
-	if err != nil {
-		if errors.Is(err, policy.ErrNoPolicy) {
-			o.logger.Debugw("no policy", "policy-id", policyKey)
-			return nil // No policy? No problem!
+// EvaluateFailure applies the policies for an appraisal that failed before it
+// could be completed (e.g. because the integrity of the evidence could not be
+// validated), in the same way as Evaluate. The problem is made available to
+// the policies as the "problem" entry of the session context, alongside
+// whatever evidence and endorsements had been obtained before the failure.
+// As the evidence cannot be trusted, the policies may only add claims to the
+// result: the status, the trust vector and the claims (e.g. the "problem") of
+// the failed appraisal are kept.
+func (o *PolicyManager) EvaluateFailure(
+	ctx context.Context,
+	scheme string,
+	appraisal *appraisal.Appraisal,
+	endorsements []string,
+	problem error,
+) error {
+	type outcome struct {
+		status      *ear.TrustTier
+		trustVector *ear.TrustVector
+		claims      map[string]interface{}
+	}
+
+	failed := make(map[string]outcome, len(appraisal.Result.Submods))
+	for name, submod := range appraisal.Result.Submods {
+		failure := outcome{status: submod.Status, trustVector: submod.TrustVector}
+		if submod.VeraisonPolicyClaims != nil {
+			failure.claims = make(map[string]interface{}, len(*submod.VeraisonPolicyClaims))
+			for k, v := range *submod.VeraisonPolicyClaims {
+				failure.claims[k] = v
+			}
 		}
+		failed[name] = failure
+	}
+
+	err := o.evaluate(ctx, scheme, appraisal, endorsements, map[string]interface{}{
+		"problem": problem.Error(),
+	})
+
+	for name, submod := range appraisal.Result.Submods {
+		failure := failed[name]
+
+		submod.Status = failure.status
+		submod.TrustVector = failure.trustVector
 
+		if len(failure.claims) == 0 {
+			continue
+		}
+
+		if submod.VeraisonPolicyClaims == nil {
+			claims := make(map[string]interface{}, len(failure.claims))
+			submod.VeraisonPolicyClaims = &claims
+		}
+		for k, v := range failure.claims {
+			(*submod.VeraisonPolicyClaims)[k] = v
+		}
+	}
+
+	return err
+}
+
+func (o *PolicyManager) evaluate(
+	ctx context.Context,
+	scheme string,
+	appraisal *appraisal.Appraisal,
+	endorsements []string,
+	session map[string]interface{},
+) error {
+	layers, err := o.getLayers(appraisal)
+	if err != nil {
 		return err
 	}
 
+	if len(layers) == 0 {
+		o.logger.Debugw("no policy",
+			"tenant-id", appraisal.EvidenceContext.TenantId, "scheme", appraisal.Scheme)
+		return nil // No policy? No problem!
+	}
+
 	appraisalContext := map[string]interface{}{
 		"nonce": appraisal.Result.Nonce,
 	}
+	for k, v := range session {
+		appraisalContext[k] = v
+	}
 
-	for submod, submodAppraisal := range appraisal.Result.Submods {
-		evaluated, err := o.Agent.Evaluate(
-			ctx,
-			appraisalContext,
-			scheme,
-			pol,
-			submod,
-			submodAppraisal,
-			appraisal.EvidenceContext,
-			endorsements,
-		)
-		if err != nil {
-			return err
+	var evidence map[string]interface{}
+	for _, layer := range layers {
+		if len(layer.bindings) != 0 {
+			evidence = appraisal.EvidenceContext.Evidence.AsMap()
+			break
 		}
-		appraisal.Result.Submods[submod] = evaluated
+	}
 
-		if err := appraisal.UpdatePolicyID(pol); err != nil {
-			return err
+	for submod := range appraisal.Result.Submods {
+		for _, layer := range layers {
+			pol := o.selectPolicy(layer, appraisal.MediaType, submod, evidence)
+			if pol == nil {
+				continue
+			}
+
+			evaluated, err := o.Agent.Evaluate(
+				ctx,
+				appraisalContext,
+				scheme,
+				pol,
+				layer.data,
+				appraisal.History,
+				submod,
+				appraisal.Result.Submods[submod],
+				appraisal.EvidenceContext,
+				endorsements,
+			)
+			if err != nil {
+				return err
+			}
+			appraisal.Result.Submods[submod] = evaluated
+
+			if err := appraisal.UpdateSubmodPolicyID(submod, pol); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// getLayers returns the layers of policies that apply to the appraisal, in
+// the order they are evaluated. Layers with no policy are omitted.
+func (o *PolicyManager) getLayers(a *appraisal.Appraisal) ([]policyLayer, error) {
+	schemeKey := o.getPolicyKey(a)
+
+	keys := []policy.PolicyKey{
+		policy.BaselinePolicyKey(schemeKey.Name),
+		policy.TenantPolicyKey(schemeKey.TenantId, schemeKey.Name),
+		schemeKey,
+	}
+
+	var layers []policyLayer // nolint:prealloc
+
+	for _, key := range keys {
+		layer, err := o.getLayer(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if layer.active == nil && len(layer.bindings) == 0 {
+			continue
+		}
+
+		layers = append(layers, *layer)
+	}
+
+	return layers, nil
+}
+
+// policyUUIDs returns the UUIDs of the policies that may be applied by the
+// layer: the active one, and the bound ones.
+func (o policyLayer) policyUUIDs() map[uuid.UUID]bool {
+	ids := make(map[uuid.UUID]bool, len(o.bound)+1)
+
+	if o.active != nil {
+		ids[o.active.UUID] = true
+	}
+
+	for id := range o.bound {
+		ids[id] = true
+	}
+
+	return ids
+}
+
+// selectPolicy returns the policy version bound to the submod by the first of
+// the layer's matching bindings or, if no binding matches, the layer's active
+// policy (which may be nil).
+func (o *PolicyManager) selectPolicy(
+	layer policyLayer,
+	mediaType string,
+	submod string,
+	evidence map[string]interface{},
+) *policy.Policy {
+	binding := policy.SelectBinding(layer.bindings, mediaType, submod, evidence)
+	if binding == nil {
+		return layer.active
+	}
+
+	pol := layer.bound[binding.PolicyUUID]
+
+	o.logger.Debugw("policy selected by binding", "policy-id", layer.key,
+		"submod", submod, "binding", binding.ID, "policy-uuid", pol.UUID)
+
+	return pol
+}
+
 func (o *PolicyManager) getPolicyKey(a *appraisal.Appraisal) policy.PolicyKey {
 	return policy.PolicyKey{
 		TenantId: a.EvidenceContext.TenantId,
@@ -86,11 +323,180 @@ func (o *PolicyManager) getPolicyKey(a *appraisal.Appraisal) policy.PolicyKey {
 	}
 }
 
-func (o *PolicyManager) getPolicy(policyKey policy.PolicyKey) (*policy.Policy, error) {
+// getLayer returns the layer for the key, which is cached for
+// ActivePolicyTTL. The layer is read without holding the lock, so that the
+// layers of other keys remain available meanwhile, and concurrent reads of
+// the layer of the same key are coalesced.
+func (o *PolicyManager) getLayer(key policy.PolicyKey) (*policyLayer, error) {
+	if o.ActivePolicyTTL <= 0 {
+		return o.readLayer(key)
+	}
+
+	if layer := o.cachedLayer(key); layer != nil {
+		return layer, nil
+	}
+
+	layer, err, _ := o.reads.Do(key.String(), func() (interface{}, error) {
+		// the layer may have been read since it was found missing
+		if layer := o.cachedLayer(key); layer != nil {
+			return layer, nil
+		}
+
+		layer, err := o.readLayer(key)
+		if err != nil {
+			return nil, err
+		}
+
+		o.cacheLayer(key, layer)
+
+		return layer, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return layer.(*policyLayer), nil
+}
+
+// cachedLayer returns the cached layer for the key, or nil if it is not cached
+// (or has expired).
+func (o *PolicyManager) cachedLayer(key policy.PolicyKey) *policyLayer {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	cached, ok := o.layers[key.String()]
+	if !ok || time.Now().After(cached.expires) {
+		return nil
+	}
+
+	return cached.layer
+}
+
+// cacheLayer caches the layer for the key, replacing the previous one. Anything
+// the agent has cached for the policies of the previous layer that are no
+// longer in the new one (e.g. because another version has been activated, or
+// a binding removed) is discarded.
+func (o *PolicyManager) cacheLayer(key policy.PolicyKey, layer *policyLayer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if previous, ok := o.layers[key.String()]; ok {
+		current := layer.policyUUIDs()
+		for id := range previous.layer.policyUUIDs() {
+			if !current[id] {
+				o.logger.Debugw("policy no longer applied", "policy-id", key,
+					"policy-uuid", id)
+				o.Agent.Invalidate(id)
+			}
+		}
+	}
+
+	if o.layers == nil {
+		o.layers = make(map[string]cachedLayer)
+	}
+
+	o.layers[key.String()] = cachedLayer{
+		layer:   layer,
+		expires: time.Now().Add(o.ActivePolicyTTL),
+	}
+}
+
+// readLayer reads the layer for the key from the store: the active policy (if
+// any), the bindings (for the key of an attestation scheme's policy) and the
+// policy versions they are bound to and, if either a policy or a binding
+// exists, the data document. The signatures of the active and bound policies
+// are verified, and a layer with a policy whose signature cannot be verified
+// is not applied (nor cached).
+func (o *PolicyManager) readLayer(key policy.PolicyKey) (*policyLayer, error) {
+	layer := policyLayer{key: key}
+
+	if key.Scheme != "" {
+		bindings, err := o.Store.GetBindings(key.TenantId, key.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		layer.bindings = bindings
+
+		if layer.bound, err = o.getBound(key, bindings); err != nil {
+			return nil, err
+		}
+	}
+
+	active, err := o.getActive(key)
+	if err != nil {
+		if !errors.Is(err, policy.ErrNoPolicy) && !errors.Is(err, policy.ErrNoActivePolicy) {
+			return nil, err
+		}
+	} else {
+		layer.active = active
+	}
+
+	if layer.active == nil && len(layer.bindings) == 0 {
+		return &layer, nil
+	}
+
+	doc, err := o.Store.GetData(key.TenantId, key.Scheme)
+	if err != nil {
+		if !errors.Is(err, policy.ErrNoData) {
+			return nil, err
+		}
+	} else {
+		o.logger.Debugw("policy data", "policy-id", key, "data-uuid", doc.UUID)
+		layer.data = doc.Data
+	}
+
+	return &layer, nil
+}
+
+// getActive reads the active policy for the key from the store, and verifies
+// its signature.
+func (o *PolicyManager) getActive(policyKey policy.PolicyKey) (*policy.Policy, error) {
 	p, err := o.Store.GetActive(policyKey)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := o.verifyPolicy(p); err != nil {
+		return nil, err
+	}
+
 	return p, nil
 }
+
+// getBound reads the policy versions the bindings are bound to from the
+// store, and verifies their signatures.
+func (o *PolicyManager) getBound(
+	policyKey policy.PolicyKey,
+	bindings []*policy.Binding,
+) (map[uuid.UUID]*policy.Policy, error) {
+	bound := make(map[uuid.UUID]*policy.Policy, len(bindings))
+
+	for _, binding := range bindings {
+		if _, ok := bound[binding.PolicyUUID]; ok {
+			continue
+		}
+
+		p, err := o.Store.GetPolicy(policyKey, binding.PolicyUUID)
+		if err != nil {
+			return nil, fmt.Errorf("binding %q: %w", binding.ID.String(), err)
+		}
+
+		if err := o.verifyPolicy(p); err != nil {
+			return nil, fmt.Errorf("binding %q: %w", binding.ID.String(), err)
+		}
+
+		bound[binding.PolicyUUID] = p
+	}
+
+	return bound, nil
+}
+
+func (o *PolicyManager) verifyPolicy(p *policy.Policy) error {
+	if err := o.Verifier.Verify(p); err != nil {
+		o.logger.Errorw("refusing policy", "policy-id", p.StoreKey,
+			"policy-uuid", p.UUID, "error", err)
+		return fmt.Errorf("policy %s (%s): %w", p.StoreKey, p.UUID, err)
+	}
+
+	return nil
+}
@@ -4,10 +4,18 @@ package policymanager
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -21,10 +29,13 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
-func TestPolicyMgr_getPolicy_not_found(t *testing.T) {
+func TestPolicyMgr_getLayer_not_found(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	store := mock_deps.NewMockIKVStore(ctrl)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@bindings")).
+		Return(nil, kvstore.ErrKeyNotFound)
 	store.EXPECT().
 		Get(gomock.Eq("0:TPM_ENACTTRUST:opa")).
 		Return(nil, kvstore.ErrKeyNotFound)
@@ -51,18 +62,26 @@ func TestPolicyMgr_getPolicy_not_found(t *testing.T) {
 	polKey := pm.getPolicyKey(appraisal)
 	assert.Equal(t, "0:TPM_ENACTTRUST:opa", polKey.String())
 
-	pol, err := pm.getPolicy(polKey)
-	assert.Nil(t, pol)
-	assert.ErrorIs(t, err, policy.ErrNoPolicy)
+	layer, err := pm.getLayer(polKey)
+	require.NoError(t, err)
+	assert.Nil(t, layer.active)
+	assert.Empty(t, layer.bindings)
+	assert.Nil(t, layer.data)
 }
 
-func TestPolicyMgr_getPolicy_OK(t *testing.T) {
+func TestPolicyMgr_getLayer_OK(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	store := mock_deps.NewMockIKVStore(ctrl)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@bindings")).
+		Return(nil, kvstore.ErrKeyNotFound)
 	store.EXPECT().
 		Get(gomock.Eq("0:TPM_ENACTTRUST:opa")).
 		Return([]string{`{"uuid": "7df7714e-aa04-4638-bcbf-434b1dd720f1", "active": true}`}, nil)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@data")).
+		Return(nil, kvstore.ErrKeyNotFound)
 
 	agent := mock_deps.NewMockIAgent(ctrl)
 	agent.EXPECT().GetBackendName().Return("opa")
@@ -80,13 +99,16 @@ func TestPolicyMgr_getPolicy_OK(t *testing.T) {
 		},
 	}
 
-	pm := &PolicyManager{Store: &policy.Store{KVStore: store}, Agent: agent}
+	pm := &PolicyManager{Store: &policy.Store{KVStore: store}, Agent: agent,
+		logger: log.Named("test")}
 
 	polKey := pm.getPolicyKey(appraisal)
 	assert.Equal(t, "0:TPM_ENACTTRUST:opa", polKey.String())
 
-	_, err = pm.getPolicy(polKey)
+	layer, err := pm.getLayer(polKey)
 	require.NoError(t, err)
+	require.NotNil(t, layer.active)
+	assert.Equal(t, "7df7714e-aa04-4638-bcbf-434b1dd720f1", layer.active.UUID.String())
 }
 
 func TestPolicyMgr_New_policyAgent_OK(t *testing.T) {
@@ -119,6 +141,18 @@ func TestPolicyMgr_Evaluate_OK(t *testing.T) {
 	store.EXPECT().
 		Get(gomock.Eq("0:TPM_ENACTTRUST:opa")).
 		Return([]string{`{"uuid": "7df7714e-aa04-4638-bcbf-434b1dd720f1", "active": true}`}, nil)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@bindings")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@data")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("::opa")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("0::opa")).
+		Return(nil, kvstore.ErrKeyNotFound)
 
 	ec := &proto.EvidenceContext{
 		TenantId:       "0",
@@ -129,6 +163,9 @@ func TestPolicyMgr_Evaluate_OK(t *testing.T) {
 	endorsements := []string{"h0KPxSKAPTEGXnvOPPA/5HUJZjHl4Hu9eg/eYMTPJcc="}
 	ar := ear.NewAttestationResult("test", "test", "test")
 	ap := &appraisal.Appraisal{EvidenceContext: ec, Result: ar, Scheme: "TPM_ENACTTRUST"}
+	ap.History = []map[string]interface{}{
+		{"time": "2023-10-01T12:00:00Z", "evidence": map[string]interface{}{"firmware": 7}},
+	}
 
 	polID := "policy:TPM_ENACTTRUST"
 	tier := ear.TrustTierAffirming
@@ -142,6 +179,8 @@ func TestPolicyMgr_Evaluate_OK(t *testing.T) {
 			gomock.Any(),
 			"test",
 			gomock.Any(),
+			gomock.Nil(),
+			ap.History,
 			gomock.Any(),
 			ar.Submods["test"],
 			ec,
@@ -165,6 +204,18 @@ func TestPolicyMgr_Evaluate_NOK(t *testing.T) {
 	store.EXPECT().
 		Get(gomock.Eq("0:TPM_ENACTTRUST:opa")).
 		Return([]string{`{"uuid": "7df7714e-aa04-4638-bcbf-434b1dd720f1", "active": true}`}, nil)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@bindings")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@data")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("::opa")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("0::opa")).
+		Return(nil, kvstore.ErrKeyNotFound)
 
 	ec := &proto.EvidenceContext{
 		TenantId:       "0",
@@ -178,7 +229,7 @@ func TestPolicyMgr_Evaluate_NOK(t *testing.T) {
 	expectedErr := errors.New("could not evaluate policy: policy returned bad update")
 	agent := mock_deps.NewMockIAgent(ctrl)
 	agent.EXPECT().GetBackendName().Return("opa")
-	agent.EXPECT().Evaluate(context.TODO(), gomock.Any(), "test", gomock.Any(), gomock.Any(), ar.Submods["test"], ec, endorsements).Return(nil, expectedErr)
+	agent.EXPECT().Evaluate(context.TODO(), gomock.Any(), "test", gomock.Any(), gomock.Nil(), gomock.Nil(), gomock.Any(), ar.Submods["test"], ec, endorsements).Return(nil, expectedErr)
 	pm := &PolicyManager{
 		Store:  &policy.Store{KVStore: store, Logger: log.Named("store")},
 		Agent:  agent,
@@ -188,3 +239,510 @@ func TestPolicyMgr_Evaluate_NOK(t *testing.T) {
 	assert.ErrorIs(t, err, expectedErr)
 
 }
+
+func TestPolicyMgr_EvaluateFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	store := mock_deps.NewMockIKVStore(ctrl)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:opa")).
+		Return([]string{`{"uuid": "7df7714e-aa04-4638-bcbf-434b1dd720f1", "active": true}`}, nil)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@bindings")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("0:TPM_ENACTTRUST:@data")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("::opa")).
+		Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().
+		Get(gomock.Eq("0::opa")).
+		Return(nil, kvstore.ErrKeyNotFound)
+
+	ap := appraisal.New("0", []byte("nonce"), "TPM_ENACTTRUST")
+	ap.SetAllClaims(ear.CryptoValidationFailedClaim)
+	ap.AddPolicyClaim("problem", "integrity validation failed")
+	ap.Result.UpdateStatusFromTrustVector()
+
+	failed := ap.Result.Submods["TPM_ENACTTRUST"]
+	failedTV := *failed.TrustVector
+
+	// the policy tries to overturn the failure, and replaces the claims
+	affirming := ear.TrustTierAffirming
+	evaluated := ear.Appraisal{
+		Status:            &affirming,
+		TrustVector:       &ear.TrustVector{Hardware: ear.GenuineHardwareClaim},
+		AppraisalPolicyID: failed.AppraisalPolicyID,
+	}
+	evaluated.VeraisonPolicyClaims = &map[string]interface{}{
+		"hint": "re-provision the device",
+	}
+
+	problem := errors.New("bad evidence: signature verification failed")
+
+	agent := mock_deps.NewMockIAgent(ctrl)
+	agent.EXPECT().GetBackendName().Return("opa")
+	agent.EXPECT().
+		Evaluate(
+			context.TODO(),
+			map[string]interface{}{
+				"nonce":   ap.Result.Nonce,
+				"problem": "bad evidence: signature verification failed",
+			},
+			"TPM_ENACTTRUST",
+			gomock.Any(),
+			gomock.Nil(),
+			gomock.Nil(),
+			"TPM_ENACTTRUST",
+			failed,
+			ap.EvidenceContext,
+			gomock.Nil(),
+		).
+		Return(&evaluated, nil)
+	pm := &PolicyManager{
+		Store:  &policy.Store{KVStore: store, Logger: log.Named("store")},
+		Agent:  agent,
+		logger: log.Named("manager"),
+	}
+
+	err := pm.EvaluateFailure(context.TODO(), "TPM_ENACTTRUST", ap, nil, problem)
+	require.NoError(t, err)
+
+	submod := ap.Result.Submods["TPM_ENACTTRUST"]
+	assert.Equal(t, ear.TrustTierContraindicated, *submod.Status)
+	assert.Equal(t, failedTV, *submod.TrustVector)
+	assert.Equal(t, map[string]interface{}{
+		"problem": "integrity validation failed",
+		"hint":    "re-provision the device",
+	}, *submod.VeraisonPolicyClaims)
+	assert.Equal(t,
+		"policy:TPM_ENACTTRUST/7df7714e-aa04-4638-bcbf-434b1dd720f1",
+		*submod.AppraisalPolicyID)
+}
+
+func TestPolicyMgr_Evaluate_binding(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	v := viper.New()
+	v.Set("backend", "memory")
+
+	store, err := policy.NewStore(v, log.Named("store"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	key := policy.PolicyKey{TenantId: "0", Scheme: "PSA_IOT", Name: "opa"}
+
+	active, err := store.Add(key, "active", "opa", "active rules")
+	require.NoError(t, err)
+	require.NoError(t, store.Activate(key, active.UUID))
+
+	bound, err := store.Update(key, "bound", "opa", "bound rules")
+	require.NoError(t, err)
+
+	binding, err := policy.NewBinding(bound.UUID)
+	require.NoError(t, err)
+	binding.MediaType = "application/psa-attestation-token"
+	binding.Attributes = map[string]interface{}{"psa-client-id": float64(1)}
+	require.NoError(t, store.AddBinding("0", "PSA_IOT", binding))
+
+	vectors := []struct {
+		Name      string
+		MediaType string
+		ClientID  float64
+		Expected  *policy.Policy
+	}{
+		{"binding matches", "application/psa-attestation-token", 1, bound},
+		{"other client ID", "application/psa-attestation-token", 2, active},
+		{"other media type", "application/eat-cwt", 1, active},
+	}
+
+	for _, vec := range vectors {
+		t.Run(vec.Name, func(t *testing.T) {
+			evStruct, err := structpb.NewStruct(map[string]interface{}{
+				"psa-client-id": vec.ClientID,
+			})
+			require.NoError(t, err)
+
+			ap := appraisal.New("0", []byte("nonce"), "PSA_IOT")
+			ap.MediaType = vec.MediaType
+			ap.EvidenceContext.Evidence = evStruct
+
+			agent := mock_deps.NewMockIAgent(ctrl)
+			agent.EXPECT().GetBackendName().Return("opa")
+			agent.EXPECT().
+				Evaluate(gomock.Any(), gomock.Any(), "PSA_IOT",
+					gomock.Any(), gomock.Any(), gomock.Any(), "PSA_IOT", gomock.Any(), gomock.Any(),
+					gomock.Any()).
+				DoAndReturn(func(
+					_ context.Context,
+					_ map[string]interface{},
+					_ string,
+					pol *policy.Policy,
+					_ map[string]interface{},
+					_ []map[string]interface{},
+					_ string,
+					submodAppraisal *ear.Appraisal,
+					_ *proto.EvidenceContext,
+					_ []string,
+				) (*ear.Appraisal, error) {
+					assert.Equal(t, vec.Expected.UUID, pol.UUID)
+					return submodAppraisal, nil
+				})
+
+			pm := &PolicyManager{Store: store, Agent: agent, logger: log.Named("manager")}
+
+			err = pm.Evaluate(context.TODO(), "PSA_IOT", ap, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, "policy:PSA_IOT/"+vec.Expected.UUID.String(),
+				*ap.Result.Submods["PSA_IOT"].AppraisalPolicyID)
+		})
+	}
+}
+
+func TestPolicyMgr_Evaluate_layers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	v := viper.New()
+	v.Set("backend", "memory")
+
+	store, err := policy.NewStore(v, log.Named("store"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	var policies []*policy.Policy
+	for _, key := range []policy.PolicyKey{
+		policy.BaselinePolicyKey("opa"),
+		policy.TenantPolicyKey("0", "opa"),
+		{TenantId: "0", Scheme: "PSA_IOT", Name: "opa"},
+	} {
+		pol, err := store.Add(key, key.String(), "opa", "rules")
+		require.NoError(t, err)
+		require.NoError(t, store.Activate(key, pol.UUID))
+
+		policies = append(policies, pol)
+	}
+
+	// a tenant-wide policy for another tenant does not apply
+	other, err := store.Add(policy.TenantPolicyKey("1", "opa"), "other", "opa", "rules")
+	require.NoError(t, err)
+	require.NoError(t, store.Activate(other.StoreKey, other.UUID))
+
+	// only the tenant-wide layer has a data document
+	tenantData := map[string]interface{}{"threshold": 1.0}
+	_, err = store.AddData("0", "", tenantData)
+	require.NoError(t, err)
+	layerData := []map[string]interface{}{nil, tenantData, nil}
+
+	ap := appraisal.New("0", []byte("nonce"), "PSA_IOT")
+	ap.EvidenceContext.Evidence, err = structpb.NewStruct(nil)
+	require.NoError(t, err)
+
+	statuses := []ear.TrustTier{
+		ear.TrustTierAffirming,
+		ear.TrustTierWarning,
+		ear.TrustTierContraindicated,
+	}
+
+	agent := mock_deps.NewMockIAgent(ctrl)
+	agent.EXPECT().GetBackendName().Return("opa")
+
+	var calls []*gomock.Call
+	for i := range policies {
+		i := i
+		calls = append(calls, agent.EXPECT().
+			Evaluate(gomock.Any(), gomock.Any(), "PSA_IOT",
+				gomock.Any(), gomock.Any(), gomock.Any(), "PSA_IOT", gomock.Any(), gomock.Any(),
+				gomock.Any()).
+			DoAndReturn(func(
+				_ context.Context,
+				_ map[string]interface{},
+				_ string,
+				pol *policy.Policy,
+				data map[string]interface{},
+				_ []map[string]interface{},
+				_ string,
+				submodAppraisal *ear.Appraisal,
+				_ *proto.EvidenceContext,
+				_ []string,
+			) (*ear.Appraisal, error) {
+				assert.Equal(t, policies[i].UUID, pol.UUID)
+				assert.Equal(t, layerData[i], data)
+
+				// each layer sees the result of the previous one
+				if i > 0 {
+					assert.Equal(t, statuses[i-1], *submodAppraisal.Status)
+				}
+
+				evaluated := *submodAppraisal
+				evaluated.Status = &statuses[i]
+
+				return &evaluated, nil
+			}))
+	}
+	gomock.InOrder(calls...)
+
+	pm := &PolicyManager{Store: store, Agent: agent, logger: log.Named("manager")}
+
+	err = pm.Evaluate(context.TODO(), "PSA_IOT", ap, nil)
+	require.NoError(t, err)
+
+	submod := ap.Result.Submods["PSA_IOT"]
+	assert.Equal(t, ear.TrustTierContraindicated, *submod.Status)
+	assert.Equal(t,
+		"policy:PSA_IOT/"+policies[0].UUID.String()+"/"+
+			policies[1].UUID.String()+"/"+policies[2].UUID.String(),
+		*submod.AppraisalPolicyID)
+}
+
+func TestPolicyMgr_New_activePolicyTTL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	store := mock_deps.NewMockIKVStore(ctrl)
+	v := viper.New()
+	v.Set("backend", "opa")
+
+	pm, err := New(v, &policy.Store{KVStore: store}, log.Named("test"))
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, pm.ActivePolicyTTL)
+
+	v.Set("active-policy-ttl", "1m")
+	pm, err = New(v, &policy.Store{KVStore: store}, log.Named("test"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, pm.ActivePolicyTTL)
+
+	v.Set("active-policy-ttl", "soon")
+	_, err = New(v, &policy.Store{KVStore: store}, log.Named("test"))
+	assert.ErrorContains(t, err, "active-policy-ttl")
+}
+
+func TestPolicyMgr_getLayer_cache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	v := viper.New()
+	v.Set("backend", "memory")
+
+	store, err := policy.NewStore(v, log.Named("store"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	key := policy.PolicyKey{TenantId: "0", Scheme: "PSA_IOT", Name: "opa"}
+
+	first, err := store.Add(key, "first", "opa", "rules")
+	require.NoError(t, err)
+	require.NoError(t, store.Activate(key, first.UUID))
+
+	second, err := store.Update(key, "second", "opa", "rules")
+	require.NoError(t, err)
+
+	_, err = store.AddData(key.TenantId, key.Scheme, map[string]interface{}{"version": 1})
+	require.NoError(t, err)
+
+	agent := mock_deps.NewMockIAgent(ctrl)
+	pm := &PolicyManager{
+		Store:           store,
+		Agent:           agent,
+		ActivePolicyTTL: time.Hour,
+		logger:          log.Named("manager"),
+	}
+
+	layer, err := pm.getLayer(key)
+	require.NoError(t, err)
+	assert.Equal(t, first.UUID, layer.active.UUID)
+	assert.Empty(t, layer.bindings)
+	assert.EqualValues(t, 1, layer.data["version"])
+
+	// changes are not picked up until the cached layer expires...
+	require.NoError(t, store.Activate(key, second.UUID))
+
+	binding, err := policy.NewBinding(first.UUID)
+	require.NoError(t, err)
+	require.NoError(t, store.AddBinding(key.TenantId, key.Scheme, binding))
+
+	_, err = store.AddData(key.TenantId, key.Scheme, map[string]interface{}{"version": 2})
+	require.NoError(t, err)
+
+	layer, err = pm.getLayer(key)
+	require.NoError(t, err)
+	assert.Equal(t, first.UUID, layer.active.UUID)
+	assert.Empty(t, layer.bindings)
+	assert.EqualValues(t, 1, layer.data["version"])
+
+	expire := func() {
+		cached := pm.layers[key.String()]
+		cached.expires = time.Time{}
+		pm.layers[key.String()] = cached
+	}
+
+	// ...at which point the policies no longer applied are invalidated
+	// (but not the previously active one, which is now bound)
+	expire()
+
+	layer, err = pm.getLayer(key)
+	require.NoError(t, err)
+	assert.Equal(t, second.UUID, layer.active.UUID)
+	require.Len(t, layer.bindings, 1)
+	assert.Equal(t, binding.ID, layer.bindings[0].ID)
+	assert.EqualValues(t, 2, layer.data["version"])
+
+	// the bound policy versions are cached along with the bindings
+	require.Len(t, layer.bound, 1)
+	assert.Equal(t, first.UUID, layer.bound[first.UUID].UUID)
+	assert.Equal(t, first.Rules, layer.bound[first.UUID].Rules)
+
+	require.NoError(t, store.DeactivateAll(key))
+	require.NoError(t, store.DelBindings(key.TenantId, key.Scheme))
+
+	expire()
+	agent.EXPECT().Invalidate(first.UUID)
+	agent.EXPECT().Invalidate(second.UUID)
+
+	layer, err = pm.getLayer(key)
+	require.NoError(t, err)
+	assert.Nil(t, layer.active)
+	assert.Empty(t, layer.bindings)
+	assert.Nil(t, layer.data)
+
+	// the absence of an active policy is cached too
+	require.NoError(t, store.Activate(key, first.UUID))
+
+	layer, err = pm.getLayer(key)
+	require.NoError(t, err)
+	assert.Nil(t, layer.active)
+}
+
+func TestPolicyMgr_getLayer_signature(t *testing.T) {
+	v := viper.New()
+	v.Set("backend", "memory")
+
+	store, err := policy.NewStore(v, log.Named("store"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	author, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pub, err := jwk.FromRaw(&author.PublicKey)
+	require.NoError(t, err)
+
+	keys := jwk.NewSet()
+	require.NoError(t, keys.AddKey(pub))
+
+	key := policy.PolicyKey{TenantId: "0", Scheme: "PSA_IOT", Name: "opa"}
+	rules := "package policy\n\nhardware = GENUINE_HW\n"
+
+	signature, err := jws.Sign(nil, jws.WithKey(jwa.ES256, author),
+		jws.WithDetachedPayload([]byte(rules)))
+	require.NoError(t, err)
+
+	signed, err := policy.NewPolicy(key, "signed", "opa", rules)
+	require.NoError(t, err)
+	signed.Signature = string(signature)
+	require.NoError(t, store.AddVersion(signed))
+
+	// the rules of a stored policy altered after it has been signed
+	tampered, err := policy.NewPolicy(key, "tampered", "opa", "package policy\n")
+	require.NoError(t, err)
+	tampered.Signature = string(signature)
+	require.NoError(t, store.AddVersion(tampered))
+
+	unsigned, err := store.Update(key, "unsigned", "opa", rules)
+	require.NoError(t, err)
+
+	pm := &PolicyManager{
+		Store:    store,
+		Verifier: &policy.SignatureVerifier{Keys: keys, Enforce: true},
+		logger:   log.Named("manager"),
+	}
+
+	require.NoError(t, store.Activate(key, signed.UUID))
+
+	layer, err := pm.getLayer(key)
+	require.NoError(t, err)
+	assert.Equal(t, signed.UUID, layer.active.UUID)
+
+	require.NoError(t, store.Activate(key, tampered.UUID))
+
+	_, err = pm.getLayer(key)
+	assert.ErrorIs(t, err, policy.ErrBadSignature)
+
+	require.NoError(t, store.Activate(key, unsigned.UUID))
+
+	_, err = pm.getLayer(key)
+	assert.ErrorIs(t, err, policy.ErrUnsignedPolicy)
+
+	// the policies selected by bindings are verified too
+	require.NoError(t, store.Activate(key, signed.UUID))
+
+	binding, err := policy.NewBinding(tampered.UUID)
+	require.NoError(t, err)
+	require.NoError(t, store.AddBinding(key.TenantId, key.Scheme, binding))
+
+	_, err = pm.getLayer(key)
+	assert.ErrorIs(t, err, policy.ErrBadSignature)
+
+	require.NoError(t, store.DelBindings(key.TenantId, key.Scheme))
+
+	// unless signatures are enforced, only signed policies are verified
+	pm.Verifier.Enforce = false
+	require.NoError(t, store.Activate(key, unsigned.UUID))
+
+	layer, err = pm.getLayer(key)
+	require.NoError(t, err)
+	assert.Equal(t, unsigned.UUID, layer.active.UUID)
+}
+
+func TestPolicyMgr_getLayer_concurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	slow := policy.PolicyKey{TenantId: "0", Scheme: "PSA_IOT", Name: "opa"}
+	fast := policy.PolicyKey{TenantId: "0", Scheme: "TPM_ENACTTRUST", Name: "opa"}
+
+	reading := make(chan struct{})
+	release := make(chan struct{})
+
+	// the layer for the slow key is read once, however many times it is
+	// requested while being read
+	store := mock_deps.NewMockIKVStore(ctrl)
+	store.EXPECT().
+		Get("0:PSA_IOT:@bindings").
+		DoAndReturn(func(string) ([]string, error) {
+			close(reading)
+			<-release
+			return nil, kvstore.ErrKeyNotFound
+		})
+	store.EXPECT().Get("0:PSA_IOT:opa").Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().Get("0:TPM_ENACTTRUST:@bindings").Return(nil, kvstore.ErrKeyNotFound)
+	store.EXPECT().Get("0:TPM_ENACTTRUST:opa").Return(nil, kvstore.ErrKeyNotFound)
+
+	pm := &PolicyManager{
+		Store:           &policy.Store{KVStore: store, Logger: log.Named("store")},
+		ActivePolicyTTL: time.Hour,
+		logger:          log.Named("manager"),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pm.getLayer(slow)
+			assert.NoError(t, err)
+		}()
+	}
+
+	<-reading
+
+	// reading a layer does not prevent getting the layers of other keys
+	_, err := pm.getLayer(fast)
+	require.NoError(t, err)
+
+	close(release)
+	wg.Wait()
+
+	_, err = pm.getLayer(slow)
+	require.NoError(t, err)
+}
@@ -0,0 +1,251 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package storearchive implements the portable archive format used to export
+// the contents of the trust anchor and endorsement stores from a deployment,
+// and to import them into another.
+package storearchive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/veraison/services/handler"
+	"github.com/veraison/services/kvstore"
+)
+
+const (
+	MediaType = "application/vnd.veraison.store-archive+json"
+
+	// FormatVersion is the version of the archive format produced by this
+	// package.
+	FormatVersion = 1
+
+	// TrustAnchorStore and EndorsementStore identify the store an entry
+	// was exported from (and is to be imported into).
+	TrustAnchorStore = "ta-store"
+	EndorsementStore = "en-store"
+
+	digestPrefix = "sha-256:"
+)
+
+// Archive is a portable representation of (a subset of) the contents of the
+// trust anchor and endorsement stores.
+type Archive struct {
+	Version    int        `json:"version"`
+	Provenance Provenance `json:"provenance"`
+	Validity   Validity   `json:"validity"`
+	Entries    []Entry    `json:"entries"`
+	// Digest is the SHA-256 of the JSON encoding of the archive, with an
+	// empty digest, used to detect corrupted, truncated or modified archives.
+	Digest string `json:"digest"`
+}
+
+// Provenance describes where, when and how an archive was produced.
+type Provenance struct {
+	// Exporter identifies the software that produced the archive.
+	Exporter string `json:"exporter"`
+	// ExporterVersion is the version of the exporter.
+	ExporterVersion string `json:"exporter-version"`
+	// Source optionally identifies the deployment the archive was exported
+	// from.
+	Source  string    `json:"source,omitempty"`
+	Created time.Time `json:"created"`
+	// Filter is the filter that was applied to the stores' contents.
+	Filter Filter `json:"filter"`
+}
+
+// Validity is the period during which an archive may be imported.
+type Validity struct {
+	NotBefore time.Time `json:"not-before"`
+	// NotAfter is not set if the archive does not expire.
+	NotAfter *time.Time `json:"not-after,omitempty"`
+}
+
+// Filter selects the store contents to export. Zero-valued fields match
+// everything.
+type Filter struct {
+	Schemes []string `json:"schemes,omitempty"`
+	Tenant  string   `json:"tenant,omitempty"`
+}
+
+// Match returns true if the filter matches an endorsement of the specified
+// tenant.
+func (o Filter) Match(tenant string, endorsement *handler.Endorsement) bool {
+	if o.Tenant != "" && o.Tenant != tenant {
+		return false
+	}
+
+	if len(o.Schemes) == 0 {
+		return true
+	}
+
+	for _, scheme := range o.Schemes {
+		if scheme == endorsement.Scheme {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Entry is an endorsement exported from one of the stores, along with the
+// keys it was stored under. The keys are informational: on import, they are
+// synthesized again from the endorsement.
+type Entry struct {
+	Store       string              `json:"store"`
+	Tenant      string              `json:"tenant"`
+	Keys        []string            `json:"keys"`
+	Endorsement handler.Endorsement `json:"endorsement"`
+}
+
+// Collect returns the entries of the store matching the filter, one per
+// distinct endorsement (of a tenant), along with the number of values that
+// were not exported because they could not be parsed as endorsements.
+func Collect(storeName string, store kvstore.IKVStore, filter Filter) ([]Entry, int, error) {
+	keys, err := store.GetKeys()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: could not get keys: %w", storeName, err)
+	}
+	sort.Strings(keys)
+
+	var (
+		entries    []Entry
+		unparsable int
+	)
+
+	// the same endorsement is typically stored under multiple keys
+	index := make(map[[2]string]int)
+
+	for _, key := range keys {
+		tenant := kvstore.KeyTenant(key)
+
+		vals, err := store.Get(key)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: could not get %q: %w", storeName, key, err)
+		}
+
+		for _, val := range vals {
+			var endorsement handler.Endorsement
+
+			if err := json.Unmarshal([]byte(val), &endorsement); err != nil || endorsement.Scheme == "" {
+				unparsable++
+				continue
+			}
+
+			if !filter.Match(tenant, &endorsement) {
+				continue
+			}
+
+			id := [2]string{tenant, val}
+			if i, ok := index[id]; ok {
+				entries[i].Keys = append(entries[i].Keys, key)
+				continue
+			}
+
+			index[id] = len(entries)
+			entries = append(entries, Entry{
+				Store:       storeName,
+				Tenant:      tenant,
+				Keys:        []string{key},
+				Endorsement: endorsement,
+			})
+		}
+	}
+
+	return entries, unparsable, nil
+}
+
+// New creates an archive of the provided entries. If validity is not zero,
+// the archive expires after that long.
+func New(entries []Entry, provenance Provenance, validity time.Duration) (*Archive, error) {
+	if entries == nil {
+		entries = []Entry{}
+	}
+
+	archive := Archive{
+		Version:    FormatVersion,
+		Provenance: provenance,
+		Validity:   Validity{NotBefore: provenance.Created},
+		Entries:    entries,
+	}
+
+	if validity != 0 {
+		notAfter := provenance.Created.Add(validity)
+		archive.Validity.NotAfter = &notAfter
+	}
+
+	digest, err := archive.digest()
+	if err != nil {
+		return nil, err
+	}
+	archive.Digest = digest
+
+	return &archive, nil
+}
+
+// Parse decodes an archive, and checks its integrity.
+func Parse(data []byte) (*Archive, error) {
+	var archive Archive
+
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	if archive.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported archive version %d (expected %d)",
+			archive.Version, FormatVersion)
+	}
+
+	digest, err := archive.digest()
+	if err != nil {
+		return nil, err
+	}
+
+	if digest != archive.Digest {
+		return nil, errors.New("archive digest mismatch")
+	}
+
+	return &archive, nil
+}
+
+// CheckValidity returns an error if the archive is not valid at the
+// specified time.
+func (o Archive) CheckValidity(now time.Time) error {
+	if now.Before(o.Validity.NotBefore) {
+		return fmt.Errorf("archive is not valid before %s",
+			o.Validity.NotBefore.Format(time.RFC3339))
+	}
+
+	if o.Validity.NotAfter != nil && now.After(*o.Validity.NotAfter) {
+		return fmt.Errorf("archive expired at %s",
+			o.Validity.NotAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Marshal returns the JSON encoding of the archive.
+func (o Archive) Marshal() ([]byte, error) {
+	return json.MarshalIndent(o, "", "  ")
+}
+
+// digest returns the digest of the archive, which covers all of its fields
+// but the digest itself.
+func (o Archive) digest() (string, error) {
+	o.Digest = ""
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return "", fmt.Errorf("could not encode archive: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return digestPrefix + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Contributors to the Veraison project.
+// SPDX-License-Identifier: Apache-2.0
+package storearchive
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/services/handler"
+	"github.com/veraison/services/kvstore"
+	"github.com/veraison/services/log"
+)
+
+var (
+	testPSAEndorsement = handler.Endorsement{
+		Scheme:     "PSA_IOT",
+		Type:       handler.EndorsementType_REFERENCE_VALUE,
+		SubType:    "PSA_IOT.sw-component",
+		Attributes: json.RawMessage(`{"PSA_IOT.measurement-type":"BL"}`),
+	}
+	testCCAEndorsement = handler.Endorsement{
+		Scheme:     "CCA_SSD_PLATFORM",
+		Type:       handler.EndorsementType_REFERENCE_VALUE,
+		SubType:    "CCA_SSD_PLATFORM.sw-component",
+		Attributes: json.RawMessage(`{"CCA_SSD_PLATFORM.measurement-type":"BL"}`),
+	}
+	testCreated = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func newTestStore(t *testing.T, data map[string][]interface{}) kvstore.IKVStore {
+	store := &kvstore.Memory{}
+	require.NoError(t, store.Init(nil, log.Named("test")))
+
+	for key, vals := range data {
+		for _, val := range vals {
+			s, ok := val.(string)
+			if !ok {
+				b, err := json.Marshal(val)
+				require.NoError(t, err)
+				s = string(b)
+			}
+			require.NoError(t, store.Add(key, s))
+		}
+	}
+
+	return store
+}
+
+func TestCollect(t *testing.T) {
+	store := newTestStore(t, map[string][]interface{}{
+		"PSA_IOT://0/a/b":          {testPSAEndorsement},
+		"PSA_IOT://0/a/c":          {testPSAEndorsement},
+		"PSA_IOT://1/a/b":          {testPSAEndorsement},
+		"CCA_SSD_PLATFORM://0/a/b": {testCCAEndorsement, `{"not":"an endorsement"}`},
+	})
+
+	entries, unparsable, err := Collect(EndorsementStore, store, Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, unparsable)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "0", entries[0].Tenant)
+	assert.Equal(t, []string{"CCA_SSD_PLATFORM://0/a/b"}, entries[0].Keys)
+	assert.Equal(t, "CCA_SSD_PLATFORM", entries[0].Endorsement.Scheme)
+
+	assert.Equal(t, "0", entries[1].Tenant)
+	assert.Equal(t, []string{"PSA_IOT://0/a/b", "PSA_IOT://0/a/c"}, entries[1].Keys)
+	assert.Equal(t, EndorsementStore, entries[1].Store)
+
+	assert.Equal(t, "1", entries[2].Tenant)
+	assert.Equal(t, []string{"PSA_IOT://1/a/b"}, entries[2].Keys)
+
+	entries, _, err = Collect(EndorsementStore, store, Filter{Schemes: []string{"PSA_IOT"}, Tenant: "0"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []string{"PSA_IOT://0/a/b", "PSA_IOT://0/a/c"}, entries[0].Keys)
+}
+
+func TestArchive_round_trip(t *testing.T) {
+	store := newTestStore(t, map[string][]interface{}{
+		"PSA_IOT://0/a/b": {testPSAEndorsement},
+	})
+
+	entries, _, err := Collect(TrustAnchorStore, store, Filter{})
+	require.NoError(t, err)
+
+	provenance := Provenance{
+		Exporter:        "test",
+		ExporterVersion: "1.0",
+		Created:         testCreated,
+	}
+
+	archive, err := New(entries, provenance, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, archive.Version)
+	assert.Equal(t, testCreated, archive.Validity.NotBefore)
+	assert.Equal(t, testCreated.Add(time.Hour), *archive.Validity.NotAfter)
+
+	data, err := archive.Marshal()
+	require.NoError(t, err)
+
+	parsed, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, archive.Digest, parsed.Digest)
+	assert.Equal(t, provenance, parsed.Provenance)
+	require.Len(t, parsed.Entries, 1)
+	assert.JSONEq(t, string(testPSAEndorsement.Attributes), string(parsed.Entries[0].Endorsement.Attributes))
+
+	assert.NoError(t, parsed.CheckValidity(testCreated.Add(time.Minute)))
+	assert.EqualError(t, parsed.CheckValidity(testCreated.Add(-time.Minute)),
+		"archive is not valid before 2023-05-01T12:00:00Z")
+	assert.EqualError(t, parsed.CheckValidity(testCreated.Add(2*time.Hour)),
+		"archive expired at 2023-05-01T13:00:00Z")
+}
+
+func TestParse_nok(t *testing.T) {
+	archive, err := New(nil, Provenance{Created: testCreated}, 0)
+	require.NoError(t, err)
+	assert.Nil(t, archive.Validity.NotAfter)
+
+	archive.Entries = append(archive.Entries, Entry{Store: EndorsementStore})
+	data, err := archive.Marshal()
+	require.NoError(t, err)
+
+	_, err = Parse(data)
+	assert.EqualError(t, err, "archive digest mismatch")
+
+	archive.Version = 2
+	data, err = archive.Marshal()
+	require.NoError(t, err)
+
+	_, err = Parse(data)
+	assert.EqualError(t, err, "unsupported archive version 2 (expected 1)")
+
+	_, err = Parse([]byte("{"))
+	assert.ErrorContains(t, err, "invalid archive")
+}
+
+func TestParse_tampered_validity(t *testing.T) {
+	archive, err := New(nil, Provenance{Created: testCreated}, time.Hour)
+	require.NoError(t, err)
+
+	data, err := archive.Marshal()
+	require.NoError(t, err)
+
+	_, err = Parse(data)
+	require.NoError(t, err)
+
+	// extend the validity of the archive
+	notAfter := testCreated.Add(24 * time.Hour)
+	archive.Validity.NotAfter = &notAfter
+	data, err = archive.Marshal()
+	require.NoError(t, err)
+
+	_, err = Parse(data)
+	assert.EqualError(t, err, "archive digest mismatch")
+
+	// make the archive never expire
+	archive.Validity.NotAfter = nil
+	data, err = archive.Marshal()
+	require.NoError(t, err)
+
+	_, err = Parse(data)
+	assert.EqualError(t, err, "archive digest mismatch")
+}
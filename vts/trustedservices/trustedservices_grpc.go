@@ -7,8 +7,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -22,10 +24,14 @@ import (
 	handlermod "github.com/veraison/services/handler"
 	"github.com/veraison/services/kvstore"
 	"github.com/veraison/services/plugin"
+	"github.com/veraison/services/policy"
 	"github.com/veraison/services/proto"
+	"github.com/veraison/services/scheme/common"
 	"github.com/veraison/services/vts/appraisal"
 	"github.com/veraison/services/vts/earsigner"
+	"github.com/veraison/services/vts/history"
 	"github.com/veraison/services/vts/policymanager"
+	"github.com/veraison/services/vts/storearchive"
 )
 
 // XXX
@@ -45,6 +51,15 @@ const DummyTenantID = "0"
 type GRPCConfig struct {
 	ServerAddress string `mapstructure:"server-addr" valid:"dialstring"`
 	ListenAddress string `mapstructure:"listen-addr" valid:"dialstring" config:"zerodefault"`
+	// CheckCollectionBinding enables checking that the members of an
+	// evidence collection are bound together.
+	CheckCollectionBinding bool `mapstructure:"check-collection-binding" config:"zerodefault"`
+	// EvaluatePolicyOnBadEvidence enables evaluating the policies for
+	// appraisals that have failed because of bad evidence. This is the only
+	// kind of failure that yields an attestation result: other failures
+	// (e.g. an unsupported media type, or an unavailable store) are returned
+	// as errors, so the policies are not evaluated for them.
+	EvaluatePolicyOnBadEvidence bool `mapstructure:"evaluate-policy-on-bad-evidence" config:"zerodefault"`
 }
 
 func NewGRPCConfig() *GRPCConfig {
@@ -59,7 +74,20 @@ type GRPC struct {
 	EvPluginManager  plugin.IManager[handler.IEvidenceHandler]
 	EndPluginManager plugin.IManager[handler.IEndorsementHandler]
 	PolicyManager    *policymanager.PolicyManager
-	EarSigner        earsigner.IEarSigner
+	// HistoryStore keeps the attestation history of devices, which is
+	// made available to policies. History is not kept if it is nil.
+	HistoryStore *history.Store
+	// EarSigners produce the supported attestation result formats. The
+	// first one is used by default.
+	EarSigners []earsigner.IEarSigner
+	// CheckCollectionBinding enables the cross-binding check on the
+	// members of evidence collections.
+	CheckCollectionBinding bool
+	// EvaluatePolicyOnBadEvidence enables evaluating the policies even when
+	// the appraisal fails because of bad evidence (e.g. its integrity could
+	// not be validated), so that they may add claims to the result. Other
+	// failures do not yield a result (see GRPCConfig).
+	EvaluatePolicyOnBadEvidence bool
 
 	Server *grpc.Server
 	Socket net.Listener
@@ -74,7 +102,8 @@ func NewGRPC(
 	evpluginManager plugin.IManager[handler.IEvidenceHandler],
 	endpluginManager plugin.IManager[handler.IEndorsementHandler],
 	policyManager *policymanager.PolicyManager,
-	earSigner earsigner.IEarSigner,
+	historyStore *history.Store,
+	earSigners []earsigner.IEarSigner,
 	logger *zap.SugaredLogger,
 ) ITrustedServices {
 	return &GRPC{
@@ -83,7 +112,8 @@ func NewGRPC(
 		EvPluginManager:  evpluginManager,
 		EndPluginManager: endpluginManager,
 		PolicyManager:    policyManager,
-		EarSigner:        earSigner,
+		HistoryStore:     historyStore,
+		EarSigners:       earSigners,
 		logger:           logger,
 	}
 }
@@ -109,6 +139,8 @@ func (o *GRPC) Init(v *viper.Viper, evm plugin.IManager[handler.IEvidenceHandler
 
 	o.EvPluginManager = evm
 	o.EndPluginManager = endm
+	o.CheckCollectionBinding = cfg.CheckCollectionBinding
+	o.EvaluatePolicyOnBadEvidence = cfg.EvaluatePolicyOnBadEvidence
 
 	if cfg.ListenAddress != "" {
 		o.ServerAddress = cfg.ListenAddress
@@ -155,8 +187,16 @@ func (o *GRPC) Close() error {
 		o.logger.Errorf("endorsement store closure failed: %v", err)
 	}
 
-	if err := o.EarSigner.Close(); err != nil {
-		o.logger.Errorf("EAR signer closure failed: %v", err)
+	if o.HistoryStore != nil {
+		if err := o.HistoryStore.Close(); err != nil {
+			o.logger.Errorf("history store closure failed: %v", err)
+		}
+	}
+
+	for _, signer := range o.EarSigners {
+		if err := signer.Close(); err != nil {
+			o.logger.Errorf("EAR signer closure failed: %v", err)
+		}
 	}
 
 	return nil
@@ -182,137 +222,503 @@ func (o *GRPC) GetServiceState(context.Context, *emptypb.Empty) (*proto.ServiceS
 func (o *GRPC) SubmitEndorsements(ctx context.Context, req *proto.SubmitEndorsementsRequest) (*proto.SubmitEndorsementsResponse, error) {
 	o.logger.Debugw("SubmitEndorsements", "media-type", req.MediaType)
 
-	handlerPlugin, err := o.EndPluginManager.LookupByMediaType(req.MediaType)
+	handlerPlugin, err := o.lookupEndorsementHandler(req.MediaType, req.Data)
 	if err != nil {
 		return nil, err
 	}
 
 	rsp, err := handlerPlugin.Decode(req.Data)
 	if err != nil {
-		return submitEndorsementErrorResponse(err), nil
+		return submitEndorsementErrorResponse(err, nil), nil
+	}
+
+	outcomes, err := o.storeEndorsements(ctx, rsp)
+	if err != nil {
+		return submitEndorsementErrorResponse(err, outcomes), nil
+	}
+
+	return submitEndorsementSuccessResponse(outcomes), nil
+}
+
+// DryRunEndorsements decodes the endorsements and synthesizes their keys, as
+// SubmitEndorsements would, but does not store anything. It is a separate RPC
+// (rather than a flag of SubmitEndorsements), so that a dry run is never
+// mistaken for a submission.
+func (o *GRPC) DryRunEndorsements(ctx context.Context, req *proto.SubmitEndorsementsRequest) (*proto.SubmitEndorsementsResponse, error) {
+	o.logger.Debugw("DryRunEndorsements", "media-type", req.MediaType)
+
+	handlerPlugin, err := o.lookupEndorsementHandler(req.MediaType, req.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := handlerPlugin.Decode(req.Data)
+	if err != nil {
+		return submitEndorsementErrorResponse(err, nil), nil
+	}
+
+	outcomes, err := o.synthEndorsementKeys(rsp)
+	if err != nil {
+		return submitEndorsementErrorResponse(err, outcomes), nil
+	}
+
+	return submitEndorsementSuccessResponse(outcomes), nil
+}
+
+// lookupEndorsementHandler returns the endorsement handler for the media type.
+// Unsigned CoRIMs submitted without a profile parameter are routed to the
+// handler for the profile found in the CoRIM itself.
+func (o *GRPC) lookupEndorsementHandler(
+	mediaType string,
+	data []byte,
+) (handler.IEndorsementHandler, error) {
+	handlerPlugin, err := o.EndPluginManager.LookupByMediaType(mediaType)
+	if err == nil || !isUnprofiledCorimMediaType(mediaType) {
+		return handlerPlugin, err
+	}
+
+	profile, perr := common.GetUnsignedCorimProfile(data)
+	if perr != nil {
+		return nil, fmt.Errorf("%w (could not get CoRIM profile: %v)", err, perr)
 	}
-	if err := o.storeEndorsements(ctx, rsp); err != nil {
-		return submitEndorsementErrorResponse(err), nil
+
+	for _, mt := range profiledCorimMediaTypes(profile) {
+		if handlerPlugin, perr := o.EndPluginManager.LookupByMediaType(mt); perr == nil {
+			o.logger.Debugw("routed CoRIM by profile", "profile", profile, "media-type", mt)
+			return handlerPlugin, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w (no handler found for CoRIM profile %q)", err, profile)
+}
+
+// isUnprofiledCorimMediaType returns true if the media type is the unsigned
+// CoRIM one, without a profile parameter.
+func isUnprofiledCorimMediaType(mediaType string) bool {
+	mt, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+
+	_, ok := params["profile"]
+
+	return mt == common.UnsignedCorimMediaType && !ok
+}
+
+// profiledCorimMediaTypes returns the media types under which the handler for
+// unsigned CoRIMs of the specified profile may have been registered, i.e.,
+// with the profile parameter either as a token or as a quoted string.
+func profiledCorimMediaTypes(profile string) []string {
+	return []string{
+		common.UnsignedCorimMediaType + "; profile=" + profile,
+		common.UnsignedCorimMediaType + `; profile="` + profile + `"`,
 	}
-	return submitEndorsementSuccessResponse(), nil
 }
 
-func (o *GRPC) storeEndorsements(ctx context.Context, rsp *handler.EndorsementHandlerResponse) error {
-	for _, ta := range rsp.TrustAnchors {
+// storeEndorsements stores the trust anchors, reference values and endorsed
+// values in rsp, stopping at the first failure. An outcome is returned for
+// each of the endorsements, including those that were not processed.
+func (o *GRPC) storeEndorsements(
+	ctx context.Context,
+	rsp *handler.EndorsementHandlerResponse,
+) ([]*proto.EndorsementOutcome, error) {
+	var (
+		outcomes []*proto.EndorsementOutcome
+		failed   error
+	)
+
+	store := func(endorsement *handler.Endorsement, add addEndorsementFn, what string) {
+		outcome := &proto.EndorsementOutcome{
+			Scheme:  endorsement.Scheme,
+			Type:    endorsement.Type,
+			SubType: endorsement.SubType,
+		}
+		outcomes = append(outcomes, outcome)
+
+		if failed != nil {
+			return
+		}
 
-		err := o.addTrustAnchor(ctx, &ta)
+		keys, err := add(ctx, endorsement)
 		if err != nil {
-			return fmt.Errorf("store operation failed for trust anchor: %w", err)
+			failed = fmt.Errorf("store operation failed for %s: %w", what, err)
+			outcome.Status = &proto.Status{Result: false, ErrorDetail: err.Error()}
+			return
 		}
+
+		outcome.Keys = keys
+		outcome.Status = &proto.Status{Result: true}
 	}
 
-	for _, refVal := range rsp.ReferenceValues {
+	for i := range rsp.TrustAnchors {
+		store(&rsp.TrustAnchors[i], o.addTrustAnchor, "trust anchor")
+	}
+
+	for i := range rsp.ReferenceValues {
+		store(&rsp.ReferenceValues[i], o.addRefValues, "reference values")
+	}
+
+	// endorsed values are looked up alongside the reference values for the
+	// same environment, hence are stored under the same keys
+	for i := range rsp.EndorsedValues {
+		store(&rsp.EndorsedValues[i], o.addRefValues, "endorsed values")
+	}
+
+	return outcomes, failed
+}
+
+type addEndorsementFn func(context.Context, *handler.Endorsement) ([]string, error)
+
+// synthEndorsementKeys synthesizes the keys the trust anchors, reference
+// values and endorsed values in rsp would be stored under, without storing
+// them. Unlike storeEndorsements, all the endorsements are processed even if
+// some fail. The outcomes include the JSON-encoded attributes of the
+// endorsements.
+func (o *GRPC) synthEndorsementKeys(
+	rsp *handler.EndorsementHandlerResponse,
+) ([]*proto.EndorsementOutcome, error) {
+	var (
+		outcomes []*proto.EndorsementOutcome
+		failed   []string
+	)
+
+	synth := func(endorsement *handler.Endorsement, synthKeys synthKeysFn) {
+		outcome := &proto.EndorsementOutcome{
+			Scheme:     endorsement.Scheme,
+			Type:       endorsement.Type,
+			SubType:    endorsement.SubType,
+			Attributes: endorsement.Attributes,
+		}
+		outcomes = append(outcomes, outcome)
 
-		err := o.addRefValues(ctx, &refVal)
+		keys, err := synthKeys(DummyTenantID, endorsement)
 		if err != nil {
-			return fmt.Errorf("store operation failed for reference values: %w", err)
+			failed = append(failed, fmt.Sprintf("%s (%s): %v", endorsement.Type, endorsement.Scheme, err))
+			outcome.Status = &proto.Status{Result: false, ErrorDetail: err.Error()}
+			return
 		}
+
+		outcome.Keys = keys
+		outcome.Status = &proto.Status{Result: true}
 	}
 
-	return nil
+	for i := range rsp.TrustAnchors {
+		synth(&rsp.TrustAnchors[i], o.synthTrustAnchorKeys)
+	}
+
+	for i := range rsp.ReferenceValues {
+		synth(&rsp.ReferenceValues[i], o.synthRefValueKeys)
+	}
+
+	for i := range rsp.EndorsedValues {
+		synth(&rsp.EndorsedValues[i], o.synthRefValueKeys)
+	}
+
+	if len(failed) != 0 {
+		return outcomes, fmt.Errorf("key synthesis failed for %s", strings.Join(failed, "; "))
+	}
+
+	return outcomes, nil
+}
+
+type synthKeysFn func(string, *handler.Endorsement) ([]string, error)
+
+func (o *GRPC) synthRefValueKeys(tenantID string, refVal *handler.Endorsement) ([]string, error) {
+	handler, err := o.EvPluginManager.LookupByAttestationScheme(refVal.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler.SynthKeysFromRefValue(tenantID, refVal)
+}
+
+func (o *GRPC) synthTrustAnchorKeys(tenantID string, ta *handler.Endorsement) ([]string, error) {
+	handler, err := o.EvPluginManager.LookupByAttestationScheme(ta.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler.SynthKeysFromTrustAnchor(tenantID, ta)
+}
+
+func (o *GRPC) ExportEndorsements(
+	ctx context.Context,
+	req *proto.ExportEndorsementsRequest,
+) (*proto.StoreArchive, error) {
+	o.logger.Infow("export endorsements", "schemes", req.Schemes,
+		"tenant-id", req.TenantId, "source", req.Source)
+
+	var validity time.Duration
+	if req.Validity != nil {
+		if validity = req.Validity.AsDuration(); validity < 0 {
+			return nil, fmt.Errorf("invalid validity: %s", validity)
+		}
+	}
+
+	filter := storearchive.Filter{Schemes: req.Schemes, Tenant: req.TenantId}
+	stores := []struct {
+		name  string
+		store kvstore.IKVStore
+	}{
+		{storearchive.TrustAnchorStore, o.TaStore},
+		{storearchive.EndorsementStore, o.EnStore},
+	}
+
+	var entries []storearchive.Entry
+
+	for _, s := range stores {
+		storeEntries, unparsable, err := storearchive.Collect(s.name, s.store, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		if unparsable != 0 {
+			o.logger.Warnw("skipped values that are not endorsements",
+				"store", s.name, "count", unparsable)
+		}
+
+		entries = append(entries, storeEntries...)
+	}
+
+	provenance := storearchive.Provenance{
+		Exporter:        "veraison-vts",
+		ExporterVersion: config.Version,
+		Source:          req.Source,
+		Created:         time.Now().UTC().Truncate(time.Second),
+		Filter:          filter,
+	}
+
+	archive, err := storearchive.New(entries, provenance, validity)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := archive.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	o.logger.Infow("exported endorsements", "entries", len(entries))
+
+	return &proto.StoreArchive{Data: data}, nil
+}
+
+func (o *GRPC) ImportEndorsements(
+	ctx context.Context,
+	req *proto.ImportEndorsementsRequest,
+) (*proto.ImportEndorsementsResponse, error) {
+	archive, err := storearchive.Parse(req.GetArchive().GetData())
+	if err != nil {
+		return importEndorsementsErrorResponse(err), nil
+	}
+
+	o.logger.Infow("import endorsements", "entries", len(archive.Entries),
+		"exporter", archive.Provenance.Exporter,
+		"exporter-version", archive.Provenance.ExporterVersion,
+		"source", archive.Provenance.Source,
+		"created", archive.Provenance.Created)
+
+	if err := archive.CheckValidity(time.Now()); err != nil {
+		if !req.IgnoreValidity {
+			return importEndorsementsErrorResponse(err), nil
+		}
+
+		o.logger.Warnw("importing invalid archive", "error", err)
+	}
+
+	rsp := &proto.ImportEndorsementsResponse{}
+
+	var failures []string
+
+	for i := range archive.Entries {
+		added, err := o.importEntry(&archive.Entries[i])
+
+		switch {
+		case err != nil:
+			rsp.Failed++
+			failures = append(failures, fmt.Sprintf("entry %d: %v", i, err))
+		case added:
+			rsp.Imported++
+		default:
+			rsp.Duplicates++
+		}
+	}
+
+	o.logger.Infow("imported endorsements", "imported", rsp.Imported,
+		"duplicates", rsp.Duplicates, "failed", rsp.Failed)
+
+	if len(failures) != 0 {
+		rsp.Status = &proto.Status{
+			Result:      false,
+			ErrorDetail: strings.Join(failures, "; "),
+		}
+	} else {
+		rsp.Status = &proto.Status{Result: true}
+	}
+
+	return rsp, nil
 }
 
-func submitEndorsementSuccessResponse() *proto.SubmitEndorsementsResponse {
+// importEntry stores the endorsement of an archive entry under the keys
+// synthesized for it by the evidence handler of its scheme, skipping the keys
+// under which it is already stored. It returns true if the endorsement was
+// added under any key.
+func (o *GRPC) importEntry(entry *storearchive.Entry) (bool, error) {
+	var (
+		store     kvstore.IKVStore
+		synthKeys synthKeysFn
+	)
+
+	switch entry.Store {
+	case storearchive.TrustAnchorStore:
+		store, synthKeys = o.TaStore, o.synthTrustAnchorKeys
+	case storearchive.EndorsementStore:
+		store, synthKeys = o.EnStore, o.synthRefValueKeys
+	default:
+		return false, fmt.Errorf("unknown store %q", entry.Store)
+	}
+
+	tenantID := entry.Tenant
+	if tenantID == "" {
+		tenantID = DummyTenantID
+	}
+
+	keys, err := synthKeys(tenantID, &entry.Endorsement)
+	if err != nil {
+		return false, err
+	}
+
+	val, err := json.Marshal(entry.Endorsement)
+	if err != nil {
+		return false, err
+	}
+
+	added := false
+
+	for _, key := range keys {
+		vals, err := store.Get(key)
+		if err != nil && !errors.Is(err, kvstore.ErrKeyNotFound) {
+			return added, err
+		}
+
+		if containsString(vals, string(val)) {
+			continue
+		}
+
+		if err := store.Add(key, string(val)); err != nil {
+			return added, err
+		}
+		added = true
+	}
+
+	return added, nil
+}
+
+func containsString(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
+
+func importEndorsementsErrorResponse(err error) *proto.ImportEndorsementsResponse {
+	return &proto.ImportEndorsementsResponse{
+		Status: &proto.Status{
+			Result:      false,
+			ErrorDetail: fmt.Sprintf("%v", err),
+		},
+	}
+}
+
+func submitEndorsementSuccessResponse(outcomes []*proto.EndorsementOutcome) *proto.SubmitEndorsementsResponse {
 	return &proto.SubmitEndorsementsResponse{
 		Status: &proto.Status{
 			Result: true,
 		},
+		Outcomes: outcomes,
 	}
 }
 
-func submitEndorsementErrorResponse(err error) *proto.SubmitEndorsementsResponse {
+func submitEndorsementErrorResponse(err error, outcomes []*proto.EndorsementOutcome) *proto.SubmitEndorsementsResponse {
 	return &proto.SubmitEndorsementsResponse{
 		Status: &proto.Status{
 			Result:      false,
 			ErrorDetail: fmt.Sprintf("%v", err),
 		},
+		Outcomes: outcomes,
 	}
 }
 
-func (o *GRPC) addRefValues(ctx context.Context, refVal *handler.Endorsement) error {
+func (o *GRPC) addRefValues(ctx context.Context, refVal *handler.Endorsement) ([]string, error) {
 	var (
-		err     error
-		keys    []string
-		handler handler.IEvidenceHandler
-		val     []byte
+		err  error
+		keys []string
+		val  []byte
 	)
 
-	handler, err = o.EvPluginManager.LookupByAttestationScheme(refVal.Scheme)
-	if err != nil {
-		return err
-	}
-
-	keys, err = handler.SynthKeysFromRefValue(DummyTenantID, refVal)
+	keys, err = o.synthRefValueKeys(DummyTenantID, refVal)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	val, err = json.Marshal(refVal)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for _, key := range keys {
 		if err := o.EnStore.Add(key, string(val)); err != nil {
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
 
 	o.logger.Infow("added reference values", "keys", keys)
 
-	return nil
+	return keys, nil
 }
 
 func (o *GRPC) addTrustAnchor(
 	ctx context.Context,
 	req *handler.Endorsement,
-) error {
+) ([]string, error) {
 	var (
-		err     error
-		keys    []string
-		handler handler.IEvidenceHandler
-		val     []byte
+		err  error
+		keys []string
+		val  []byte
 	)
 
 	o.logger.Debugw("AddTrustAnchor", "trust-anchor", req)
 
 	if req == nil {
-		return errors.New("nil trust anchor in request")
+		return nil, errors.New("nil trust anchor in request")
 	}
 
-	handler, err = o.EvPluginManager.LookupByAttestationScheme(req.Scheme)
+	keys, err = o.synthTrustAnchorKeys(DummyTenantID, req)
 	if err != nil {
-		return err
-	}
-
-	keys, err = handler.SynthKeysFromTrustAnchor(DummyTenantID, req)
-	if err != nil {
-		return err
+		return nil, err
 	}
 
 	val, err = json.Marshal(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for _, key := range keys {
 		if err := o.TaStore.Add(key, string(val)); err != nil {
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
 
 	o.logger.Infow("added trust anchor", "keys", keys)
 
-	return nil
+	return keys, nil
 }
 
 func (o *GRPC) GetAttestation(
@@ -320,19 +726,170 @@ func (o *GRPC) GetAttestation(
 	token *proto.AttestationToken,
 ) (*proto.AppraisalContext, error) {
 	o.logger.Infow("get attestation", "media-type", token.MediaType,
-		"tenant-id", token.TenantId)
+		"tenant-id", token.TenantId, "result-media-type", token.ResultMediaType)
+
+	// the result format is checked upfront, as there is no way of
+	// reporting the problem in the (signed) result otherwise
+	if _, err := o.getEarSigner(token.ResultMediaType); err != nil {
+		return nil, err
+	}
+
+	appraisal, err := o.appraise(ctx, token)
+
+	return o.finalize(appraisal, err)
+}
+
+func (o *GRPC) GetCollectionAttestation(
+	ctx context.Context,
+	collection *proto.AttestationTokenCollection,
+) (*proto.AppraisalContext, error) {
+	o.logger.Infow("get collection attestation", "members", len(collection.Members),
+		"tenant-id", collection.TenantId, "result-media-type", collection.ResultMediaType)
+
+	if _, err := o.getEarSigner(collection.ResultMediaType); err != nil {
+		return nil, err
+	}
+
+	if len(collection.Members) == 0 {
+		return nil, errors.New("empty evidence collection")
+	}
+
+	members := make([]appraisal.Member, 0, len(collection.Members))
+	labels := make(map[string]bool, len(collection.Members))
+
+	for _, member := range collection.Members {
+		if labels[member.Label] {
+			return nil, fmt.Errorf("duplicate evidence collection label: %q", member.Label)
+		}
+		labels[member.Label] = true
+
+		token := &proto.AttestationToken{
+			TenantId:        collection.TenantId,
+			Data:            member.Data,
+			MediaType:       member.MediaType,
+			Nonce:           collection.Nonce,
+			ResultMediaType: collection.ResultMediaType,
+		}
+
+		o.logger.Debugw("appraising collection member", "label", member.Label,
+			"media-type", member.MediaType)
+
+		memberAppraisal, err := o.appraise(ctx, token)
+		members = append(members, appraisal.Member{
+			Label:     member.Label,
+			Data:      member.Data,
+			Appraisal: memberAppraisal,
+		})
+
+		if errors.Is(err, handlermod.BadEvidenceError{}) {
+			// reported in the member's submod
+			o.logger.Warn(err)
+		} else if err != nil {
+			// anything else fails the whole collection
+			return o.finalize(o.newCollectionAppraisal(collection, members), err)
+		}
+	}
+
+	combined := o.newCollectionAppraisal(collection, members)
+
+	if o.CheckCollectionBinding {
+		if err := appraisal.CheckBinding(members); err != nil {
+			o.logger.Warn(err)
+			combined.SetAllClaims(ear.UnexpectedEvidenceClaim)
+			combined.AddPolicyClaim("problem", "collection members are not bound together")
+		}
+	}
+
+	o.logger.Infow("evaluated collection attestation result", "attestation-result", combined.Result)
 
+	return o.finalize(combined, nil)
+}
+
+func (o *GRPC) newCollectionAppraisal(
+	collection *proto.AttestationTokenCollection,
+	members []appraisal.Member,
+) *appraisal.Appraisal {
+	combined := appraisal.NewCollection(collection.TenantId, collection.Nonce, members)
+	combined.ResultMediaType = collection.ResultMediaType
+
+	return combined
+}
+
+// appraise runs the evidence in the token through the pipeline of the
+// evidence handler associated with its media type, and the applicable policy.
+// The returned appraisal is never nil, so that problems can be reported in the
+// attestation result.
+func (o *GRPC) appraise(
+	ctx context.Context,
+	token *proto.AttestationToken,
+) (*appraisal.Appraisal, error) {
 	handler, err := o.EvPluginManager.LookupByMediaType(token.MediaType)
 	if err != nil {
 		appraisal := appraisal.New(token.TenantId, token.Nonce, "ERROR")
+		appraisal.ResultMediaType = token.ResultMediaType
 		appraisal.SetAllClaims(ear.UnexpectedEvidenceClaim)
 		appraisal.AddPolicyClaim("problem", "could not resolve media type")
-		return o.finalize(appraisal, err)
+		return appraisal, err
 	}
 
+	// policies may look up further endorsements and trust anchors of the
+	// tenant from the stores
+	ctx = o.withStoreLookups(ctx, token.TenantId)
+
+	appraisal, endorsements, err := o.appraiseEvidence(ctx, handler, token)
+	if err != nil && o.EvaluatePolicyOnBadEvidence && errors.Is(err, handlermod.BadEvidenceError{}) {
+		// only bad evidence failures are reported in the attestation
+		// result (see finalize), the others are returned as errors. The
+		// policy sees the failure, but cannot recover from it: the
+		// appraisal still fails with the original problem
+		polErr := o.PolicyManager.EvaluateFailure(ctx, handler.GetAttestationScheme(),
+			appraisal, endorsements, err)
+		if polErr != nil {
+			o.logger.Errorw("could not evaluate policy for failed appraisal",
+				"error", polErr)
+		}
+	}
+
+	return appraisal, err
+}
+
+// withStoreLookups returns a copy of the context with lookups of the
+// endorsement and trust anchor stores for the policy built-ins. The lookups
+// are confined to the keys of the specified tenant.
+func (o *GRPC) withStoreLookups(ctx context.Context, tenantID string) context.Context {
+	ctx = policy.WithEndorsementLookup(ctx, storeLookup(o.EnStore, tenantID))
+	return policy.WithTrustAnchorLookup(ctx, storeLookup(o.TaStore, tenantID))
+}
+
+func storeLookup(store kvstore.IKVStore, tenantID string) policy.Lookup {
+	return func(key string) ([]string, error) {
+		if kvstore.KeyTenant(key) != tenantID {
+			return nil, fmt.Errorf("key %q does not belong to tenant %q", key, tenantID)
+		}
+
+		values, err := store.Get(key)
+		if err != nil {
+			if errors.Is(err, kvstore.ErrKeyNotFound) {
+				return []string{}, nil
+			}
+			return nil, err
+		}
+
+		return values, nil
+	}
+}
+
+// appraiseEvidence is the pipeline of appraise once the evidence handler has
+// been resolved. Alongside the appraisal, it returns the endorsements obtained
+// for the evidence (so far, if the appraisal failed).
+func (o *GRPC) appraiseEvidence(
+	ctx context.Context,
+	handler handler.IEvidenceHandler,
+	token *proto.AttestationToken,
+) (*appraisal.Appraisal, []string, error) {
 	appraisal, err := o.initEvidenceContext(handler, token)
 	if err != nil {
-		return o.finalize(appraisal, err)
+		return appraisal, nil, err
 	}
 
 	tas, err := o.getTrustAnchors(appraisal.EvidenceContext.TrustAnchorIds)
@@ -343,7 +900,11 @@ func (o *GRPC) GetAttestation(
 			appraisal.SetAllClaims(ear.CryptoValidationFailedClaim)
 			appraisal.AddPolicyClaim("problem", "no trust anchor for evidence")
 		}
-		return o.finalize(appraisal, err)
+		return appraisal, nil, err
+	}
+
+	if err = o.loadHistory(appraisal); err != nil {
+		return appraisal, nil, err
 	}
 
 	extracted, err := handler.ExtractClaims(token, tas)
@@ -351,13 +912,13 @@ func (o *GRPC) GetAttestation(
 		if errors.Is(err, handlermod.BadEvidenceError{}) {
 			appraisal.AddPolicyClaim("problem", err.Error())
 		}
-		return o.finalize(appraisal, err)
+		return appraisal, nil, err
 	}
 
 	appraisal.EvidenceContext.Evidence, err = structpb.NewStruct(extracted.ClaimsSet)
 	if err != nil {
 		err = fmt.Errorf("unserializable claims in result: %w", err)
-		return o.finalize(appraisal, err)
+		return appraisal, nil, err
 	}
 
 	appraisal.EvidenceContext.ReferenceIds = extracted.ReferenceIDs
@@ -371,37 +932,102 @@ func (o *GRPC) GetAttestation(
 
 		endorsements, err := o.EnStore.Get(refvalID)
 		if err != nil && !errors.Is(err, kvstore.ErrKeyNotFound) {
-			return o.finalize(appraisal, err)
+			return appraisal, multEndorsements, err
 		}
 
 		o.logger.Debugw("obtained endorsements", "endorsements", endorsements)
 		multEndorsements = append(multEndorsements, endorsements...)
 	}
 
-	if err = handler.ValidateEvidenceIntegrity(token, tas, multEndorsements); err != nil {
+	// endorsed values are not for the evidence handler to appraise the
+	// evidence against: those whose condition matches the evidence are
+	// reported as annotated evidence in the result, and made available to the
+	// policy alongside the reference values
+	refValues, endorsedValues := handlermod.SplitEndorsedValues(multEndorsements)
+
+	endorsedValues, err = appraisal.SelectEndorsedValues(endorsedValues)
+	if err != nil {
+		return appraisal, multEndorsements, err
+	}
+	multEndorsements = append(refValues, endorsedValues...)
+
+	if err = handler.ValidateEvidenceIntegrity(token, tas, refValues); err != nil {
 		if errors.Is(err, handlermod.BadEvidenceError{}) {
 			appraisal.SetAllClaims(ear.CryptoValidationFailedClaim)
 			appraisal.AddPolicyClaim("problem", "integrity validation failed")
 		}
-		return o.finalize(appraisal, err)
+		return appraisal, multEndorsements, err
 	}
 
-	appraisedResult, err := handler.AppraiseEvidence(appraisal.EvidenceContext, multEndorsements)
+	appraisedResult, err := handler.AppraiseEvidence(appraisal.EvidenceContext, refValues)
 	if err != nil {
-		return o.finalize(appraisal, err)
+		return appraisal, multEndorsements, err
 	}
 	appraisedResult.Nonce = appraisal.Result.Nonce
 	appraisal.Result = appraisedResult
 	appraisal.InitPolicyID()
 
+	if err = appraisal.AddEndorsedValues(endorsedValues); err != nil {
+		return appraisal, multEndorsements, err
+	}
+
 	err = o.PolicyManager.Evaluate(ctx, handler.GetAttestationScheme(), appraisal, multEndorsements)
 	if err != nil {
-		return o.finalize(appraisal, err)
+		return appraisal, multEndorsements, err
+	}
+
+	if err = o.recordHistory(appraisal); err != nil {
+		return appraisal, multEndorsements, err
 	}
 
 	o.logger.Infow("evaluated attestation result", "attestation-result", appraisal.Result)
 
-	return o.finalize(appraisal, nil)
+	return appraisal, multEndorsements, nil
+}
+
+// loadHistory makes the attestation history of the device available to the
+// policies applied to the appraisal.
+func (o *GRPC) loadHistory(appraisal *appraisal.Appraisal) error {
+	if o.HistoryStore == nil {
+		return nil
+	}
+
+	entries, err := o.HistoryStore.Get(appraisal.EvidenceContext.TrustAnchorIds)
+	if err != nil {
+		return fmt.Errorf("could not get attestation history: %w", err)
+	}
+
+	appraisal.History = make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		appraisal.History = append(appraisal.History, entry.AsMap())
+	}
+
+	return nil
+}
+
+// recordHistory adds the (successful) appraisal to the attestation history of
+// the device.
+func (o *GRPC) recordHistory(appraisal *appraisal.Appraisal) error {
+	if o.HistoryStore == nil {
+		return nil
+	}
+
+	// the status is settled here (rather than when the appraisal is
+	// finalized), so that the recorded result is the one that is reported
+	appraisal.Result.UpdateStatusFromTrustVector()
+
+	entry := history.Entry{
+		Time:     time.Now(),
+		Evidence: appraisal.EvidenceContext.Evidence.AsMap(),
+		Result:   appraisal.Result.AsMap(),
+	}
+
+	err := o.HistoryStore.Record(appraisal.EvidenceContext.TrustAnchorIds, entry)
+	if err != nil {
+		return fmt.Errorf("could not record attestation history: %w", err)
+	}
+
+	return nil
 }
 
 func (c *GRPC) initEvidenceContext(
@@ -411,6 +1037,8 @@ func (c *GRPC) initEvidenceContext(
 	var err error
 
 	appraisal := appraisal.New(token.TenantId, token.Nonce, handler.GetAttestationScheme())
+	appraisal.MediaType = token.MediaType
+	appraisal.ResultMediaType = token.ResultMediaType
 	appraisal.EvidenceContext.TrustAnchorIds, err = handler.GetTrustAnchorIDs(token)
 
 	if errors.Is(err, handlermod.BadEvidenceError{}) {
@@ -447,11 +1075,41 @@ func (c *GRPC) GetSupportedVerificationMediaTypes(context.Context, *emptypb.Empt
 
 func (c *GRPC) GetSupportedProvisioningMediaTypes(context.Context, *emptypb.Empty) (*proto.MediaTypeList, error) {
 	mts := c.EndPluginManager.GetRegisteredMediaTypes()
+
+	// unsigned CoRIMs may also be submitted without a profile parameter, if
+	// there is a handler for any profile (see lookupEndorsementHandler)
+	var hasProfiled, hasUnprofiled bool
+	for _, mt := range mts {
+		if isUnprofiledCorimMediaType(mt) {
+			hasUnprofiled = true
+		} else if strings.HasPrefix(mt, common.UnsignedCorimMediaType+";") {
+			hasProfiled = true
+		}
+	}
+
+	if hasProfiled && !hasUnprofiled {
+		mts = append(mts, common.UnsignedCorimMediaType)
+	}
+
+	return &proto.MediaTypeList{MediaTypes: mts}, nil
+}
+
+func (o *GRPC) GetSupportedResultMediaTypes(context.Context, *emptypb.Empty) (*proto.MediaTypeList, error) {
+	mts := make([]string, 0, len(o.EarSigners))
+	for _, signer := range o.EarSigners {
+		mts = append(mts, signer.GetMediaType())
+	}
 	return &proto.MediaTypeList{MediaTypes: mts}, nil
 }
 
 func (o *GRPC) GetEARSigningPublicKey(context.Context, *emptypb.Empty) (*proto.PublicKey, error) {
-	alg, key, err := o.EarSigner.GetEARSigningPublicKey()
+	// all EAR signers share the same key
+	signer, err := o.getEarSigner("")
+	if err != nil {
+		return nil, err
+	}
+
+	alg, key, err := signer.GetEARSigningPublicKey()
 	if err != nil {
 		return nil, err
 	}
@@ -503,7 +1161,11 @@ func (o *GRPC) finalize(
 
 	appraisal.Result.UpdateStatusFromTrustVector()
 
-	appraisal.SignedEAR, signErr = o.EarSigner.Sign(*appraisal.Result)
+	signer, signErr := o.getEarSigner(appraisal.ResultMediaType)
+	if signErr == nil {
+		appraisal.SignedEAR, signErr = signer.Sign(*appraisal.Result)
+		appraisal.ResultMediaType = signer.GetMediaType()
+	}
 	if signErr != nil {
 		// Signing error overrides whatever the problem that got us
 		// here was, as it indicates a serious issue with the service.
@@ -517,3 +1179,23 @@ func (o *GRPC) finalize(
 
 	return appraisal.GetContext(), err
 }
+
+// getEarSigner returns the EAR signer producing the specified media type, or
+// the default signer if mediaType is empty.
+func (o *GRPC) getEarSigner(mediaType string) (earsigner.IEarSigner, error) {
+	if len(o.EarSigners) == 0 {
+		return nil, errors.New("no EAR signer configured")
+	}
+
+	if mediaType == "" {
+		return o.EarSigners[0], nil
+	}
+
+	for _, signer := range o.EarSigners {
+		if signer.GetMediaType() == mediaType {
+			return signer, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported attestation result media type: %q", mediaType)
+}
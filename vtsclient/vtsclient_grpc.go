@@ -98,6 +98,21 @@ func (o *GRPC) GetAttestation(
 	return c.GetAttestation(ctx, in, opts...)
 }
 
+func (o *GRPC) GetCollectionAttestation(
+	ctx context.Context, in *proto.AttestationTokenCollection, opts ...grpc.CallOption,
+) (*proto.AppraisalContext, error) {
+	if err := o.EnsureConnection(); err != nil {
+		return nil, NewNoConnectionError("GetCollectionAttestation", err)
+	}
+
+	c := o.GetProvisionerClient()
+	if c == nil {
+		return nil, ErrNoClient
+	}
+
+	return c.GetCollectionAttestation(ctx, in, opts...)
+}
+
 func (o *GRPC) GetSupportedVerificationMediaTypes(
 	ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption,
 ) (*proto.MediaTypeList, error) {
@@ -141,6 +156,45 @@ func (o *GRPC) SubmitEndorsements(
 	return c.SubmitEndorsements(ctx, in, opts...)
 }
 
+func (o *GRPC) DryRunEndorsements(
+	ctx context.Context, in *proto.SubmitEndorsementsRequest, opts ...grpc.CallOption,
+) (*proto.SubmitEndorsementsResponse, error) {
+	if err := o.EnsureConnection(); err != nil {
+		return nil, NewNoConnectionError("DryRunEndorsements", err)
+	}
+	c := o.GetProvisionerClient()
+	if c == nil {
+		return nil, ErrNoClient
+	}
+	return c.DryRunEndorsements(ctx, in, opts...)
+}
+
+func (o *GRPC) ExportEndorsements(
+	ctx context.Context, in *proto.ExportEndorsementsRequest, opts ...grpc.CallOption,
+) (*proto.StoreArchive, error) {
+	if err := o.EnsureConnection(); err != nil {
+		return nil, NewNoConnectionError("ExportEndorsements", err)
+	}
+	c := o.GetProvisionerClient()
+	if c == nil {
+		return nil, ErrNoClient
+	}
+	return c.ExportEndorsements(ctx, in, opts...)
+}
+
+func (o *GRPC) ImportEndorsements(
+	ctx context.Context, in *proto.ImportEndorsementsRequest, opts ...grpc.CallOption,
+) (*proto.ImportEndorsementsResponse, error) {
+	if err := o.EnsureConnection(); err != nil {
+		return nil, NewNoConnectionError("ImportEndorsements", err)
+	}
+	c := o.GetProvisionerClient()
+	if c == nil {
+		return nil, ErrNoClient
+	}
+	return c.ImportEndorsements(ctx, in, opts...)
+}
+
 func (o *GRPC) GetProvisionerClient() proto.VTSClient {
 	if o.Connection == nil {
 		return nil
@@ -184,3 +238,18 @@ func (o *GRPC) GetEARSigningPublicKey(ctx context.Context, in *emptypb.Empty, op
 
 	return c.GetEARSigningPublicKey(ctx, in, opts...)
 }
+
+func (o *GRPC) GetSupportedResultMediaTypes(
+	ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption,
+) (*proto.MediaTypeList, error) {
+	if err := o.EnsureConnection(); err != nil {
+		return nil, NewNoConnectionError("GetSupportedResultMediaTypes", err)
+	}
+
+	c := o.GetProvisionerClient()
+	if c == nil {
+		return nil, ErrNoClient
+	}
+
+	return c.GetSupportedResultMediaTypes(ctx, in, opts...)
+}